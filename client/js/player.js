@@ -68,13 +68,29 @@ async function loadReplay() {
         }
 
         const replay = await response.json();
-        displayReplay(replay);
+        const videoUrl = await getVideoUrl(replay.id);
+        displayReplay(replay, videoUrl);
     } catch (error) {
         console.error('Error loading replay:', error);
         showError('Ошибка загрузки реплея: ' + error.message);
     }
 }
 
+// Подписанная ссылка на файл: <video> не умеет передавать заголовок Authorization
+async function getVideoUrl(id) {
+    const response = await fetch(`${API_BASE}/replays/${id}/file-url`, {
+        method: 'POST',
+        headers: getAuthHeaders()
+    });
+
+    if (!response.ok) {
+        throw new Error('Не удалось получить ссылку на видео');
+    }
+
+    const data = await response.json();
+    return new URL(data.url, API_BASE).href;
+}
+
 function getVideoType(filename) {
     const ext = filename.toLowerCase().split('.').pop();
     const types = {
@@ -90,15 +106,13 @@ function getVideoType(filename) {
     return types[ext] || 'video/mp4';
 }
 
-function displayReplay(replay) {
+function displayReplay(replay, videoUrl) {
     const headerInfo = document.getElementById('headerInfo');
     headerInfo.innerHTML = `
         <div class="replay-title-header">${replay.title || replay.original_name}</div>
         <div class="replay-meta">${replay.game_name || 'Неизвестная игра'}</div>
     `;
 
-    const token = TokenManager.getToken();
-    const videoUrl = `${API_BASE}/replays/${replay.id}/file?token=${encodeURIComponent(token)}`;
     const videoType = getVideoType(replay.original_name);
     const playerContent = document.getElementById('playerContent');
     
@@ -137,10 +151,28 @@ function displayReplay(replay) {
 
     // Обработка ошибок загрузки видео
     const video = document.getElementById('videoPlayer');
-    video.addEventListener('error', function(e) {
+    const source = video.querySelector('source');
+    let refreshed = false;
+    async function onVideoError(e) {
+        // Ссылка живет несколько минут: при перемотке после ее истечения берем новую один раз
+        if (!refreshed && video.currentTime > 0) {
+            refreshed = true;
+            const position = video.currentTime;
+            try {
+                source.src = await getVideoUrl(replay.id);
+                video.load();
+                video.currentTime = position;
+                video.play();
+                return;
+            } catch (err) {
+                console.error('Video URL refresh error:', err);
+            }
+        }
         console.error('Video error:', e);
         showError('Не удалось загрузить видео. Возможно, формат не поддерживается браузером.');
-    });
+    }
+    video.addEventListener('error', onVideoError);
+    source.addEventListener('error', onVideoError);
     
     video.addEventListener('loadedmetadata', function() {
         console.log('Video loaded successfully');
@@ -218,20 +218,42 @@ function playVideo(replayId) {
     window.location.href = `/html/player.html?id=${replayId}`;
 }
 
-async function loadGames() {
-    try {
-        const response = await fetch(`${API_BASE}/games`, {
+// Listings are paged: follow next_cursor until the last page
+async function fetchAllPages(url, itemsKey) {
+    const items = [];
+    let cursor = '';
+    do {
+        const pageUrl = cursor ? `${url}&cursor=${encodeURIComponent(cursor)}` : url;
+        const response = await fetch(pageUrl, {
             headers: getAuthHeaders()
         });
-        
-        if (response.status === 401) {
-            // Token invalid or expired
-            TokenManager.removeToken();
-            window.location.href = '/html/login.html';
-            return;
+        if (!response.ok) {
+            const error = new Error(`HTTP ${response.status}`);
+            error.status = response.status;
+            throw error;
+        }
+
+        const page = await response.json();
+        items.push(...(page[itemsKey] || []));
+        cursor = page.next_cursor;
+    } while (cursor);
+    return items;
+}
+
+async function loadGames() {
+    try {
+        let games;
+        try {
+            games = await fetchAllPages(`${API_BASE}/games?limit=100`, 'games');
+        } catch (error) {
+            if (error.status === 401) {
+                // Token invalid or expired
+                TokenManager.removeToken();
+                window.location.href = '/html/login.html';
+                return;
+            }
+            throw error;
         }
-        
-        const games = await response.json();
         
         const gameList = document.getElementById('gameList');
         
@@ -294,10 +316,7 @@ async function selectGame(gameId, gameName) {
     contentArea.innerHTML = '<div class="loading">Загрузка реплеев...</div>';
 
     try {
-        const response = await fetch(`${API_BASE}/games/${gameId}/replays?limit=100`, {
-            headers: getAuthHeaders()
-        });
-        const replays = await response.json() || [];
+        const replays = await fetchAllPages(`${API_BASE}/games/${gameId}/replays?limit=100`, 'replays');
 
         contentArea.innerHTML = `
             <div style="display: flex; justify-content: space-between; align-items: center; margin-bottom: 20px;">
@@ -343,8 +362,6 @@ async function selectGame(gameId, gameName) {
                     '<div class="empty-state"><div class="empty-state-icon">📄</div><p>Нет реплеев</p></div>' :
                     replays.map(replay => {
                         const isVideo = isVideoFile(replay.original_name);
-                        const token = TokenManager.getToken();
-                        const videoUrl = `${API_BASE}/replays/${replay.id}/file?token=${encodeURIComponent(token)}`;
                         
                         return `
                             <div class="replay-card">
@@ -368,7 +385,7 @@ async function selectGame(gameId, gameName) {
                                         <div class="video-placeholder">
                                             <div class="video-placeholder-icon">🎬</div>
                                         </div>
-                                        <video src="${videoUrl}" preload="metadata" id="video-${replay.id}" crossorigin="anonymous" style="position: absolute; top: 0; left: 0; width: 100%; height: 100%; object-fit: cover;"></video>
+                                        <video preload="metadata" id="video-${replay.id}" crossorigin="anonymous" style="position: absolute; top: 0; left: 0; width: 100%; height: 100%; object-fit: cover;"></video>
                                         <div class="video-badge">VIDEO</div>
                                         <div class="play-overlay">
                                             <div class="play-button">
@@ -408,6 +425,10 @@ async function selectGame(gameId, gameName) {
                         durationEl.textContent = 'VIDEO';
                     });
                     
+                    getReplayFileUrl(replay.id)
+                        .then(url => { video.src = url; })
+                        .catch(() => { durationEl.textContent = 'VIDEO'; });
+                    
                     // Fallback if metadata doesn't load within 3 seconds
                     setTimeout(() => {
                         if (durationEl.textContent === '--:--') {
@@ -518,9 +539,29 @@ async function uploadReplay() {
     }
 }
 
-function downloadReplay(replayId) {
-    const token = TokenManager.getToken();
-    window.open(`${API_BASE}/replays/${replayId}/file?download=true&token=${encodeURIComponent(token)}`, '_blank');
+// Подписанная ссылка на файл реплея для <video> и скачивания, где нельзя передать Authorization
+async function getReplayFileUrl(replayId) {
+    const response = await fetch(`${API_BASE}/replays/${replayId}/file-url`, {
+        method: 'POST',
+        headers: getAuthHeaders()
+    });
+    if (!response.ok) {
+        throw new Error('Не удалось получить ссылку на файл');
+    }
+    const data = await response.json();
+    return new URL(data.url, API_BASE).href;
+}
+
+async function downloadReplay(replayId) {
+    // Окно открывается сразу, иначе браузер заблокирует его после await
+    const win = window.open('', '_blank');
+    try {
+        const url = await getReplayFileUrl(replayId);
+        win.location.href = `${url}&download=true`;
+    } catch (error) {
+        win.close();
+        showToast('Ошибка скачивания: ' + error.message, 'error', 'Ошибка');
+    }
 }
 
 function playReplay(replayId) {
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/google/uuid"
+)
+
+// commandSet - сервисы, доступные служебным командам
+type commandSet struct {
+	integrity *services.IntegrityService
+	reconcile *services.ReconcileService
+	blobs     *services.BlobMigrationService
+	quota     *services.QuotaService
+	keys      *services.KeyRotationService
+	media     *services.MediaBackfillService
+}
+
+// runCommand выполняет служебную команду вместо запуска HTTP сервера
+// Ненулевой код выхода означает ошибку или найденные проблемы
+func runCommand(ctx context.Context, name string, args []string, cmds commandSet) error {
+	switch name {
+	case "scrub":
+		return runScrub(ctx, args, cmds.integrity)
+	case "reconcile":
+		return runReconcile(ctx, args, cmds.reconcile)
+	case "migrate-blobs":
+		return runMigrateBlobs(ctx, args, cmds.blobs)
+	case "quota":
+		return runQuota(ctx, args, cmds.quota)
+	case "rotate-keys":
+		return runRotateKeys(ctx, args, cmds.keys)
+	case "probe-media":
+		return runProbeMedia(ctx, args, cmds.media)
+	default:
+		return fmt.Errorf("unknown command %q (available: scrub, reconcile, migrate-blobs, quota, rotate-keys, probe-media)", name)
+	}
+}
+
+// runScrub перечитывает все файлы реплеев и печатает отчет в JSON
+func runScrub(ctx context.Context, args []string, integrity *services.IntegrityService) error {
+	flags := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := integrity.Scrub(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(report); err != nil {
+		return err
+	}
+
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("scrub found %d damaged or missing files", len(report.Issues))
+	}
+	return nil
+}
+
+// runReconcile сверяет хранилище с БД; без -dry-run переносит расхождения в карантин
+func runReconcile(ctx context.Context, args []string, reconcile *services.ReconcileService) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report orphan files and dangling rows")
+	grace := flags.Duration("grace", services.DefaultReconcileGrace, "ignore files younger than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := reconcile.Reconcile(ctx, services.ReconcileOptions{DryRun: *dryRun, Grace: *grace})
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(report); err != nil {
+		return err
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("failed to quarantine %d items", len(report.Errors))
+	}
+	if *dryRun && report.Found() {
+		return fmt.Errorf("found %d orphan files and %d dangling replays", len(report.OrphanFiles), len(report.DanglingReplays))
+	}
+	return nil
+}
+
+// runMigrateBlobs переносит файлы старых реплеев в content-addressed раскладку blobs/
+func runMigrateBlobs(ctx context.Context, args []string, blobs *services.BlobMigrationService) error {
+	flags := flag.NewFlagSet("migrate-blobs", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := blobs.MigrateLegacyFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(report); err != nil {
+		return err
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("failed to migrate %d replay files", len(report.Errors))
+	}
+	return nil
+}
+
+// runRotateKeys переоборачивает ключи данных активным (последним) мастер-ключом, не трогая файлы
+func runRotateKeys(ctx context.Context, args []string, keys *services.KeyRotationService) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := keys.RotateKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(report); err != nil {
+		return err
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("failed to rewrap %d data keys", len(report.Errors))
+	}
+	return nil
+}
+
+// runProbeMedia извлекает параметры видео у реплеев, загруженных без них
+func runProbeMedia(ctx context.Context, args []string, mediaBackfill *services.MediaBackfillService) error {
+	flags := flag.NewFlagSet("probe-media", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := mediaBackfill.BackfillMedia(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := printReport(report); err != nil {
+		return err
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("failed to probe %d video files", len(report.Errors))
+	}
+	return nil
+}
+
+// runQuota печатает использование и квоту пользователя; с -max-bytes / -max-replays задает переопределение
+// Значение -1 (или -reset) возвращает значение по умолчанию из конфига, 0 снимает ограничение
+func runQuota(ctx context.Context, args []string, quota *services.QuotaService) error {
+	flags := flag.NewFlagSet("quota", flag.ContinueOnError)
+	user := flags.String("user", "", "user id")
+	maxBytes := flags.Int64("max-bytes", -1, "storage quota in bytes (0 - unlimited, -1 - default)")
+	maxReplays := flags.Int64("max-replays", -1, "replay count quota (0 - unlimited, -1 - default)")
+	reset := flags.Bool("reset", false, "remove the override")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(*user)
+	if err != nil {
+		return fmt.Errorf("invalid -user %q", *user)
+	}
+
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name != "user"
+	})
+	if set {
+		override := &models.QuotaOverride{}
+		if !*reset {
+			if *maxBytes >= 0 {
+				override.MaxBytes = maxBytes
+			}
+			if *maxReplays >= 0 {
+				override.MaxReplays = maxReplays
+			}
+		}
+		if err := quota.SetOverride(ctx, userID, override); err != nil {
+			return err
+		}
+	}
+
+	usage, err := quota.GetUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return printReport(usage)
+}
+
+func printReport(report any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
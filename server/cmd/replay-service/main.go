@@ -5,15 +5,24 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/fckoffmw/replay-service/server/config"
+	"github.com/fckoffmw/replay-service/server/internal/compression"
 	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
 	"github.com/fckoffmw/replay-service/server/internal/handlers"
 	"github.com/fckoffmw/replay-service/server/internal/logger"
 	"github.com/fckoffmw/replay-service/server/internal/middleware"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/replayparser"
 	"github.com/fckoffmw/replay-service/server/internal/repository"
 	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/fckoffmw/replay-service/server/internal/signedurl"
 	"github.com/fckoffmw/replay-service/server/internal/storage"
 	"github.com/gin-gonic/gin"
 )
@@ -22,6 +31,16 @@ const (
 	API_V1_PATH         = "/api/v1"
 	API_V1_GAMES_PATH   = API_V1_PATH + "/games"
 	API_V1_REPLAYS_PATH = API_V1_PATH + "/replays"
+	API_V1_USAGE_PATH   = API_V1_PATH + "/usage"
+	API_V1_TAGS_PATH    = API_V1_PATH + "/tags"
+	API_V1_SEARCH_PATH  = API_V1_PATH + "/search"
+	API_V1_SHARES_PATH  = API_V1_PATH + "/shares"
+	API_V1_GRANTS_PATH  = API_V1_PATH + "/grants"
+	API_V1_INVITES_PATH = API_V1_PATH + "/invitations"
+	API_V1_SHARED_PATH  = API_V1_PATH + "/shared"
+	SHARE_PATH          = "/s"
+
+	uploadCleanupInterval = 15 * time.Minute
 )
 
 func main() {
@@ -47,22 +66,141 @@ func main() {
 	gameRepo := repository.NewGameRepository(db)
 	replayRepo := repository.NewReplayRepository(db)
 	userRepo := repository.NewUserRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	quotaRepo := repository.NewQuotaRepository(db)
+	markerRepo := repository.NewMarkerRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	shareRepo := repository.NewShareRepository(db)
+	grantRepo := repository.NewGrantRepository(db)
+
+	fileStorage, err := newBlobStorage(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Using %s storage driver", cfg.StorageDriver))
+	if disk, ok := fileStorage.(*storage.FileStorage); ok {
+		// Обход большого STORAGE_DIR не укладывается в таймаут старта, поэтому идет в фоне без дедлайна
+		go func(started time.Time) {
+			removed, err := disk.RemoveTempFiles(context.Background(), started)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("Failed to remove temp files: %v", err))
+			} else if removed > 0 {
+				logger.Info(fmt.Sprintf("Removed %d temp files left by interrupted writes", removed))
+			}
+		}(time.Now())
+	}
+
+	keyring, err := encryption.LoadKeyring(cfg.EncryptionKey, cfg.EncryptionKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
 
-	fileStorage := storage.NewFileStorage(cfg.StorageDir)
+	uploadPolicy, err := filetype.ParsePolicy(cfg.UploadAllowedTypes)
+	if err != nil {
+		log.Fatalf("Invalid UPLOAD_ALLOWED_TYPES: %v", err)
+	}
 
 	authService := services.NewAuthService(userRepo, cfg.JWTSecret, logger)
 	gameService := services.NewGameService(gameRepo, replayRepo, fileStorage, logger)
-	replayService := services.NewReplayService(replayRepo, fileStorage, logger)
+	quotaService := services.NewQuotaService(quotaRepo, models.Quota{
+		MaxBytes:   cfg.QuotaMaxBytes,
+		MaxReplays: cfg.QuotaMaxReplays,
+	}, logger)
+	uploadPolicyService := services.NewUploadPolicyService(gameRepo, uploadPolicy, logger)
+	markerService := services.NewMarkerService(markerRepo, replayRepo, logger)
+	commentService := services.NewCommentService(commentRepo, replayRepo, logger)
+	tagService := services.NewTagService(tagRepo, logger)
+	searchService := services.NewSearchService(searchRepo, logger)
+	replayOptions := []services.ReplayOption{
+		services.WithCompression(compression.NewPolicy(cfg.Compression, cfg.CompressionSkipExtensions)),
+		services.WithQuota(quotaService),
+		services.WithTypePolicy(uploadPolicyService),
+		services.WithParsers(replayparser.NewRegistry(replayparser.SourceDemo{}, replayparser.BroodWar{})),
+		services.WithMarkers(markerRepo),
+	}
+	if cfg.VerifyDownloads {
+		replayOptions = append(replayOptions, services.WithDownloadVerification())
+	}
+	if cfg.UploadFaststart {
+		replayOptions = append(replayOptions, services.WithFaststart())
+	}
+	var integrityOptions []services.IntegrityOption
+	if keyring != nil {
+		replayOptions = append(replayOptions, services.WithEncryption(keyring))
+		integrityOptions = append(integrityOptions, services.WithScrubEncryption(keyring))
+		logger.Info(fmt.Sprintf("Encryption at rest enabled, active key %s", keyring.ActiveKeyID()))
+	}
+	replayService := services.NewReplayService(replayRepo, fileStorage, logger, replayOptions...)
+	urlSigner := signedurl.NewSigner(cfg.JWTSecret, cfg.SignedURLTTL)
+	shareService := services.NewShareService(shareRepo, replayService, urlSigner, logger)
+	grantService := services.NewGrantService(grantRepo, userRepo, gameRepo, replayRepo, logger)
+	downloadURLService := services.NewDownloadURLService(replayRepo, urlSigner, logger)
+	integrityService := services.NewIntegrityService(replayRepo, fileStorage, logger, integrityOptions...)
+	reconcileService := services.NewReconcileService(replayRepo, uploadRepo, fileStorage, logger)
+	blobMigrationService := services.NewBlobMigrationService(replayRepo, fileStorage, logger)
+	keyRotationService := services.NewKeyRotationService(replayRepo, keyring, logger)
+	mediaBackfillService := services.NewMediaBackfillService(replayRepo, fileStorage, keyring, logger)
+
+	if len(os.Args) > 1 {
+		cmdCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := runCommand(cmdCtx, os.Args[1], os.Args[2:], commandSet{
+			integrity: integrityService,
+			reconcile: reconcileService,
+			blobs:     blobMigrationService,
+			quota:     quotaService,
+			keys:      keyRotationService,
+			media:     mediaBackfillService,
+		})
+		stop()
+		if err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+	uploadService := services.NewUploadService(uploadRepo, replayService, fileStorage, cfg.UploadSessionTTL, logger,
+		services.WithUploadQuota(quotaService))
+
+	go uploadService.RunCleanup(context.Background(), uploadCleanupInterval)
+	if cfg.ScrubInterval > 0 {
+		go integrityService.RunScrub(context.Background(), cfg.ScrubInterval)
+	}
+	if cfg.ReconcileInterval > 0 {
+		go reconcileService.RunReconcile(context.Background(), cfg.ReconcileInterval, services.ReconcileOptions{
+			DryRun: cfg.ReconcileDryRun,
+			Grace:  services.DefaultReconcileGrace,
+		})
+	}
 
 	handler := handlers.NewHandler(gameService, replayService)
 	authHandler := handlers.NewAuthHandler(authService)
+	uploadHandler := handlers.NewUploadHandler(uploadService)
+	quotaHandler := handlers.NewQuotaHandler(quotaService)
+	uploadPolicyHandler := handlers.NewUploadPolicyHandler(uploadPolicyService)
+	clipHandler := handlers.NewClipHandler(replayService)
+	markerHandler := handlers.NewMarkerHandler(markerService)
+	commentHandler := handlers.NewCommentHandler(commentService)
+	tagHandler := handlers.NewTagHandler(tagService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	shareHandler := handlers.NewShareHandler(shareService)
+	grantHandler := handlers.NewGrantHandler(grantService)
+	downloadURLHandler := handlers.NewDownloadURLHandler(downloadURLService)
+
+	var authOptions []middleware.AuthOption
+	if !cfg.AuthQueryToken {
+		authOptions = append(authOptions, middleware.WithoutQueryToken())
+	}
+	requireAuth := middleware.AuthMiddleware(authService, logger, authOptions...)
 
 	r := gin.Default()
+	r.Use(middleware.MaxBodySize(cfg.MaxRequestBodyBytes))
 
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, If-None-Match, If-Modified-Since, If-Range, X-Share-Password, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, ETag, Content-Range, Content-Disposition, Accept-Ranges, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Expires")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -81,27 +219,143 @@ func main() {
 	}
 
 	gamesAPI := r.Group(API_V1_GAMES_PATH)
-	gamesAPI.Use(middleware.AuthMiddleware(authService, logger))
+	gamesAPI.Use(requireAuth)
 	{
 		gamesAPI.GET("", handler.GetGames)
 		gamesAPI.POST("", handler.CreateGame)
 		gamesAPI.PUT("/:game_id", handler.UpdateGame)
 		gamesAPI.DELETE("/:game_id", handler.DeleteGame)
+		gamesAPI.GET("/:game_id/upload-policy", uploadPolicyHandler.GetUploadPolicy)
+		gamesAPI.PUT("/:game_id/upload-policy", uploadPolicyHandler.SetUploadPolicy)
+		gamesAPI.POST("/:game_id/shares", shareHandler.CreateGameShare)
+		gamesAPI.POST("/:game_id/grants", grantHandler.GrantGame)
 
 		gamesAPI.GET("/:game_id/replays", handler.GetReplays)
 		gamesAPI.POST("/:game_id/replays", handler.CreateReplay)
+
+		gamesAPI.POST("/:game_id/uploads", uploadHandler.CreateUpload)
+		gamesAPI.HEAD("/:game_id/uploads/:upload_id", uploadHandler.GetUploadOffset)
+		gamesAPI.PATCH("/:game_id/uploads/:upload_id", uploadHandler.AppendUploadChunk)
+		gamesAPI.POST("/:game_id/uploads/:upload_id/complete", uploadHandler.CompleteUpload)
+		gamesAPI.DELETE("/:game_id/uploads/:upload_id", uploadHandler.DeleteUpload)
 	}
 
 	replaysAPI := r.Group(API_V1_REPLAYS_PATH)
-	replaysAPI.Use(middleware.AuthMiddleware(authService, logger))
+	replaysAPI.Use(requireAuth)
 	{
+		replaysAPI.GET("", handler.GetUserReplays)
 		replaysAPI.GET("/:replay_id", handler.GetReplay)
 		replaysAPI.PUT("/:replay_id", handler.UpdateReplay)
 		replaysAPI.DELETE("/:replay_id", handler.DeleteReplay)
-		replaysAPI.GET("/:replay_id/file", handler.GetReplayFile)
+		replaysAPI.POST("/:replay_id/file-url", downloadURLHandler.CreateDownloadURL)
+		replaysAPI.POST("/:replay_id/clips", clipHandler.CreateClip)
+		replaysAPI.POST("/:replay_id/shares", shareHandler.CreateReplayShare)
+		replaysAPI.POST("/:replay_id/grants", grantHandler.GrantReplay)
+
+		replaysAPI.GET("/:replay_id/markers", markerHandler.ListMarkers)
+		replaysAPI.POST("/:replay_id/markers", markerHandler.CreateMarker)
+		replaysAPI.PUT("/:replay_id/markers/:marker_id", markerHandler.UpdateMarker)
+		replaysAPI.DELETE("/:replay_id/markers/:marker_id", markerHandler.DeleteMarker)
+
+		replaysAPI.GET("/:replay_id/comments", commentHandler.ListComments)
+		replaysAPI.POST("/:replay_id/comments", commentHandler.CreateComment)
+		replaysAPI.PUT("/:replay_id/comments/:comment_id", commentHandler.UpdateComment)
+		replaysAPI.DELETE("/:replay_id/comments/:comment_id", commentHandler.DeleteComment)
+		replaysAPI.GET("/:replay_id/comments/:comment_id/replies", commentHandler.ListReplies)
+		replaysAPI.GET("/:replay_id/comments/:comment_id/revisions", commentHandler.ListRevisions)
+	}
+
+	// Файл и главы реплея открываются и по подписанной ссылке без JWT: ее подставляют в <video> и <track>
+	replayFilesAPI := r.Group(API_V1_REPLAYS_PATH)
+	replayFilesAPI.Use(middleware.SignedDownload(urlSigner, requireAuth, logger))
+	{
+		replayFilesAPI.GET("/:replay_id/file", handler.GetReplayFile)
+		replayFilesAPI.HEAD("/:replay_id/file", handler.GetReplayFile)
+		replayFilesAPI.GET("/:replay_id/markers.vtt", markerHandler.GetChapters)
+	}
+
+	tagsAPI := r.Group(API_V1_TAGS_PATH)
+	tagsAPI.Use(requireAuth)
+	{
+		tagsAPI.GET("", tagHandler.GetTags)
+		tagsAPI.POST("", tagHandler.CreateTag)
+		tagsAPI.POST("/assign", tagHandler.AssignTags)
+		tagsAPI.PUT("/:tag_id", tagHandler.UpdateTag)
+		tagsAPI.DELETE("/:tag_id", tagHandler.DeleteTag)
+	}
+
+	searchAPI := r.Group(API_V1_SEARCH_PATH)
+	searchAPI.Use(requireAuth)
+	{
+		searchAPI.GET("", searchHandler.Search)
+	}
+
+	sharesAPI := r.Group(API_V1_SHARES_PATH)
+	sharesAPI.Use(requireAuth)
+	{
+		sharesAPI.GET("", shareHandler.GetShares)
+		sharesAPI.DELETE("/:share_id", shareHandler.RevokeShare)
+	}
+
+	grantsAPI := r.Group(API_V1_GRANTS_PATH)
+	grantsAPI.Use(requireAuth)
+	{
+		grantsAPI.GET("", grantHandler.GetGrants)
+		grantsAPI.DELETE("/:grant_id", grantHandler.DeleteGrant)
+	}
+
+	invitationsAPI := r.Group(API_V1_INVITES_PATH)
+	invitationsAPI.Use(requireAuth)
+	{
+		invitationsAPI.GET("", grantHandler.GetInvitations)
+		invitationsAPI.POST("/:grant_id/accept", grantHandler.AcceptInvitation)
+		invitationsAPI.POST("/:grant_id/decline", grantHandler.DeclineInvitation)
+	}
+
+	// Чужие игры и реплеи, доступ к которым пользователь принял
+	sharedAPI := r.Group(API_V1_SHARED_PATH)
+	sharedAPI.Use(requireAuth)
+	{
+		sharedAPI.GET("/games", grantHandler.GetSharedGames)
+		sharedAPI.GET("/replays", grantHandler.GetSharedReplays)
+	}
+
+	// Публичные ссылки открываются без JWT: доступ дает токен ссылки
+	sharePublic := r.Group(SHARE_PATH)
+	{
+		sharePublic.GET("/:token", shareHandler.GetSharedContent)
+		sharePublic.POST("/:token/views", shareHandler.StartSharedView)
+		sharePublic.GET("/:token/file", shareHandler.GetSharedFile)
+		sharePublic.HEAD("/:token/file", shareHandler.GetSharedFile)
+		sharePublic.POST("/:token/replays/:replay_id/views", shareHandler.StartSharedView)
+		sharePublic.GET("/:token/replays/:replay_id/file", shareHandler.GetSharedFile)
+		sharePublic.HEAD("/:token/replays/:replay_id/file", shareHandler.GetSharedFile)
+	}
+
+	usageAPI := r.Group(API_V1_USAGE_PATH)
+	usageAPI.Use(requireAuth)
+	{
+		usageAPI.GET("", quotaHandler.GetUsage)
 	}
 
 	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func newBlobStorage(ctx context.Context, cfg *config.Config) (storage.Blob, error) {
+	switch cfg.StorageDriver {
+	case storage.DriverS3:
+		return storage.NewS3Storage(ctx, storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+			Prefix:    cfg.S3Prefix,
+		})
+	default:
+		return storage.NewFileStorage(cfg.StorageDir), nil
+	}
+}
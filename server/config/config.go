@@ -4,21 +4,64 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// maxSignedURLTTL - подписанная ссылка остается в логах и истории браузера, поэтому живет недолго
+const maxSignedURLTTL = time.Hour
+
 type Config struct {
-	Port       string
-	DBDSN      string
-	StorageDir string
-	LogLevel   string
-	JWTSecret  string
+	Port          string
+	DBDSN         string
+	StorageDriver string
+	StorageDir    string
+	S3Endpoint    string
+	S3Region      string
+	S3Bucket      string
+	S3Prefix      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3UseSSL      bool
+	Compression   string
+	// CompressionSkipExtensions - расширения, которые не сжимаются; nil - список по умолчанию
+	CompressionSkipExtensions []string
+	// UploadSessionTTL - сколько живет незавершенная resumable-загрузка без новых кусков
+	UploadSessionTTL time.Duration
+	// VerifyDownloads - сверять SHA-256 файла перед отдачей клиенту
+	VerifyDownloads bool
+	// ScrubInterval - период фоновой проверки целостности; 0 - выключена
+	ScrubInterval time.Duration
+	// ReconcileInterval - период сверки хранилища с БД; 0 - выключена
+	ReconcileInterval time.Duration
+	// ReconcileDryRun - фоновая сверка только пишет отчет в лог, ничего не перенося в карантин
+	ReconcileDryRun bool
+	// QuotaMaxBytes, QuotaMaxReplays - квота пользователя по умолчанию; 0 - без ограничения
+	QuotaMaxBytes   int64
+	QuotaMaxReplays int64
+	// MaxRequestBodyBytes - предельный размер тела запроса; 0 - без ограничения
+	MaxRequestBodyBytes int64
+	// UploadAllowedTypes - политика загрузки по умолчанию: виды (video, replay, unknown) или MIME-типы
+	UploadAllowedTypes []string
+	// UploadFaststart - переносить moov в начало MP4, записанных с ним в конце файла
+	UploadFaststart bool
+	// EncryptionKey, EncryptionKeyFile - мастер-ключи шифрования файлов (base64); пусто - шифрование выключено
+	EncryptionKey     string
+	EncryptionKeyFile string
+	LogLevel          string
+	JWTSecret         string
+	// SignedURLTTL - срок действия подписанной ссылки на файл реплея
+	SignedURLTTL time.Duration
+	// AuthQueryToken - принимать JWT в ?token= (устарело: вместо него подписанные ссылки)
+	AuthQueryToken bool
 }
 
 func (c Config) String() string {
-	return fmt.Sprintf("{  PORT=%s,  DBDSN=%s,  STORAGE_DIR=%s,  LOG_LEVEL=%s,  JWT_SECRET=***  }",
-		c.Port, c.DBDSN, c.StorageDir, c.LogLevel)
+	return fmt.Sprintf("{  PORT=%s,  DBDSN=%s,  STORAGE_DRIVER=%s,  STORAGE_DIR=%s,  S3_ENDPOINT=%s,  S3_BUCKET=%s,  S3_PREFIX=%s,  COMPRESSION=%s,  LOG_LEVEL=%s,  JWT_SECRET=***  }",
+		c.Port, c.DBDSN, c.StorageDriver, c.StorageDir, c.S3Endpoint, c.S3Bucket, c.S3Prefix, c.Compression, c.LogLevel)
 }
 func Load() (*Config, error) {
 	if root, err := findProjectRoot(); err == nil {
@@ -38,11 +81,67 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		Port:       getEnv("PORT", "8080"),
-		DBDSN:      getEnv("DB_DSN", ""),
-		StorageDir: getEnv("STORAGE_DIR", "./storage"),
-		LogLevel:   getEnv("LOG_LEVEL", "debug"),
-		JWTSecret:  getEnv("JWT_SECRET", ""),
+		Port:            getEnv("PORT", "8080"),
+		DBDSN:           getEnv("DB_DSN", ""),
+		StorageDriver:   getEnv("STORAGE_DRIVER", "disk"),
+		StorageDir:      getEnv("STORAGE_DIR", "./storage"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3Prefix:        getEnv("S3_PREFIX", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:        getEnv("S3_USE_SSL", "true") == "true",
+		Compression:     getEnv("COMPRESSION", "zstd"),
+		VerifyDownloads: getEnv("VERIFY_DOWNLOADS", "false") == "true",
+		UploadFaststart: getEnv("UPLOAD_FASTSTART", "true") == "true",
+		ReconcileDryRun: getEnv("RECONCILE_DRY_RUN", "true") == "true",
+		LogLevel:        getEnv("LOG_LEVEL", "debug"),
+		JWTSecret:       getEnv("JWT_SECRET", ""),
+		AuthQueryToken:  getEnv("AUTH_QUERY_TOKEN", "true") == "true",
+	}
+
+	cfg.EncryptionKey = getEnv("ENCRYPTION_KEY", "")
+	cfg.EncryptionKeyFile = getEnv("ENCRYPTION_KEY_FILE", "")
+
+	if skip := getEnv("COMPRESSION_SKIP_EXTENSIONS", ""); skip != "" {
+		cfg.CompressionSkipExtensions = strings.Split(skip, ",")
+	}
+
+	cfg.UploadAllowedTypes = strings.Split(getEnv("UPLOAD_ALLOWED_TYPES", "video,replay"), ",")
+
+	uploadTTL, err := time.ParseDuration(getEnv("UPLOAD_SESSION_TTL", "24h"))
+	if err != nil || uploadTTL <= 0 {
+		return nil, fmt.Errorf("invalid UPLOAD_SESSION_TTL %q (expected duration like 24h)", getEnv("UPLOAD_SESSION_TTL", ""))
+	}
+	cfg.UploadSessionTTL = uploadTTL
+
+	scrubInterval, err := time.ParseDuration(getEnv("SCRUB_INTERVAL", "0"))
+	if err != nil || scrubInterval < 0 {
+		return nil, fmt.Errorf("invalid SCRUB_INTERVAL %q (expected duration like 24h, 0 disables)", getEnv("SCRUB_INTERVAL", ""))
+	}
+	cfg.ScrubInterval = scrubInterval
+
+	reconcileInterval, err := time.ParseDuration(getEnv("RECONCILE_INTERVAL", "0"))
+	if err != nil || reconcileInterval < 0 {
+		return nil, fmt.Errorf("invalid RECONCILE_INTERVAL %q (expected duration like 24h, 0 disables)", getEnv("RECONCILE_INTERVAL", ""))
+	}
+	cfg.ReconcileInterval = reconcileInterval
+
+	signedURLTTL, err := time.ParseDuration(getEnv("SIGNED_URL_TTL", "5m"))
+	if err != nil || signedURLTTL <= 0 || signedURLTTL > maxSignedURLTTL {
+		return nil, fmt.Errorf("invalid SIGNED_URL_TTL %q (expected duration up to %s like 5m)", getEnv("SIGNED_URL_TTL", ""), maxSignedURLTTL)
+	}
+	cfg.SignedURLTTL = signedURLTTL
+
+	if cfg.QuotaMaxBytes, err = getEnvInt64("QUOTA_MAX_BYTES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.QuotaMaxReplays, err = getEnvInt64("QUOTA_MAX_REPLAYS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRequestBodyBytes, err = getEnvInt64("MAX_REQUEST_BODY_BYTES", 2<<30); err != nil {
+		return nil, err
 	}
 
 	if cfg.DBDSN == "" {
@@ -53,6 +152,22 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("JWT_SECRET is required (set JWT_SECRET environment variable or create .env file in project root)")
 	}
 
+	switch cfg.Compression {
+	case "none", "gzip", "zstd":
+	default:
+		return nil, fmt.Errorf("unknown COMPRESSION %q (expected none, gzip or zstd)", cfg.Compression)
+	}
+
+	switch cfg.StorageDriver {
+	case "disk":
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required when STORAGE_DRIVER=s3")
+		}
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q (expected disk or s3)", cfg.StorageDriver)
+	}
+
 	return cfg, nil
 }
 
@@ -83,3 +198,17 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt64 читает неотрицательное целое (размер в байтах или количество)
+func getEnvInt64(key string, defaultValue int64) (int64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q (expected non-negative integer, 0 disables)", key, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,142 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// defaultSkipExtensions - форматы, которые уже сжаты и не выигрывают от повторного сжатия
+var defaultSkipExtensions = []string{
+	".mp4", ".webm", ".ogg", ".ogv", ".mov", ".avi", ".mkv", ".m4v",
+	".zip", ".gz", ".zst", ".7z", ".rar", ".bz2", ".xz",
+	".jpg", ".jpeg", ".png", ".gif", ".webp",
+}
+
+// compressedTypes - распознанные форматы реплеев, которые уже сжаты внутри:
+// MPQ-архивы движка SC2 и реплеи со сжатыми блоками
+var compressedTypes = map[string]bool{
+	"application/x-starcraft-replay": true,
+	"application/x-sc2-replay":       true,
+	"application/x-heroes-replay":    true,
+	"application/x-warcraft3-replay": true,
+}
+
+// Policy решает, каким алгоритмом сжимать загружаемый файл
+type Policy struct {
+	Algorithm string
+	skip      map[string]bool
+}
+
+// NewPolicy создает политику; skipExtensions = nil означает список по умолчанию
+func NewPolicy(algorithm string, skipExtensions []string) Policy {
+	if skipExtensions == nil {
+		skipExtensions = defaultSkipExtensions
+	}
+
+	skip := make(map[string]bool, len(skipExtensions))
+	for _, ext := range skipExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		skip[ext] = true
+	}
+
+	return Policy{Algorithm: algorithm, skip: skip}
+}
+
+// AlgorithmFor выбирает алгоритм для файла формата detected, определенного по содержимому
+// Видео и уже сжатые реплеи не сжимаются; расширение filename решает только для нераспознанного формата
+func (p Policy) AlgorithmFor(filename string, detected filetype.Type) string {
+	if p.Algorithm == "" || p.Algorithm == None {
+		return None
+	}
+	switch {
+	case detected.Kind == filetype.KindVideo, compressedTypes[detected.MIME]:
+		return None
+	case detected.Kind != filetype.KindReplay && p.skip[strings.ToLower(filepath.Ext(filename))]:
+		return None
+	}
+	return p.Algorithm
+}
+
+func NewWriter(algorithm string, w io.Writer) (io.WriteCloser, error) {
+	switch algorithm {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", algorithm)
+	}
+}
+
+// NewReader распаковывает r; Close закрывает и декодер, и r
+func NewReader(algorithm string, r io.ReadCloser) (io.ReadCloser, error) {
+	switch algorithm {
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		return &decodingReader{Reader: zr, closeFn: zr.Close, src: r}, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return &decodingReader{Reader: zr, closeFn: func() error { zr.Close(); return nil }, src: r}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", algorithm)
+	}
+}
+
+// Compress возвращает поток со сжатым содержимым r
+// Сжатие идет в отдельной горутине; закрытие результата останавливает ее
+func Compress(algorithm string, r io.Reader) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	zw, err := NewWriter(algorithm, pw)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if _, err := io.Copy(zw, r); err != nil {
+			zw.Close()
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(zw.Close())
+	}()
+
+	return pr, nil
+}
+
+type decodingReader struct {
+	io.Reader
+	closeFn func() error
+	src     io.Closer
+}
+
+func (d *decodingReader) Close() error {
+	err := d.closeFn()
+	if srcErr := d.src.Close(); err == nil {
+		err = srcErr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompress_RoundTrip проверяет, что сжатый поток распаковывается в исходные данные
+func TestCompress_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("replay frame data "), 1000)
+
+	for _, algorithm := range []string{Gzip, Zstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			stream, err := Compress(algorithm, bytes.NewReader(data))
+			require.NoError(t, err)
+			compressed, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			require.NoError(t, stream.Close())
+
+			assert.Less(t, len(compressed), len(data), "данные должны сжаться")
+
+			reader, err := NewReader(algorithm, io.NopCloser(bytes.NewReader(compressed)))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			decoded, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, data, decoded)
+		})
+	}
+}
+
+// TestCompress_Unsupported проверяет ошибку для неизвестного алгоритма
+func TestCompress_Unsupported(t *testing.T) {
+	_, err := Compress("lz4", bytes.NewReader(nil))
+
+	assert.Error(t, err)
+}
+
+var unknownType = filetype.Lookup(filetype.Unknown)
+
+// TestPolicy_AlgorithmFor проверяет выбор алгоритма по расширению нераспознанного файла
+func TestPolicy_AlgorithmFor(t *testing.T) {
+	policy := NewPolicy(Zstd, nil)
+
+	assert.Equal(t, Zstd, policy.AlgorithmFor("match.rep", unknownType))
+	assert.Equal(t, Zstd, policy.AlgorithmFor("match.DEM", unknownType))
+	assert.Equal(t, None, policy.AlgorithmFor("clip.mp4", unknownType), "видео уже сжато")
+	assert.Equal(t, None, policy.AlgorithmFor("archive.zip", unknownType))
+}
+
+// TestPolicy_DetectedType проверяет, что распознанный формат важнее расширения
+func TestPolicy_DetectedType(t *testing.T) {
+	policy := NewPolicy(Zstd, nil)
+
+	assert.Equal(t, None, policy.AlgorithmFor("match.rep", filetype.Lookup("video/mp4")), "видео под чужим расширением")
+	assert.Equal(t, None, policy.AlgorithmFor("match.dem", filetype.Lookup("application/x-sc2-replay")), "MPQ-архив уже сжат")
+	assert.Equal(t, Zstd, policy.AlgorithmFor("match.zip", filetype.Lookup("application/x-source-demo")), "демо сжимается при любом расширении")
+}
+
+// TestPolicy_CustomSkipList проверяет пользовательский список исключений
+func TestPolicy_CustomSkipList(t *testing.T) {
+	policy := NewPolicy(Gzip, []string{"dem", ".MOD"})
+
+	assert.Equal(t, None, policy.AlgorithmFor("match.dem", unknownType))
+	assert.Equal(t, None, policy.AlgorithmFor("match.mod", unknownType))
+	assert.Equal(t, Gzip, policy.AlgorithmFor("clip.mp4", unknownType), "свой список заменяет список по умолчанию")
+}
+
+// TestPolicy_None проверяет, что выключенное сжатие ничего не сжимает
+func TestPolicy_None(t *testing.T) {
+	assert.Equal(t, None, NewPolicy(None, nil).AlgorithmFor("match.rep", unknownType))
+	assert.Equal(t, None, Policy{}.AlgorithmFor("match.rep", unknownType))
+}
@@ -0,0 +1,290 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Формат зашифрованного файла: заголовок (magic + префикс nonce), затем сегменты по SegmentSize байт
+// открытого текста, каждый запечатан AES-256-GCM отдельно. Nonce сегмента = префикс || номер || флаг
+// последнего сегмента, поэтому переставленные, подмененные и отрезанные сегменты не расшифруются
+const (
+	SegmentSize = 64 << 10
+	KeySize     = 32
+
+	magic       = "RSE1"
+	prefixSize  = 7
+	headerSize  = len(magic) + prefixSize
+	tagSize     = 16
+	sealedSize  = SegmentSize + tagSize
+	maxSegments = 1<<32 - 1
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid encryption key")
+	// ErrCorrupted - файл обрезан, поврежден или зашифрован другим ключом
+	ErrCorrupted = errors.New("encrypted file is corrupted")
+)
+
+// NewDataKey создает случайный ключ данных для одного файла
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// CiphertextSize возвращает размер зашифрованного файла для size байт открытого текста
+func CiphertextSize(size int64) int64 {
+	return int64(headerSize) + size + tagSize*segmentCount(size)
+}
+
+// PlaintextSize возвращает размер открытого текста по размеру зашифрованного файла
+func PlaintextSize(size int64) (int64, error) {
+	body := size - int64(headerSize)
+	if body < tagSize {
+		return 0, ErrCorrupted
+	}
+
+	segments := (body + sealedSize - 1) / sealedSize
+	last := body - (segments-1)*sealedSize
+	// Пустой последний сегмент пишется только для пустого файла
+	if last < tagSize || (last == tagSize && segments > 1) || segments > maxSegments {
+		return 0, ErrCorrupted
+	}
+	return body - tagSize*segments, nil
+}
+
+func segmentCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + SegmentSize - 1) / SegmentSize
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func segmentNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 0, prefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Encrypt возвращает поток с зашифрованным содержимым r
+// В памяти держится один сегмент, размер исходного потока заранее знать не нужно
+func Encrypt(key []byte, r io.Reader) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+
+	return &encryptingReader{
+		src:     bufio.NewReaderSize(r, SegmentSize+1),
+		aead:    aead,
+		prefix:  header[len(magic):],
+		plain:   make([]byte, SegmentSize),
+		sealed:  make([]byte, 0, sealedSize),
+		pending: header,
+	}, nil
+}
+
+type encryptingReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	index   uint32
+	plain   []byte
+	sealed  []byte
+	pending []byte
+	done    bool
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.pending) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+func (e *encryptingReader) sealNext() error {
+	n, err := io.ReadFull(e.src, e.plain)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// Сегмент последний, если за ним ничего нет: иначе файл ровно кратный SegmentSize
+		// нельзя было бы отличить от обрезанного
+		if _, err := e.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if e.index == maxSegments && !last {
+		return errors.New("file is too large to encrypt")
+	}
+
+	e.pending = e.aead.Seal(e.sealed[:0], segmentNonce(e.prefix, e.index, last), e.plain[:n], nil)
+	e.index++
+	e.done = last
+	return nil
+}
+
+// Reader расшифровывает файл с произвольным доступом: Seek перечитывает только нужный сегмент
+type Reader struct {
+	src       io.ReadSeekCloser
+	aead      cipher.AEAD
+	prefix    []byte
+	size      int64
+	segments  int64
+	pos       int64
+	srcPos    int64
+	loaded    int64
+	plain     []byte
+	sealedBuf []byte
+}
+
+// NewReader расшифровывает src размером size байт; Close закрывает src
+func NewReader(key []byte, src io.ReadSeekCloser, size int64) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plainSize, err := PlaintextSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrCorrupted
+	}
+
+	return &Reader{
+		src:       src,
+		aead:      aead,
+		prefix:    header[len(magic):],
+		size:      plainSize,
+		segments:  segmentCount(plainSize),
+		srcPos:    int64(headerSize),
+		loaded:    -1,
+		sealedBuf: make([]byte, sealedSize),
+	}, nil
+}
+
+// Size возвращает размер открытого текста
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.pos / SegmentSize
+	if index != r.loaded {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain[r.pos-index*SegmentSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *Reader) load(index int64) error {
+	offset := int64(headerSize) + index*sealedSize
+	if offset != r.srcPos {
+		if _, err := r.src.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		r.srcPos = offset
+	}
+
+	last := index == r.segments-1
+	length := int64(sealedSize)
+	if last {
+		length = r.size - index*SegmentSize + tagSize
+	}
+
+	sealed := r.sealedBuf[:length]
+	if _, err := io.ReadFull(r.src, sealed); err != nil {
+		r.srcPos = -1
+		return err
+	}
+	r.srcPos += length
+
+	plain, err := r.aead.Open(sealed[:0], segmentNonce(r.prefix, uint32(index), last), sealed, nil)
+	if err != nil {
+		r.loaded = -1
+		return ErrCorrupted
+	}
+	r.plain = plain
+	r.loaded = index
+	return nil
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *Reader) Close() error {
+	return r.src.Close()
+}
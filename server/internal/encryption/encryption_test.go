@@ -0,0 +1,182 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func randomBytes(t *testing.T, n int) []byte {
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func encrypt(t *testing.T, key, data []byte) []byte {
+	stream, err := Encrypt(key, bytes.NewReader(data))
+	require.NoError(t, err)
+	encrypted, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	return encrypted
+}
+
+func decrypt(key, encrypted []byte) (*Reader, error) {
+	return NewReader(key, nopSeekCloser{bytes.NewReader(encrypted)}, int64(len(encrypted)))
+}
+
+// TestEncrypt_RoundTrip проверяет шифрование и расшифровку на границах сегментов
+func TestEncrypt_RoundTrip(t *testing.T) {
+	key, err := NewDataKey()
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, 3*SegmentSize + 17} {
+		data := randomBytes(t, size)
+
+		encrypted := encrypt(t, key, data)
+		assert.Equal(t, CiphertextSize(int64(size)), int64(len(encrypted)), "size %d", size)
+		plainSize, err := PlaintextSize(int64(len(encrypted)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), plainSize)
+
+		reader, err := decrypt(key, encrypted)
+		require.NoError(t, err)
+		decrypted, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(data, decrypted), "size %d", size)
+	}
+}
+
+// TestReader_Seek проверяет произвольный доступ, нужный для Range-запросов
+func TestReader_Seek(t *testing.T) {
+	key, err := NewDataKey()
+	require.NoError(t, err)
+	data := randomBytes(t, 2*SegmentSize+100)
+
+	reader, err := decrypt(key, encrypt(t, key, data))
+	require.NoError(t, err)
+
+	end, err := reader.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), end)
+
+	offset := int64(SegmentSize - 10)
+	_, err = reader.Seek(offset, io.SeekStart)
+	require.NoError(t, err)
+	part := make([]byte, 50)
+	_, err = io.ReadFull(reader, part)
+	require.NoError(t, err)
+	assert.Equal(t, data[offset:offset+50], part, "чтение через границу сегментов")
+
+	_, err = reader.Seek(-20, io.SeekEnd)
+	require.NoError(t, err)
+	tail, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, data[len(data)-20:], tail)
+}
+
+// TestReader_DetectsTampering проверяет, что измененный или обрезанный файл не расшифровывается
+func TestReader_DetectsTampering(t *testing.T) {
+	key, err := NewDataKey()
+	require.NoError(t, err)
+	data := randomBytes(t, 2*SegmentSize)
+	encrypted := encrypt(t, key, data)
+
+	t.Run("flipped byte", func(t *testing.T) {
+		tampered := bytes.Clone(encrypted)
+		tampered[headerSize+SegmentSize+5] ^= 1
+		reader, err := decrypt(key, tampered)
+		require.NoError(t, err)
+		_, err = io.ReadAll(reader)
+		assert.ErrorIs(t, err, ErrCorrupted)
+	})
+
+	t.Run("dropped last segment", func(t *testing.T) {
+		reader, err := decrypt(key, encrypted[:headerSize+sealedSize])
+		require.NoError(t, err)
+		_, err = io.ReadAll(reader)
+		assert.ErrorIs(t, err, ErrCorrupted, "отрезанный по границе сегмента файл не должен выглядеть целым")
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other, err := NewDataKey()
+		require.NoError(t, err)
+		reader, err := decrypt(other, encrypted)
+		require.NoError(t, err)
+		_, err = io.ReadAll(reader)
+		assert.ErrorIs(t, err, ErrCorrupted)
+	})
+
+	t.Run("truncated mid segment", func(t *testing.T) {
+		_, err := decrypt(key, encrypted[:headerSize+sealedSize+10])
+		assert.ErrorIs(t, err, ErrCorrupted)
+	})
+}
+
+// TestKeyring_Rewrap проверяет, что после ротации ключ данных читается новым мастер-ключом
+func TestKeyring_Rewrap(t *testing.T) {
+	oldMaster, newMaster := randomBytes(t, KeySize), randomBytes(t, KeySize)
+	dataKey, err := NewDataKey()
+	require.NoError(t, err)
+
+	oldRing, err := NewKeyring(oldMaster)
+	require.NoError(t, err)
+	oldID, wrapped, err := oldRing.Wrap(dataKey)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(oldMaster), oldID)
+
+	rotating, err := NewKeyring(oldMaster, newMaster)
+	require.NoError(t, err)
+	newID, rewrapped, err := rotating.Rewrap(oldID, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(newMaster), newID)
+
+	newRing, err := NewKeyring(newMaster)
+	require.NoError(t, err)
+	unwrapped, err := newRing.Unwrap(newID, rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	_, err = newRing.Unwrap(oldID, wrapped)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = newRing.Unwrap(newID, rewrapped[:len(rewrapped)-1])
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+// TestLoadKeyring проверяет чтение ключей из файла и из переменной окружения
+func TestLoadKeyring(t *testing.T) {
+	first, second := randomBytes(t, KeySize), randomBytes(t, KeySize)
+	encode := base64.StdEncoding.EncodeToString
+
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keyFile, []byte("# old key\n"+encode(first)+"\n\n"), 0o600))
+
+	ring, err := LoadKeyring(encode(second), keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(second), ring.ActiveKeyID())
+
+	ring, err = LoadKeyring("", keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(first), ring.ActiveKeyID())
+
+	ring, err = LoadKeyring("", "")
+	require.NoError(t, err)
+	assert.Nil(t, ring, "без ключей шифрование выключено")
+
+	_, err = LoadKeyring(encode(first[:16]), "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,149 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrUnknownKey = errors.New("unknown master key")
+
+// Keyring хранит мастер-ключи, которыми оборачиваются ключи данных файлов
+// Новые ключи данных оборачиваются активным (последним) ключом, остальные нужны только для
+// чтения файлов, ключи которых еще не перевернуты на активный
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewKeyring создает связку из мастер-ключей; последний ключ становится активным
+func NewKeyring(masterKeys ...[]byte) (*Keyring, error) {
+	if len(masterKeys) == 0 {
+		return nil, errors.New("at least one master key is required")
+	}
+
+	k := &Keyring{keys: make(map[string][]byte, len(masterKeys))}
+	for _, key := range masterKeys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("%w: master key must be %d bytes, got %d", ErrInvalidKey, KeySize, len(key))
+		}
+		k.active = KeyID(key)
+		k.keys[k.active] = key
+	}
+	return k, nil
+}
+
+// LoadKeyring читает мастер-ключи из значения key и/или файла keyFile (base64, по ключу на строку,
+// пустые строки и # комментарии пропускаются). key добавляется последним и становится активным
+// Если не задано ни то, ни другое, возвращает nil: шифрование выключено
+func LoadKeyring(key, keyFile string) (*Keyring, error) {
+	var encoded []string
+	if keyFile != "" {
+		lines, err := readKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, lines...)
+	}
+	if key != "" {
+		encoded = append(encoded, key)
+	}
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+
+	masterKeys := make([][]byte, 0, len(encoded))
+	for i, value := range encoded {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: master key %d is not valid base64", ErrInvalidKey, i+1)
+		}
+		masterKeys = append(masterKeys, decoded)
+	}
+	return NewKeyring(masterKeys...)
+}
+
+func readKeyFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return lines, nil
+}
+
+// KeyID - отпечаток мастер-ключа, который хранится рядом с обернутым ключом данных
+func KeyID(masterKey []byte) string {
+	sum := sha256.Sum256(masterKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Wrap шифрует ключ данных активным мастер-ключом и возвращает id этого ключа
+func (k *Keyring) Wrap(dataKey []byte) (string, []byte, error) {
+	aead, err := newAEAD(k.keys[k.active])
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(dataKey)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// id ключа входит в AAD: обернутый ключ нельзя выдать за обернутый другим мастер-ключом
+	return k.active, aead.Seal(nonce, nonce, dataKey, []byte(k.active)), nil
+}
+
+// Unwrap расшифровывает ключ данных мастер-ключом keyID
+func (k *Keyring) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return dataKey, nil
+}
+
+// Rewrap переоборачивает ключ данных активным мастер-ключом; сам файл не меняется
+func (k *Keyring) Rewrap(keyID string, wrapped []byte) (string, []byte, error) {
+	dataKey, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.Wrap(dataKey)
+}
@@ -0,0 +1,167 @@
+package filetype
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// HeaderSize - сколько первых байтов файла нужно Detect
+const HeaderSize = 512
+
+const (
+	KindVideo   = "video"
+	KindReplay  = "replay"
+	KindUnknown = "unknown"
+
+	// Unknown - MIME-тип файла, формат которого не распознан
+	Unknown = "application/octet-stream"
+)
+
+// Type - формат файла, определенный по содержимому
+type Type struct {
+	MIME string
+	Kind string
+}
+
+// known - все форматы, которые умеет распознавать Detect
+var known = map[string]string{
+	"video/mp4":                      KindVideo,
+	"video/quicktime":                KindVideo,
+	"video/x-m4v":                    KindVideo,
+	"video/3gpp":                     KindVideo,
+	"video/webm":                     KindVideo,
+	"video/x-matroska":               KindVideo,
+	"video/ogg":                      KindVideo,
+	"video/x-msvideo":                KindVideo,
+	"application/x-starcraft-replay": KindReplay,
+	"application/x-sc2-replay":       KindReplay,
+	"application/x-heroes-replay":    KindReplay,
+	"application/x-warcraft3-replay": KindReplay,
+	"application/x-source-demo":      KindReplay,
+	"application/x-source2-demo":     KindReplay,
+}
+
+var (
+	ebmlMagic     = []byte{0x1A, 0x45, 0xDF, 0xA3}
+	ebmlDocTypeID = []byte{0x42, 0x82}
+	w3gMagic      = []byte("Warcraft III recording\x1A\x00")
+)
+
+func newType(mime string) Type {
+	return Type{MIME: mime, Kind: known[mime]}
+}
+
+// Lookup возвращает формат по MIME-типу, определенному раньше, например сохраненному у реплея
+func Lookup(mime string) Type {
+	if kind, ok := known[mime]; ok {
+		return Type{MIME: mime, Kind: kind}
+	}
+	return Type{MIME: mime, Kind: KindUnknown}
+}
+
+// Detect определяет формат по магическим байтам в начале файла (достаточно HeaderSize байтов)
+// Расширение имени файла не учитывается: его задает клиент
+func Detect(head []byte) Type {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return newType(isoBrandType(string(head[8:12])))
+	case bytes.HasPrefix(head, ebmlMagic):
+		if ebmlDocType(head) == "webm" {
+			return newType("video/webm")
+		}
+		return newType("video/x-matroska")
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return newType("video/ogg")
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "AVI ":
+		return newType("video/x-msvideo")
+	case len(head) >= 16 && (string(head[12:16]) == "reRS" || string(head[12:16]) == "seRS"):
+		// StarCraft: Brood War - первая секция содержит идентификатор реплея
+		return newType("application/x-starcraft-replay")
+	case bytes.HasPrefix(head, w3gMagic):
+		return newType("application/x-warcraft3-replay")
+	case bytes.HasPrefix(head, []byte("MPQ\x1B")):
+		// Реплеи игр Blizzard на движке SC2 - MPQ-архив с пользовательским заголовком
+		if bytes.Contains(head, []byte("StarCraft II replay")) {
+			return newType("application/x-sc2-replay")
+		}
+		if bytes.Contains(head, []byte("Heroes of the Storm replay")) {
+			return newType("application/x-heroes-replay")
+		}
+	case bytes.HasPrefix(head, []byte("HL2DEMO\x00")):
+		return newType("application/x-source-demo")
+	case bytes.HasPrefix(head, []byte("PBDEMS2\x00")):
+		return newType("application/x-source2-demo")
+	}
+	return Type{MIME: Unknown, Kind: KindUnknown}
+}
+
+// isoBrandType выбирает MIME-тип ISO BMFF по major brand из ftyp
+func isoBrandType(brand string) string {
+	switch {
+	case brand == "qt  ":
+		return "video/quicktime"
+	case strings.HasPrefix(brand, "M4V"):
+		return "video/x-m4v"
+	case strings.HasPrefix(brand, "3gp"):
+		return "video/3gpp"
+	default:
+		return "video/mp4"
+	}
+}
+
+// ebmlDocType находит элемент DocType в заголовке EBML ("webm", "matroska")
+func ebmlDocType(head []byte) string {
+	i := bytes.Index(head, ebmlDocTypeID)
+	if i < 0 || i+2 >= len(head) {
+		return ""
+	}
+
+	// Размер элемента - EBML varint; DocType короткий, поэтому хватает однобайтовой формы
+	size := head[i+2]
+	if size&0x80 == 0 {
+		return ""
+	}
+	n := int(size & 0x7F)
+	start := i + 3
+	if start+n > len(head) {
+		return ""
+	}
+	return string(head[start : start+n])
+}
+
+// Policy - какие форматы разрешено загружать в игру
+// Элемент политики - вид формата (video, replay, unknown) или конкретный MIME-тип
+type Policy struct {
+	entries []string
+	allowed map[string]bool
+}
+
+// ParsePolicy проверяет элементы политики; неизвестный элемент - ошибка
+func ParsePolicy(entries []string) (Policy, error) {
+	policy := Policy{allowed: make(map[string]bool, len(entries))}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if _, ok := known[entry]; !ok && entry != KindVideo && entry != KindReplay && entry != KindUnknown {
+			return Policy{}, fmt.Errorf("unknown file type %q", entry)
+		}
+		if !policy.allowed[entry] {
+			policy.entries = append(policy.entries, entry)
+		}
+		policy.allowed[entry] = true
+	}
+	return policy, nil
+}
+
+// Allows проверяет, разрешен ли формат t политикой
+func (p Policy) Allows(t Type) bool {
+	return p.allowed[t.Kind] || p.allowed[t.MIME]
+}
+
+// Entries возвращает элементы политики в нормализованном виде
+func (p Policy) Entries() []string {
+	return p.entries
+}
@@ -0,0 +1,57 @@
+package filetype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDetect проверяет распознавание форматов по магическим байтам
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		mime string
+		kind string
+	}{
+		{"mp4", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), "video/mp4", KindVideo},
+		{"quicktime", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"), "video/quicktime", KindVideo},
+		{"webm", append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81, 0x01, 0x42, 0x82, 0x84}, "webm"...), "video/webm", KindVideo},
+		{"matroska", append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0xA3, 0x42, 0x82, 0x88}, "matroska"...), "video/x-matroska", KindVideo},
+		{"avi", []byte("RIFF\x00\x10\x00\x00AVI LIST"), "video/x-msvideo", KindVideo},
+		{"ogg", []byte("OggS\x00\x02"), "video/ogg", KindVideo},
+		{"starcraft", []byte("\x3d\x7a\x1a\x42\x01\x00\x00\x00\x04\x00\x00\x00reRS"), "application/x-starcraft-replay", KindReplay},
+		{"starcraft remastered", []byte("\x3d\x7a\x1a\x42\x01\x00\x00\x00\x04\x00\x00\x00seRS"), "application/x-starcraft-replay", KindReplay},
+		{"warcraft 3", []byte("Warcraft III recording\x1A\x00\x44\x00"), "application/x-warcraft3-replay", KindReplay},
+		{"starcraft 2", []byte("MPQ\x1B\x00\x02\x00\x00\x00\x04\x00\x00\x00StarCraft II replay\x1B11"), "application/x-sc2-replay", KindReplay},
+		{"source demo", []byte("HL2DEMO\x00\x03\x00\x00\x00"), "application/x-source-demo", KindReplay},
+		{"source 2 demo", []byte("PBDEMS2\x00\x10\x00"), "application/x-source2-demo", KindReplay},
+		{"generic mpq", []byte("MPQ\x1B\x00\x02\x00\x00"), Unknown, KindUnknown},
+		{"text", []byte("just some text pretending to be .mp4"), Unknown, KindUnknown},
+		{"empty", nil, Unknown, KindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detected := Detect(tt.head)
+			assert.Equal(t, tt.mime, detected.MIME)
+			assert.Equal(t, tt.kind, detected.Kind)
+		})
+	}
+}
+
+// TestPolicy проверяет разрешение по виду формата и по конкретному MIME-типу
+func TestPolicy(t *testing.T) {
+	policy, err := ParsePolicy([]string{"Video", " application/x-starcraft-replay ", "video"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"video", "application/x-starcraft-replay"}, policy.Entries())
+	assert.True(t, policy.Allows(Type{MIME: "video/webm", Kind: KindVideo}))
+	assert.True(t, policy.Allows(Type{MIME: "application/x-starcraft-replay", Kind: KindReplay}))
+	assert.False(t, policy.Allows(Type{MIME: "application/x-sc2-replay", Kind: KindReplay}))
+	assert.False(t, policy.Allows(Type{MIME: Unknown, Kind: KindUnknown}))
+
+	_, err = ParsePolicy([]string{"image/png"})
+	assert.Error(t, err)
+}
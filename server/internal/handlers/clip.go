@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ClipHandler struct {
+	clipService ClipServiceInterface
+}
+
+func NewClipHandler(clipService ClipServiceInterface) *ClipHandler {
+	return &ClipHandler{clipService: clipService}
+}
+
+// CreateClip вырезает фрагмент MP4-реплея в новый реплей той же игры
+func (h *ClipHandler) CreateClip(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	var req struct {
+		StartMS *int64 `json:"start_ms" binding:"required"`
+		EndMS   *int64 `json:"end_ms" binding:"required"`
+		Title   string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "start_ms and end_ms are required")
+		return
+	}
+
+	start := time.Duration(*req.StartMS) * time.Millisecond
+	end := time.Duration(*req.EndMS) * time.Millisecond
+	clip, err := h.clipService.CreateClip(c.Request.Context(), replayID, userID, start, end, req.Title)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrReplayNotFound):
+			respondNotFound(c, "replay not found")
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, services.ErrForbidden.Error())
+		case errors.Is(err, services.ErrInvalidClipRange):
+			respondBadRequest(c, services.ErrInvalidClipRange.Error())
+		case errors.Is(err, services.ErrClipUnsupported):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": services.ErrClipUnsupported.Error()})
+		case isQuotaExceeded(err):
+			respondQuotaExceeded(c, err)
+		default:
+			respondInternalError(c, "failed to create clip")
+		}
+		return
+	}
+
+	respondCreated(c, clip)
+}
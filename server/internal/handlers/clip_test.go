@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockClipService - мок для вырезки фрагментов
+type MockClipService struct {
+	mock.Mock
+}
+
+func (m *MockClipService) CreateClip(ctx context.Context, replayID, userID uuid.UUID, start, end time.Duration, title string) (*models.Replay, error) {
+	args := m.Called(ctx, replayID, userID, start, end, title)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Replay), args.Error(1)
+}
+
+func setupClipRouter(service *MockClipService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.POST("/replays/:replay_id/clips", NewClipHandler(service).CreateClip)
+	return router
+}
+
+// TestCreateClip_Success проверяет перевод миллисекунд в границы фрагмента и ответ 201
+func TestCreateClip_Success(t *testing.T) {
+	mockClipService := new(MockClipService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupClipRouter(mockClipService, userID)
+
+	clip := &models.Replay{ID: uuid.New(), OriginalName: "match_clip_60-90.mp4", SourceReplayID: &replayID}
+	mockClipService.On("CreateClip", mock.Anything, replayID, userID, 61500*time.Millisecond, 90*time.Second, "ace").Return(clip, nil)
+
+	req, _ := http.NewRequest("POST", "/replays/"+replayID.String()+"/clips", strings.NewReader(`{"start_ms": 61500, "end_ms": 90000, "title": "ace"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"source_replay_id":"`+replayID.String()+`"`)
+	mockClipService.AssertExpectations(t)
+}
+
+// TestCreateClip_Errors проверяет коды ответа на ошибки сервиса и неполное тело запроса
+func TestCreateClip_Errors(t *testing.T) {
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{services.ErrReplayNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: out of media", services.ErrInvalidClipRange), http.StatusBadRequest},
+		{services.ErrClipUnsupported, http.StatusUnsupportedMediaType},
+		{fmt.Errorf("failed to check quota: %w", services.ErrQuotaBytesExceeded), http.StatusInsufficientStorage},
+	}
+	for _, tc := range cases {
+		mockClipService := new(MockClipService)
+		userID, replayID := uuid.New(), uuid.New()
+		router := setupClipRouter(mockClipService, userID)
+		mockClipService.On("CreateClip", mock.Anything, replayID, userID, mock.Anything, mock.Anything, "").Return(nil, tc.err)
+
+		req, _ := http.NewRequest("POST", "/replays/"+replayID.String()+"/clips", strings.NewReader(`{"start_ms": 0, "end_ms": 1000}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.err.Error())
+	}
+
+	mockClipService := new(MockClipService)
+	router := setupClipRouter(mockClipService, uuid.New())
+	req, _ := http.NewRequest("POST", "/replays/"+uuid.New().String()+"/clips", strings.NewReader(`{"start_ms": 0}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockClipService.AssertNotCalled(t, "CreateClip")
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	paramCommentID      = "comment_id"
+	queryCursor         = "cursor"
+	defaultCommentLimit = 20
+)
+
+type CommentHandler struct {
+	commentService CommentServiceInterface
+}
+
+func NewCommentHandler(commentService CommentServiceInterface) *CommentHandler {
+	return &CommentHandler{commentService: commentService}
+}
+
+// ListComments возвращает страницу комментариев верхнего уровня
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	limit, ok := limitParam(c, defaultCommentLimit)
+	if !ok {
+		return
+	}
+
+	page, err := h.commentService.ListComments(c.Request.Context(), replayID, userID, nil, c.Query(queryCursor), limit)
+	if err != nil {
+		respondCommentError(c, err)
+		return
+	}
+
+	respondOK(c, page)
+}
+
+// ListReplies возвращает страницу ответов на комментарий
+func (h *CommentHandler) ListReplies(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, commentID, ok := commentParams(c)
+	if !ok {
+		return
+	}
+
+	limit, ok := limitParam(c, defaultCommentLimit)
+	if !ok {
+		return
+	}
+
+	page, err := h.commentService.ListComments(c.Request.Context(), replayID, userID, &commentID, c.Query(queryCursor), limit)
+	if err != nil {
+		respondCommentError(c, err)
+		return
+	}
+
+	respondOK(c, page)
+}
+
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	var req struct {
+		Body     string     `json:"body"`
+		TimeMS   *int64     `json:"time_ms"`
+		ParentID *uuid.UUID `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	comment, err := h.commentService.CreateComment(c.Request.Context(), replayID, userID,
+		models.Comment{Body: req.Body, TimeMS: req.TimeMS, ParentID: req.ParentID})
+	if err != nil {
+		respondCommentError(c, err)
+		return
+	}
+
+	respondCreated(c, comment)
+}
+
+// UpdateComment меняет текст комментария; прежний текст уходит в историю правок
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, commentID, ok := commentParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	comment, err := h.commentService.UpdateComment(c.Request.Context(), commentID, replayID, userID, req.Body)
+	if err != nil {
+		respondCommentError(c, err)
+		return
+	}
+
+	respondOK(c, comment)
+}
+
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, commentID, ok := commentParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.commentService.DeleteComment(c.Request.Context(), commentID, replayID, userID); err != nil {
+		respondCommentError(c, err)
+		return
+	}
+
+	respondSuccess(c, "deleted")
+}
+
+func (h *CommentHandler) ListRevisions(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, commentID, ok := commentParams(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.commentService.ListRevisions(c.Request.Context(), commentID, replayID, userID)
+	if err != nil {
+		respondCommentError(c, err)
+		return
+	}
+
+	respondOK(c, revisions)
+}
+
+func commentParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	commentID, err := uuid.Parse(c.Param(paramCommentID))
+	if err != nil {
+		respondBadRequest(c, "invalid comment_id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return replayID, commentID, true
+}
+
+func respondCommentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidComment):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrInvalidCursor):
+		respondBadRequest(c, services.ErrInvalidCursor.Error())
+	case errors.Is(err, services.ErrReplayNotFound):
+		respondNotFound(c, "replay not found")
+	case errors.Is(err, services.ErrCommentNotFound):
+		respondNotFound(c, "comment not found")
+	case errors.Is(err, services.ErrCommentForbidden):
+		respondForbidden(c, services.ErrCommentForbidden.Error())
+	case errors.Is(err, services.ErrForbidden):
+		respondForbidden(c, services.ErrForbidden.Error())
+	default:
+		respondInternalError(c, "failed to process comment")
+	}
+}
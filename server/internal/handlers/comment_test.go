@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCommentService - мок для обсуждений реплеев
+type MockCommentService struct {
+	mock.Mock
+}
+
+func (m *MockCommentService) ListComments(ctx context.Context, replayID, userID uuid.UUID, parentID *uuid.UUID, cursor string, limit int) (*models.CommentPage, error) {
+	args := m.Called(ctx, replayID, userID, parentID, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CommentPage), args.Error(1)
+}
+
+func (m *MockCommentService) CreateComment(ctx context.Context, replayID, userID uuid.UUID, comment models.Comment) (*models.Comment, error) {
+	args := m.Called(ctx, replayID, userID, comment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
+
+func (m *MockCommentService) UpdateComment(ctx context.Context, commentID, replayID, userID uuid.UUID, body string) (*models.Comment, error) {
+	args := m.Called(ctx, commentID, replayID, userID, body)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
+
+func (m *MockCommentService) DeleteComment(ctx context.Context, commentID, replayID, userID uuid.UUID) error {
+	args := m.Called(ctx, commentID, replayID, userID)
+	return args.Error(0)
+}
+
+func (m *MockCommentService) ListRevisions(ctx context.Context, commentID, replayID, userID uuid.UUID) ([]models.CommentRevision, error) {
+	args := m.Called(ctx, commentID, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.CommentRevision), args.Error(1)
+}
+
+func setupCommentRouter(service *MockCommentService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	handler := NewCommentHandler(service)
+	router.GET("/replays/:replay_id/comments", handler.ListComments)
+	router.POST("/replays/:replay_id/comments", handler.CreateComment)
+	router.PUT("/replays/:replay_id/comments/:comment_id", handler.UpdateComment)
+	router.GET("/replays/:replay_id/comments/:comment_id/replies", handler.ListReplies)
+	return router
+}
+
+// TestListComments_Query проверяет передачу курсора и лимита и ответ со страницей
+func TestListComments_Query(t *testing.T) {
+	mockCommentService := new(MockCommentService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupCommentRouter(mockCommentService, userID)
+
+	page := &models.CommentPage{Comments: []models.Comment{{ID: uuid.New(), Body: "gg"}}, NextCursor: "next"}
+	mockCommentService.On("ListComments", mock.Anything, replayID, userID, (*uuid.UUID)(nil), "abc", 50).Return(page, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/comments?cursor=abc&limit=50", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"next"`)
+	mockCommentService.AssertExpectations(t)
+}
+
+// TestListReplies проверяет, что ответы запрашиваются по родительскому комментарию с лимитом по умолчанию
+func TestListReplies(t *testing.T) {
+	mockCommentService := new(MockCommentService)
+	userID, replayID, commentID := uuid.New(), uuid.New(), uuid.New()
+	router := setupCommentRouter(mockCommentService, userID)
+
+	mockCommentService.On("ListComments", mock.Anything, replayID, userID, &commentID, "", defaultCommentLimit).
+		Return(&models.CommentPage{Comments: []models.Comment{}}, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/comments/"+commentID.String()+"/replies", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockCommentService.AssertExpectations(t)
+}
+
+// TestComment_Errors проверяет коды ответа на ошибки сервиса
+func TestComment_Errors(t *testing.T) {
+	mockCommentService := new(MockCommentService)
+	userID, replayID, commentID := uuid.New(), uuid.New(), uuid.New()
+	router := setupCommentRouter(mockCommentService, userID)
+
+	mockCommentService.On("CreateComment", mock.Anything, replayID, userID, mock.Anything).Return(nil, services.ErrReplayNotFound)
+	mockCommentService.On("UpdateComment", mock.Anything, commentID, replayID, userID, "edit").Return(nil, services.ErrCommentForbidden)
+	mockCommentService.On("ListComments", mock.Anything, replayID, userID, mock.Anything, "bad", mock.Anything).Return(nil, services.ErrInvalidCursor)
+
+	base := "/replays/" + replayID.String() + "/comments"
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", base, `{"body": "hi"}`, http.StatusNotFound},
+		{"PUT", base + "/" + commentID.String(), `{"body": "edit"}`, http.StatusForbidden},
+		{"GET", base + "?cursor=bad", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt.method+" "+tt.path)
+	}
+}
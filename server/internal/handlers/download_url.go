@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"path"
+
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DownloadURLHandler struct {
+	downloadURLService DownloadURLServiceInterface
+}
+
+func NewDownloadURLHandler(downloadURLService DownloadURLServiceInterface) *DownloadURLHandler {
+	return &DownloadURLHandler{downloadURLService: downloadURLService}
+}
+
+// CreateDownloadURL выдает подписанную ссылку на файл реплея, открывающуюся без JWT несколько минут
+func (h *DownloadURLHandler) CreateDownloadURL(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	// Ссылка ведет на соседний маршрут: .../replays/{replay_id}/file-url -> .../replays/{replay_id}/file
+	filePath := path.Join(path.Dir(c.Request.URL.Path), "file")
+
+	signed, err := h.downloadURLService.SignReplayDownload(c.Request.Context(), replayID, userID, filePath)
+	if err != nil {
+		if errors.Is(err, services.ErrReplayNotFound) {
+			respondNotFound(c, "replay not found")
+			return
+		}
+		respondInternalError(c, "failed to sign download url")
+		return
+	}
+
+	respondCreated(c, signed)
+}
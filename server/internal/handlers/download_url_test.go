@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDownloadURLService - мок для подписанных ссылок на файл реплея
+type MockDownloadURLService struct {
+	mock.Mock
+}
+
+func (m *MockDownloadURLService) SignReplayDownload(ctx context.Context, replayID, userID uuid.UUID, filePath string) (*models.SignedURL, error) {
+	args := m.Called(ctx, replayID, userID, filePath)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SignedURL), args.Error(1)
+}
+
+func setupDownloadURLRouter(service *MockDownloadURLService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	handler := NewDownloadURLHandler(service)
+	router.POST("/api/v1/replays/:replay_id/file-url", handler.CreateDownloadURL)
+	return router
+}
+
+// TestCreateDownloadURL_Success проверяет, что ссылка ведет на маршрут файла того же реплея
+func TestCreateDownloadURL_Success(t *testing.T) {
+	mockService := new(MockDownloadURLService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupDownloadURLRouter(mockService, userID)
+
+	filePath := "/api/v1/replays/" + replayID.String() + "/file"
+	signed := &models.SignedURL{URL: filePath + "?expires=1&sig=x&uid=" + userID.String(), ExpiresAt: time.Now()}
+	mockService.On("SignReplayDownload", mock.Anything, replayID, userID, filePath).Return(signed, nil)
+
+	req, _ := http.NewRequest("POST", "/api/v1/replays/"+replayID.String()+"/file-url", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"url"`)
+	mockService.AssertExpectations(t)
+}
+
+// TestCreateDownloadURL_NotFound проверяет ответ для недоступного реплея
+func TestCreateDownloadURL_NotFound(t *testing.T) {
+	mockService := new(MockDownloadURLService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupDownloadURLRouter(mockService, userID)
+
+	mockService.On("SignReplayDownload", mock.Anything, replayID, userID, mock.Anything).Return(nil, services.ErrReplayNotFound)
+
+	req, _ := http.NewRequest("POST", "/api/v1/replays/"+replayID.String()+"/file-url", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/fckoffmw/replay-service/server/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,6 +16,10 @@ func respondNotFound(c *gin.Context, message string) {
 	c.JSON(http.StatusNotFound, gin.H{"error": message})
 }
 
+func respondForbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, gin.H{"error": message})
+}
+
 func respondInternalError(c *gin.Context, message string) {
 	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
 }
@@ -29,3 +35,37 @@ func respondOK(c *gin.Context, data interface{}) {
 func respondSuccess(c *gin.Context, message string) {
 	c.JSON(http.StatusOK, gin.H{"message": message})
 }
+
+// respondQuotaExceeded отвечает 507: загрузка не помещается в квоту пользователя
+func respondQuotaExceeded(c *gin.Context, err error) {
+	message := services.ErrQuotaBytesExceeded.Error()
+	if errors.Is(err, services.ErrQuotaReplaysExceeded) {
+		message = services.ErrQuotaReplaysExceeded.Error()
+	}
+	c.JSON(http.StatusInsufficientStorage, gin.H{"error": message})
+}
+
+// respondTooLarge отвечает 413: тело запроса оборвано лимитом размера
+func respondTooLarge(c *gin.Context, err error) {
+	var maxErr *http.MaxBytesError
+	errors.As(err, &maxErr)
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large", "max_bytes": maxErr.Limit})
+}
+
+// respondUnsupportedType отвечает 415: формат файла не разрешен политикой загрузки игры
+func respondUnsupportedType(c *gin.Context) {
+	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": services.ErrFileTypeNotAllowed.Error()})
+}
+
+func isFileTypeNotAllowed(err error) bool {
+	return errors.Is(err, services.ErrFileTypeNotAllowed)
+}
+
+func isQuotaExceeded(err error) bool {
+	return errors.Is(err, services.ErrQuotaBytesExceeded) || errors.Is(err, services.ErrQuotaReplaysExceeded)
+}
+
+func isRequestTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
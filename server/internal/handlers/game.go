@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -8,18 +11,31 @@ import (
 const (
 	contextKeyUserID = "user_id"
 	paramGameID      = "game_id"
+	queryName        = "name"
+	defaultGameLimit = 50
 )
 
+// GetGames возвращает страницу игр пользователя; name - подстрока названия
 func (h *Handler) GetGames(c *gin.Context) {
 	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
 
-	games, err := h.gameService.GetUserGames(c.Request.Context(), userID)
+	sort, ok := sortParam(c, models.GameSortCreatedAt)
+	if !ok {
+		return
+	}
+	limit, ok := limitParam(c, defaultGameLimit)
+	if !ok {
+		return
+	}
+	filter := models.GameFilter{Name: strings.TrimSpace(c.Query(queryName))}
+
+	page, err := h.gameService.GetUserGames(c.Request.Context(), userID, filter, sort, c.Query(queryCursor), limit)
 	if err != nil {
-		respondInternalError(c, "failed to get games")
+		respondListError(c, err, "failed to get games")
 		return
 	}
 
-	respondOK(c, games)
+	respondOK(c, page)
 }
 
 func (h *Handler) CreateGame(c *gin.Context) {
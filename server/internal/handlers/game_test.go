@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -22,12 +23,12 @@ type MockGameService struct {
 	mock.Mock
 }
 
-func (m *MockGameService) GetUserGames(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
-	args := m.Called(ctx, userID)
+func (m *MockGameService) GetUserGames(ctx context.Context, userID uuid.UUID, filter models.GameFilter, sort models.Sort, cursor string, limit int) (*models.GamePage, error) {
+	args := m.Called(ctx, userID, filter, sort, cursor, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]models.Game), args.Error(1)
+	return args.Get(0).(*models.GamePage), args.Error(1)
 }
 
 func (m *MockGameService) CreateGame(ctx context.Context, userID uuid.UUID, name string) (*models.Game, error) {
@@ -78,7 +79,9 @@ func TestGetGames_Success(t *testing.T) {
 		{ID: uuid.New(), Name: "Game 2"},
 	}
 	
-	mockGameService.On("GetUserGames", mock.Anything, userID).Return(expectedGames, nil)
+	newest := models.Sort{Field: models.GameSortCreatedAt, Desc: true}
+	mockGameService.On("GetUserGames", mock.Anything, userID, models.GameFilter{}, newest, "", 50).
+		Return(&models.GamePage{Games: expectedGames, Total: 2}, nil)
 	
 	// Создаем HTTP запрос
 	req, _ := http.NewRequest("GET", "/games", nil)
@@ -90,10 +93,11 @@ func TestGetGames_Success(t *testing.T) {
 	// Проверяем ответ
 	assert.Equal(t, http.StatusOK, w.Code, "должен вернуться статус 200")
 	
-	var response []models.Game
+	var response models.GamePage
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err, "ответ должен быть валидным JSON")
-	assert.Equal(t, 2, len(response), "должно быть 2 игры")
+	assert.Equal(t, 2, len(response.Games), "должно быть 2 игры")
+	assert.Equal(t, 2, response.Total)
 	
 	mockGameService.AssertExpectations(t)
 }
@@ -115,7 +119,7 @@ func TestGetGames_ServiceError(t *testing.T) {
 	
 	router.GET("/games", handler.GetGames)
 	
-	mockGameService.On("GetUserGames", mock.Anything, userID).Return(nil, assert.AnError)
+	mockGameService.On("GetUserGames", mock.Anything, userID, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, assert.AnError)
 	
 	req, _ := http.NewRequest("GET", "/games", nil)
 	w := httptest.NewRecorder()
@@ -324,12 +328,20 @@ type MockReplayService struct {
 	mock.Mock
 }
 
-func (m *MockReplayService) GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, limit int) ([]models.Replay, error) {
-	args := m.Called(ctx, gameID, userID, limit)
+func (m *MockReplayService) GetUserReplays(ctx context.Context, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error) {
+	args := m.Called(ctx, userID, filter, sort, cursor, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]models.Replay), args.Error(1)
+	return args.Get(0).(*models.ReplayPage), args.Error(1)
+}
+
+func (m *MockReplayService) GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error) {
+	args := m.Called(ctx, gameID, userID, filter, sort, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ReplayPage), args.Error(1)
 }
 
 func (m *MockReplayService) GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
@@ -358,7 +370,10 @@ func (m *MockReplayService) DeleteReplay(ctx context.Context, replayID, userID u
 	return args.Error(0)
 }
 
-func (m *MockReplayService) GetReplayFilePath(ctx context.Context, replayID, userID uuid.UUID) (string, string, error) {
-	args := m.Called(ctx, replayID, userID)
-	return args.String(0), args.String(1), args.Error(2)
+func (m *MockReplayService) OpenReplayFile(ctx context.Context, replayID, userID uuid.UUID, acceptEncodings []string) (*services.ReplayFile, error) {
+	args := m.Called(ctx, replayID, userID, acceptEncodings)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.ReplayFile), args.Error(1)
 }
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const paramGrantID = "grant_id"
+
+type GrantHandler struct {
+	grantService GrantServiceInterface
+}
+
+func NewGrantHandler(grantService GrantServiceInterface) *GrantHandler {
+	return &GrantHandler{grantService: grantService}
+}
+
+// GrantGame приглашает пользователя по логину в игру владельца
+func (h *GrantHandler) GrantGame(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, err := uuid.Parse(c.Param(paramGameID))
+	if err != nil {
+		respondBadRequest(c, "invalid game_id")
+		return
+	}
+
+	var req models.GrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	grant, err := h.grantService.GrantGame(c.Request.Context(), gameID, userID, req)
+	if err != nil {
+		respondGrantError(c, err)
+		return
+	}
+
+	respondCreated(c, grant)
+}
+
+// GrantReplay приглашает пользователя по логину к реплею владельца
+func (h *GrantHandler) GrantReplay(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	var req models.GrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	grant, err := h.grantService.GrantReplay(c.Request.Context(), replayID, userID, req)
+	if err != nil {
+		respondGrantError(c, err)
+		return
+	}
+
+	respondCreated(c, grant)
+}
+
+// GetGrants возвращает доступы, выданные пользователем
+func (h *GrantHandler) GetGrants(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	grants, err := h.grantService.GetGrants(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get grants")
+		return
+	}
+
+	respondOK(c, grants)
+}
+
+// DeleteGrant отзывает доступ владельцем или отказывается от него получателем
+func (h *GrantHandler) DeleteGrant(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	grantID, err := uuid.Parse(c.Param(paramGrantID))
+	if err != nil {
+		respondBadRequest(c, "invalid grant_id")
+		return
+	}
+
+	if err := h.grantService.DeleteGrant(c.Request.Context(), grantID, userID); err != nil {
+		respondGrantError(c, err)
+		return
+	}
+
+	respondSuccess(c, "deleted")
+}
+
+// GetInvitations возвращает приглашения пользователю, ожидающие ответа
+func (h *GrantHandler) GetInvitations(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	grants, err := h.grantService.GetInvitations(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get invitations")
+		return
+	}
+
+	respondOK(c, grants)
+}
+
+func (h *GrantHandler) AcceptInvitation(c *gin.Context) {
+	h.respondInvitation(c, h.grantService.AcceptInvitation)
+}
+
+func (h *GrantHandler) DeclineInvitation(c *gin.Context) {
+	h.respondInvitation(c, h.grantService.DeclineInvitation)
+}
+
+func (h *GrantHandler) respondInvitation(c *gin.Context, respond func(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error)) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	grantID, err := uuid.Parse(c.Param(paramGrantID))
+	if err != nil {
+		respondBadRequest(c, "invalid grant_id")
+		return
+	}
+
+	grant, err := respond(c.Request.Context(), grantID, userID)
+	if err != nil {
+		respondGrantError(c, err)
+		return
+	}
+
+	respondOK(c, grant)
+}
+
+// GetSharedGames возвращает чужие игры, доступные пользователю, с его ролью и владельцем
+func (h *GrantHandler) GetSharedGames(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	games, err := h.grantService.GetSharedGames(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get shared games")
+		return
+	}
+
+	respondOK(c, games)
+}
+
+// GetSharedReplays возвращает чужие реплеи, выданные пользователю по одному
+func (h *GrantHandler) GetSharedReplays(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	replays, err := h.grantService.GetSharedReplays(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get shared replays")
+		return
+	}
+
+	respondOK(c, replays)
+}
+
+func respondGrantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidGrant):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrUserNotFound):
+		respondNotFound(c, services.ErrUserNotFound.Error())
+	case errors.Is(err, services.ErrGameNotFound):
+		respondNotFound(c, "game not found")
+	case errors.Is(err, services.ErrReplayNotFound):
+		respondNotFound(c, "replay not found")
+	case errors.Is(err, services.ErrGrantNotFound):
+		respondNotFound(c, services.ErrGrantNotFound.Error())
+	case errors.Is(err, services.ErrInvitationNotFound):
+		respondNotFound(c, services.ErrInvitationNotFound.Error())
+	default:
+		respondInternalError(c, "failed to process grant")
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockGrantService - мок для доступа к чужим играм и реплеям
+type MockGrantService struct {
+	mock.Mock
+}
+
+func (m *MockGrantService) GrantGame(ctx context.Context, gameID, ownerID uuid.UUID, req models.GrantRequest) (*models.Grant, error) {
+	args := m.Called(ctx, gameID, ownerID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Grant), args.Error(1)
+}
+
+func (m *MockGrantService) GrantReplay(ctx context.Context, replayID, ownerID uuid.UUID, req models.GrantRequest) (*models.Grant, error) {
+	args := m.Called(ctx, replayID, ownerID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Grant), args.Error(1)
+}
+
+func (m *MockGrantService) GetGrants(ctx context.Context, ownerID uuid.UUID) ([]models.Grant, error) {
+	args := m.Called(ctx, ownerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Grant), args.Error(1)
+}
+
+func (m *MockGrantService) GetInvitations(ctx context.Context, userID uuid.UUID) ([]models.Grant, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Grant), args.Error(1)
+}
+
+func (m *MockGrantService) AcceptInvitation(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error) {
+	args := m.Called(ctx, grantID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Grant), args.Error(1)
+}
+
+func (m *MockGrantService) DeclineInvitation(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error) {
+	args := m.Called(ctx, grantID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Grant), args.Error(1)
+}
+
+func (m *MockGrantService) DeleteGrant(ctx context.Context, grantID, userID uuid.UUID) error {
+	args := m.Called(ctx, grantID, userID)
+	return args.Error(0)
+}
+
+func (m *MockGrantService) GetSharedGames(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Game), args.Error(1)
+}
+
+func (m *MockGrantService) GetSharedReplays(ctx context.Context, userID uuid.UUID) ([]models.Replay, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Replay), args.Error(1)
+}
+
+func setupGrantRouter(service *MockGrantService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	handler := NewGrantHandler(service)
+	router.POST("/games/:game_id/grants", handler.GrantGame)
+	router.POST("/invitations/:grant_id/accept", handler.AcceptInvitation)
+	router.POST("/invitations/:grant_id/decline", handler.DeclineInvitation)
+	router.GET("/shared/games", handler.GetSharedGames)
+	return router
+}
+
+// TestGrantGame_Success проверяет приглашение в игру по логину
+func TestGrantGame_Success(t *testing.T) {
+	mockGrantService := new(MockGrantService)
+	userID, gameID := uuid.New(), uuid.New()
+	router := setupGrantRouter(mockGrantService, userID)
+
+	req := models.GrantRequest{Login: "coach", Role: models.RoleCommenter}
+	grant := &models.Grant{ID: uuid.New(), GameID: &gameID, GranteeLogin: "coach", Role: models.RoleCommenter, Status: models.GrantPending}
+	mockGrantService.On("GrantGame", mock.Anything, gameID, userID, req).Return(grant, nil)
+
+	body := `{"login": "coach", "role": "commenter"}`
+	httpReq, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/grants", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "pending", response["status"])
+	assert.Equal(t, "coach", response["grantee_login"])
+	mockGrantService.AssertExpectations(t)
+}
+
+// TestGrantGame_Errors проверяет коды ответов на ошибки приглашения
+func TestGrantGame_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "invalid", err: services.ErrInvalidGrant, code: http.StatusBadRequest},
+		{name: "unknown user", err: services.ErrUserNotFound, code: http.StatusNotFound},
+		{name: "not owner", err: services.ErrGameNotFound, code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGrantService := new(MockGrantService)
+			router := setupGrantRouter(mockGrantService, uuid.New())
+			mockGrantService.On("GrantGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, tt.err)
+
+			req, _ := http.NewRequest("POST", "/games/"+uuid.New().String()+"/grants", strings.NewReader(`{"login": "coach", "role": "viewer"}`))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+// TestRespondInvitation проверяет принятие и отклонение приглашения и ответ на уже отвеченное
+func TestRespondInvitation(t *testing.T) {
+	mockGrantService := new(MockGrantService)
+	userID, grantID := uuid.New(), uuid.New()
+	router := setupGrantRouter(mockGrantService, userID)
+
+	mockGrantService.On("AcceptInvitation", mock.Anything, grantID, userID).
+		Return(&models.Grant{ID: grantID, Status: models.GrantAccepted}, nil)
+	mockGrantService.On("DeclineInvitation", mock.Anything, grantID, userID).
+		Return(nil, services.ErrInvitationNotFound)
+
+	req, _ := http.NewRequest("POST", "/invitations/"+grantID.String()+"/accept", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"accepted"`)
+
+	req, _ = http.NewRequest("POST", "/invitations/"+grantID.String()+"/decline", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestGetSharedGames проверяет, что в списке чужих игр есть роль и владелец
+func TestGetSharedGames(t *testing.T) {
+	mockGrantService := new(MockGrantService)
+	userID := uuid.New()
+	router := setupGrantRouter(mockGrantService, userID)
+
+	games := []models.Game{{ID: uuid.New(), Name: "Dota 2", Role: models.RoleViewer, OwnerLogin: "player"}}
+	mockGrantService.On("GetSharedGames", mock.Anything, userID).Return(games, nil)
+
+	req, _ := http.NewRequest("GET", "/shared/games", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+	assert.Contains(t, w.Body.String(), `"owner_login":"player"`)
+}
@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
+	"net/url"
+	"time"
 
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
 	"github.com/google/uuid"
 )
 
 // GameServiceInterface определяет методы для работы с играми
 type GameServiceInterface interface {
-	GetUserGames(ctx context.Context, userID uuid.UUID) ([]models.Game, error)
+	GetUserGames(ctx context.Context, userID uuid.UUID, filter models.GameFilter, sort models.Sort, cursor string, limit int) (*models.GamePage, error)
 	CreateGame(ctx context.Context, userID uuid.UUID, name string) (*models.Game, error)
 	UpdateGame(ctx context.Context, gameID, userID uuid.UUID, name string) error
 	DeleteGame(ctx context.Context, gameID, userID uuid.UUID) error
@@ -18,10 +22,98 @@ type GameServiceInterface interface {
 
 // ReplayServiceInterface определяет методы для работы с реплеями
 type ReplayServiceInterface interface {
-	GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, limit int) ([]models.Replay, error)
+	GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error)
+	GetUserReplays(ctx context.Context, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error)
 	GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error)
 	CreateReplay(ctx context.Context, file *multipart.FileHeader, gameID, userID uuid.UUID, title, comment string) (*models.Replay, error)
 	UpdateReplay(ctx context.Context, replayID, userID uuid.UUID, title, comment *string) error
 	DeleteReplay(ctx context.Context, replayID, userID uuid.UUID) error
-	GetReplayFilePath(ctx context.Context, replayID, userID uuid.UUID) (string, string, error)
+	OpenReplayFile(ctx context.Context, replayID, userID uuid.UUID, acceptEncodings []string) (*services.ReplayFile, error)
+}
+
+// UploadServiceInterface определяет методы для resumable-загрузок
+type UploadServiceInterface interface {
+	CreateUpload(ctx context.Context, gameID, userID uuid.UUID, filename string, size int64, title, comment string) (*models.UploadSession, error)
+	GetUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.UploadSession, error)
+	AppendChunk(ctx context.Context, uploadID, gameID, userID uuid.UUID, offset int64, body io.Reader, size int64) (int64, error)
+	FinalizeUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.Replay, error)
+	AbortUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) error
+}
+
+// QuotaServiceInterface определяет методы для квот пользователей
+type QuotaServiceInterface interface {
+	GetUsage(ctx context.Context, userID uuid.UUID) (*models.QuotaUsage, error)
+}
+
+// UploadPolicyServiceInterface определяет методы для политик загрузки игр
+type UploadPolicyServiceInterface interface {
+	GetPolicy(ctx context.Context, gameID, userID uuid.UUID) (*models.UploadPolicy, error)
+	SetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID, allowedTypes []string) (*models.UploadPolicy, error)
+}
+
+// ClipServiceInterface определяет методы для вырезки фрагментов реплеев
+type ClipServiceInterface interface {
+	CreateClip(ctx context.Context, replayID, userID uuid.UUID, start, end time.Duration, title string) (*models.Replay, error)
+}
+
+// MarkerServiceInterface определяет методы для отметок на временной шкале реплеев
+type MarkerServiceInterface interface {
+	ListMarkers(ctx context.Context, replayID, userID uuid.UUID) ([]models.Marker, error)
+	CreateMarker(ctx context.Context, replayID, userID uuid.UUID, marker models.Marker) (*models.Marker, error)
+	UpdateMarker(ctx context.Context, markerID, replayID, userID uuid.UUID, marker models.Marker) (*models.Marker, error)
+	DeleteMarker(ctx context.Context, markerID, replayID, userID uuid.UUID) error
+	ExportChapters(ctx context.Context, replayID, userID uuid.UUID) ([]byte, error)
+}
+
+// CommentServiceInterface определяет методы для обсуждений реплеев
+type CommentServiceInterface interface {
+	ListComments(ctx context.Context, replayID, userID uuid.UUID, parentID *uuid.UUID, cursor string, limit int) (*models.CommentPage, error)
+	CreateComment(ctx context.Context, replayID, userID uuid.UUID, comment models.Comment) (*models.Comment, error)
+	UpdateComment(ctx context.Context, commentID, replayID, userID uuid.UUID, body string) (*models.Comment, error)
+	DeleteComment(ctx context.Context, commentID, replayID, userID uuid.UUID) error
+	ListRevisions(ctx context.Context, commentID, replayID, userID uuid.UUID) ([]models.CommentRevision, error)
+}
+
+// TagServiceInterface определяет методы для тегов пользователя
+type TagServiceInterface interface {
+	GetUserTags(ctx context.Context, userID uuid.UUID) ([]models.Tag, error)
+	CreateTag(ctx context.Context, userID uuid.UUID, name string, color *string) (*models.Tag, error)
+	UpdateTag(ctx context.Context, tagID, userID uuid.UUID, name string, color *string) (*models.Tag, error)
+	DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error
+	AssignTags(ctx context.Context, userID uuid.UUID, assignment models.TagAssignment) error
+}
+
+// SearchServiceInterface определяет методы для поиска по реплеям
+type SearchServiceInterface interface {
+	Search(ctx context.Context, userID uuid.UUID, q, cursor string, limit int) (*models.SearchPage, error)
+}
+
+// ShareServiceInterface определяет методы для публичных ссылок на реплеи и игры
+type ShareServiceInterface interface {
+	CreateReplayShare(ctx context.Context, replayID, userID uuid.UUID, opts models.ShareOptions) (*models.ShareLink, error)
+	CreateGameShare(ctx context.Context, gameID, userID uuid.UUID, opts models.ShareOptions) (*models.ShareLink, error)
+	GetUserShares(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error)
+	RevokeShare(ctx context.Context, shareID, userID uuid.UUID) error
+	OpenShare(ctx context.Context, token, password, cursor string, limit int) (*models.SharedContent, error)
+	StartSharedView(ctx context.Context, token, password string, replayID *uuid.UUID, filePath string) (*models.SignedURL, error)
+	OpenSharedFile(ctx context.Context, token, password string, replayID *uuid.UUID, acceptEncodings []string, countView bool) (*services.ReplayFile, error)
+	OpenSharedViewFile(ctx context.Context, token string, replayID *uuid.UUID, view url.Values, acceptEncodings []string) (*services.ReplayFile, error)
+}
+
+// DownloadURLServiceInterface определяет методы для подписанных ссылок на файл реплея
+type DownloadURLServiceInterface interface {
+	SignReplayDownload(ctx context.Context, replayID, userID uuid.UUID, filePath string) (*models.SignedURL, error)
+}
+
+// GrantServiceInterface определяет методы для доступа пользователей к чужим играм и реплеям
+type GrantServiceInterface interface {
+	GrantGame(ctx context.Context, gameID, ownerID uuid.UUID, req models.GrantRequest) (*models.Grant, error)
+	GrantReplay(ctx context.Context, replayID, ownerID uuid.UUID, req models.GrantRequest) (*models.Grant, error)
+	GetGrants(ctx context.Context, ownerID uuid.UUID) ([]models.Grant, error)
+	GetInvitations(ctx context.Context, userID uuid.UUID) ([]models.Grant, error)
+	AcceptInvitation(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error)
+	DeclineInvitation(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error)
+	DeleteGrant(ctx context.Context, grantID, userID uuid.UUID) error
+	GetSharedGames(ctx context.Context, userID uuid.UUID) ([]models.Game, error)
+	GetSharedReplays(ctx context.Context, userID uuid.UUID) ([]models.Replay, error)
 }
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	paramMarkerID     = "marker_id"
+	contentTypeWebVTT = "text/vtt; charset=utf-8"
+)
+
+type MarkerHandler struct {
+	markerService MarkerServiceInterface
+}
+
+func NewMarkerHandler(markerService MarkerServiceInterface) *MarkerHandler {
+	return &MarkerHandler{markerService: markerService}
+}
+
+// markerRequest - тело создания и замены отметки
+type markerRequest struct {
+	TimeMS *int64  `json:"time_ms"`
+	Frame  *int64  `json:"frame"`
+	Label  string  `json:"label"`
+	Color  *string `json:"color"`
+}
+
+func (r markerRequest) marker() models.Marker {
+	return models.Marker{TimeMS: r.TimeMS, Frame: r.Frame, Label: r.Label, Color: r.Color}
+}
+
+func (h *MarkerHandler) ListMarkers(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	markers, err := h.markerService.ListMarkers(c.Request.Context(), replayID, userID)
+	if err != nil {
+		respondMarkerError(c, err)
+		return
+	}
+
+	respondOK(c, markers)
+}
+
+func (h *MarkerHandler) CreateMarker(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	var req markerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	marker, err := h.markerService.CreateMarker(c.Request.Context(), replayID, userID, req.marker())
+	if err != nil {
+		respondMarkerError(c, err)
+		return
+	}
+
+	respondCreated(c, marker)
+}
+
+// UpdateMarker заменяет отметку целиком: момент, подпись и цвет
+func (h *MarkerHandler) UpdateMarker(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, markerID, ok := markerParams(c)
+	if !ok {
+		return
+	}
+
+	var req markerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	marker, err := h.markerService.UpdateMarker(c.Request.Context(), markerID, replayID, userID, req.marker())
+	if err != nil {
+		respondMarkerError(c, err)
+		return
+	}
+
+	respondOK(c, marker)
+}
+
+func (h *MarkerHandler) DeleteMarker(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, markerID, ok := markerParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.markerService.DeleteMarker(c.Request.Context(), markerID, replayID, userID); err != nil {
+		respondMarkerError(c, err)
+		return
+	}
+
+	respondSuccess(c, "deleted")
+}
+
+// GetChapters отдает отметки дорожкой глав WebVTT для <track kind="chapters">
+func (h *MarkerHandler) GetChapters(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	vtt, err := h.markerService.ExportChapters(c.Request.Context(), replayID, userID)
+	if err != nil {
+		respondMarkerError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, contentTypeWebVTT, vtt)
+}
+
+func markerParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	markerID, err := uuid.Parse(c.Param(paramMarkerID))
+	if err != nil {
+		respondBadRequest(c, "invalid marker_id")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return replayID, markerID, true
+}
+
+func respondMarkerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMarker):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrReplayNotFound):
+		respondNotFound(c, "replay not found")
+	case errors.Is(err, services.ErrMarkerNotFound):
+		respondNotFound(c, "marker not found")
+	case errors.Is(err, services.ErrForbidden):
+		respondForbidden(c, services.ErrForbidden.Error())
+	default:
+		respondInternalError(c, "failed to process marker")
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMarkerService - мок для отметок реплеев
+type MockMarkerService struct {
+	mock.Mock
+}
+
+func (m *MockMarkerService) ListMarkers(ctx context.Context, replayID, userID uuid.UUID) ([]models.Marker, error) {
+	args := m.Called(ctx, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Marker), args.Error(1)
+}
+
+func (m *MockMarkerService) CreateMarker(ctx context.Context, replayID, userID uuid.UUID, marker models.Marker) (*models.Marker, error) {
+	args := m.Called(ctx, replayID, userID, marker)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Marker), args.Error(1)
+}
+
+func (m *MockMarkerService) UpdateMarker(ctx context.Context, markerID, replayID, userID uuid.UUID, marker models.Marker) (*models.Marker, error) {
+	args := m.Called(ctx, markerID, replayID, userID, marker)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Marker), args.Error(1)
+}
+
+func (m *MockMarkerService) DeleteMarker(ctx context.Context, markerID, replayID, userID uuid.UUID) error {
+	args := m.Called(ctx, markerID, replayID, userID)
+	return args.Error(0)
+}
+
+func (m *MockMarkerService) ExportChapters(ctx context.Context, replayID, userID uuid.UUID) ([]byte, error) {
+	args := m.Called(ctx, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func setupMarkerRouter(service *MockMarkerService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	handler := NewMarkerHandler(service)
+	router.GET("/replays/:replay_id/markers", handler.ListMarkers)
+	router.POST("/replays/:replay_id/markers", handler.CreateMarker)
+	router.GET("/replays/:replay_id/markers.vtt", handler.GetChapters)
+	router.PUT("/replays/:replay_id/markers/:marker_id", handler.UpdateMarker)
+	router.DELETE("/replays/:replay_id/markers/:marker_id", handler.DeleteMarker)
+	return router
+}
+
+// TestCreateMarker_Success проверяет передачу полей отметки в сервис и ответ 201
+func TestCreateMarker_Success(t *testing.T) {
+	mockMarkerService := new(MockMarkerService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupMarkerRouter(mockMarkerService, userID)
+
+	timeMS, color := int64(61500), "#ff0000"
+	created := &models.Marker{ID: uuid.New(), ReplayID: replayID, TimeMS: &timeMS, Label: "retake", Color: &color, AuthorID: userID}
+	mockMarkerService.On("CreateMarker", mock.Anything, replayID, userID, models.Marker{TimeMS: &timeMS, Label: "retake", Color: &color}).
+		Return(created, nil)
+
+	req, _ := http.NewRequest("POST", "/replays/"+replayID.String()+"/markers", strings.NewReader(`{"time_ms": 61500, "label": "retake", "color": "#ff0000"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"label":"retake"`)
+	mockMarkerService.AssertExpectations(t)
+}
+
+// TestMarker_Errors проверяет коды ответа на ошибки сервиса
+func TestMarker_Errors(t *testing.T) {
+	mockMarkerService := new(MockMarkerService)
+	userID, replayID, markerID := uuid.New(), uuid.New(), uuid.New()
+	router := setupMarkerRouter(mockMarkerService, userID)
+
+	mockMarkerService.On("CreateMarker", mock.Anything, replayID, userID, mock.Anything).
+		Return(nil, fmt.Errorf("%w: label is required", services.ErrInvalidMarker))
+	mockMarkerService.On("ListMarkers", mock.Anything, replayID, userID).Return(nil, services.ErrReplayNotFound)
+	mockMarkerService.On("DeleteMarker", mock.Anything, markerID, replayID, userID).Return(services.ErrMarkerNotFound)
+
+	base := "/replays/" + replayID.String() + "/markers"
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", base, `{"time_ms": 0}`, http.StatusBadRequest},
+		{"GET", base, "", http.StatusNotFound},
+		{"DELETE", base + "/" + markerID.String(), "", http.StatusNotFound},
+		{"PUT", base + "/not-a-uuid", `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt.method+" "+tt.path)
+	}
+}
+
+// TestGetChapters проверяет отдачу дорожки глав с типом text/vtt
+func TestGetChapters(t *testing.T) {
+	mockMarkerService := new(MockMarkerService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupMarkerRouter(mockMarkerService, userID)
+
+	vtt := []byte("WEBVTT\n\nm1\n00:00:00.000 --> 00:00:10.000\nstart\n")
+	mockMarkerService.On("ExportChapters", mock.Anything, replayID, userID).Return(vtt, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/markers.vtt", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/vtt; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, string(vtt), w.Body.String())
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	querySort  = "sort"
+	queryOrder = "order"
+	dateLayout = "2006-01-02"
+)
+
+// limitParam - параметр limit запроса, по умолчанию def; не положительное число - ответ 400
+func limitParam(c *gin.Context, def int) (int, bool) {
+	limitStr := c.Query(queryLimit)
+	if limitStr == "" {
+		return def, true
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		respondBadRequest(c, "invalid limit")
+		return 0, false
+	}
+	return limit, true
+}
+
+// sortParam - сортировка из параметров sort и order, по умолчанию по полю def
+// Без order названия идут по возрастанию, остальные поля - по убыванию
+func sortParam(c *gin.Context, def string) (models.Sort, bool) {
+	sort := models.Sort{Field: def}
+	if field := c.Query(querySort); field != "" {
+		sort.Field = field
+	}
+
+	switch c.Query(queryOrder) {
+	case "":
+		sort.Desc = sort.Field != models.ReplaySortTitle && sort.Field != models.GameSortName
+	case "asc":
+	case "desc":
+		sort.Desc = true
+	default:
+		respondBadRequest(c, "order must be asc or desc")
+		return models.Sort{}, false
+	}
+	return sort, true
+}
+
+// timeParam разбирает время в RFC 3339 или дату; дата с endOfDay означает конец этого дня
+func timeParam(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected RFC 3339 time or YYYY-MM-DD", key)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func int64Param(c *gin.Context, key string) (*int64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &n, nil
+}
+
+// respondListError отвечает 400 на неверные фильтр, сортировку или курсор, иначе 500 с message
+func respondListError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidFilter), errors.Is(err, services.ErrInvalidSort):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrInvalidCursor):
+		respondBadRequest(c, services.ErrInvalidCursor.Error())
+	default:
+		respondInternalError(c, message)
+	}
+}
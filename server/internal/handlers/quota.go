@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type QuotaHandler struct {
+	quotaService QuotaServiceInterface
+}
+
+func NewQuotaHandler(quotaService QuotaServiceInterface) *QuotaHandler {
+	return &QuotaHandler{quotaService: quotaService}
+}
+
+// GetUsage возвращает использование хранилища текущим пользователем и его квоту (0 - без ограничения)
+func (h *QuotaHandler) GetUsage(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	usage, err := h.quotaService.GetUsage(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get usage")
+		return
+	}
+
+	respondOK(c, usage)
+}
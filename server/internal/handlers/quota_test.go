@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockQuotaService - мок для QuotaService
+type MockQuotaService struct {
+	mock.Mock
+}
+
+func (m *MockQuotaService) GetUsage(ctx context.Context, userID uuid.UUID) (*models.QuotaUsage, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.QuotaUsage), args.Error(1)
+}
+
+// TestGetUsage_Success проверяет, что использование и квота отдаются плоским объектом
+func TestGetUsage_Success(t *testing.T) {
+	mockQuotaService := new(MockQuotaService)
+	handler := NewQuotaHandler(mockQuotaService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/usage", handler.GetUsage)
+
+	usage := &models.QuotaUsage{BytesUsed: 512, ReplaysUsed: 3, Quota: models.Quota{MaxBytes: 1024}}
+	mockQuotaService.On("GetUsage", mock.Anything, userID).Return(usage, nil)
+
+	req, _ := http.NewRequest("GET", "/usage", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]int64
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, int64(512), response["bytes_used"])
+	assert.Equal(t, int64(3), response["replays_used"])
+	assert.Equal(t, int64(1024), response["max_bytes"])
+	assert.Equal(t, int64(0), response["max_replays"])
+	mockQuotaService.AssertExpectations(t)
+}
@@ -1,12 +1,21 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"slices"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/fckoffmw/replay-service/server/internal/tagexpr"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -18,9 +27,18 @@ const (
 	formFieldComment   = "comment"
 	queryLimit         = "limit"
 	queryDownload      = "download"
+	queryMetadata      = "metadata."
+	queryTags          = "tags"
+	queryUploadedFrom  = "uploaded_from"
+	queryUploadedTo    = "uploaded_to"
+	queryMinSize       = "min_size"
+	queryMaxSize       = "max_size"
+	queryExtension     = "ext"
+	queryCompressed    = "compressed"
 	defaultReplayLimit = 5
 )
 
+// GetReplays возвращает страницу реплеев игры
 func (h *Handler) GetReplays(c *gin.Context) {
 	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
 	gameID, err := uuid.Parse(c.Param(paramGameID))
@@ -29,20 +47,111 @@ func (h *Handler) GetReplays(c *gin.Context) {
 		return
 	}
 
-	limit := defaultReplayLimit
-	if limitStr := c.Query(queryLimit); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	filter, sort, limit, ok := replayListParams(c)
+	if !ok {
+		return
 	}
 
-	replays, err := h.replayService.GetGameReplays(c.Request.Context(), gameID, userID, limit)
+	page, err := h.replayService.GetGameReplays(c.Request.Context(), gameID, userID, filter, sort, c.Query(queryCursor), limit)
 	if err != nil {
-		respondInternalError(c, "failed to get replays")
+		respondListError(c, err, "failed to get replays")
+		return
+	}
+
+	respondOK(c, page)
+}
+
+// GetUserReplays возвращает реплеи пользователя из всех игр с теми же параметрами, что и GetReplays
+func (h *Handler) GetUserReplays(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	filter, sort, limit, ok := replayListParams(c)
+	if !ok {
 		return
 	}
 
-	respondOK(c, replays)
+	page, err := h.replayService.GetUserReplays(c.Request.Context(), userID, filter, sort, c.Query(queryCursor), limit)
+	if err != nil {
+		respondListError(c, err, "failed to get replays")
+		return
+	}
+
+	respondOK(c, page)
+}
+
+func replayListParams(c *gin.Context) (models.ReplayFilter, models.Sort, int, bool) {
+	filter, err := replayFilter(c)
+	if err != nil {
+		respondBadRequest(c, err.Error())
+		return models.ReplayFilter{}, models.Sort{}, 0, false
+	}
+	sort, ok := sortParam(c, models.ReplaySortUploadedAt)
+	if !ok {
+		return models.ReplayFilter{}, models.Sort{}, 0, false
+	}
+	limit, ok := limitParam(c, defaultReplayLimit)
+	if !ok {
+		return models.ReplayFilter{}, models.Sort{}, 0, false
+	}
+	return filter, sort, limit, true
+}
+
+// replayFilter собирает фильтр из параметров вида metadata.map=Arena&metadata.player=alice,
+// выражения над тегами tags=ranked AND NOT smurf, диапазонов uploaded_from/uploaded_to и
+// min_size/max_size, расширений ext=dem,mp4 и признака compressed
+func replayFilter(c *gin.Context) (models.ReplayFilter, error) {
+	var filter models.ReplayFilter
+	for key, values := range c.Request.URL.Query() {
+		field, ok := strings.CutPrefix(key, queryMetadata)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[field] = values[0]
+	}
+
+	tags, err := tagexpr.Parse(c.Query(queryTags))
+	if err != nil {
+		return models.ReplayFilter{}, err
+	}
+	filter.Tags = tags
+
+	if filter.UploadedFrom, err = timeParam(c, queryUploadedFrom, false); err != nil {
+		return models.ReplayFilter{}, err
+	}
+	if filter.UploadedTo, err = timeParam(c, queryUploadedTo, true); err != nil {
+		return models.ReplayFilter{}, err
+	}
+	if filter.MinSize, err = int64Param(c, queryMinSize); err != nil {
+		return models.ReplayFilter{}, err
+	}
+	if filter.MaxSize, err = int64Param(c, queryMaxSize); err != nil {
+		return models.ReplayFilter{}, err
+	}
+
+	for _, value := range c.QueryArray(queryExtension) {
+		for _, ext := range strings.Split(value, ",") {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			filter.Extensions = append(filter.Extensions, ext)
+		}
+	}
+
+	if value := c.Query(queryCompressed); value != "" {
+		compressed, err := strconv.ParseBool(value)
+		if err != nil {
+			return models.ReplayFilter{}, fmt.Errorf("invalid %s", queryCompressed)
+		}
+		filter.Compressed = &compressed
+	}
+	return filter, nil
 }
 
 func (h *Handler) GetReplay(c *gin.Context) {
@@ -72,6 +181,10 @@ func (h *Handler) CreateReplay(c *gin.Context) {
 
 	file, err := c.FormFile(formFieldFile)
 	if err != nil {
+		if isRequestTooLarge(err) {
+			respondTooLarge(c, err)
+			return
+		}
 		respondBadRequest(c, "file is required")
 		return
 	}
@@ -81,6 +194,14 @@ func (h *Handler) CreateReplay(c *gin.Context) {
 
 	replay, err := h.replayService.CreateReplay(c.Request.Context(), file, gameID, userID, title, comment)
 	if err != nil {
+		if isQuotaExceeded(err) {
+			respondQuotaExceeded(c, err)
+			return
+		}
+		if isFileTypeNotAllowed(err) {
+			respondUnsupportedType(c)
+			return
+		}
 		respondInternalError(c, "failed to create replay")
 		return
 	}
@@ -97,6 +218,10 @@ func (h *Handler) DeleteReplay(c *gin.Context) {
 	}
 
 	if err := h.replayService.DeleteReplay(c.Request.Context(), replayID, userID); err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			respondForbidden(c, services.ErrForbidden.Error())
+			return
+		}
 		respondNotFound(c, "replay not found")
 		return
 	}
@@ -124,13 +249,23 @@ func (h *Handler) UpdateReplay(c *gin.Context) {
 	}
 
 	if err := h.replayService.UpdateReplay(c.Request.Context(), replayID, userID, titlePtr, commentPtr); err != nil {
-		respondInternalError(c, "failed to update replay")
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			respondForbidden(c, services.ErrForbidden.Error())
+		case errors.Is(err, services.ErrReplayNotFound):
+			respondNotFound(c, "replay not found")
+		default:
+			respondInternalError(c, "failed to update replay")
+		}
 		return
 	}
 
 	respondSuccess(c, "updated")
 }
 
+// GetReplayFile отдает файл реплея
+// Исходные байты отдаются через http.ServeContent: Range (в том числе multipart/byteranges), 206/416,
+// If-None-Match, If-Modified-Since и If-Range. Распаковываемый на лету поток отдается целиком
 func (h *Handler) GetReplayFile(c *gin.Context) {
 	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
 	replayID, err := uuid.Parse(c.Param(paramReplayID))
@@ -139,68 +274,175 @@ func (h *Handler) GetReplayFile(c *gin.Context) {
 		return
 	}
 
-	replay, err := h.replayService.GetReplay(c.Request.Context(), replayID, userID)
-	if err != nil {
-		respondNotFound(c, "replay not found")
-		return
-	}
-
-	fullPath, ext, err := h.replayService.GetReplayFilePath(c.Request.Context(), replayID, userID)
+	acceptEncodings := parseAcceptEncoding(c.GetHeader("Accept-Encoding"))
+	replayFile, err := h.replayService.OpenReplayFile(c.Request.Context(), replayID, userID, acceptEncodings)
 	if err != nil {
+		if errors.Is(err, services.ErrChecksumMismatch) {
+			respondInternalError(c, "file integrity check failed")
+			return
+		}
 		respondNotFound(c, "file not found")
 		return
 	}
+	defer replayFile.Content.Close()
 
-	file, err := os.Open(fullPath)
-	if err != nil {
-		respondNotFound(c, "file not found")
-		return
-	}
-	defer file.Close()
+	serveReplayFile(c, replayFile, "public, max-age=31536000")
+}
 
-	contentType := getContentType(ext)
+// serveReplayFile отдает открытый файл реплея; cacheControl - политика кеширования ответа
+func serveReplayFile(c *gin.Context, replayFile *services.ReplayFile, cacheControl string) {
+	replay := replayFile.Replay
+	// Тип определен по содержимому при загрузке, расширение имени файла не учитывается
+	contentType := replay.ContentType
+	if contentType == "" {
+		contentType = filetype.Unknown
+	}
 
 	download := c.Query(queryDownload) == "true"
 
-	if download || !isVideoFile(ext) {
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", replay.OriginalName))
+	if download || !strings.HasPrefix(contentType, "video/") {
+		c.Header("Content-Disposition", contentDisposition("attachment", replay.OriginalName))
 	} else {
-		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%s", replay.OriginalName))
+		c.Header("Content-Disposition", contentDisposition("inline", replay.OriginalName))
 	}
 
+	etag := replayETag(replayFile)
+
 	c.Header("Content-Type", contentType)
-	c.Header("Accept-Ranges", "bytes")
-	c.Header("Cache-Control", "public, max-age=31536000")
+	c.Header("Cache-Control", cacheControl)
+	c.Header("Vary", "Accept-Encoding")
+	c.Header("ETag", etag)
+	if replayFile.Encoding != "" {
+		c.Header("Content-Encoding", replayFile.Encoding)
+	} else if digest := sha256Digest(replay.SHA256); digest != "" {
+		c.Header("Repr-Digest", "sha-256=:"+digest+":")
+		c.Header("Digest", "SHA-256="+digest)
+	}
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		respondInternalError(c, "failed to read file")
+	if content, ok := replayFile.Content.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", replayFile.ModTime, content)
+		return
+	}
+
+	c.Header("Accept-Ranges", "none")
+	if !replayFile.ModTime.IsZero() {
+		c.Header("Last-Modified", replayFile.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request, etag, replayFile.ModTime) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
-	c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-	io.Copy(c.Writer, file)
+	if replayFile.Size >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(replayFile.Size, 10))
+	}
+	c.Status(http.StatusOK)
+	if c.Request.Method != http.MethodHead {
+		io.Copy(c.Writer, replayFile.Content)
+	}
 }
 
-func getContentType(ext string) string {
-	contentTypes := map[string]string{
-		".mp4":  "video/mp4",
-		".webm": "video/webm",
-		".ogg":  "video/ogg",
-		".ogv":  "video/ogg",
-		".mov":  "video/quicktime",
-		".avi":  "video/x-msvideo",
-		".mkv":  "video/x-matroska",
-		".m4v":  "video/x-m4v",
+// replayETag строит сильный ETag из SHA-256 содержимого
+// Сжатое представление отличается от исходного, поэтому к нему добавляется суффикс кодировки
+// У старых реплеев без хеша используется id: файл реплея после загрузки не меняется
+func replayETag(replayFile *services.ReplayFile) string {
+	tag := replayFile.Replay.SHA256
+	if tag == "" {
+		tag = replayFile.Replay.ID.String()
+	}
+	if replayFile.Encoding != "" {
+		tag += "-" + replayFile.Encoding
 	}
+	return `"` + tag + `"`
+}
 
-	if contentType, ok := contentTypes[ext]; ok {
-		return contentType
+// sha256Digest переводит hex SHA-256 в base64 для Repr-Digest (RFC 9530) и Digest (RFC 3230)
+// Заголовки описывают исходные байты, поэтому для ответа с Content-Encoding не отправляются
+func sha256Digest(hexSum string) string {
+	sum, err := hex.DecodeString(hexSum)
+	if err != nil || len(sum) != sha256.Size {
+		return ""
 	}
-	return "application/octet-stream"
+	return base64.StdEncoding.EncodeToString(sum)
 }
 
-func isVideoFile(ext string) bool {
-	videoExts := []string{".mp4", ".webm", ".ogg", ".ogv", ".mov", ".avi", ".mkv", ".m4v"}
-	return slices.Contains(videoExts, ext)
+// notModified проверяет If-None-Match и If-Modified-Since для ответа без http.ServeContent
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !modTime.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
+
+// contentDisposition формирует заголовок по RFC 6266
+// filename - ASCII-замена для старых клиентов, filename* - точное имя в UTF-8 (RFC 8187)
+func contentDisposition(dispositionType, filename string) string {
+	var fallback strings.Builder
+	exact := true
+	for _, r := range filename {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			fallback.WriteByte('_')
+			exact = false
+			continue
+		}
+		fallback.WriteRune(r)
+	}
+
+	header := fmt.Sprintf(`%s; filename="%s"`, dispositionType, fallback.String())
+	if !exact {
+		header += "; filename*=UTF-8''" + encodeRFC8187(filename)
+	}
+	return header
+}
+
+// encodeRFC8187 процентно кодирует все байты, кроме attr-char из RFC 8187
+func encodeRFC8187(value string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.IndexByte(attrChars, ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// parseAcceptEncoding возвращает content-coding из Accept-Encoding, которые клиент принимает (q > 0)
+func parseAcceptEncoding(header string) []string {
+	var encodings []string
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q <= 0 {
+				continue
+			}
+		}
+		encodings = append(encodings, coding)
+	}
+	return encodings
 }
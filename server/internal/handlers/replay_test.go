@@ -3,16 +3,24 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/fckoffmw/replay-service/server/internal/tagexpr"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // TestGetReplays_Success проверяет получение списка реплеев игры
@@ -37,7 +45,8 @@ func TestGetReplays_Success(t *testing.T) {
 		{ID: uuid.New(), OriginalName: "replay2.rep", GameID: gameID},
 	}
 
-	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, 5).Return(expectedReplays, nil)
+	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, models.ReplayFilter{}, newestFirst, "", 5).
+		Return(&models.ReplayPage{Replays: expectedReplays, Total: 2}, nil)
 
 	req, _ := http.NewRequest("GET", "/games/"+gameID.String()+"/replays", nil)
 	w := httptest.NewRecorder()
@@ -46,9 +55,10 @@ func TestGetReplays_Success(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var response []models.Replay
+	var response models.ReplayPage
 	json.Unmarshal(w.Body.Bytes(), &response)
-	assert.Equal(t, 2, len(response))
+	assert.Equal(t, 2, len(response.Replays))
+	assert.Equal(t, 2, response.Total)
 
 	mockReplayService.AssertExpectations(t)
 }
@@ -75,7 +85,8 @@ func TestGetReplays_WithLimit(t *testing.T) {
 	}
 
 	// Проверяем, что передается правильный лимит
-	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, 10).Return(expectedReplays, nil)
+	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, models.ReplayFilter{}, newestFirst, "", 10).
+		Return(&models.ReplayPage{Replays: expectedReplays, Total: 1}, nil)
 
 	req, _ := http.NewRequest("GET", "/games/"+gameID.String()+"/replays?limit=10", nil)
 	w := httptest.NewRecorder()
@@ -402,25 +413,53 @@ func TestGetReplayFile_Success(t *testing.T) {
 
 	router.GET("/replays/:replay_id/file", handler.GetReplayFile)
 
-	replay := &models.Replay{
-		ID:           replayID,
-		OriginalName: "game.rep",
+	content := []byte("replay file content")
+	replayFile := &services.ReplayFile{
+		Replay:  &models.Replay{ID: replayID, OriginalName: "game.rep"},
+		Content: io.NopCloser(bytes.NewReader(content)),
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
 	}
 
-	// Создаем временный файл для теста
-	tmpFile := "/tmp/test_replay_" + replayID.String() + ".rep"
-	// Примечание: в реальном тесте нужно создать файл, но для демонстрации пропустим
+	mockReplayService.On("OpenReplayFile", mock.Anything, replayID, userID, mock.Anything).Return(replayFile, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/file", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
 
-	mockReplayService.On("GetReplay", mock.Anything, replayID, userID).Return(replay, nil)
-	mockReplayService.On("GetReplayFilePath", mock.Anything, replayID, userID).Return(tmpFile, ".rep", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	mockReplayService.AssertExpectations(t)
+}
+
+// TestGetReplayFile_NotFound проверяет ответ, когда файла нет в хранилище
+func TestGetReplayFile_NotFound(t *testing.T) {
+	mockGameService := &MockGameService{}
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(mockGameService, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	replayID := uuid.New()
+
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+
+	router.GET("/replays/:replay_id/file", handler.GetReplayFile)
+
+	mockReplayService.On("OpenReplayFile", mock.Anything, replayID, userID, mock.Anything).Return(nil, assert.AnError)
 
 	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/file", nil)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
 
-	// Файл не существует, поэтому ожидаем 404
-	// В реальном окружении файл бы существовал
 	assert.Equal(t, http.StatusNotFound, w.Code)
 
 	mockReplayService.AssertExpectations(t)
@@ -453,5 +492,449 @@ func TestGetReplayFile_InvalidReplayID(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &response)
 	assert.Contains(t, response["error"], "invalid replay_id")
 
-	mockReplayService.AssertNotCalled(t, "GetReplay")
+	mockReplayService.AssertNotCalled(t, "OpenReplayFile")
+}
+
+// TestGetReplayFile_PassThroughEncoding проверяет отдачу сжатых байтов клиенту, принимающему кодировку
+func TestGetReplayFile_PassThroughEncoding(t *testing.T) {
+	mockGameService := &MockGameService{}
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(mockGameService, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	replayID := uuid.New()
+
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+
+	router.GET("/replays/:replay_id/file", handler.GetReplayFile)
+
+	compressed := []byte("zstd frame")
+	replayFile := &services.ReplayFile{
+		Replay:   &models.Replay{ID: replayID, OriginalName: "game.dem", Compression: "zstd", Compressed: true},
+		Content:  io.NopCloser(bytes.NewReader(compressed)),
+		Size:     int64(len(compressed)),
+		Encoding: "zstd",
+	}
+
+	mockReplayService.On("OpenReplayFile", mock.Anything, replayID, userID, []string{"gzip", "zstd"}).Return(replayFile, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/file", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br;q=0, zstd;q=0.8")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "zstd", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, compressed, w.Body.Bytes())
+
+	mockReplayService.AssertExpectations(t)
+}
+
+// nopReadSeekCloser добавляет Close к io.ReadSeeker
+// Зачем: хранилище отдает несжатые файлы с поддержкой Seek, от этого зависит обработка Range
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error { return nil }
+
+// serveSeekableReplayFile выполняет запрос к GetReplayFile для несжатого файла с заданными заголовками
+func serveSeekableReplayFile(replay *models.Replay, content []byte, modTime time.Time, headers map[string]string) *httptest.ResponseRecorder {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/replays/:replay_id/file", handler.GetReplayFile)
+
+	replayFile := &services.ReplayFile{
+		Replay:  replay,
+		Content: nopReadSeekCloser{bytes.NewReader(content)},
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}
+	mockReplayService.On("OpenReplayFile", mock.Anything, replay.ID, userID, mock.Anything).Return(replayFile, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replay.ID.String()+"/file", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestGetReplayFile_SingleRange проверяет ответ 206 на одиночный диапазон
+func TestGetReplayFile_SingleRange(t *testing.T) {
+	replay := &models.Replay{ID: uuid.New(), OriginalName: "clip.mp4", ContentType: "video/mp4", SHA256: "abc123"}
+
+	w := serveSeekableReplayFile(replay, []byte("0123456789"), time.Now(), map[string]string{"Range": "bytes=2-5"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "2345", w.Body.String())
+	assert.Equal(t, "bytes 2-5/10", w.Header().Get("Content-Range"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "inline")
+}
+
+// TestGetReplayFile_MultiRange проверяет ответ multipart/byteranges на несколько диапазонов
+func TestGetReplayFile_MultiRange(t *testing.T) {
+	replay := &models.Replay{ID: uuid.New(), OriginalName: "clip.mp4", ContentType: "video/mp4", SHA256: "abc123"}
+
+	w := serveSeekableReplayFile(replay, []byte("0123456789"), time.Now(), map[string]string{"Range": "bytes=0-1,8-9"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges"))
+	assert.Contains(t, w.Body.String(), "Content-Range: bytes 0-1/10")
+	assert.Contains(t, w.Body.String(), "Content-Range: bytes 8-9/10")
+}
+
+// TestGetReplayFile_RangeNotSatisfiable проверяет 416 для диапазона за концом файла
+func TestGetReplayFile_RangeNotSatisfiable(t *testing.T) {
+	replay := &models.Replay{ID: uuid.New(), OriginalName: "clip.mp4", ContentType: "video/mp4", SHA256: "abc123"}
+
+	w := serveSeekableReplayFile(replay, []byte("0123456789"), time.Now(), map[string]string{"Range": "bytes=20-30"})
+
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */10", w.Header().Get("Content-Range"))
+}
+
+// TestGetReplayFile_IfNoneMatch проверяет 304, если у клиента актуальная версия
+func TestGetReplayFile_IfNoneMatch(t *testing.T) {
+	replay := &models.Replay{ID: uuid.New(), OriginalName: "game.rep", SHA256: "abc123"}
+
+	w := serveSeekableReplayFile(replay, []byte("content"), time.Now(), map[string]string{"If-None-Match": `"other", "abc123"`})
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+}
+
+// TestGetReplayFile_IfModifiedSince проверяет 304 по дате изменения
+func TestGetReplayFile_IfModifiedSince(t *testing.T) {
+	replay := &models.Replay{ID: uuid.New(), OriginalName: "game.rep"}
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	w := serveSeekableReplayFile(replay, []byte("content"), modTime, map[string]string{
+		"If-Modified-Since": modTime.Add(time.Hour).Format(http.TimeFormat),
+	})
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"`+replay.ID.String()+`"`, w.Header().Get("ETag"), "без хеша ETag строится из id")
+}
+
+// TestGetReplayFile_DecodedIfNoneMatch проверяет 304 для потока, распаковываемого на лету
+func TestGetReplayFile_DecodedIfNoneMatch(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	replayID := uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/replays/:replay_id/file", handler.GetReplayFile)
+
+	replayFile := &services.ReplayFile{
+		Replay:  &models.Replay{ID: replayID, OriginalName: "game.dem", SHA256: "abc123", Compressed: true},
+		Content: io.NopCloser(strings.NewReader("decoded")),
+		Size:    7,
+	}
+	mockReplayService.On("OpenReplayFile", mock.Anything, replayID, userID, mock.Anything).Return(replayFile, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/file", nil)
+	req.Header.Set("If-None-Match", `"abc123"`)
+	req.Header.Set("Range", "bytes=0-1")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, "none", w.Header().Get("Accept-Ranges"))
+}
+
+// TestContentDisposition проверяет заголовок для ASCII и не-ASCII имен файлов
+func TestContentDisposition(t *testing.T) {
+	assert.Equal(t, `attachment; filename="game.rep"`, contentDisposition("attachment", "game.rep"))
+	assert.Equal(t,
+		`inline; filename="____ 1.mp4"; filename*=UTF-8''%D0%BC%D0%B0%D1%82%D1%87%201.mp4`,
+		contentDisposition("inline", "матч 1.mp4"))
+	assert.Equal(t,
+		`attachment; filename="a_b_.rep"; filename*=UTF-8''a%22b%5C.rep`,
+		contentDisposition("attachment", `a"b\.rep`))
+}
+
+// TestGetReplayFile_ReprDigest проверяет заголовки Repr-Digest и Digest для исходных байтов
+func TestGetReplayFile_ReprDigest(t *testing.T) {
+	// SHA-256 строки "test"
+	replay := &models.Replay{
+		ID:           uuid.New(),
+		OriginalName: "game.rep",
+		SHA256:       "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+
+	w := serveSeekableReplayFile(replay, []byte("test"), time.Now(), nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "sha-256=:n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=:", w.Header().Get("Repr-Digest"))
+	assert.Equal(t, "SHA-256=n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=", w.Header().Get("Digest"))
+}
+
+// TestGetReplayFile_ChecksumMismatch проверяет ответ 500, если файл не прошел проверку хеша
+func TestGetReplayFile_ChecksumMismatch(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	replayID := uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/replays/:replay_id/file", handler.GetReplayFile)
+
+	mockReplayService.On("OpenReplayFile", mock.Anything, replayID, userID, mock.Anything).
+		Return(nil, fmt.Errorf("failed to verify replay file: %w", services.ErrChecksumMismatch))
+
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/file", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// TestCreateReplay_QuotaExceeded проверяет, что превышение квоты отдается как 507
+func TestCreateReplay_QuotaExceeded(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	gameID := uuid.New()
+
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.POST("/games/:game_id/replays", handler.CreateReplay)
+
+	mockReplayService.On("CreateReplay", mock.Anything, mock.Anything, gameID, userID, "", "").
+		Return(nil, fmt.Errorf("check quota: %w", services.ErrQuotaReplaysExceeded))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.rep")
+	part.Write([]byte("fake replay data"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/replays", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, services.ErrQuotaReplaysExceeded.Error(), response["error"])
+}
+
+// TestCreateReplay_FileTypeNotAllowed проверяет, что запрещенный политикой формат отдается как 415
+func TestCreateReplay_FileTypeNotAllowed(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	gameID := uuid.New()
+
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.POST("/games/:game_id/replays", handler.CreateReplay)
+
+	mockReplayService.On("CreateReplay", mock.Anything, mock.Anything, gameID, userID, "", "").
+		Return(nil, fmt.Errorf("check file type: %w: application/octet-stream", services.ErrFileTypeNotAllowed))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "fake.mp4")
+	part.Write([]byte("not a video"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/replays", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// TestGetReplayFile_ContentTypeIgnoresExtension проверяет, что тип ответа берется из сохраненного типа, а не из имени
+func TestGetReplayFile_ContentTypeIgnoresExtension(t *testing.T) {
+	replay := &models.Replay{ID: uuid.New(), OriginalName: "clip.mp4", ContentType: "application/x-sc2-replay"}
+
+	w := serveSeekableReplayFile(replay, []byte("MPQ\x1b"), time.Now(), nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-sc2-replay", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+}
+
+// TestGetReplays_MetadataFilter проверяет передачу фильтров metadata.* в сервис
+func TestGetReplays_MetadataFilter(t *testing.T) {
+	mockGameService := &MockGameService{}
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(mockGameService, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	gameID := uuid.New()
+
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/games/:game_id/replays", handler.GetReplays)
+
+	filter := models.ReplayFilter{Metadata: map[string]string{"map": "de_inferno", "player": "alice"}}
+	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, filter, newestFirst, "", 5).Return(&models.ReplayPage{}, nil)
+	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, models.ReplayFilter{Metadata: map[string]string{"unknown": "x"}}, newestFirst, "", 5).
+		Return(nil, fmt.Errorf("%w: unknown metadata field", services.ErrInvalidFilter))
+
+	req, _ := http.NewRequest("GET", "/games/"+gameID.String()+"/replays?metadata.map=de_inferno&metadata.player=alice", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/games/"+gameID.String()+"/replays?metadata.unknown=x", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockReplayService.AssertExpectations(t)
+}
+
+// TestGetReplays_TagsFilter проверяет разбор выражения tags и ответ 400 на ошибку в нем
+func TestGetReplays_TagsFilter(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID, gameID := uuid.New(), uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/games/:game_id/replays", handler.GetReplays)
+
+	expr, err := tagexpr.Parse("ranked AND NOT smurf")
+	require.NoError(t, err)
+	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, models.ReplayFilter{Tags: expr}, newestFirst, "", 5).
+		Return(&models.ReplayPage{Replays: []models.Replay{{ID: uuid.New(), Tags: []string{"ranked"}}}, Total: 1}, nil)
+
+	req, _ := http.NewRequest("GET", "/games/"+gameID.String()+"/replays?tags="+url.QueryEscape("Ranked and not smurf"), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"tags":["ranked"]`)
+
+	req, _ = http.NewRequest("GET", "/games/"+gameID.String()+"/replays?tags="+url.QueryEscape("ranked AND (smurf"), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockReplayService.AssertExpectations(t)
+}
+
+// TestGetUserReplays_ListParams проверяет, что список по всем играм принимает параметры списка игры
+func TestGetUserReplays_ListParams(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/replays", handler.GetUserReplays)
+
+	expr, err := tagexpr.Parse("ranked AND NOT smurf")
+	require.NoError(t, err)
+	mockReplayService.On("GetUserReplays", mock.Anything, userID, models.ReplayFilter{Tags: expr}, newestFirst, "", 5).
+		Return(&models.ReplayPage{Replays: []models.Replay{{ID: uuid.New(), GameName: "Dota 2"}}, Total: 1}, nil)
+
+	req, _ := http.NewRequest("GET", "/replays?tags="+url.QueryEscape("Ranked and not smurf"), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"game_name":"Dota 2"`)
+
+	req, _ = http.NewRequest("GET", "/replays?tags="+url.QueryEscape("ranked AND (smurf"), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockReplayService.AssertExpectations(t)
+}
+
+var newestFirst = models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+
+// TestGetReplays_ListParams проверяет разбор сортировки, курсора и фильтров диапазонов, расширений и сжатия
+func TestGetReplays_ListParams(t *testing.T) {
+	mockReplayService := new(MockReplayService)
+	handler := NewHandler(&MockGameService{}, mockReplayService)
+
+	router := setupTestRouter()
+	userID, gameID := uuid.New(), uuid.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/games/:game_id/replays", handler.GetReplays)
+
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	minSize, compressed := int64(1024), false
+	filter := models.ReplayFilter{
+		UploadedFrom: &from,
+		UploadedTo:   &to,
+		MinSize:      &minSize,
+		Extensions:   []string{".dem", ".mp4"},
+		Compressed:   &compressed,
+	}
+	mockReplayService.On("GetGameReplays", mock.Anything, gameID, userID, filter, models.Sort{Field: models.ReplaySortTitle}, "abc", 50).
+		Return(&models.ReplayPage{NextCursor: "def"}, nil)
+
+	query := "?sort=title&cursor=abc&limit=50&uploaded_from=2025-11-01&uploaded_to=2025-11-30&min_size=1024&ext=DEM,.mp4&compressed=false"
+	req, _ := http.NewRequest("GET", "/games/"+gameID.String()+"/replays"+query, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"def"`)
+	mockReplayService.AssertExpectations(t)
+
+	for _, query := range []string{"?limit=0", "?limit=abc", "?order=up", "?min_size=1kb", "?uploaded_from=yesterday", "?compressed=maybe"} {
+		req, _ := http.NewRequest("GET", "/games/"+gameID.String()+"/replays"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
 }
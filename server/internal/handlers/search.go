@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	querySearch        = "q"
+	defaultSearchLimit = 20
+)
+
+type SearchHandler struct {
+	searchService SearchServiceInterface
+}
+
+func NewSearchHandler(searchService SearchServiceInterface) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search возвращает страницу реплеев, найденных по запросу q, от самых подходящих
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	limit, ok := limitParam(c, defaultSearchLimit)
+	if !ok {
+		return
+	}
+
+	page, err := h.searchService.Search(c.Request.Context(), userID, c.Query(querySearch), c.Query(queryCursor), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSearchQuery):
+			respondBadRequest(c, err.Error())
+		case errors.Is(err, services.ErrInvalidCursor):
+			respondBadRequest(c, services.ErrInvalidCursor.Error())
+		default:
+			respondInternalError(c, "failed to search replays")
+		}
+		return
+	}
+
+	respondOK(c, page)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSearchService - мок для поиска по реплеям
+type MockSearchService struct {
+	mock.Mock
+}
+
+func (m *MockSearchService) Search(ctx context.Context, userID uuid.UUID, q, cursor string, limit int) (*models.SearchPage, error) {
+	args := m.Called(ctx, userID, q, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SearchPage), args.Error(1)
+}
+
+func setupSearchRouter(service *MockSearchService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.GET("/search", NewSearchHandler(service).Search)
+	return router
+}
+
+// TestSearch_Success проверяет передачу запроса, курсора и limit в сервис
+func TestSearch_Success(t *testing.T) {
+	mockSearchService := new(MockSearchService)
+	userID := uuid.New()
+	router := setupSearchRouter(mockSearchService, userID)
+
+	page := &models.SearchPage{
+		Results: []models.SearchResult{{ID: uuid.New(), OriginalName: "inferno.dem", Snippet: "<mark>inferno</mark> dem"}},
+		Total:   1,
+	}
+	mockSearchService.On("Search", mock.Anything, userID, "inferno", "abc", 10).Return(page, nil)
+
+	req, _ := http.NewRequest("GET", "/search?q=inferno&cursor=abc&limit=10", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+	mockSearchService.AssertExpectations(t)
+}
+
+// TestSearch_Errors проверяет ответ 400 на пустой запрос и испорченный курсор
+func TestSearch_Errors(t *testing.T) {
+	mockSearchService := new(MockSearchService)
+	userID := uuid.New()
+	router := setupSearchRouter(mockSearchService, userID)
+
+	mockSearchService.On("Search", mock.Anything, userID, "", "", defaultSearchLimit).
+		Return(nil, fmt.Errorf("%w: q is required", services.ErrInvalidSearchQuery))
+	mockSearchService.On("Search", mock.Anything, userID, "alice", "broken", defaultSearchLimit).
+		Return(nil, services.ErrInvalidCursor)
+
+	for _, path := range []string{"/search", "/search?q=alice&cursor=broken"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/fckoffmw/replay-service/server/internal/signedurl"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	paramShareID        = "share_id"
+	paramShareToken     = "token"
+	headerSharePassword = "X-Share-Password"
+	// sharePathPrefix - публичный маршрут ссылок, доступный без JWT
+	sharePathPrefix    = "/s/"
+	defaultSharedLimit = 20
+)
+
+type ShareHandler struct {
+	shareService ShareServiceInterface
+}
+
+func NewShareHandler(shareService ShareServiceInterface) *ShareHandler {
+	return &ShareHandler{shareService: shareService}
+}
+
+// shareResponse - созданная ссылка с путем, по которому ее открывает получатель
+type shareResponse struct {
+	models.ShareLink
+	URL string `json:"url"`
+}
+
+// CreateReplayShare создает публичную ссылку на реплей
+func (h *ShareHandler) CreateReplayShare(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	replayID, err := uuid.Parse(c.Param(paramReplayID))
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return
+	}
+
+	opts, ok := shareOptions(c)
+	if !ok {
+		return
+	}
+
+	link, err := h.shareService.CreateReplayShare(c.Request.Context(), replayID, userID, opts)
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	respondCreated(c, shareResponse{ShareLink: *link, URL: sharePathPrefix + link.Token})
+}
+
+// CreateGameShare создает публичную ссылку на игру со всеми ее реплеями
+func (h *ShareHandler) CreateGameShare(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, err := uuid.Parse(c.Param(paramGameID))
+	if err != nil {
+		respondBadRequest(c, "invalid game_id")
+		return
+	}
+
+	opts, ok := shareOptions(c)
+	if !ok {
+		return
+	}
+
+	link, err := h.shareService.CreateGameShare(c.Request.Context(), gameID, userID, opts)
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	respondCreated(c, shareResponse{ShareLink: *link, URL: sharePathPrefix + link.Token})
+}
+
+// GetShares возвращает ссылки пользователя; токены в списке не отдаются
+func (h *ShareHandler) GetShares(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	links, err := h.shareService.GetUserShares(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get share links")
+		return
+	}
+
+	respondOK(c, links)
+}
+
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	shareID, err := uuid.Parse(c.Param(paramShareID))
+	if err != nil {
+		respondBadRequest(c, "invalid share_id")
+		return
+	}
+
+	if err := h.shareService.RevokeShare(c.Request.Context(), shareID, userID); err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	respondSuccess(c, "revoked")
+}
+
+// GetSharedContent отдает получателю ссылки реплей или игру со страницей реплеев
+func (h *ShareHandler) GetSharedContent(c *gin.Context) {
+	limit, ok := limitParam(c, defaultSharedLimit)
+	if !ok {
+		return
+	}
+
+	content, err := h.shareService.OpenShare(c.Request.Context(), c.Param(paramShareToken),
+		c.GetHeader(headerSharePassword), c.Query(queryCursor), limit)
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	respondOK(c, content)
+}
+
+// StartSharedView засчитывает просмотр и выдает подписанную ссылку на файл для <video src>
+// Для ссылки на игру реплей задается параметром replay_id маршрута
+func (h *ShareHandler) StartSharedView(c *gin.Context) {
+	replayID, ok := sharedReplayParam(c)
+	if !ok {
+		return
+	}
+
+	// Ссылка ведет на соседний маршрут: .../views -> .../file
+	filePath := path.Join(path.Dir(c.Request.URL.Path), "file")
+
+	signed, err := h.shareService.StartSharedView(c.Request.Context(), c.Param(paramShareToken),
+		c.GetHeader(headerSharePassword), replayID, filePath)
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	respondCreated(c, signed)
+}
+
+// GetSharedFile отдает файл реплея по ссылке так же, как GetReplayFile
+// С подписью из StartSharedView запросы продолжают засчитанный просмотр;
+// без нее каждый GET засчитывается как новый просмотр
+func (h *ShareHandler) GetSharedFile(c *gin.Context) {
+	replayID, ok := sharedReplayParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	token := c.Param(paramShareToken)
+	acceptEncodings := parseAcceptEncoding(c.GetHeader("Accept-Encoding"))
+
+	var replayFile *services.ReplayFile
+	var err error
+	if query := c.Request.URL.Query(); signedurl.Signed(query) {
+		replayFile, err = h.shareService.OpenSharedViewFile(ctx, token, replayID, query, acceptEncodings)
+	} else {
+		replayFile, err = h.shareService.OpenSharedFile(ctx, token, c.GetHeader(headerSharePassword),
+			replayID, acceptEncodings, c.Request.Method == http.MethodGet)
+	}
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+	defer replayFile.Content.Close()
+
+	// Кеш перепроверяется на каждом запросе, чтобы отзыв ссылки действовал сразу
+	serveReplayFile(c, replayFile, "private, no-cache")
+}
+
+// sharedReplayParam читает необязательный replay_id маршрута ссылки на игру
+func sharedReplayParam(c *gin.Context) (*uuid.UUID, bool) {
+	param := c.Param(paramReplayID)
+	if param == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(param)
+	if err != nil {
+		respondBadRequest(c, "invalid replay_id")
+		return nil, false
+	}
+	return &id, true
+}
+
+// shareOptions читает ограничения ссылки из тела запроса; пустое тело - ссылка без ограничений
+func shareOptions(c *gin.Context) (models.ShareOptions, bool) {
+	var opts models.ShareOptions
+	if c.Request.ContentLength == 0 {
+		return opts, true
+	}
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return models.ShareOptions{}, false
+	}
+	return opts, true
+}
+
+func respondShareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidShare):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrShareNotFound):
+		respondNotFound(c, services.ErrShareNotFound.Error())
+	case errors.Is(err, services.ErrReplayNotFound):
+		respondNotFound(c, "replay not found")
+	case errors.Is(err, services.ErrGameNotFound):
+		respondNotFound(c, "game not found")
+	case errors.Is(err, services.ErrShareGone):
+		c.JSON(http.StatusGone, gin.H{"error": services.ErrShareGone.Error()})
+	case errors.Is(err, services.ErrSharePasswordRequired), errors.Is(err, services.ErrInvalidSharePassword),
+		errors.Is(err, services.ErrInvalidShareView):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSharePasswordThrottled):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrChecksumMismatch):
+		respondInternalError(c, "file integrity check failed")
+	default:
+		respondListError(c, err, "failed to process share link")
+	}
+}
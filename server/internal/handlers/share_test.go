@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockShareService - мок для публичных ссылок
+type MockShareService struct {
+	mock.Mock
+}
+
+func (m *MockShareService) CreateReplayShare(ctx context.Context, replayID, userID uuid.UUID, opts models.ShareOptions) (*models.ShareLink, error) {
+	args := m.Called(ctx, replayID, userID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ShareLink), args.Error(1)
+}
+
+func (m *MockShareService) CreateGameShare(ctx context.Context, gameID, userID uuid.UUID, opts models.ShareOptions) (*models.ShareLink, error) {
+	args := m.Called(ctx, gameID, userID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ShareLink), args.Error(1)
+}
+
+func (m *MockShareService) GetUserShares(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ShareLink), args.Error(1)
+}
+
+func (m *MockShareService) RevokeShare(ctx context.Context, shareID, userID uuid.UUID) error {
+	args := m.Called(ctx, shareID, userID)
+	return args.Error(0)
+}
+
+func (m *MockShareService) OpenShare(ctx context.Context, token, password, cursor string, limit int) (*models.SharedContent, error) {
+	args := m.Called(ctx, token, password, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SharedContent), args.Error(1)
+}
+
+func (m *MockShareService) StartSharedView(ctx context.Context, token, password string, replayID *uuid.UUID, filePath string) (*models.SignedURL, error) {
+	args := m.Called(ctx, token, password, replayID, filePath)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SignedURL), args.Error(1)
+}
+
+func (m *MockShareService) OpenSharedViewFile(ctx context.Context, token string, replayID *uuid.UUID, view url.Values, acceptEncodings []string) (*services.ReplayFile, error) {
+	args := m.Called(ctx, token, replayID, view, acceptEncodings)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.ReplayFile), args.Error(1)
+}
+
+func (m *MockShareService) OpenSharedFile(ctx context.Context, token, password string, replayID *uuid.UUID, acceptEncodings []string, countView bool) (*services.ReplayFile, error) {
+	args := m.Called(ctx, token, password, replayID, acceptEncodings, countView)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.ReplayFile), args.Error(1)
+}
+
+// setupShareRouter регистрирует маршруты владельца за тестовой авторизацией, а публичные - без нее
+func setupShareRouter(service *MockShareService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	handler := NewShareHandler(service)
+
+	router.GET("/s/:token", handler.GetSharedContent)
+	router.POST("/s/:token/views", handler.StartSharedView)
+	router.POST("/s/:token/replays/:replay_id/views", handler.StartSharedView)
+	router.GET("/s/:token/file", handler.GetSharedFile)
+	router.HEAD("/s/:token/file", handler.GetSharedFile)
+	router.GET("/s/:token/replays/:replay_id/file", handler.GetSharedFile)
+
+	authorized := router.Group("")
+	authorized.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	authorized.POST("/replays/:replay_id/shares", handler.CreateReplayShare)
+	authorized.POST("/games/:game_id/shares", handler.CreateGameShare)
+	authorized.DELETE("/shares/:share_id", handler.RevokeShare)
+	return router
+}
+
+// TestCreateReplayShare_Success проверяет создание ссылки с ограничениями и путь для получателя
+func TestCreateReplayShare_Success(t *testing.T) {
+	mockShareService := new(MockShareService)
+	userID, replayID := uuid.New(), uuid.New()
+	router := setupShareRouter(mockShareService, userID)
+
+	maxViews := 3
+	opts := models.ShareOptions{MaxViews: &maxViews, Password: "hunter2"}
+	link := &models.ShareLink{ID: uuid.New(), Token: "abc", ReplayID: &replayID, HasPassword: true, MaxViews: &maxViews}
+	mockShareService.On("CreateReplayShare", mock.Anything, replayID, userID, opts).Return(link, nil)
+
+	body := `{"max_views": 3, "password": "hunter2"}`
+	req, _ := http.NewRequest("POST", "/replays/"+replayID.String()+"/shares", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "abc", response["token"])
+	assert.Equal(t, "/s/abc", response["url"])
+	assert.Equal(t, true, response["has_password"])
+	assert.NotContains(t, w.Body.String(), "hunter2")
+	mockShareService.AssertExpectations(t)
+}
+
+// TestCreateGameShare_EmptyBody проверяет, что ссылку без ограничений можно создать без тела запроса
+func TestCreateGameShare_EmptyBody(t *testing.T) {
+	mockShareService := new(MockShareService)
+	userID, gameID := uuid.New(), uuid.New()
+	router := setupShareRouter(mockShareService, userID)
+
+	mockShareService.On("CreateGameShare", mock.Anything, gameID, userID, models.ShareOptions{}).
+		Return(nil, services.ErrGameNotFound)
+
+	req, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/shares", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockShareService.AssertExpectations(t)
+}
+
+// TestGetSharedContent_Errors проверяет коды ответов публичного маршрута
+func TestGetSharedContent_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "unknown", err: services.ErrShareNotFound, code: http.StatusNotFound},
+		{name: "gone", err: services.ErrShareGone, code: http.StatusGone},
+		{name: "password required", err: services.ErrSharePasswordRequired, code: http.StatusUnauthorized},
+		{name: "wrong password", err: services.ErrInvalidSharePassword, code: http.StatusUnauthorized},
+		{name: "throttled", err: services.ErrSharePasswordThrottled, code: http.StatusTooManyRequests},
+		{name: "bad cursor", err: services.ErrInvalidCursor, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockShareService := new(MockShareService)
+			router := setupShareRouter(mockShareService, uuid.New())
+			mockShareService.On("OpenShare", mock.Anything, "tok", "secret", "", defaultSharedLimit).Return(nil, tt.err)
+
+			req, _ := http.NewRequest("GET", "/s/tok", nil)
+			req.Header.Set(headerSharePassword, "secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func sharedTestFile() *services.ReplayFile {
+	content := bytes.Repeat([]byte("x"), 2048)
+	return &services.ReplayFile{
+		Replay:  &models.Replay{ID: uuid.New(), OriginalName: "match.mp4", ContentType: "video/mp4"},
+		Content: nopReadSeekCloser{bytes.NewReader(content)},
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+}
+
+// TestGetSharedFile_CountsView проверяет, что без ссылки просмотра любой GET засчитывается, каким бы ни был Range
+func TestGetSharedFile_CountsView(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		rangeHdr  string
+		countView bool
+	}{
+		{name: "whole file", method: "GET", countView: true},
+		{name: "from start", method: "GET", rangeHdr: "bytes=0-", countView: true},
+		{name: "from middle", method: "GET", rangeHdr: "bytes=1024-", countView: true},
+		{name: "multi range", method: "GET", rangeHdr: "bytes=1-2,0-", countView: true},
+		{name: "head", method: "HEAD", countView: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockShareService := new(MockShareService)
+			router := setupShareRouter(mockShareService, uuid.New())
+
+			mockShareService.On("OpenSharedFile", mock.Anything, "tok", "secret", (*uuid.UUID)(nil), mock.Anything, tt.countView).
+				Return(sharedTestFile(), nil)
+
+			req, _ := http.NewRequest(tt.method, "/s/tok/file", nil)
+			req.Header.Set(headerSharePassword, "secret")
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Contains(t, []int{http.StatusOK, http.StatusPartialContent}, w.Code)
+			assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+			assert.Contains(t, w.Header().Get("Content-Disposition"), "inline")
+			mockShareService.AssertExpectations(t)
+		})
+	}
+}
+
+// TestGetSharedFile_View проверяет, что запрос с подписью просмотра не проверяет пароль и не засчитывается
+func TestGetSharedFile_View(t *testing.T) {
+	mockShareService := new(MockShareService)
+	router := setupShareRouter(mockShareService, uuid.New())
+
+	view := url.Values{"expires": {"1700000000"}, "sig": {"abc"}}
+	mockShareService.On("OpenSharedViewFile", mock.Anything, "tok", (*uuid.UUID)(nil), view, mock.Anything).
+		Return(sharedTestFile(), nil).Once()
+	mockShareService.On("OpenSharedViewFile", mock.Anything, "tok", (*uuid.UUID)(nil), view, mock.Anything).
+		Return(nil, services.ErrInvalidShareView).Once()
+
+	req, _ := http.NewRequest("GET", "/s/tok/file?"+view.Encode(), nil)
+	req.Header.Set("Range", "bytes=1024-")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockShareService.AssertNotCalled(t, "OpenSharedFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockShareService.AssertExpectations(t)
+}
+
+// TestStartSharedView_Success проверяет, что ссылка просмотра ведет на соседний маршрут файла
+func TestStartSharedView_Success(t *testing.T) {
+	mockShareService := new(MockShareService)
+	router := setupShareRouter(mockShareService, uuid.New())
+	replayID := uuid.New()
+
+	filePath := "/s/tok/replays/" + replayID.String() + "/file"
+	signed := &models.SignedURL{URL: filePath + "?expires=1&sig=abc", ExpiresAt: time.Now().Add(time.Minute)}
+	mockShareService.On("StartSharedView", mock.Anything, "tok", "secret", &replayID, filePath).Return(signed, nil)
+	mockShareService.On("StartSharedView", mock.Anything, "tok", "secret", (*uuid.UUID)(nil), "/s/tok/file").
+		Return(nil, services.ErrShareGone)
+
+	req, _ := http.NewRequest("POST", "/s/tok/replays/"+replayID.String()+"/views", nil)
+	req.Header.Set(headerSharePassword, "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, signed.URL, response["url"])
+
+	req, _ = http.NewRequest("POST", "/s/tok/views", nil)
+	req.Header.Set(headerSharePassword, "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	mockShareService.AssertExpectations(t)
+}
+
+// TestGetSharedFile_GameReplay проверяет передачу replay_id для ссылки на игру
+func TestGetSharedFile_GameReplay(t *testing.T) {
+	mockShareService := new(MockShareService)
+	router := setupShareRouter(mockShareService, uuid.New())
+	replayID := uuid.New()
+
+	mockShareService.On("OpenSharedFile", mock.Anything, "tok", "", &replayID, mock.Anything, true).
+		Return(nil, services.ErrReplayNotFound)
+
+	req, _ := http.NewRequest("GET", "/s/tok/replays/"+replayID.String()+"/file", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockShareService.AssertExpectations(t)
+}
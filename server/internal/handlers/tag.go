@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const paramTagID = "tag_id"
+
+type TagHandler struct {
+	tagService TagServiceInterface
+}
+
+func NewTagHandler(tagService TagServiceInterface) *TagHandler {
+	return &TagHandler{tagService: tagService}
+}
+
+type tagRequest struct {
+	Name  string  `json:"name" binding:"required"`
+	Color *string `json:"color"`
+}
+
+// GetTags возвращает теги пользователя со счетчиками реплеев и игр
+func (h *TagHandler) GetTags(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	tags, err := h.tagService.GetUserTags(c.Request.Context(), userID)
+	if err != nil {
+		respondInternalError(c, "failed to get tags")
+		return
+	}
+
+	respondOK(c, tags)
+}
+
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "name is required")
+		return
+	}
+
+	tag, err := h.tagService.CreateTag(c.Request.Context(), userID, req.Name, req.Color)
+	if err != nil {
+		respondTagError(c, err)
+		return
+	}
+
+	respondCreated(c, tag)
+}
+
+// UpdateTag переименовывает тег и задает ему цвет
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	tagID, err := uuid.Parse(c.Param(paramTagID))
+	if err != nil {
+		respondBadRequest(c, "invalid tag_id")
+		return
+	}
+
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "name is required")
+		return
+	}
+
+	tag, err := h.tagService.UpdateTag(c.Request.Context(), tagID, userID, req.Name, req.Color)
+	if err != nil {
+		respondTagError(c, err)
+		return
+	}
+
+	respondOK(c, tag)
+}
+
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	tagID, err := uuid.Parse(c.Param(paramTagID))
+	if err != nil {
+		respondBadRequest(c, "invalid tag_id")
+		return
+	}
+
+	if err := h.tagService.DeleteTag(c.Request.Context(), tagID, userID); err != nil {
+		respondTagError(c, err)
+		return
+	}
+
+	respondSuccess(c, "deleted")
+}
+
+// AssignTags навешивает и снимает теги сразу у нескольких реплеев и игр
+func (h *TagHandler) AssignTags(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+
+	var req models.TagAssignment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	if err := h.tagService.AssignTags(c.Request.Context(), userID, req); err != nil {
+		respondTagError(c, err)
+		return
+	}
+
+	respondSuccess(c, "updated")
+}
+
+func respondTagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTag):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrTagNotFound):
+		respondNotFound(c, "tag not found")
+	case errors.Is(err, services.ErrTagTargetNotFound):
+		respondNotFound(c, services.ErrTagTargetNotFound.Error())
+	case errors.Is(err, services.ErrTagAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		respondInternalError(c, "failed to process tags")
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTagService - мок для тегов пользователя
+type MockTagService struct {
+	mock.Mock
+}
+
+func (m *MockTagService) GetUserTags(ctx context.Context, userID uuid.UUID) ([]models.Tag, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockTagService) CreateTag(ctx context.Context, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	args := m.Called(ctx, userID, name, color)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockTagService) UpdateTag(ctx context.Context, tagID, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	args := m.Called(ctx, tagID, userID, name, color)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockTagService) DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error {
+	args := m.Called(ctx, tagID, userID)
+	return args.Error(0)
+}
+
+func (m *MockTagService) AssignTags(ctx context.Context, userID uuid.UUID, assignment models.TagAssignment) error {
+	args := m.Called(ctx, userID, assignment)
+	return args.Error(0)
+}
+
+func setupTagRouter(service *MockTagService, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	handler := NewTagHandler(service)
+	router.GET("/tags", handler.GetTags)
+	router.POST("/tags", handler.CreateTag)
+	router.POST("/tags/assign", handler.AssignTags)
+	router.PUT("/tags/:tag_id", handler.UpdateTag)
+	router.DELETE("/tags/:tag_id", handler.DeleteTag)
+	return router
+}
+
+// TestAssignTags_Success проверяет передачу массового изменения тегов в сервис
+func TestAssignTags_Success(t *testing.T) {
+	mockTagService := new(MockTagService)
+	userID, replayID, gameID := uuid.New(), uuid.New(), uuid.New()
+	router := setupTagRouter(mockTagService, userID)
+
+	assignment := models.TagAssignment{
+		ReplayIDs: []uuid.UUID{replayID},
+		GameIDs:   []uuid.UUID{gameID},
+		Add:       []string{"ranked"},
+		Remove:    []string{"todo"},
+	}
+	mockTagService.On("AssignTags", mock.Anything, userID, assignment).Return(nil)
+
+	body := fmt.Sprintf(`{"replay_ids": ["%s"], "game_ids": ["%s"], "add": ["ranked"], "remove": ["todo"]}`, replayID, gameID)
+	req, _ := http.NewRequest("POST", "/tags/assign", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockTagService.AssertExpectations(t)
+}
+
+// TestTag_Errors проверяет коды ответа на ошибки сервиса
+func TestTag_Errors(t *testing.T) {
+	mockTagService := new(MockTagService)
+	userID, tagID := uuid.New(), uuid.New()
+	router := setupTagRouter(mockTagService, userID)
+
+	mockTagService.On("CreateTag", mock.Anything, userID, "bad name", (*string)(nil)).
+		Return(nil, fmt.Errorf("%w: invalid character", services.ErrInvalidTag))
+	mockTagService.On("UpdateTag", mock.Anything, tagID, userID, "ranked", (*string)(nil)).
+		Return(nil, fmt.Errorf("%w: ranked", services.ErrTagAlreadyExists))
+	mockTagService.On("DeleteTag", mock.Anything, tagID, userID).Return(services.ErrTagNotFound)
+	mockTagService.On("AssignTags", mock.Anything, userID, mock.Anything).Return(services.ErrTagTargetNotFound)
+
+	tests := []struct {
+		method, path, body string
+		code               int
+	}{
+		{"POST", "/tags", `{"name": "bad name"}`, http.StatusBadRequest},
+		{"POST", "/tags", `{}`, http.StatusBadRequest},
+		{"PUT", "/tags/" + tagID.String(), `{"name": "ranked"}`, http.StatusConflict},
+		{"PUT", "/tags/not-a-uuid", `{"name": "ranked"}`, http.StatusBadRequest},
+		{"DELETE", "/tags/" + tagID.String(), "", http.StatusNotFound},
+		{"POST", "/tags/assign", `{"replay_ids": ["` + uuid.NewString() + `"], "add": ["x"]}`, http.StatusNotFound},
+		{"POST", "/tags/assign", `{"replay_ids": ["nope"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt.method+" "+tt.path+" "+tt.body)
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	paramUploadID = "upload_id"
+
+	headerTusResumable   = "Tus-Resumable"
+	headerUploadLength   = "Upload-Length"
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadMetadata = "Upload-Metadata"
+	headerUploadExpires  = "Upload-Expires"
+
+	tusVersion             = "1.0.0"
+	contentTypeOffsetOctet = "application/offset+octet-stream"
+)
+
+type UploadHandler struct {
+	uploadService UploadServiceInterface
+}
+
+func NewUploadHandler(uploadService UploadServiceInterface) *UploadHandler {
+	return &UploadHandler{uploadService: uploadService}
+}
+
+// CreateUpload открывает сессию загрузки
+// Размер передается в Upload-Length, имя файла и подписи - в Upload-Metadata (ключ base64(значение), через запятую)
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, err := uuid.Parse(c.Param(paramGameID))
+	if err != nil {
+		respondBadRequest(c, "invalid game_id")
+		return
+	}
+
+	size, err := strconv.ParseInt(c.GetHeader(headerUploadLength), 10, 64)
+	if err != nil || size < 0 {
+		respondBadRequest(c, "invalid Upload-Length")
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader(headerUploadMetadata))
+	if err != nil {
+		respondBadRequest(c, "invalid Upload-Metadata")
+		return
+	}
+	if metadata["filename"] == "" {
+		respondBadRequest(c, "filename is required")
+		return
+	}
+
+	session, err := h.uploadService.CreateUpload(c.Request.Context(), gameID, userID,
+		metadata["filename"], size, metadata["title"], metadata["comment"])
+	if err != nil {
+		if errors.Is(err, services.ErrGameNotFound) {
+			respondNotFound(c, "game not found")
+			return
+		}
+		if isQuotaExceeded(err) {
+			respondQuotaExceeded(c, err)
+			return
+		}
+		respondInternalError(c, "failed to create upload")
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+session.ID.String())
+	c.Header(headerTusResumable, tusVersion)
+	c.Header(headerUploadOffset, strconv.FormatInt(session.Offset, 10))
+	c.Header(headerUploadExpires, session.ExpiresAt.UTC().Format(http.TimeFormat))
+	respondCreated(c, session)
+}
+
+// GetUploadOffset отвечает на HEAD: сколько байт уже принято сервером
+func (h *UploadHandler) GetUploadOffset(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, uploadID, ok := parseUploadParams(c)
+	if !ok {
+		return
+	}
+
+	session, err := h.uploadService.GetUpload(c.Request.Context(), uploadID, gameID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header(headerTusResumable, tusVersion)
+	c.Header(headerUploadOffset, strconv.FormatInt(session.Offset, 10))
+	c.Header(headerUploadLength, strconv.FormatInt(session.SizeBytes, 10))
+	c.Header(headerUploadExpires, session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// AppendUploadChunk дописывает тело запроса начиная с Upload-Offset
+func (h *UploadHandler) AppendUploadChunk(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, uploadID, ok := parseUploadParams(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != contentTypeOffsetOctet {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + contentTypeOffsetOctet})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		respondBadRequest(c, "invalid Upload-Offset")
+		return
+	}
+
+	newOffset, err := h.uploadService.AppendChunk(c.Request.Context(), uploadID, gameID, userID,
+		offset, c.Request.Body, c.Request.ContentLength)
+	c.Header(headerTusResumable, tusVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadNotFound):
+			respondNotFound(c, "upload not found")
+		case errors.Is(err, services.ErrUploadOffsetMismatch):
+			c.Header(headerUploadOffset, strconv.FormatInt(newOffset, 10))
+			c.JSON(http.StatusConflict, gin.H{"error": "upload offset mismatch"})
+		case errors.Is(err, services.ErrUploadTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "chunk exceeds Upload-Length"})
+		case isRequestTooLarge(err):
+			respondTooLarge(c, err)
+		default:
+			respondInternalError(c, "failed to append chunk")
+		}
+		return
+	}
+
+	c.Header(headerUploadOffset, strconv.FormatInt(newOffset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CompleteUpload превращает полностью загруженную сессию в реплей
+func (h *UploadHandler) CompleteUpload(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, uploadID, ok := parseUploadParams(c)
+	if !ok {
+		return
+	}
+
+	replay, err := h.uploadService.FinalizeUpload(c.Request.Context(), uploadID, gameID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUploadNotFound):
+			respondNotFound(c, "upload not found")
+		case errors.Is(err, services.ErrUploadIncomplete):
+			c.JSON(http.StatusConflict, gin.H{"error": "upload is not complete"})
+		case errors.Is(err, services.ErrUploadLeaseLost):
+			c.JSON(http.StatusConflict, gin.H{"error": "upload is being completed by another request"})
+		case isQuotaExceeded(err):
+			respondQuotaExceeded(c, err)
+		case isFileTypeNotAllowed(err):
+			respondUnsupportedType(c)
+		default:
+			respondInternalError(c, "failed to complete upload")
+		}
+		return
+	}
+
+	respondCreated(c, gin.H{"id": replay.ID})
+}
+
+func (h *UploadHandler) DeleteUpload(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, uploadID, ok := parseUploadParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadService.AbortUpload(c.Request.Context(), uploadID, gameID, userID); err != nil {
+		if errors.Is(err, services.ErrUploadNotFound) {
+			respondNotFound(c, "upload not found")
+			return
+		}
+		respondInternalError(c, "failed to delete upload")
+		return
+	}
+
+	respondSuccess(c, "deleted")
+}
+
+func parseUploadParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	gameID, err := uuid.Parse(c.Param(paramGameID))
+	if err != nil {
+		respondBadRequest(c, "invalid game_id")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	uploadID, err := uuid.Parse(c.Param(paramUploadID))
+	if err != nil {
+		respondBadRequest(c, "invalid upload_id")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return gameID, uploadID, true
+}
+
+// parseUploadMetadata разбирает tus-заголовок Upload-Metadata: "key base64value,key2 base64value2"
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
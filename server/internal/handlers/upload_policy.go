@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UploadPolicyHandler struct {
+	policyService UploadPolicyServiceInterface
+}
+
+func NewUploadPolicyHandler(policyService UploadPolicyServiceInterface) *UploadPolicyHandler {
+	return &UploadPolicyHandler{policyService: policyService}
+}
+
+// GetUploadPolicy возвращает форматы, которые разрешено загружать в игру
+func (h *UploadPolicyHandler) GetUploadPolicy(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, err := uuid.Parse(c.Param(paramGameID))
+	if err != nil {
+		respondBadRequest(c, "invalid game_id")
+		return
+	}
+
+	policy, err := h.policyService.GetPolicy(c.Request.Context(), gameID, userID)
+	if err != nil {
+		respondUploadPolicyError(c, err)
+		return
+	}
+
+	respondOK(c, policy)
+}
+
+// SetUploadPolicy задает игре собственную политику загрузки; allowed_types: null возвращает политику по умолчанию
+func (h *UploadPolicyHandler) SetUploadPolicy(c *gin.Context) {
+	userID := c.MustGet(contextKeyUserID).(uuid.UUID)
+	gameID, err := uuid.Parse(c.Param(paramGameID))
+	if err != nil {
+		respondBadRequest(c, "invalid game_id")
+		return
+	}
+
+	var req struct {
+		AllowedTypes []string `json:"allowed_types"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBadRequest(c, "invalid request body")
+		return
+	}
+
+	policy, err := h.policyService.SetAllowedTypes(c.Request.Context(), gameID, userID, req.AllowedTypes)
+	if err != nil {
+		respondUploadPolicyError(c, err)
+		return
+	}
+
+	respondOK(c, policy)
+}
+
+func respondUploadPolicyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidUploadPolicy):
+		respondBadRequest(c, err.Error())
+	case errors.Is(err, services.ErrGameNotFound):
+		respondNotFound(c, "game not found")
+	default:
+		respondInternalError(c, "failed to process upload policy")
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockUploadService - мок для UploadService
+type MockUploadService struct {
+	mock.Mock
+}
+
+func (m *MockUploadService) CreateUpload(ctx context.Context, gameID, userID uuid.UUID, filename string, size int64, title, comment string) (*models.UploadSession, error) {
+	args := m.Called(ctx, gameID, userID, filename, size, title, comment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UploadSession), args.Error(1)
+}
+
+func (m *MockUploadService) GetUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.UploadSession, error) {
+	args := m.Called(ctx, uploadID, gameID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UploadSession), args.Error(1)
+}
+
+func (m *MockUploadService) AppendChunk(ctx context.Context, uploadID, gameID, userID uuid.UUID, offset int64, body io.Reader, size int64) (int64, error) {
+	args := m.Called(ctx, uploadID, gameID, userID, offset, body, size)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUploadService) FinalizeUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.Replay, error) {
+	args := m.Called(ctx, uploadID, gameID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Replay), args.Error(1)
+}
+
+func (m *MockUploadService) AbortUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) error {
+	args := m.Called(ctx, uploadID, gameID, userID)
+	return args.Error(0)
+}
+
+// setupUploadRouter регистрирует маршруты загрузок так же, как main
+func setupUploadRouter(handler *UploadHandler, userID uuid.UUID) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+
+	router.POST("/games/:game_id/uploads", handler.CreateUpload)
+	router.HEAD("/games/:game_id/uploads/:upload_id", handler.GetUploadOffset)
+	router.PATCH("/games/:game_id/uploads/:upload_id", handler.AppendUploadChunk)
+	router.POST("/games/:game_id/uploads/:upload_id/complete", handler.CompleteUpload)
+	return router
+}
+
+// TestCreateUpload_Success проверяет создание сессии и tus-заголовки ответа
+func TestCreateUpload_Success(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	userID, gameID := uuid.New(), uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), userID)
+
+	session := &models.UploadSession{ID: uuid.New(), SizeBytes: 100, ExpiresAt: time.Now().Add(time.Hour)}
+	mockUploadService.On("CreateUpload", mock.Anything, gameID, userID, "матч.rep", int64(100), "Final", "").Return(session, nil)
+
+	req, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/uploads", nil)
+	req.Header.Set("Upload-Length", "100")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("матч.rep"))+
+		",title "+base64.StdEncoding.EncodeToString([]byte("Final")))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/games/"+gameID.String()+"/uploads/"+session.ID.String(), w.Header().Get("Location"))
+	assert.Equal(t, "0", w.Header().Get("Upload-Offset"))
+	mockUploadService.AssertExpectations(t)
+}
+
+// TestCreateUpload_MissingLength проверяет, что без Upload-Length сессия не создается
+func TestCreateUpload_MissingLength(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	gameID := uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), uuid.New())
+
+	req, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/uploads", nil)
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("match.rep")))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockUploadService.AssertNotCalled(t, "CreateUpload")
+}
+
+// TestGetUploadOffset_Success проверяет, что HEAD отдает прогресс загрузки
+func TestGetUploadOffset_Success(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	userID, gameID, uploadID := uuid.New(), uuid.New(), uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), userID)
+
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 100, Offset: 40}
+	mockUploadService.On("GetUpload", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+
+	req, _ := http.NewRequest("HEAD", "/games/"+gameID.String()+"/uploads/"+uploadID.String(), nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "40", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "100", w.Header().Get("Upload-Length"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
+
+// TestAppendUploadChunk_Success проверяет дозапись куска
+func TestAppendUploadChunk_Success(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	userID, gameID, uploadID := uuid.New(), uuid.New(), uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), userID)
+
+	mockUploadService.On("AppendChunk", mock.Anything, uploadID, gameID, userID, int64(40), mock.Anything, int64(5)).
+		Return(int64(45), nil)
+
+	req, _ := http.NewRequest("PATCH", "/games/"+gameID.String()+"/uploads/"+uploadID.String(), strings.NewReader("chunk"))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "40")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "45", w.Header().Get("Upload-Offset"))
+}
+
+// TestAppendUploadChunk_WrongContentType проверяет 415 для неверного Content-Type
+func TestAppendUploadChunk_WrongContentType(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	gameID, uploadID := uuid.New(), uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), uuid.New())
+
+	req, _ := http.NewRequest("PATCH", "/games/"+gameID.String()+"/uploads/"+uploadID.String(), strings.NewReader("chunk"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// TestAppendUploadChunk_OffsetMismatch проверяет 409 и актуальное смещение в ответе
+func TestAppendUploadChunk_OffsetMismatch(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	userID, gameID, uploadID := uuid.New(), uuid.New(), uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), userID)
+
+	mockUploadService.On("AppendChunk", mock.Anything, uploadID, gameID, userID, int64(0), mock.Anything, int64(5)).
+		Return(int64(40), services.ErrUploadOffsetMismatch)
+
+	req, _ := http.NewRequest("PATCH", "/games/"+gameID.String()+"/uploads/"+uploadID.String(), strings.NewReader("chunk"))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "40", w.Header().Get("Upload-Offset"))
+}
+
+// TestCompleteUpload_Incomplete проверяет 409 при финализации недогруженного файла
+func TestCompleteUpload_Incomplete(t *testing.T) {
+	mockUploadService := new(MockUploadService)
+	userID, gameID, uploadID := uuid.New(), uuid.New(), uuid.New()
+	router := setupUploadRouter(NewUploadHandler(mockUploadService), userID)
+
+	mockUploadService.On("FinalizeUpload", mock.Anything, uploadID, gameID, userID).Return(nil, services.ErrUploadIncomplete)
+
+	req, _ := http.NewRequest("POST", "/games/"+gameID.String()+"/uploads/"+uploadID.String()+"/complete", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,664 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrInvalidRange - границы фрагмента пустые или не попадают в видео
+var ErrInvalidRange = errors.New("clip range is outside the media")
+
+// maxSamples - предел числа сэмплов дорожки: защищает от таблиц с заведомо ложным счетчиком
+const maxSamples = 1 << 24
+
+// ClipPlan - фрагмент MP4, вырезанный без перекодирования: новые таблицы сэмплов и список
+// байтовых диапазонов исходного файла, из которых собирается mdat
+type ClipPlan struct {
+	ftyp   []byte
+	moov   []byte
+	mdat   []byte
+	ranges []sampleRange
+	size   int64
+	// Start, End - фактические границы фрагмента: начало сдвигается назад к ключевому кадру
+	Start, End time.Duration
+}
+
+type sampleRange struct {
+	offset, size int64
+}
+
+// Size - размер файла, который запишет Write
+func (p *ClipPlan) Size() int64 {
+	return p.size
+}
+
+// PlanClip читает ftyp и moov исходного MP4 размера size и готовит фрагмент [start, end)
+// Начало фрагмента - ближайший ключевой кадр видеодорожки не позже start; в файле остаются только
+// видео- и аудиодорожки. Фрагментированный MP4 не поддерживается (ErrUnsupported)
+// Таблицы сэмплов, которые не помещаются в size, отклоняются (ErrInvalid) до выделения памяти под них
+func PlanClip(r io.Reader, size int64, start, end time.Duration) (*ClipPlan, error) {
+	if start < 0 || end <= start {
+		return nil, ErrInvalidRange
+	}
+
+	sourceSize := size
+	src := newReader(r)
+	var ftyp []byte
+	for {
+		boxStart := src.pos
+		boxType, size, err := readBoxHeader(src)
+		if err == io.EOF {
+			return nil, ErrInvalid
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		header := src.pos - boxStart
+
+		switch boxType {
+		case "moof":
+			return nil, ErrUnsupported
+		case "ftyp", "moov":
+			if size < 0 || size > maxMoovSize {
+				return nil, ErrInvalid
+			}
+			data := make([]byte, header+size)
+			copy(data, boxHeader(boxType, header, size))
+			if err := src.readFull(data[header:]); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if boxType == "ftyp" {
+				ftyp = data
+				continue
+			}
+			return planClip(ftyp, data[header:], sourceSize, start, end)
+		}
+
+		if size < 0 {
+			return nil, ErrInvalid
+		}
+		if err := src.skip(size); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+}
+
+// Write пишет фрагмент в w; r - исходный файл, прочитанный с начала
+func (p *ClipPlan) Write(w io.Writer, r io.Reader) error {
+	for _, part := range [][]byte{p.ftyp, p.moov, p.mdat} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+
+	src := newReader(r)
+	for _, rng := range p.ranges {
+		if err := src.skip(rng.offset - src.pos); err != nil {
+			return unexpectedEOF(err)
+		}
+		if _, err := io.CopyN(w, src.r, rng.size); err != nil {
+			return unexpectedEOF(err)
+		}
+		src.pos += rng.size
+	}
+	return nil
+}
+
+// clipTrack - таблицы сэмплов дорожки, развернутые по сэмплам
+type clipTrack struct {
+	trak      []byte
+	handler   string
+	timescale uint32
+	sizes     []uint32
+	offsets   []int64
+	times     []uint64
+	deltas    []uint32
+	// cts - смещения композиции (ctts); nil - таблицы нет
+	cts        []uint32
+	ctsVersion byte
+	// sync - ключевые кадры (stss); nil - ключевые все сэмплы
+	sync []bool
+	desc []uint32
+
+	// first, last - выбранные сэмплы [first, last); newOffsets - их смещения в новом файле
+	first, last int
+	newOffsets  []int64
+}
+
+func (t *clipTrack) isSync(i int) bool {
+	return t.sync == nil || t.sync[i]
+}
+
+func (t *clipTrack) duration(first, last int) uint64 {
+	if last <= first {
+		return 0
+	}
+	return t.times[last-1] + uint64(t.deltas[last-1]) - t.times[first]
+}
+
+func planClip(ftyp, moov []byte, sourceSize int64, start, end time.Duration) (*ClipPlan, error) {
+	if child(moov, "mvex") != nil {
+		return nil, ErrUnsupported
+	}
+	movieScale, _, ok := parseDuration(child(moov, "mvhd"))
+	if !ok || movieScale == 0 {
+		return nil, ErrInvalid
+	}
+
+	var tracks []*clipTrack
+	for _, b := range children(moov) {
+		if b.typ != "trak" {
+			continue
+		}
+		mdia := child(b.data, "mdia")
+		hdlr := child(mdia, "hdlr")
+		if len(hdlr) < 12 || (string(hdlr[8:12]) != "vide" && string(hdlr[8:12]) != "soun") {
+			continue
+		}
+		track, err := parseClipTrack(b.data, string(hdlr[8:12]), sourceSize)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	if len(tracks) == 0 {
+		return nil, ErrUnsupported
+	}
+
+	// Границы задает первая видеодорожка, остальные дорожки режутся по тому же времени
+	ref := tracks[0]
+	for _, t := range tracks {
+		if t.handler == "vide" {
+			ref = t
+			break
+		}
+	}
+	if err := ref.selectFromKeyframe(start, end); err != nil {
+		return nil, err
+	}
+	clipStart := ticksToDuration(ref.times[ref.first], ref.timescale)
+	clipEnd := clipStart + ticksToDuration(ref.duration(ref.first, ref.last), ref.timescale)
+
+	var kept []*clipTrack
+	for _, t := range tracks {
+		if t != ref {
+			t.selectRange(clipStart, clipEnd)
+		}
+		if t.last > t.first {
+			kept = append(kept, t)
+		}
+	}
+
+	// Сэмплы пишутся в порядке исходного файла: чередование дорожек сохраняется, а исходник читается за один проход
+	type sampleRef struct {
+		track *clipTrack
+		index int
+	}
+	var refs []sampleRef
+	var mdatSize int64
+	for _, t := range kept {
+		t.newOffsets = make([]int64, t.last-t.first)
+		for i := t.first; i < t.last; i++ {
+			refs = append(refs, sampleRef{track: t, index: i})
+			mdatSize += int64(t.sizes[i])
+		}
+	}
+	sort.SliceStable(refs, func(a, b int) bool {
+		return refs[a].track.offsets[refs[a].index] < refs[b].track.offsets[refs[b].index]
+	})
+
+	co64 := int64(len(ftyp))+maxMoovSize+16+mdatSize > math.MaxUint32
+	mdatHeaderSize := int64(8)
+	if mdatSize+8 > math.MaxUint32 {
+		mdatHeaderSize = 16
+	}
+
+	// Размер moov не зависит от значений смещений, поэтому он собирается дважды: для размера и начисто
+	build := func() []byte {
+		return buildClipMoov(moov, kept, movieScale, clipEnd-clipStart, co64)
+	}
+	base := int64(len(ftyp)) + int64(len(build())) + mdatHeaderSize
+
+	plan := &ClipPlan{ftyp: ftyp, mdat: boxHeader("mdat", mdatHeaderSize, mdatSize), Start: clipStart, End: clipEnd}
+	pos := base
+	for _, ref := range refs {
+		t := ref.track
+		offset, size := t.offsets[ref.index], int64(t.sizes[ref.index])
+		t.newOffsets[ref.index-t.first] = pos
+		pos += size
+
+		if n := len(plan.ranges); n > 0 {
+			last := &plan.ranges[n-1]
+			if offset < last.offset+last.size {
+				return nil, ErrInvalid
+			}
+			if offset == last.offset+last.size {
+				last.size += size
+				continue
+			}
+		}
+		plan.ranges = append(plan.ranges, sampleRange{offset: offset, size: size})
+	}
+
+	plan.moov = build()
+	plan.size = pos
+	return plan, nil
+}
+
+// selectFromKeyframe выбирает сэмплы от ключевого кадра не позже start до end
+func (t *clipTrack) selectFromKeyframe(start, end time.Duration) error {
+	startTicks, endTicks := durationToTicks(start, t.timescale), durationToTicks(end, t.timescale)
+	count := len(t.sizes)
+	if count == 0 || startTicks >= t.times[count-1]+uint64(t.deltas[count-1]) {
+		return ErrInvalidRange
+	}
+
+	first := -1
+	for i := 0; i < count && t.times[i] <= startTicks; i++ {
+		if t.isSync(i) {
+			first = i
+		}
+	}
+	if first < 0 {
+		// start раньше первого ключевого кадра: фрагмент начинается с него
+		for i := 0; i < count; i++ {
+			if t.isSync(i) {
+				first = i
+				break
+			}
+		}
+	}
+	if first < 0 {
+		return ErrInvalid
+	}
+
+	last := sort.Search(count, func(i int) bool { return t.times[i] >= endTicks })
+	if last <= first {
+		return ErrInvalidRange
+	}
+	t.first, t.last = first, last
+	return nil
+}
+
+// selectRange выбирает сэмплы, которые начинаются в [start, end)
+func (t *clipTrack) selectRange(start, end time.Duration) {
+	startTicks, endTicks := durationToTicks(start, t.timescale), durationToTicks(end, t.timescale)
+	count := len(t.sizes)
+	t.first = sort.Search(count, func(i int) bool { return t.times[i] >= startTicks })
+	t.last = sort.Search(count, func(i int) bool { return t.times[i] >= endTicks })
+}
+
+// parseClipTrack разворачивает таблицы сэмплов дорожки; все сэмплы должны лежать в первых sourceSize байтах файла
+func parseClipTrack(trak []byte, handler string, sourceSize int64) (*clipTrack, error) {
+	mdia := child(trak, "mdia")
+	timescale, _, ok := parseDuration(child(mdia, "mdhd"))
+	if !ok || timescale == 0 {
+		return nil, ErrInvalid
+	}
+	stbl := child(mdia, "minf", "stbl")
+	if child(stbl, "stsd") == nil {
+		return nil, ErrInvalid
+	}
+	if child(stbl, "stsz") == nil && child(stbl, "stz2") != nil {
+		return nil, ErrUnsupported
+	}
+
+	t := &clipTrack{trak: trak, handler: handler, timescale: timescale}
+	var err error
+	if t.sizes, err = parseSampleSizes(child(stbl, "stsz"), sourceSize); err != nil {
+		return nil, err
+	}
+	count := len(t.sizes)
+
+	if t.deltas, err = expandRuns(child(stbl, "stts"), count); err != nil {
+		return nil, err
+	}
+	t.times = make([]uint64, count)
+	var clock uint64
+	for i, delta := range t.deltas {
+		t.times[i] = clock
+		clock += uint64(delta)
+	}
+
+	if ctts := child(stbl, "ctts"); ctts != nil {
+		if t.cts, err = expandRuns(ctts, count); err != nil {
+			return nil, err
+		}
+		t.ctsVersion = ctts[0]
+	}
+
+	if stss := child(stbl, "stss"); stss != nil {
+		entries, err := fullBoxEntries(stss, 4)
+		if err != nil {
+			return nil, err
+		}
+		t.sync = make([]bool, count)
+		for _, e := range entries {
+			if n := binary.BigEndian.Uint32(e); n >= 1 && int(n) <= count {
+				t.sync[n-1] = true
+			}
+		}
+	}
+
+	if err := t.parseChunks(stbl, sourceSize); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseChunks раскладывает сэмплы по чанкам (stsc + stco/co64) и считает смещение каждого сэмпла
+// Сэмпл, который заканчивается за sourceSize, - ErrInvalid
+func (t *clipTrack) parseChunks(stbl []byte, sourceSize int64) error {
+	var chunkOffsets []int64
+	if stco := child(stbl, "stco"); stco != nil {
+		entries, err := fullBoxEntries(stco, 4)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint32(e)))
+		}
+	} else {
+		entries, err := fullBoxEntries(child(stbl, "co64"), 8)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint64(e)))
+		}
+	}
+
+	runs, err := fullBoxEntries(child(stbl, "stsc"), 12)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 && len(t.sizes) > 0 {
+		return ErrInvalid
+	}
+
+	count := len(t.sizes)
+	t.offsets = make([]int64, count)
+	t.desc = make([]uint32, count)
+	sample, run := 0, 0
+	for i, offset := range chunkOffsets {
+		chunk := uint32(i + 1)
+		for run+1 < len(runs) && binary.BigEndian.Uint32(runs[run+1]) <= chunk {
+			run++
+		}
+		if offset < 0 || offset > sourceSize {
+			return ErrInvalid
+		}
+		perChunk := binary.BigEndian.Uint32(runs[run][4:])
+		desc := binary.BigEndian.Uint32(runs[run][8:])
+		for k := uint32(0); k < perChunk && sample < count; k++ {
+			t.offsets[sample], t.desc[sample] = offset, desc
+			offset += int64(t.sizes[sample])
+			if offset > sourceSize {
+				return ErrInvalid
+			}
+			sample++
+		}
+	}
+	if sample < count {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// parseSampleSizes читает размеры сэмплов (stsz); сэмплы вместе не могут быть больше sourceSize
+func parseSampleSizes(stsz []byte, sourceSize int64) ([]uint32, error) {
+	if len(stsz) < 12 {
+		return nil, ErrInvalid
+	}
+	size := binary.BigEndian.Uint32(stsz[4:8])
+	count := int(binary.BigEndian.Uint32(stsz[8:12]))
+	if count > maxSamples || int64(count) > sourceSize {
+		return nil, ErrInvalid
+	}
+
+	if size != 0 {
+		if int64(size)*int64(count) > sourceSize {
+			return nil, ErrInvalid
+		}
+		sizes := make([]uint32, count)
+		for i := range sizes {
+			sizes[i] = size
+		}
+		return sizes, nil
+	}
+	if count > (len(stsz)-12)/4 {
+		return nil, ErrInvalid
+	}
+	var total int64
+	for i := 0; i < count; i++ {
+		total += int64(binary.BigEndian.Uint32(stsz[12+i*4:]))
+	}
+	if total > sourceSize {
+		return nil, ErrInvalid
+	}
+	sizes := make([]uint32, count)
+	for i := range sizes {
+		sizes[i] = binary.BigEndian.Uint32(stsz[12+i*4:])
+	}
+	return sizes, nil
+}
+
+// expandRuns разворачивает таблицу (sample_count, value) - stts или ctts - в значение на каждый сэмпл
+func expandRuns(data []byte, count int) ([]uint32, error) {
+	entries, err := fullBoxEntries(data, 8)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]uint32, 0, count)
+	for _, e := range entries {
+		n := int(binary.BigEndian.Uint32(e))
+		value := binary.BigEndian.Uint32(e[4:])
+		for ; n > 0 && len(values) < count; n-- {
+			values = append(values, value)
+		}
+	}
+	if len(values) < count {
+		return nil, ErrInvalid
+	}
+	return values, nil
+}
+
+// fullBoxEntries делит таблицу полного бокса (version/flags, entry_count, записи) на записи
+func fullBoxEntries(data []byte, width int) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, ErrInvalid
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	entries := data[8:]
+	if count > len(entries)/width {
+		return nil, ErrInvalid
+	}
+	out := make([][]byte, count)
+	for i := range out {
+		out[i] = entries[i*width : (i+1)*width]
+	}
+	return out, nil
+}
+
+// buildClipMoov собирает moov фрагмента: длительности в mvhd/tkhd/mdhd и таблицы сэмплов
+// пересчитываются, edts выбрасывается - список правок описывает исходную временную шкалу
+func buildClipMoov(moov []byte, tracks []*clipTrack, movieScale uint32, duration time.Duration, co64 bool) []byte {
+	var parts [][]byte
+	for _, b := range children(moov) {
+		switch b.typ {
+		case "mvhd":
+			parts = append(parts, makeBox("mvhd", withDuration(b.data, durationToTicks(duration, movieScale), 4)))
+		case "trak":
+			// Дорожки добавляются ниже в исходном порядке
+		default:
+			parts = append(parts, makeBox(b.typ, b.data))
+		}
+	}
+	for _, t := range tracks {
+		trackDuration := ticksToDuration(t.duration(t.first, t.last), t.timescale)
+		parts = append(parts, t.buildTrak(durationToTicks(trackDuration, movieScale), co64))
+	}
+	return makeBox("moov", parts...)
+}
+
+func (t *clipTrack) buildTrak(movieDuration uint64, co64 bool) []byte {
+	var trakParts [][]byte
+	for _, b := range children(t.trak) {
+		switch b.typ {
+		case "tkhd":
+			trakParts = append(trakParts, makeBox("tkhd", withDuration(b.data, movieDuration, 8)))
+		case "edts":
+			// Список правок описывает временную шкалу исходного файла
+		case "mdia":
+			var mdiaParts [][]byte
+			for _, m := range children(b.data) {
+				switch m.typ {
+				case "mdhd":
+					mdiaParts = append(mdiaParts, makeBox("mdhd", withDuration(m.data, t.duration(t.first, t.last), 4)))
+				case "minf":
+					var minfParts [][]byte
+					for _, n := range children(m.data) {
+						if n.typ == "stbl" {
+							minfParts = append(minfParts, t.buildStbl(n.data, co64))
+						} else {
+							minfParts = append(minfParts, makeBox(n.typ, n.data))
+						}
+					}
+					mdiaParts = append(mdiaParts, makeBox("minf", minfParts...))
+				default:
+					mdiaParts = append(mdiaParts, makeBox(m.typ, m.data))
+				}
+			}
+			trakParts = append(trakParts, makeBox("mdia", mdiaParts...))
+		default:
+			trakParts = append(trakParts, makeBox(b.typ, b.data))
+		}
+	}
+	return makeBox("trak", trakParts...)
+}
+
+// buildStbl пишет таблицы выбранных сэмплов; каждый сэмпл - отдельный чанк
+func (t *clipTrack) buildStbl(stbl []byte, co64 bool) []byte {
+	first, last := t.first, t.last
+	parts := [][]byte{makeBox("stsd", child(stbl, "stsd"))}
+
+	parts = append(parts, makeBox("stts", runsTable(0, t.deltas[first:last])))
+	if t.cts != nil {
+		parts = append(parts, makeBox("ctts", runsTable(t.ctsVersion, t.cts[first:last])))
+	}
+	if t.sync != nil {
+		var numbers []uint32
+		for i := first; i < last; i++ {
+			if t.sync[i] {
+				numbers = append(numbers, uint32(i-first+1))
+			}
+		}
+		parts = append(parts, makeBox("stss", table(0, numbers)))
+	}
+
+	sizes := binary.BigEndian.AppendUint32(nil, 0)
+	sizes = append(sizes, table(0, t.sizes[first:last])...)
+	parts = append(parts, makeBox("stsz", sizes))
+
+	var stsc []uint32
+	var runs uint32
+	for i := first; i < last; i++ {
+		if i == first || t.desc[i] != t.desc[i-1] {
+			stsc = append(stsc, uint32(i-first+1), 1, t.desc[i])
+			runs++
+		}
+	}
+	stscData := binary.BigEndian.AppendUint32(nil, 0)
+	stscData = binary.BigEndian.AppendUint32(stscData, runs)
+	for _, v := range stsc {
+		stscData = binary.BigEndian.AppendUint32(stscData, v)
+	}
+	parts = append(parts, makeBox("stsc", stscData))
+
+	offsets := binary.BigEndian.AppendUint32(nil, 0)
+	offsets = binary.BigEndian.AppendUint32(offsets, uint32(len(t.newOffsets)))
+	for _, offset := range t.newOffsets {
+		if co64 {
+			offsets = binary.BigEndian.AppendUint64(offsets, uint64(offset))
+		} else {
+			offsets = binary.BigEndian.AppendUint32(offsets, uint32(offset))
+		}
+	}
+	if co64 {
+		parts = append(parts, makeBox("co64", offsets))
+	} else {
+		parts = append(parts, makeBox("stco", offsets))
+	}
+	return makeBox("stbl", parts...)
+}
+
+// runsTable сжимает значения по сэмплам обратно в (sample_count, value)
+func runsTable(version byte, values []uint32) []byte {
+	var entries []uint32
+	for i, v := range values {
+		if i > 0 && v == values[i-1] {
+			entries[len(entries)-2]++
+			continue
+		}
+		entries = append(entries, 1, v)
+	}
+	out := binary.BigEndian.AppendUint32([]byte{version, 0, 0, 0}, uint32(len(entries)/2))
+	for _, v := range entries {
+		out = binary.BigEndian.AppendUint32(out, v)
+	}
+	return out
+}
+
+// table - полный бокс: version/flags, entry_count = len(values), значения
+func table(version byte, values []uint32) []byte {
+	out := []byte{version, 0, 0, 0}
+	out = binary.BigEndian.AppendUint32(out, uint32(len(values)))
+	for _, v := range values {
+		out = binary.BigEndian.AppendUint32(out, v)
+	}
+	return out
+}
+
+// withDuration копирует полный бокс с новой длительностью; длительность лежит после creation_time,
+// modification_time и fields байт: 4 у mvhd/mdhd (timescale), 8 у tkhd (track_ID, reserved)
+func withDuration(data []byte, duration uint64, fields int) []byte {
+	out := append([]byte(nil), data...)
+	if len(out) < 4 {
+		return out
+	}
+	if out[0] == 1 {
+		if at := 4 + 16 + fields; len(out) >= at+8 {
+			binary.BigEndian.PutUint64(out[at:], duration)
+		}
+		return out
+	}
+	if at := 4 + 8 + fields; len(out) >= at+4 {
+		binary.BigEndian.PutUint32(out[at:], uint32(min(duration, math.MaxUint32)))
+	}
+	return out
+}
+
+func makeBox(typ string, parts ...[]byte) []byte {
+	size := 8
+	for _, p := range parts {
+		size += len(p)
+	}
+	out := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(out, uint32(size))
+	copy(out[4:], typ)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func durationToTicks(d time.Duration, timescale uint32) uint64 {
+	if d <= 0 {
+		return 0
+	}
+	return uint64(math.Round(d.Seconds() * float64(timescale)))
+}
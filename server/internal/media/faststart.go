@@ -0,0 +1,164 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+)
+
+// mp4ContentTypes - контейнеры семейства ISO BMFF, для которых имеет смысл faststart
+var mp4ContentTypes = []string{"video/mp4", "video/quicktime", "video/x-m4v", "video/3gpp"}
+
+func IsMP4(mime string) bool {
+	return slices.Contains(mp4ContentTypes, mime)
+}
+
+// FaststartPlan - перестановка moov, лежащего после mdat, в начало файла
+// Размер файла не меняется: moov переносится целиком, смещения чанков в stco/co64 сдвигаются на его размер
+type FaststartPlan struct {
+	insertAt int64
+	moovAt   int64
+	moov     []byte
+}
+
+// PlanFaststart читает верхний уровень MP4 и готовит исправленный moov
+// nil без ошибки - перестановка не нужна: moov уже перед mdat или файл фрагментирован
+// ErrUnsupported - после сдвига смещения не помещаются в 32-битный stco
+func PlanFaststart(r io.Reader) (*FaststartPlan, error) {
+	src := newReader(r)
+	mdatAt := int64(-1)
+	for {
+		start := src.pos
+		boxType, size, err := readBoxHeader(src)
+		if err == io.EOF {
+			return nil, ErrInvalid
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		header := src.pos - start
+
+		switch boxType {
+		case "moof":
+			// Смещения фрагментов считаются от moof, их перестановка не затрагивает
+			return nil, nil
+		case "mdat":
+			if mdatAt < 0 {
+				mdatAt = start
+			}
+		case "moov":
+			if mdatAt < 0 {
+				return nil, nil
+			}
+			if size < 0 || size > maxMoovSize {
+				return nil, ErrInvalid
+			}
+			moov := make([]byte, header+size)
+			copy(moov, boxHeader("moov", header, size))
+			if err := src.readFull(moov[header:]); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+
+			plan := &FaststartPlan{insertAt: mdatAt, moovAt: start, moov: moov}
+			if err := plan.shiftChunkOffsets(moov[header:]); err != nil {
+				return nil, err
+			}
+			return plan, nil
+		}
+
+		if size < 0 {
+			// Бокс до конца файла, moov за ним быть не может
+			return nil, ErrInvalid
+		}
+		if err := src.skip(size); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+}
+
+// Write пишет в w файл с moov перед первым mdat; r - тот же файл, прочитанный с начала
+func (p *FaststartPlan) Write(w io.Writer, r io.Reader) error {
+	src := newReader(r)
+	if _, err := io.CopyN(w, src.r, p.insertAt); err != nil {
+		return unexpectedEOF(err)
+	}
+	if _, err := w.Write(p.moov); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, src.r, p.moovAt-p.insertAt); err != nil {
+		return unexpectedEOF(err)
+	}
+	if err := src.skip(int64(len(p.moov))); err != nil {
+		return unexpectedEOF(err)
+	}
+	_, err := io.Copy(w, src.r)
+	return err
+}
+
+// shiftChunkOffsets сдвигает смещения чанков, указывающие между новым и старым местом moov
+// children возвращает срезы того же буфера, поэтому таблицы правятся на месте
+func (p *FaststartPlan) shiftChunkOffsets(moov []byte) error {
+	shift := uint64(len(p.moov))
+	moved := func(offset uint64) bool {
+		return offset >= uint64(p.insertAt) && offset < uint64(p.moovAt)
+	}
+
+	for _, trak := range children(moov) {
+		if trak.typ != "trak" {
+			continue
+		}
+		for _, table := range children(child(trak.data, "mdia", "minf", "stbl")) {
+			// version/flags, entry_count, затем смещения по 4 (stco) или 8 (co64) байт
+			var width int
+			switch table.typ {
+			case "stco":
+				width = 4
+			case "co64":
+				width = 8
+			default:
+				continue
+			}
+			if len(table.data) < 8 {
+				return ErrInvalid
+			}
+			count := int(binary.BigEndian.Uint32(table.data[4:8]))
+			entries := table.data[8:]
+			if count > len(entries)/width {
+				return ErrInvalid
+			}
+
+			for i := 0; i < count; i++ {
+				entry := entries[i*width:]
+				if width == 8 {
+					if offset := binary.BigEndian.Uint64(entry); moved(offset) {
+						binary.BigEndian.PutUint64(entry, offset+shift)
+					}
+					continue
+				}
+				offset := uint64(binary.BigEndian.Uint32(entry))
+				if !moved(offset) {
+					continue
+				}
+				if offset+shift > math.MaxUint32 {
+					return ErrUnsupported
+				}
+				binary.BigEndian.PutUint32(entry, uint32(offset+shift))
+			}
+		}
+	}
+	return nil
+}
+
+// boxHeader собирает заголовок бокса той же длины, что был в файле (8 или 16 байт)
+func boxHeader(boxType string, header, size int64) []byte {
+	b := make([]byte, header)
+	copy(b[4:8], boxType)
+	if header == 16 {
+		binary.BigEndian.PutUint32(b[:4], 1)
+		binary.BigEndian.PutUint64(b[8:], uint64(size+16))
+		return b
+	}
+	binary.BigEndian.PutUint32(b[:4], uint32(size+8))
+	return b
+}
@@ -0,0 +1,339 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ID элементов Matroska, которые нужны для метаданных
+const (
+	idEBML            = 0x1A45DFA3
+	idSegment         = 0x18538067
+	idInfo            = 0x1549A966
+	idTimecodeScale   = 0x2AD7B1
+	idDuration        = 0x4489
+	idTracks          = 0x1654AE6B
+	idTrackEntry      = 0xAE
+	idTrackNumber     = 0xD7
+	idTrackType       = 0x83
+	idCodecID         = 0x86
+	idDefaultDuration = 0x23E383
+	idVideo           = 0xE0
+	idPixelWidth      = 0xB0
+	idPixelHeight     = 0xBA
+	idCluster         = 0x1F43B675
+	idTimecode        = 0xE7
+	idBlockGroup      = 0xA0
+	idBlock           = 0xA1
+	idSimpleBlock     = 0xA3
+
+	trackTypeVideo = 1
+
+	// maxElementSize - предел размера прочитываемого целиком элемента (строки и числа)
+	maxElementSize = 1 << 10
+	// unknownSize - размер элемента не указан (запись в реальном времени)
+	unknownSize = -1
+)
+
+// matroskaCodecs - кодеки по CodecID
+var matroskaCodecs = map[string]string{
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_AV1":            "av1",
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"V_MPEG2":          "mpeg2",
+	"V_THEORA":         "theora",
+	"V_MJPEG":          "mjpeg",
+	"V_PRORES":         "prores",
+	"V_FFV1":           "ffv1",
+}
+
+// matroskaTrack - видеодорожка, собираемая из элементов TrackEntry
+type matroskaTrack struct {
+	number          uint64
+	trackType       uint64
+	codecID         string
+	defaultDuration uint64
+	width, height   uint64
+}
+
+// matroskaProbe - состояние разбора; элементы обходятся плоско: мастер-элементы, внутри которых есть
+// нужные данные, не пропускаются, а "раскрываются", поэтому элементы неизвестного размера не мешают
+type matroskaProbe struct {
+	r             *reader
+	timecodeScale uint64
+	duration      float64
+	hasInfo       bool
+	hasTracks     bool
+	tracks        []matroskaTrack
+	clusterTime   uint64
+	lastTimecode  uint64
+	videoTrack    uint64
+}
+
+// probeMatroska разбирает Info и Tracks; если в Info нет длительности (так пишут браузеры через
+// MediaRecorder), она считается по временным меткам последнего блока видеодорожки
+func probeMatroska(r *reader) (Info, error) {
+	p := &matroskaProbe{r: r, timecodeScale: 1000000}
+
+	id, size, err := p.readElementHeader()
+	if err != nil || id != idEBML || size == unknownSize {
+		return Info{}, ErrInvalid
+	}
+	if err := r.skip(size); err != nil {
+		return Info{}, unexpectedEOF(err)
+	}
+
+	if err := p.scan(); err != nil {
+		return Info{}, err
+	}
+	if !p.hasTracks {
+		return Info{}, ErrInvalid
+	}
+	return p.info(), nil
+}
+
+func (p *matroskaProbe) scan() error {
+	for {
+		id, size, err := p.readElementHeader()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch id {
+		case idSegment, idInfo, idTracks, idVideo, idBlockGroup:
+			if id == idInfo {
+				p.hasInfo = true
+			}
+			if id == idTracks {
+				p.hasTracks = true
+			}
+			// Мастер-элемент: его дети читаются следующими итерациями
+		case idTrackEntry:
+			p.tracks = append(p.tracks, matroskaTrack{})
+		case idCluster:
+			if p.hasInfo && p.hasTracks && p.duration > 0 {
+				return nil
+			}
+			p.pickVideoTrack()
+		case idTimecodeScale, idDuration, idTrackNumber, idTrackType, idCodecID, idDefaultDuration, idPixelWidth, idPixelHeight, idTimecode:
+			value, err := p.readValue(size)
+			if err != nil {
+				return err
+			}
+			p.setValue(id, value)
+		case idSimpleBlock, idBlock:
+			if err := p.readBlock(size); err != nil {
+				return err
+			}
+		default:
+			if size == unknownSize {
+				return ErrInvalid
+			}
+			if err := p.r.skip(size); err != nil {
+				return unexpectedEOF(err)
+			}
+		}
+	}
+}
+
+func (p *matroskaProbe) setValue(id uint64, value []byte) {
+	track := &matroskaTrack{}
+	if len(p.tracks) > 0 {
+		track = &p.tracks[len(p.tracks)-1]
+	}
+
+	switch id {
+	case idTimecodeScale:
+		if scale := readUint(value); scale > 0 {
+			p.timecodeScale = scale
+		}
+	case idDuration:
+		p.duration = readFloat(value)
+	case idTrackNumber:
+		track.number = readUint(value)
+	case idTrackType:
+		track.trackType = readUint(value)
+	case idCodecID:
+		track.codecID = strings.TrimRight(string(value), "\x00")
+	case idDefaultDuration:
+		track.defaultDuration = readUint(value)
+	case idPixelWidth:
+		track.width = readUint(value)
+	case idPixelHeight:
+		track.height = readUint(value)
+	case idTimecode:
+		p.clusterTime = readUint(value)
+	}
+}
+
+// readBlock читает из Block/SimpleBlock номер дорожки и смещение времени, остальное пропускает
+func (p *matroskaProbe) readBlock(size int64) error {
+	if size < 4 {
+		return ErrInvalid
+	}
+	track, n, err := p.readVint(false)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	var offset [2]byte
+	if err := p.r.readFull(offset[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if err := p.r.skip(size - int64(n) - 2); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if p.videoTrack != 0 && track != p.videoTrack {
+		return nil
+	}
+	relative := int64(int16(binary.BigEndian.Uint16(offset[:])))
+	if timecode := int64(p.clusterTime) + relative; timecode > int64(p.lastTimecode) {
+		p.lastTimecode = uint64(timecode)
+	}
+	return nil
+}
+
+func (p *matroskaProbe) pickVideoTrack() {
+	if p.videoTrack != 0 {
+		return
+	}
+	if track := p.video(); track != nil {
+		p.videoTrack = track.number
+	}
+}
+
+func (p *matroskaProbe) video() *matroskaTrack {
+	for i := range p.tracks {
+		if p.tracks[i].trackType == trackTypeVideo {
+			return &p.tracks[i]
+		}
+	}
+	return nil
+}
+
+func (p *matroskaProbe) info() Info {
+	var info Info
+	ticks := p.duration
+	if ticks <= 0 {
+		ticks = float64(p.lastTimecode)
+	}
+	if ticks > 0 {
+		nanos := ticks * float64(p.timecodeScale)
+		if nanos < math.MaxInt64 {
+			info.Duration = time.Duration(nanos)
+		}
+	}
+
+	track := p.video()
+	if track == nil {
+		return info
+	}
+	if codec, ok := matroskaCodecs[track.codecID]; ok {
+		info.Codec = codec
+	} else {
+		info.Codec = track.codecID
+	}
+	info.Width = int(track.width)
+	info.Height = int(track.height)
+	if track.defaultDuration > 0 {
+		info.FrameRate = math.Round(1e9/float64(track.defaultDuration)*1000) / 1000
+	}
+	return info
+}
+
+// readElementHeader читает ID (с маркером длины, как он записан в спецификации) и размер элемента
+// io.EOF возвращается только если файл закончился ровно на границе элементов
+func (p *matroskaProbe) readElementHeader() (uint64, int64, error) {
+	id, n, err := p.readVint(true)
+	if err != nil {
+		if err == io.EOF && n == 0 {
+			return 0, 0, io.EOF
+		}
+		return 0, 0, unexpectedEOF(err)
+	}
+
+	size, n, err := p.readVint(false)
+	if err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	if size == 1<<(7*n)-1 {
+		return id, unknownSize, nil
+	}
+	if size > math.MaxInt64 {
+		return 0, 0, ErrInvalid
+	}
+	return id, int64(size), nil
+}
+
+// readVint читает целое переменной длины EBML; keepMarker оставляет бит длины (так кодируются ID)
+// Возвращает значение и количество прочитанных байтов
+func (p *matroskaProbe) readVint(keepMarker bool) (uint64, int, error) {
+	var first [1]byte
+	n, err := io.ReadFull(p.r.r, first[:])
+	p.r.pos += int64(n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 1, ErrInvalid
+	}
+
+	value := uint64(first[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+	if length > 1 {
+		rest := make([]byte, length-1)
+		if err := p.r.readFull(rest); err != nil {
+			return 0, 1, unexpectedEOF(err)
+		}
+		for _, b := range rest {
+			value = value<<8 | uint64(b)
+		}
+	}
+	return value, length, nil
+}
+
+// readValue читает содержимое небольшого элемента целиком
+func (p *matroskaProbe) readValue(size int64) ([]byte, error) {
+	if size < 0 || size > maxElementSize {
+		return nil, ErrInvalid
+	}
+	value := make([]byte, size)
+	if err := p.r.readFull(value); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return value, nil
+}
+
+func readUint(value []byte) uint64 {
+	var n uint64
+	for _, b := range value {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+func readFloat(value []byte) float64 {
+	switch len(value) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(value)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(value))
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrUnsupported - формат контейнера не поддерживается
+	ErrUnsupported = errors.New("unsupported media container")
+	// ErrInvalid - контейнер поврежден или в нем нет нужных метаданных
+	ErrInvalid = errors.New("invalid media container")
+)
+
+// Info - параметры видео, извлеченные из заголовков контейнера; нулевые поля не удалось определить
+type Info struct {
+	Duration  time.Duration
+	Width     int
+	Height    int
+	Codec     string
+	FrameRate float64
+	// Bitrate - средний битрейт файла целиком (бит/с)
+	Bitrate int64
+}
+
+// ContentTypes - MIME-типы контейнеров, которые умеет разбирать Probe
+var ContentTypes = []string{"video/mp4", "video/quicktime", "video/x-m4v", "video/3gpp", "video/webm", "video/x-matroska"}
+
+func Supported(mime string) bool {
+	return slices.Contains(ContentTypes, mime)
+}
+
+// Probe читает метаданные видео из r; size - размер файла для расчета битрейта (<= 0 - неизвестен)
+// Читается только нужная часть файла: если r умеет Seek, данные кадров пропускаются без чтения
+func Probe(r io.Reader, size int64, mime string) (Info, error) {
+	var (
+		info Info
+		err  error
+	)
+	src := newReader(r)
+	switch mime {
+	case "video/mp4", "video/quicktime", "video/x-m4v", "video/3gpp":
+		info, err = probeMP4(src)
+	case "video/webm", "video/x-matroska":
+		info, err = probeMatroska(src)
+	default:
+		return Info{}, ErrUnsupported
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	if size > 0 && info.Duration > 0 {
+		info.Bitrate = int64(float64(size*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+// reader - последовательное чтение с пропуском байтов через Seek, если источник его поддерживает
+type reader struct {
+	r   io.Reader
+	pos int64
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{r: r}
+}
+
+func (r *reader) readFull(p []byte) error {
+	n, err := io.ReadFull(r.r, p)
+	r.pos += int64(n)
+	return err
+}
+
+func (r *reader) skip(n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	if seeker, ok := r.r.(io.Seeker); ok {
+		if _, err := seeker.Seek(n, io.SeekCurrent); err != nil {
+			return err
+		}
+		r.pos += n
+		return nil
+	}
+
+	copied, err := io.CopyN(io.Discard, r.r, n)
+	r.pos += copied
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// unexpectedEOF превращает обрыв файла внутри структуры в ErrInvalid
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrInvalid
+	}
+	return err
+}
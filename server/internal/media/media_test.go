@@ -0,0 +1,385 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	out = append(out, typ...)
+	return append(out, body...)
+}
+
+func u32(values ...uint32) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.BigEndian.AppendUint32(out, v)
+	}
+	return out
+}
+
+// testMP4 собирает MP4 с видеодорожкой h264 1280x720, 300 кадров по 512 тиков при timescale 15360 (30 fps, 10 с)
+// moovFirst - faststart-раскладка, иначе moov после mdat
+func testMP4(moovFirst bool) []byte {
+	mvhd := mp4Box("mvhd", u32(0, 0, 0, 1000, 10000), make([]byte, 80))
+	mdhd := mp4Box("mdhd", u32(0, 0, 0, 15360, 300*512), make([]byte, 4))
+	hdlr := mp4Box("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12))
+
+	visual := make([]byte, 78)
+	binary.BigEndian.PutUint16(visual[24:], 1280)
+	binary.BigEndian.PutUint16(visual[26:], 720)
+	stsd := mp4Box("stsd", u32(0, 1), mp4Box("avc1", visual))
+	stts := mp4Box("stts", u32(0, 1, 300, 512))
+	// Два чанка: в начале mdat и с середины; в mdat по этим смещениям лежат метки
+	moov := func(mdatAt int) []byte {
+		stco := mp4Box("stco", u32(0, 2, uint32(mdatAt+8), uint32(mdatAt+8+2048)))
+		stbl := mp4Box("stbl", stsd, stts, stco)
+		trak := mp4Box("trak", mp4Box("tkhd", make([]byte, 84)), mp4Box("mdia", mdhd, hdlr, mp4Box("minf", stbl)))
+		return mp4Box("moov", mvhd, trak)
+	}
+
+	ftyp := mp4Box("ftyp", []byte("isom"), u32(512), []byte("isomavc1"))
+	payload := make([]byte, 4096)
+	copy(payload, "CHUNK-1")
+	copy(payload[2048:], "CHUNK-2")
+	mdat := mp4Box("mdat", payload)
+	if moovFirst {
+		return bytes.Join([][]byte{ftyp, moov(len(ftyp) + len(moov(0))), mdat}, nil)
+	}
+	return bytes.Join([][]byte{ftyp, mdat, moov(len(ftyp))}, nil)
+}
+
+func ebml(id uint64, payload ...[]byte) []byte {
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	body := bytes.Join(payload, nil)
+	// Размер всегда 8-байтовым vint, как пишут некоторые муксеры
+	out = append(out, 0x01)
+	out = append(out, binary.BigEndian.AppendUint64(nil, uint64(len(body)))[1:]...)
+	return append(out, body...)
+}
+
+func ebmlUint(id uint64, v uint64) []byte {
+	return ebml(id, binary.BigEndian.AppendUint64(nil, v))
+}
+
+func simpleBlock(track byte, relative int16) []byte {
+	return ebml(idSimpleBlock, []byte{0x80 | track}, binary.BigEndian.AppendUint16(nil, uint16(relative)), []byte{0x80, 0, 0, 0})
+}
+
+// testWebM собирает WebM с дорожкой VP9 1920x1080, 60 fps; withDuration=false - как запись MediaRecorder
+func testWebM(withDuration bool) []byte {
+	header := ebml(idEBML, ebml(0x4282, []byte("webm")))
+
+	info := [][]byte{ebmlUint(idTimecodeScale, 1000000)}
+	if withDuration {
+		info = append(info, ebml(idDuration, binary.BigEndian.AppendUint64(nil, math.Float64bits(2500))))
+	}
+	tracks := ebml(idTracks,
+		ebml(idTrackEntry, ebmlUint(idTrackNumber, 2), ebmlUint(idTrackType, 2), ebml(idCodecID, []byte("A_OPUS"))),
+		ebml(idTrackEntry, ebmlUint(idTrackNumber, 1), ebmlUint(idTrackType, 1), ebml(idCodecID, []byte("V_VP9")),
+			ebmlUint(idDefaultDuration, 16666667),
+			ebml(idVideo, ebmlUint(idPixelWidth, 1920), ebmlUint(idPixelHeight, 1080))),
+	)
+	clusters := [][]byte{
+		ebml(idCluster, ebmlUint(idTimecode, 0), simpleBlock(1, 0), simpleBlock(1, 1000)),
+		ebml(idCluster, ebmlUint(idTimecode, 2000), simpleBlock(1, 0), simpleBlock(1, 483), simpleBlock(2, 499)),
+	}
+
+	// Segment неизвестного размера: 0x01FFFFFFFFFFFFFF
+	segment := append([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		bytes.Join(append([][]byte{ebml(idInfo, info...), tracks}, clusters...), nil)...)
+	return append(header, segment...)
+}
+
+// TestProbeMP4 проверяет разбор moov и до, и после mdat, в том числе без Seek
+func TestProbeMP4(t *testing.T) {
+	for _, moovFirst := range []bool{true, false} {
+		data := testMP4(moovFirst)
+
+		for _, src := range []io.Reader{bytes.NewReader(data), io.MultiReader(bytes.NewReader(data))} {
+			info, err := Probe(src, int64(len(data)), "video/mp4")
+			require.NoError(t, err)
+
+			assert.Equal(t, 10*time.Second, info.Duration)
+			assert.Equal(t, 1280, info.Width)
+			assert.Equal(t, 720, info.Height)
+			assert.Equal(t, "h264", info.Codec)
+			assert.Equal(t, 30.0, info.FrameRate)
+			assert.Equal(t, int64(len(data)*8/10), info.Bitrate)
+		}
+	}
+}
+
+// TestProbeWebM проверяет длительность из Info и, если ее нет, по последнему блоку видеодорожки
+func TestProbeWebM(t *testing.T) {
+	tests := []struct {
+		name         string
+		withDuration bool
+		want         time.Duration
+	}{
+		{name: "duration in info", withDuration: true, want: 2500 * time.Millisecond},
+		{name: "live recording", withDuration: false, want: 2483 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testWebM(tt.withDuration)
+
+			info, err := Probe(bytes.NewReader(data), int64(len(data)), "video/webm")
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, info.Duration)
+			assert.Equal(t, 1920, info.Width)
+			assert.Equal(t, 1080, info.Height)
+			assert.Equal(t, "vp9", info.Codec)
+			assert.Equal(t, 60.0, info.FrameRate)
+		})
+	}
+}
+
+// TestProbe_Invalid проверяет, что обрезанные и чужие файлы не принимаются за видео
+func TestProbe_Invalid(t *testing.T) {
+	mp4 := testMP4(false)
+	_, err := Probe(bytes.NewReader(mp4[:len(mp4)-20]), 0, "video/mp4")
+	assert.ErrorIs(t, err, ErrInvalid, "moov обрезан")
+
+	_, err = Probe(bytes.NewReader(mp4[:100]), 0, "video/mp4")
+	assert.ErrorIs(t, err, ErrInvalid, "moov отсутствует")
+
+	_, err = Probe(bytes.NewReader([]byte("not a webm file")), 0, "video/webm")
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = Probe(bytes.NewReader(mp4), 0, "video/ogg")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+// chunkOffsets возвращает смещения из stco единственной дорожки testMP4
+func chunkOffsets(t *testing.T, data []byte) []uint32 {
+	stco := child(data, "moov", "trak", "mdia", "minf", "stbl", "stco")
+	require.NotNil(t, stco)
+	count := int(binary.BigEndian.Uint32(stco[4:8]))
+	offsets := make([]uint32, count)
+	for i := range offsets {
+		offsets[i] = binary.BigEndian.Uint32(stco[8+i*4:])
+	}
+	return offsets
+}
+
+// TestFaststart проверяет перенос moov перед mdat со сдвигом смещений чанков
+func TestFaststart(t *testing.T) {
+	plan, err := PlanFaststart(bytes.NewReader(testMP4(true)))
+	require.NoError(t, err)
+	assert.Nil(t, plan, "moov уже в начале")
+
+	data := testMP4(false)
+	plan, err = PlanFaststart(io.MultiReader(bytes.NewReader(data)))
+	require.NoError(t, err)
+	require.NotNil(t, plan)
+
+	var out bytes.Buffer
+	require.NoError(t, plan.Write(&out, bytes.NewReader(data)))
+	remuxed := out.Bytes()
+
+	assert.Equal(t, len(data), len(remuxed))
+	assert.Equal(t, testMP4(true), remuxed, "результат совпадает с faststart-раскладкой того же файла")
+	for i, offset := range chunkOffsets(t, remuxed) {
+		assert.Equal(t, fmt.Sprintf("CHUNK-%d", i+1), string(remuxed[offset:offset+7]))
+	}
+
+	_, err = PlanFaststart(bytes.NewReader(data[:len(data)-20]))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// testSample - сэмпл длины size, начинающийся с метки дорожки и своего номера
+func testSample(marker byte, index, size int) []byte {
+	b := make([]byte, size)
+	b[0] = marker
+	binary.BigEndian.PutUint32(b[1:], uint32(index))
+	return b
+}
+
+// testAVMP4 собирает 10 с MP4 с moov в начале: видео 30 fps (ключевой кадр раз в секунду) и аудио 48 кГц
+// по 1024 сэмпла; чанки дорожек чередуются посекундно
+func testAVMP4() []byte {
+	const audioTotal = 469
+
+	var payload []byte
+	var videoChunks, audioChunks, audioStsc []uint32
+	a := 0
+	for sec := 0; sec < 10; sec++ {
+		videoChunks = append(videoChunks, uint32(len(payload)))
+		for i := sec * 30; i < sec*30+30; i++ {
+			payload = append(payload, testSample('V', i, 64)...)
+		}
+		audioChunks = append(audioChunks, uint32(len(payload)))
+		n := 0
+		for ; a < audioTotal && a*1024 < (sec+1)*48000; a++ {
+			payload = append(payload, testSample('A', a, 16)...)
+			n++
+		}
+		audioStsc = append(audioStsc, uint32(sec+1), uint32(n), 1)
+	}
+
+	var stss []uint32
+	for i := 0; i < 300; i += 30 {
+		stss = append(stss, uint32(i+1))
+	}
+	audioSizes := make([]uint32, audioTotal)
+	for i := range audioSizes {
+		audioSizes[i] = 16
+	}
+	offsets := func(chunks []uint32, base int) []byte {
+		out := u32(0, uint32(len(chunks)))
+		for _, c := range chunks {
+			out = append(out, u32(c+uint32(base))...)
+		}
+		return out
+	}
+	trak := func(handler string, timescale, duration uint32, stbl ...[]byte) []byte {
+		mdhd := mp4Box("mdhd", u32(0, 0, 0, timescale, duration), make([]byte, 4))
+		hdlr := mp4Box("hdlr", u32(0, 0), []byte(handler), make([]byte, 12))
+		return mp4Box("trak", mp4Box("tkhd", make([]byte, 84)), mp4Box("edts", mp4Box("elst", u32(0, 0))),
+			mp4Box("mdia", mdhd, hdlr, mp4Box("minf", mp4Box("stbl", stbl...))))
+	}
+	moov := func(base int) []byte {
+		video := trak("vide", 15360, 300*512,
+			mp4Box("stsd", u32(0, 1), mp4Box("avc1", make([]byte, 78))),
+			mp4Box("stts", u32(0, 1, 300, 512)),
+			mp4Box("stss", u32(0, uint32(len(stss))), u32(stss...)),
+			mp4Box("stsz", u32(0, 64, 300)),
+			mp4Box("stsc", u32(0, 1, 1, 30, 1)),
+			mp4Box("stco", offsets(videoChunks, base)))
+		audio := trak("soun", 48000, audioTotal*1024,
+			mp4Box("stsd", u32(0, 1), mp4Box("mp4a", make([]byte, 28))),
+			mp4Box("stts", u32(0, 1, audioTotal, 1024)),
+			mp4Box("stsz", u32(0, 0, audioTotal), u32(audioSizes...)),
+			mp4Box("stsc", u32(0, uint32(len(audioStsc)/3)), u32(audioStsc...)),
+			mp4Box("stco", offsets(audioChunks, base)))
+		return mp4Box("moov", mp4Box("mvhd", u32(0, 0, 0, 1000, 10000), make([]byte, 80)), video, audio)
+	}
+
+	ftyp := mp4Box("ftyp", []byte("isom"), u32(512), []byte("isomavc1"))
+	base := len(ftyp) + len(moov(0)) + 8
+	return bytes.Join([][]byte{ftyp, moov(base), mp4Box("mdat", payload)}, nil)
+}
+
+// TestPlanClip проверяет вырезку от ключевого кадра с пересчетом таблиц обеих дорожек
+func TestPlanClip(t *testing.T) {
+	data := testAVMP4()
+	plan, err := PlanClip(io.MultiReader(bytes.NewReader(data)), int64(len(data)), 2500*time.Millisecond, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, plan.Start, "начало сдвигается к ключевому кадру")
+	assert.Equal(t, 5*time.Second, plan.End)
+
+	var out bytes.Buffer
+	require.NoError(t, plan.Write(&out, io.MultiReader(bytes.NewReader(data))))
+	clip := out.Bytes()
+	assert.Equal(t, plan.Size(), int64(len(clip)))
+
+	info, err := Probe(bytes.NewReader(clip), int64(len(clip)), "video/mp4")
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, info.Duration)
+	assert.Equal(t, 30.0, info.FrameRate)
+
+	var tracks []*clipTrack
+	for _, b := range children(child(clip, "moov")) {
+		if b.typ == "trak" {
+			assert.Nil(t, child(b.data, "edts"))
+			track, err := parseClipTrack(b.data, "", int64(len(clip)))
+			require.NoError(t, err)
+			tracks = append(tracks, track)
+		}
+	}
+	require.Len(t, tracks, 2)
+
+	video, audio := tracks[0], tracks[1]
+	require.Len(t, video.sizes, 90)
+	assert.True(t, video.isSync(0))
+	assert.True(t, video.isSync(30))
+	assert.False(t, video.isSync(1))
+	for i, offset := range video.offsets {
+		require.Equal(t, byte('V'), clip[offset])
+		require.Equal(t, uint32(60+i), binary.BigEndian.Uint32(clip[offset+1:]))
+	}
+
+	// Аудио в [2 с, 5 с): сэмплы 94..234
+	require.Len(t, audio.sizes, 141)
+	for i, offset := range audio.offsets {
+		require.Equal(t, byte('A'), clip[offset])
+		require.Equal(t, uint32(94+i), binary.BigEndian.Uint32(clip[offset+1:]))
+	}
+}
+
+// TestPlanClip_InvalidRange проверяет границы за пределами видео и пустой интервал
+func TestPlanClip_InvalidRange(t *testing.T) {
+	data := testAVMP4()
+
+	_, err := PlanClip(bytes.NewReader(data), int64(len(data)), 20*time.Second, 30*time.Second)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	_, err = PlanClip(bytes.NewReader(data), int64(len(data)), 5*time.Second, 5*time.Second)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	_, err = PlanClip(bytes.NewReader(data[:100]), 100, 0, time.Second)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// testLyingMP4 собирает крошечный MP4, таблицы которого описывают count сэмплов по size байт
+// в одном чанке по смещению offset
+func testLyingMP4(count, size, offset uint32) []byte {
+	hdlr := mp4Box("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12))
+	stbl := mp4Box("stbl",
+		mp4Box("stsd", u32(0, 1), mp4Box("avc1", make([]byte, 78))),
+		mp4Box("stts", u32(0, 1, count, 1)),
+		mp4Box("stsz", u32(0, size, count)),
+		mp4Box("stsc", u32(0, 1, 1, count, 1)),
+		mp4Box("stco", u32(0, 1, offset)))
+	trak := mp4Box("trak", mp4Box("tkhd", make([]byte, 84)),
+		mp4Box("mdia", mp4Box("mdhd", u32(0, 0, 0, 1000, count), make([]byte, 4)), hdlr, mp4Box("minf", stbl)))
+	moov := mp4Box("moov", mp4Box("mvhd", u32(0, 0, 0, 1000, count), make([]byte, 80)), trak)
+	return bytes.Join([][]byte{mp4Box("ftyp", []byte("isom"), u32(512)), moov, mp4Box("mdat", make([]byte, 16))}, nil)
+}
+
+// TestPlanClip_TablesLargerThanFile проверяет, что таблицы сэмплов больше самого файла отклоняются
+// без разворачивания: иначе файл в сотни байт заставляет выделить гигабайты
+func TestPlanClip_TablesLargerThanFile(t *testing.T) {
+	tests := []struct {
+		name                string
+		count, size, offset uint32
+	}{
+		{name: "sample count", count: 1 << 24, size: 1, offset: 0},
+		{name: "total size", count: 4, size: 1 << 20, offset: 0},
+		{name: "chunk offset", count: 4, size: 4, offset: 1 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testLyingMP4(tt.count, tt.size, tt.offset)
+			require.Less(t, len(data), 1024)
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			_, err := PlanClip(bytes.NewReader(data), int64(len(data)), 0, time.Second)
+			runtime.ReadMemStats(&after)
+
+			assert.ErrorIs(t, err, ErrInvalid)
+			assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// maxMoovSize - предел размера moov: таблицы сэмплов многочасового видео занимают единицы мегабайт
+const maxMoovSize = 64 << 20
+
+// mp4Codecs - кодеки по типу sample entry из stsd
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"s263": "h263",
+	"jpeg": "mjpeg",
+	"apcn": "prores",
+	"apch": "prores",
+	"apcs": "prores",
+	"apco": "prores",
+	"ap4h": "prores",
+}
+
+// probeMP4 ищет на верхнем уровне файла бокс moov (он может быть и до, и после mdat) и разбирает его
+func probeMP4(r *reader) (Info, error) {
+	for {
+		boxType, size, err := readBoxHeader(r)
+		if err == io.EOF {
+			return Info{}, ErrInvalid
+		}
+		if err != nil {
+			return Info{}, unexpectedEOF(err)
+		}
+
+		if boxType != "moov" {
+			if size < 0 {
+				// Бокс до конца файла, moov за ним быть не может
+				return Info{}, ErrInvalid
+			}
+			if err := r.skip(size); err != nil {
+				return Info{}, unexpectedEOF(err)
+			}
+			continue
+		}
+
+		if size < 0 || size > maxMoovSize {
+			return Info{}, ErrInvalid
+		}
+		moov := make([]byte, size)
+		if err := r.readFull(moov); err != nil {
+			return Info{}, unexpectedEOF(err)
+		}
+		return parseMoov(moov)
+	}
+}
+
+// readBoxHeader читает заголовок бокса и возвращает размер его содержимого; -1 - бокс до конца файла
+func readBoxHeader(r *reader) (string, int64, error) {
+	var header [8]byte
+	n, err := io.ReadFull(r.r, header[:])
+	r.pos += int64(n)
+	if err != nil {
+		if n == 0 {
+			return "", 0, io.EOF
+		}
+		return "", 0, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:4]))
+	boxType := string(header[4:8])
+	switch size {
+	case 0:
+		return boxType, -1, nil
+	case 1:
+		var large [8]byte
+		if err := r.readFull(large[:]); err != nil {
+			return "", 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(large[:])) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, ErrInvalid
+	}
+	return boxType, size, nil
+}
+
+// box - бокс внутри moov, уже прочитанного в память
+type box struct {
+	typ  string
+	data []byte
+}
+
+// children разбирает последовательность боксов в data; битый хвост отбрасывается
+func children(data []byte) []box {
+	var boxes []box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return boxes
+		}
+		boxes = append(boxes, box{typ: typ, data: data[header:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+func child(data []byte, path ...string) []byte {
+	for _, typ := range path {
+		found := false
+		for _, b := range children(data) {
+			if b.typ == typ {
+				data, found = b.data, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return data
+}
+
+func parseMoov(moov []byte) (Info, error) {
+	timescale, duration, ok := parseDuration(child(moov, "mvhd"))
+	if !ok {
+		return Info{}, ErrInvalid
+	}
+	if duration == 0 {
+		// Фрагментированный MP4: длительность всех фрагментов лежит в mvex/mehd
+		duration = fullBoxValue(child(moov, "mvex", "mehd"))
+	}
+
+	var info Info
+	if timescale > 0 {
+		info.Duration = ticksToDuration(duration, timescale)
+	}
+
+	for _, trak := range children(moov) {
+		if trak.typ != "trak" {
+			continue
+		}
+		mdia := child(trak.data, "mdia")
+		if handler := child(mdia, "hdlr"); len(handler) < 12 || string(handler[8:12]) != "vide" {
+			continue
+		}
+
+		parseVideoTrack(mdia, &info)
+		break
+	}
+	return info, nil
+}
+
+// parseVideoTrack заполняет кодек, разрешение и частоту кадров из mdia видеодорожки
+func parseVideoTrack(mdia []byte, info *Info) {
+	stbl := child(mdia, "minf", "stbl")
+
+	// stsd: version/flags, entry_count, затем первый sample entry (VisualSampleEntry)
+	if stsd := child(stbl, "stsd"); len(stsd) >= 8 {
+		entries := children(stsd[8:])
+		if len(entries) > 0 {
+			entry := entries[0]
+			if codec, ok := mp4Codecs[entry.typ]; ok {
+				info.Codec = codec
+			} else {
+				info.Codec = entry.typ
+			}
+			// reserved(6) data_reference_index(2) pre_defined(2) reserved(2) pre_defined(12) width(2) height(2)
+			if len(entry.data) >= 28 {
+				info.Width = int(binary.BigEndian.Uint16(entry.data[24:26]))
+				info.Height = int(binary.BigEndian.Uint16(entry.data[26:28]))
+			}
+		}
+	}
+
+	timescale, duration, ok := parseDuration(child(mdia, "mdhd"))
+	if !ok || timescale == 0 || duration == 0 {
+		return
+	}
+
+	// stts: version/flags, entry_count, затем пары (sample_count, sample_delta)
+	stts := child(stbl, "stts")
+	if len(stts) < 8 {
+		return
+	}
+	count := int(binary.BigEndian.Uint32(stts[4:8]))
+	var samples uint64
+	for i := 0; i < count && 8+i*8+8 <= len(stts); i++ {
+		samples += uint64(binary.BigEndian.Uint32(stts[8+i*8:]))
+	}
+	if samples > 0 {
+		fps := float64(samples) * float64(timescale) / float64(duration)
+		info.FrameRate = math.Round(fps*1000) / 1000
+	}
+}
+
+// parseDuration читает timescale и duration из mvhd или mdhd (у них одинаковое начало)
+func parseDuration(data []byte) (uint32, uint64, bool) {
+	if len(data) < 4 {
+		return 0, 0, false
+	}
+	if data[0] == 1 {
+		// version 1: creation_time и modification_time по 8 байт
+		if len(data) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(data[20:24]), binary.BigEndian.Uint64(data[24:32]), true
+	}
+	// version 0: creation_time и modification_time по 4 байта
+	if len(data) < 20 {
+		return 0, 0, false
+	}
+	timescale := binary.BigEndian.Uint32(data[12:16])
+	duration := uint64(binary.BigEndian.Uint32(data[16:20]))
+	if duration == math.MaxUint32 {
+		// Все единицы в версии 0 - длительность неизвестна
+		duration = 0
+	}
+	return timescale, duration, true
+}
+
+// fullBoxValue читает единственное поле full box размером 4 или 8 байт в зависимости от версии
+func fullBoxValue(data []byte) uint64 {
+	switch {
+	case len(data) >= 12 && data[0] == 1:
+		return binary.BigEndian.Uint64(data[4:12])
+	case len(data) >= 8:
+		return uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	return 0
+}
+
+func ticksToDuration(ticks uint64, timescale uint32) time.Duration {
+	seconds := float64(ticks) / float64(timescale)
+	if seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -10,11 +10,39 @@ import (
 
 const (
 	contextKeyUserID = "user_id"
+	queryToken       = "token"
 )
 
-func AuthMiddleware(authService AuthServiceInterface, logger *slog.Logger) gin.HandlerFunc {
+type authConfig struct {
+	queryToken bool
+}
+
+// AuthOption настраивает AuthMiddleware
+type AuthOption func(*authConfig)
+
+// WithoutQueryToken отключает устаревшую передачу JWT в ?token=: токен попадает в логи доступа,
+// историю браузера и Referer. Файлы для <video> открываются по подписанным ссылкам
+func WithoutQueryToken() AuthOption {
+	return func(cfg *authConfig) {
+		cfg.queryToken = false
+	}
+}
+
+func AuthMiddleware(authService AuthServiceInterface, logger *slog.Logger, opts ...AuthOption) gin.HandlerFunc {
+	cfg := authConfig{queryToken: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return func(c *gin.Context) {
-		token := extractToken(c)
+		token := bearerToken(c)
+		if cfg.queryToken {
+			token = extractToken(c)
+			if c.Query(queryToken) != "" {
+				logger.Warn("JWT in query string is deprecated, use signed download URLs",
+					slog.String("path", c.Request.URL.Path))
+			}
+		}
 		if token == "" {
 			logger.Warn("missing authorization token")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
@@ -22,13 +50,9 @@ func AuthMiddleware(authService AuthServiceInterface, logger *slog.Logger) gin.H
 			return
 		}
 
-		logger.Debug("extracted token", slog.String("token_preview", token[:min(20, len(token))]+"..."))
-
 		userID, err := authService.ValidateToken(token)
 		if err != nil {
-			logger.Warn("invalid token",
-				slog.String("error", err.Error()),
-				slog.String("token_preview", token[:min(20, len(token))]))
+			logger.Warn("invalid token", slog.String("error", err.Error()))
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный или истекший токен"})
 			c.Abort()
 			return
@@ -46,11 +70,15 @@ func AuthMiddleware(authService AuthServiceInterface, logger *slog.Logger) gin.H
 
 func extractToken(c *gin.Context) string {
 	// Try query parameter first (for video/file requests)
-	if token := c.Query("token"); token != "" {
+	if token := c.Query(queryToken); token != "" {
 		return token
 	}
 
-	// Try Authorization header
+	return bearerToken(c)
+}
+
+// bearerToken - токен из заголовка Authorization: Bearer
+func bearerToken(c *gin.Context) string {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		return ""
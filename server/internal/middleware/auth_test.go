@@ -327,3 +327,31 @@ func TestMin(t *testing.T) {
 		assert.Equal(t, tc.expected, result)
 	}
 }
+
+// TestAuthMiddleware_WithoutQueryToken проверяет, что отключенный ?token= не принимается, а заголовок - да
+func TestAuthMiddleware_WithoutQueryToken(t *testing.T) {
+	mockAuthService := new(MockAuthService)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	router := setupTestRouter()
+	userID := uuid.New()
+	mockAuthService.On("ValidateToken", "header-token").Return(&userID, nil)
+
+	router.Use(AuthMiddleware(mockAuthService, logger, WithoutQueryToken()))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	req, _ := http.NewRequest("GET", "/test?token=query-token", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/test?token=query-token", nil)
+	req.Header.Set("Authorization", "Bearer header-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockAuthService.AssertNotCalled(t, "ValidateToken", "query-token")
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupBodyLimitRouter возвращает router с лимитом 4 байта и эндпоинтом, читающим все тело
+func setupBodyLimitRouter() *gin.Engine {
+	router := setupTestRouter()
+	router.Use(MaxBodySize(4))
+	router.POST("/upload", func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				c.Status(http.StatusRequestEntityTooLarge)
+				return
+			}
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// TestMaxBodySize_ContentLength проверяет отказ по Content-Length без чтения тела
+func TestMaxBodySize_ContentLength(t *testing.T) {
+	router := setupBodyLimitRouter()
+
+	req, _ := http.NewRequest("POST", "/upload", strings.NewReader("too large"))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"error":"request body too large","max_bytes":4}`, w.Body.String())
+}
+
+// TestMaxBodySize_UnknownLength проверяет обрыв чтения тела без Content-Length
+func TestMaxBodySize_UnknownLength(t *testing.T) {
+	router := setupBodyLimitRouter()
+
+	req, _ := http.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader("too large")))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+// TestMaxBodySize_WithinLimit проверяет, что небольшие запросы проходят
+func TestMaxBodySize_WithinLimit(t *testing.T) {
+	router := setupBodyLimitRouter()
+
+	req, _ := http.NewRequest("POST", "/upload", strings.NewReader("ok"))
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
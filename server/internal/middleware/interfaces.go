@@ -1,8 +1,17 @@
 package middleware
 
-import "github.com/google/uuid"
+import (
+	"net/url"
+
+	"github.com/google/uuid"
+)
 
 // AuthServiceInterface определяет методы для аутентификации
 type AuthServiceInterface interface {
 	ValidateToken(token string) (*uuid.UUID, error)
 }
+
+// DownloadURLVerifierInterface проверяет подписанные ссылки на файл реплея
+type DownloadURLVerifierInterface interface {
+	Verify(replayID uuid.UUID, query url.Values) (uuid.UUID, error)
+}
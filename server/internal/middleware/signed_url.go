@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/fckoffmw/replay-service/server/internal/signedurl"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const paramReplayID = "replay_id"
+
+// SignedDownload пускает к файлу реплея по подписанной ссылке без JWT
+// Запрос без подписи авторизуется через auth
+func SignedDownload(verifier DownloadURLVerifierInterface, auth gin.HandlerFunc, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if !signedurl.Signed(query) {
+			auth(c)
+			return
+		}
+
+		replayID, err := uuid.Parse(c.Param(paramReplayID))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid replay_id"})
+			c.Abort()
+			return
+		}
+
+		userID, err := verifier.Verify(replayID, query)
+		if err != nil {
+			logger.Warn("invalid signed url",
+				slog.String("replay_id", replayID.String()),
+				slog.String("error", err.Error()))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверная или истекшая ссылка"})
+			c.Abort()
+			return
+		}
+
+		c.Set(contextKeyUserID, userID)
+		c.Next()
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/signedurl"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSignedDownloadRouter(signer *signedurl.Signer, authService *MockAuthService) *gin.Engine {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := setupTestRouter()
+	router.Use(SignedDownload(signer, AuthMiddleware(authService, logger, WithoutQueryToken()), logger))
+	router.GET("/replays/:replay_id/file", func(c *gin.Context) {
+		c.String(http.StatusOK, c.MustGet(contextKeyUserID).(uuid.UUID).String())
+	})
+	return router
+}
+
+// TestSignedDownload_ValidSignature проверяет доступ к файлу по подписанной ссылке без JWT
+func TestSignedDownload_ValidSignature(t *testing.T) {
+	mockAuthService := new(MockAuthService)
+	signer := signedurl.NewSigner("secret", time.Minute)
+	router := setupSignedDownloadRouter(signer, mockAuthService)
+	replayID, userID := uuid.New(), uuid.New()
+
+	query, _ := signer.Sign(replayID, userID)
+	req, _ := http.NewRequest("GET", "/replays/"+replayID.String()+"/file?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userID.String(), w.Body.String())
+	mockAuthService.AssertNotCalled(t, "ValidateToken")
+}
+
+// TestSignedDownload_OtherReplay проверяет, что подпись одного реплея не открывает другой
+func TestSignedDownload_OtherReplay(t *testing.T) {
+	signer := signedurl.NewSigner("secret", time.Minute)
+	router := setupSignedDownloadRouter(signer, new(MockAuthService))
+
+	query, _ := signer.Sign(uuid.New(), uuid.New())
+	req, _ := http.NewRequest("GET", "/replays/"+uuid.New().String()+"/file?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestSignedDownload_FallbackToAuth проверяет, что запрос без подписи авторизуется JWT
+func TestSignedDownload_FallbackToAuth(t *testing.T) {
+	mockAuthService := new(MockAuthService)
+	router := setupSignedDownloadRouter(signedurl.NewSigner("secret", time.Minute), mockAuthService)
+	userID := uuid.New()
+	mockAuthService.On("ValidateToken", "valid-token").Return(&userID, nil)
+
+	req, _ := http.NewRequest("GET", "/replays/"+uuid.New().String()+"/file", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, userID.String(), w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/replays/"+uuid.New().String()+"/file", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Blob - файл в хранилище, общий для всех реплеев с одинаковым содержимым
+// SizeBytes - размер файла в хранилище (после сжатия и шифрования), RefCount - число реплеев, ссылающихся на файл
+// WrappedKey - ключ данных, обернутый мастер-ключом KeyID; nil - файл не зашифрован
+type Blob struct {
+	SHA256      string    `json:"sha256"`
+	FilePath    string    `json:"-"`
+	Compression string    `json:"compression"`
+	Compressed  bool      `json:"compressed"`
+	SizeBytes   int64     `json:"size_bytes"`
+	KeyID       string    `json:"-"`
+	WrappedKey  []byte    `json:"-"`
+	RefCount    int       `json:"ref_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
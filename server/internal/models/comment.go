@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comment - комментарий в обсуждении реплея
+// У удаленного комментария Body пустой: он остается в ветке, чтобы ответы на него не терялись
+type Comment struct {
+	ID          uuid.UUID  `json:"id"`
+	ReplayID    uuid.UUID  `json:"replay_id"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty"`
+	AuthorID    uuid.UUID  `json:"author_id"`
+	AuthorLogin string     `json:"author_login,omitempty"`
+	Body        string     `json:"body"`
+	// TimeMS - момент реплея, к которому относится комментарий
+	TimeMS     *int64     `json:"time_ms,omitempty"`
+	ReplyCount int        `json:"reply_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	Deleted    bool       `json:"deleted"`
+}
+
+// CommentRevision - прежняя версия текста комментария, действовавшая с CreatedAt до ReplacedAt
+type CommentRevision struct {
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// CommentCursor - позиция в ленте комментариев: последний комментарий предыдущей страницы
+type CommentCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// CommentPage - страница комментариев; NextCursor пустой на последней странице
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// SignedURL - подписанная ссылка на файл реплея, открывающаяся без JWT до ExpiresAt
+type SignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -12,4 +12,24 @@ type Game struct {
 	UserID      uuid.UUID `json:"-"`
 	CreatedAt   time.Time `json:"created_at"`
 	ReplayCount int       `json:"replay_count,omitempty"`
+	// AllowedTypes - разрешенные для загрузки форматы; nil - политика по умолчанию
+	AllowedTypes []string `json:"allowed_types"`
+	Tags         []string `json:"tags,omitempty"`
+	// Role, OwnerLogin - права пользователя и владелец в списке чужих игр, доступных ему
+	Role       Role   `json:"role,omitempty"`
+	OwnerLogin string `json:"owner_login,omitempty"`
+	// SortKey - значение ключа сортировки в списке, из него строится курсор следующей страницы
+	SortKey string `json:"-"`
+}
+
+// GameFilter - условия отбора игр; Name - подстрока названия без учета регистра
+type GameFilter struct {
+	Name string
+}
+
+// UploadPolicy - действующая политика загрузки игры
+// Default - у игры нет своей политики и действует UPLOAD_ALLOWED_TYPES
+type UploadPolicy struct {
+	AllowedTypes []string `json:"allowed_types"`
+	Default      bool     `json:"default"`
 }
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role - права пользователя на игру или реплей; каждая следующая роль включает предыдущие
+// viewer смотрит и скачивает, commenter еще и комментирует, editor еще и меняет описание и отметки
+type Role string
+
+const (
+	RoleViewer    Role = "viewer"
+	RoleCommenter Role = "commenter"
+	RoleEditor    Role = "editor"
+	// RoleOwner не выдается: это роль загрузившего реплей и создателя игры
+	RoleOwner Role = "owner"
+)
+
+// GrantRoles - выдаваемые роли по возрастанию прав
+var GrantRoles = []Role{RoleViewer, RoleCommenter, RoleEditor}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleCommenter:
+		return 2
+	case RoleEditor:
+		return 3
+	case RoleOwner:
+		return 4
+	}
+	return 0
+}
+
+// Allows - роли r достаточно для действия, требующего required
+func (r Role) Allows(required Role) bool {
+	return r.rank() >= required.rank() && r.rank() > 0
+}
+
+// Grantable - роль можно выдать другому пользователю
+func (r Role) Grantable() bool {
+	return r.rank() > 0 && r != RoleOwner
+}
+
+// RolesAtLeast - выдаваемые роли не ниже required
+func RolesAtLeast(required Role) []Role {
+	roles := make([]Role, 0, len(GrantRoles))
+	for _, role := range GrantRoles {
+		if role.Allows(required) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+type GrantStatus string
+
+const (
+	GrantPending  GrantStatus = "pending"
+	GrantAccepted GrantStatus = "accepted"
+	GrantDeclined GrantStatus = "declined"
+)
+
+// Grant - доступ пользователя GranteeID к игре или реплею владельца OwnerID
+// Ровно одно из GameID и ReplayID задано; доступ к игре распространяется на все ее реплеи
+type Grant struct {
+	ID           uuid.UUID  `json:"id"`
+	OwnerID      uuid.UUID  `json:"owner_id"`
+	OwnerLogin   string     `json:"owner_login"`
+	GranteeID    uuid.UUID  `json:"grantee_id"`
+	GranteeLogin string     `json:"grantee_login"`
+	GameID       *uuid.UUID `json:"game_id,omitempty"`
+	ReplayID     *uuid.UUID `json:"replay_id,omitempty"`
+	// ResourceName - название игры или реплея (title, иначе имя файла)
+	ResourceName string      `json:"resource_name"`
+	Role         Role        `json:"role"`
+	Status       GrantStatus `json:"status"`
+	CreatedAt    time.Time   `json:"created_at"`
+	RespondedAt  *time.Time  `json:"responded_at,omitempty"`
+}
+
+// GrantRequest - приглашение пользователя с логином Login с ролью Role
+type GrantRequest struct {
+	Login string `json:"login"`
+	Role  Role   `json:"role"`
+}
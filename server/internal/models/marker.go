@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Marker - отметка на временной шкале реплея
+// Момент задается TimeMS (от начала файла) или Frame (кадр/тик игры); если заданы оба, главный TimeMS
+type Marker struct {
+	ID          uuid.UUID `json:"id"`
+	ReplayID    uuid.UUID `json:"replay_id"`
+	TimeMS      *int64    `json:"time_ms,omitempty"`
+	Frame       *int64    `json:"frame,omitempty"`
+	Label       string    `json:"label"`
+	Color       *string   `json:"color,omitempty"`
+	AuthorID    uuid.UUID `json:"author_id"`
+	AuthorLogin string    `json:"author_login,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "github.com/google/uuid"
+
+// Поля сортировки реплеев
+const (
+	ReplaySortUploadedAt = "uploaded_at"
+	ReplaySortSize       = "size"
+	ReplaySortTitle      = "title"
+	ReplaySortDuration   = "duration"
+)
+
+// Поля сортировки игр
+const (
+	GameSortCreatedAt   = "created_at"
+	GameSortName        = "name"
+	GameSortReplayCount = "replay_count"
+)
+
+// Sort - порядок списка: поле и направление
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Cursor - позиция в списке: ключ сортировки и id последней записи предыдущей страницы
+type Cursor struct {
+	Key string
+	ID  uuid.UUID
+}
+
+// ReplayPage - страница реплеев; Total - сколько реплеев под фильтром, NextCursor пустой на последней странице
+type ReplayPage struct {
+	Replays    []Replay `json:"replays"`
+	Total      int      `json:"total"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// GamePage - страница игр; Total - сколько игр под фильтром, NextCursor пустой на последней странице
+type GamePage struct {
+	Games      []Game `json:"games"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Quota - ограничения пользователя на хранилище; 0 - без ограничения
+type Quota struct {
+	MaxBytes   int64 `json:"max_bytes"`
+	MaxReplays int64 `json:"max_replays"`
+}
+
+// QuotaOverride - переопределение квоты пользователя в БД; nil - значение по умолчанию из конфига
+type QuotaOverride struct {
+	MaxBytes   *int64 `json:"max_bytes"`
+	MaxReplays *int64 `json:"max_replays"`
+}
+
+// QuotaUsage - текущее использование хранилища пользователем вместе с действующей квотой
+// BytesUsed считается по исходным размерам реплеев, без учета сжатия и дедупликации
+type QuotaUsage struct {
+	BytesUsed   int64 `json:"bytes_used"`
+	ReplaysUsed int64 `json:"replays_used"`
+	Quota
+}
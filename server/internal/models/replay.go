@@ -1,8 +1,10 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/fckoffmw/replay-service/server/internal/tagexpr"
 	"github.com/google/uuid"
 )
 
@@ -12,11 +14,63 @@ type Replay struct {
 	OriginalName string    `json:"original_name"`
 	FilePath     string    `json:"-"`
 	SizeBytes    int64     `json:"size_bytes"`
+	ContentType  string    `json:"content_type"`
 	UploadedAt   time.Time `json:"uploaded_at"`
 	Compression  string    `json:"compression"`
 	Compressed   bool      `json:"compressed"`
+	SHA256       string    `json:"sha256,omitempty"`
 	Comment      *string   `json:"comment,omitempty"`
 	GameID       uuid.UUID `json:"game_id"`
 	GameName     string    `json:"game_name,omitempty"`
 	UserID       uuid.UUID `json:"-"`
+	// KeyID, WrappedKey - ключ данных зашифрованного файла (из blobs); nil - файл не зашифрован
+	KeyID      string `json:"-"`
+	WrappedKey []byte `json:"-"`
+	MediaInfo
+	// SourceReplayID - реплей, из которого вырезан этот фрагмент; nil - обычная загрузка
+	SourceReplayID *uuid.UUID `json:"source_replay_id,omitempty"`
+	// Remuxed - moov MP4 при загрузке перенесен в начало файла (faststart)
+	Remuxed bool `json:"remuxed"`
+	// Metadata - данные игрового реплея от парсера его формата (replayparser.Metadata)
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Tags - имена тегов реплея (без тегов его игры)
+	Tags []string `json:"tags,omitempty"`
+	// Markers - отметки на временной шкале; заполняются только для одного реплея (GetReplay)
+	Markers []Marker `json:"markers,omitempty"`
+	// Role - права запросившего пользователя; заполняется для одного реплея и в списке доступных ему
+	Role Role `json:"role,omitempty"`
+	// OwnerLogin - владелец чужого реплея в списке доступных пользователю
+	OwnerLogin string `json:"owner_login,omitempty"`
+	// SortKey - значение ключа сортировки в списке, из него строится курсор следующей страницы
+	SortKey string `json:"-"`
+	// Upload - аренда сессии resumable-загрузки, из которой собран реплей; nil - обычная загрузка
+	Upload *UploadLease `json:"-"`
+}
+
+// ReplayFilter - условия отбора реплеев
+// Metadata - точные значения строковых полей метаданных: game, map, game_version, winner,
+// player (имя любого из игроков) и extra.<поле>
+// Tags - выражение над тегами; тег засчитывается, если он есть у реплея или у его игры
+// UploadedFrom, UploadedTo - время загрузки в [from, to); MinSize, MaxSize - размер файла включительно
+// Extensions - расширения имени файла в нижнем регистре с точкой (.dem, .mp4)
+type ReplayFilter struct {
+	Metadata     map[string]string
+	Tags         *tagexpr.Expr
+	UploadedFrom *time.Time
+	UploadedTo   *time.Time
+	MinSize      *int64
+	MaxSize      *int64
+	Extensions   []string
+	Compressed   *bool
+}
+
+// MediaInfo - параметры видео из заголовков контейнера; nil - реплей не видео или параметр неизвестен
+type MediaInfo struct {
+	DurationMS *int64   `json:"duration_ms,omitempty"`
+	Width      *int     `json:"width,omitempty"`
+	Height     *int     `json:"height,omitempty"`
+	VideoCodec *string  `json:"video_codec,omitempty"`
+	FrameRate  *float64 `json:"frame_rate,omitempty"`
+	// Bitrate - средний битрейт файла, бит/с
+	Bitrate *int64 `json:"bitrate,omitempty"`
 }
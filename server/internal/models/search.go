@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchResult - найденный реплей; Snippet - фрагменты текста с совпадениями, выделенными <mark>
+type SearchResult struct {
+	ID           uuid.UUID `json:"id"`
+	Title        *string   `json:"title,omitempty"`
+	OriginalName string    `json:"original_name"`
+	Comment      *string   `json:"comment,omitempty"`
+	SizeBytes    int64     `json:"size_bytes"`
+	ContentType  string    `json:"content_type"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	GameID       uuid.UUID `json:"game_id"`
+	GameName     string    `json:"game_name"`
+	Tags         []string  `json:"tags,omitempty"`
+	Rank         float32   `json:"rank"`
+	Snippet      string    `json:"snippet"`
+}
+
+// SearchCursor - позиция в выдаче: последний результат предыдущей страницы
+type SearchCursor struct {
+	Rank       float32
+	UploadedAt time.Time
+	ID         uuid.UUID
+}
+
+// SearchPage - страница результатов поиска; Total - сколько реплеев найдено всего
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	Total      int            `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareLink - публичная ссылка на реплей или на игру со всеми ее реплеями
+// Задан ровно один из ReplayID и GameID; Token известен только при создании, в БД хранится его хеш
+type ShareLink struct {
+	ID          uuid.UUID  `json:"id"`
+	Token       string     `json:"token,omitempty"`
+	TokenHash   string     `json:"-"`
+	UserID      uuid.UUID  `json:"-"`
+	ReplayID    *uuid.UUID `json:"replay_id,omitempty"`
+	GameID      *uuid.UUID `json:"game_id,omitempty"`
+	GameName    string     `json:"game_name,omitempty"`
+	HasPassword bool       `json:"has_password"`
+	// PasswordHash - bcrypt-хеш пароля; "" - ссылка открывается без пароля
+	PasswordHash string     `json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxViews     *int       `json:"max_views,omitempty"`
+	ViewCount    int        `json:"view_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ShareOptions - ограничения новой ссылки; nil и "" - без ограничения
+type ShareOptions struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxViews  *int       `json:"max_views"`
+	Password  string     `json:"password"`
+}
+
+// SharedContent - то, что видит получатель ссылки: реплей или игра со страницей ее реплеев
+type SharedContent struct {
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+	MaxViews  *int        `json:"max_views,omitempty"`
+	ViewCount int         `json:"view_count"`
+	Replay    *Replay     `json:"replay,omitempty"`
+	Game      *SharedGame `json:"game,omitempty"`
+}
+
+// SharedGame - игра по ссылке со страницей ее реплеев
+type SharedGame struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	ReplayPage
+}
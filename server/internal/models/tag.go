@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tag - тег пользователя; ReplayCount и GameCount - сколько реплеев и игр им помечено
+type Tag struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Color       *string   `json:"color,omitempty"`
+	ReplayCount int       `json:"replay_count"`
+	GameCount   int       `json:"game_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TagAssignment - массовое изменение тегов: Add навешивается, Remove снимается со всех ReplayIDs и GameIDs
+type TagAssignment struct {
+	ReplayIDs []uuid.UUID `json:"replay_ids"`
+	GameIDs   []uuid.UUID `json:"game_ids"`
+	Add       []string    `json:"add"`
+	Remove    []string    `json:"remove"`
+}
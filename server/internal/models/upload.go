@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UploadSession struct {
+	ID           uuid.UUID `json:"id"`
+	OriginalName string    `json:"original_name"`
+	Title        *string   `json:"title,omitempty"`
+	Comment      *string   `json:"comment,omitempty"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Offset       int64     `json:"offset"`
+	ChunkKeys    []string  `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	GameID       uuid.UUID `json:"game_id"`
+	UserID       uuid.UUID `json:"-"`
+	// ReplayID - реплей, собранный из сессии; nil - загрузка еще не завершена
+	ReplayID *uuid.UUID `json:"replay_id,omitempty"`
+}
+
+// UploadLease - аренда финализации сессии UploadID, взятая запросом с токеном Token
+type UploadLease struct {
+	UploadID uuid.UUID
+	Token    uuid.UUID
+}
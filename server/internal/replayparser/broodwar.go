@@ -0,0 +1,193 @@
+package replayparser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	broodWarHeaderSize = 0x279
+	broodWarChunkSize  = 0x2000
+	broodWarPlayers    = 12
+	broodWarPlayerSize = 36
+	// broodWarFrame - длительность кадра на скорости Fastest, на которой играются все матчи
+	broodWarFrame = 42 * time.Millisecond
+)
+
+var (
+	// Идентификатор реплея - первая секция, она всегда хранится без сжатия
+	broodWarLegacyID = []byte("reRS")
+	broodWarModernID = []byte("seRS")
+)
+
+var broodWarRaces = map[byte]string{0: "zerg", 1: "terran", 2: "protoss", 6: "random"}
+
+var broodWarGameTypes = map[uint16]string{
+	0x02: "melee",
+	0x03: "free_for_all",
+	0x04: "one_on_one",
+	0x05: "capture_the_flag",
+	0x06: "greed",
+	0x07: "slaughter",
+	0x08: "sudden_death",
+	0x09: "ladder",
+	0x0a: "use_map_settings",
+	0x0b: "team_melee",
+	0x0c: "team_free_for_all",
+	0x0d: "team_capture_the_flag",
+	0x0f: "top_vs_bottom",
+}
+
+// BroodWar разбирает заголовок .rep StarCraft: Brood War - и старый формат (секции сжаты PKWARE DCL),
+// и формат 1.21+ (секции сжаты zlib)
+// Победитель в заголовок не попадает, для него нужен разбор команд
+type BroodWar struct{}
+
+func (BroodWar) Name() string {
+	return "broodwar"
+}
+
+func (BroodWar) Extensions() []string {
+	return []string{".rep"}
+}
+
+// Match проверяет идентификатор реплея: контрольная сумма, число блоков и длина блока - по 4 байта
+func (BroodWar) Match(head []byte) bool {
+	if len(head) < 16 {
+		return false
+	}
+	id := head[12:16]
+	return bytes.Equal(id, broodWarLegacyID) || bytes.Equal(id, broodWarModernID)
+}
+
+func (BroodWar) Parse(r io.Reader) (*Metadata, error) {
+	id, err := readBroodWarSection(r, 4, false)
+	if err != nil {
+		return nil, err
+	}
+	modern := bytes.Equal(id, broodWarModernID)
+	if !modern && !bytes.Equal(id, broodWarLegacyID) {
+		return nil, ErrInvalid
+	}
+
+	header, err := readBroodWarSection(r, broodWarHeaderSize, modern)
+	if err != nil {
+		return nil, err
+	}
+	return parseBroodWarHeader(header), nil
+}
+
+// readBroodWarSection читает секцию: контрольная сумма, число блоков и блоки с длиной впереди
+// Блок, длина которого совпадает с распакованной, хранится как есть
+func readBroodWarSection(r io.Reader, size int, modern bool) ([]byte, error) {
+	var prefix struct {
+		Checksum uint32
+		Chunks   uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &prefix); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if int(prefix.Chunks) != (size+broodWarChunkSize-1)/broodWarChunkSize {
+		return nil, ErrInvalid
+	}
+
+	data := make([]byte, 0, size)
+	for i := uint32(0); i < prefix.Chunks; i++ {
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		want := min(size-len(data), broodWarChunkSize)
+		if length == 0 || int(length) > want {
+			return nil, ErrInvalid
+		}
+
+		chunk := make([]byte, length)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if int(length) < want {
+			decoded, err := inflateBroodWarChunk(chunk, modern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+			}
+			chunk = decoded
+		}
+		if len(chunk) != want {
+			return nil, ErrInvalid
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
+
+func inflateBroodWarChunk(chunk []byte, modern bool) ([]byte, error) {
+	if !modern {
+		return explode(chunk)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(chunk))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(io.LimitReader(zr, broodWarChunkSize+1))
+}
+
+func parseBroodWarHeader(h []byte) *Metadata {
+	le := binary.LittleEndian
+
+	game := "starcraft"
+	if h[0x00] == 1 {
+		game = "broodwar"
+	}
+	frames := le.Uint32(h[0x01:])
+
+	meta := &Metadata{
+		Game:       game,
+		Map:        broodWarString(h[0x61 : 0x61+26]),
+		DurationMS: int64(frames) * broodWarFrame.Milliseconds(),
+		Extra: map[string]any{
+			"title":      broodWarString(h[0x18 : 0x18+28]),
+			"host":       broodWarString(h[0x48 : 0x48+24]),
+			"frames":     frames,
+			"map_width":  le.Uint16(h[0x34:]),
+			"map_height": le.Uint16(h[0x36:]),
+		},
+	}
+	if gameType, ok := broodWarGameTypes[le.Uint16(h[0x3c:])]; ok {
+		meta.Extra["game_type"] = gameType
+	}
+	if started := le.Uint32(h[0x08:]); started != 0 {
+		meta.Extra["start_time"] = time.Unix(int64(started), 0).UTC().Format(time.RFC3339)
+	}
+
+	for i := 0; i < broodWarPlayers; i++ {
+		p := h[0xa1+i*broodWarPlayerSize:]
+		// Тип слота: 1 - компьютер, 2 - человек; остальные слоты пустые, закрытые или нейтральные
+		if p[8] != 1 && p[8] != 2 {
+			continue
+		}
+		name := broodWarString(p[11 : 11+25])
+		if name == "" {
+			continue
+		}
+		meta.Players = append(meta.Players, Player{Name: name, Team: int(p[10]), Race: broodWarRaces[p[9]]})
+	}
+	return meta
+}
+
+// broodWarString - строка заголовка без управляющих байтов: ими в названиях карт задаются цвета
+func broodWarString(b []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, cString(b))
+}
@@ -0,0 +1,96 @@
+package replayparser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBroodWarHeader() []byte {
+	h := make([]byte, broodWarHeaderSize)
+	h[0x00] = 1
+	binary.LittleEndian.PutUint32(h[0x01:], 14286)
+	binary.LittleEndian.PutUint32(h[0x08:], uint32(time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC).Unix()))
+	copy(h[0x18:], "ASL Finals")
+	binary.LittleEndian.PutUint16(h[0x34:], 128)
+	binary.LittleEndian.PutUint16(h[0x36:], 128)
+	binary.LittleEndian.PutUint16(h[0x3c:], 0x0f)
+	copy(h[0x48:], "Flash")
+	copy(h[0x61:], "\x03Fighting \x04Spirit")
+
+	players := []struct {
+		kind, race, team byte
+		name             string
+	}{{2, 1, 1, "Flash"}, {2, 0, 2, "Jaedong"}, {6, 0, 0, "Open"}, {1, 2, 2, "Computer"}}
+	for i, p := range players {
+		slot := h[0xa1+i*broodWarPlayerSize:]
+		slot[8], slot[9], slot[10] = p.kind, p.race, p.team
+		copy(slot[11:], p.name)
+	}
+	return h
+}
+
+// testBroodWarReplay собирает реплей из двух первых секций; сжатый заголовок упаковывается zlib (формат 1.21+)
+func testBroodWarReplay(id string, compress bool) []byte {
+	var buf bytes.Buffer
+	section := func(data []byte) {
+		binary.Write(&buf, binary.LittleEndian, []uint32{0, 1, uint32(len(data))})
+		buf.Write(data)
+	}
+
+	section([]byte(id))
+	header := testBroodWarHeader()
+	if compress {
+		var zbuf bytes.Buffer
+		zw := zlib.NewWriter(&zbuf)
+		zw.Write(header)
+		zw.Close()
+		header = zbuf.Bytes()
+	}
+	section(header)
+	buf.Write(make([]byte, 64))
+	return buf.Bytes()
+}
+
+// TestBroodWar_Parse проверяет разбор заголовка в старом формате и в формате 1.21+
+func TestBroodWar_Parse(t *testing.T) {
+	for _, rep := range [][]byte{testBroodWarReplay("reRS", false), testBroodWarReplay("seRS", true)} {
+		require.True(t, BroodWar{}.Match(rep[:32]))
+
+		meta, err := BroodWar{}.Parse(bytes.NewReader(rep))
+		require.NoError(t, err)
+
+		assert.Equal(t, "broodwar", meta.Game)
+		assert.Equal(t, "Fighting Spirit", meta.Map, "управляющие байты цвета вырезаются")
+		assert.Equal(t, int64(14286*42), meta.DurationMS)
+		assert.Equal(t, []Player{
+			{Name: "Flash", Team: 1, Race: "terran"},
+			{Name: "Jaedong", Team: 2, Race: "zerg"},
+			{Name: "Computer", Team: 2, Race: "protoss"},
+		}, meta.Players)
+		assert.Equal(t, "top_vs_bottom", meta.Extra["game_type"])
+		assert.Equal(t, "2024-03-01T18:30:00Z", meta.Extra["start_time"])
+		assert.Equal(t, "ASL Finals", meta.Extra["title"])
+	}
+
+	_, err := BroodWar{}.Parse(bytes.NewReader(testBroodWarReplay("reRS", false)[:200]))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = BroodWar{}.Parse(bytes.NewReader(testBroodWarReplay("xxxx", false)))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// TestExplode проверяет распаковку PKWARE DCL на примере из blast.c
+func TestExplode(t *testing.T) {
+	out, err := explode([]byte{0x00, 0x04, 0x82, 0x24, 0x25, 0x8f, 0x80, 0x7f})
+	require.NoError(t, err)
+	assert.Equal(t, "AIAIAIAIAIAIA", string(out))
+
+	_, err = explode([]byte{0x00, 0x04, 0x82})
+	assert.Error(t, err)
+}
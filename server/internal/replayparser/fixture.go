@@ -0,0 +1,86 @@
+package replayparser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var fixtureMagic = []byte("REPLAY-FIXTURE 1\n")
+
+// Fixture - парсер текстового формата для тестов и ручной проверки фильтров, в сервисе не регистрируется
+//
+//	REPLAY-FIXTURE 1
+//	game: fixture
+//	map: Arena
+//	player: alice 1
+//	player: bob 2
+//	duration_ms: 90000
+//	version: 1.2
+//	winner: alice
+//
+// Неизвестные ключи попадают в Extra
+type Fixture struct{}
+
+func (Fixture) Name() string {
+	return "fixture"
+}
+
+func (Fixture) Extensions() []string {
+	return []string{".fixture"}
+}
+
+func (Fixture) Match(head []byte) bool {
+	return bytes.HasPrefix(head, fixtureMagic)
+}
+
+func (Fixture) Parse(r io.Reader) (*Metadata, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || scanner.Text()+"\n" != string(fixtureMagic) {
+		return nil, ErrInvalid
+	}
+
+	meta := &Metadata{}
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "game":
+			meta.Game = value
+		case "map":
+			meta.Map = value
+		case "version":
+			meta.GameVersion = value
+		case "winner":
+			meta.Winner = value
+		case "duration_ms":
+			duration, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, ErrInvalid
+			}
+			meta.DurationMS = duration
+		case "player":
+			name, team, _ := strings.Cut(value, " ")
+			player := Player{Name: name}
+			if team != "" {
+				player.Team, _ = strconv.Atoi(team)
+			}
+			meta.Players = append(meta.Players, player)
+		default:
+			if meta.Extra == nil {
+				meta.Extra = make(map[string]any)
+			}
+			meta.Extra[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
@@ -0,0 +1,88 @@
+package replayparser
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrInvalid - файл похож на реплей формата парсера, но заголовок поврежден
+var ErrInvalid = errors.New("invalid replay file")
+
+// Metadata - структурированные данные реплея; пустые поля парсер определить не смог
+// Extra - поля, специфичные для формата
+type Metadata struct {
+	Game        string         `json:"game,omitempty"`
+	Map         string         `json:"map,omitempty"`
+	Players     []Player       `json:"players,omitempty"`
+	DurationMS  int64          `json:"duration_ms,omitempty"`
+	GameVersion string         `json:"game_version,omitempty"`
+	Winner      string         `json:"winner,omitempty"`
+	Extra       map[string]any `json:"extra,omitempty"`
+}
+
+type Player struct {
+	Name string `json:"name"`
+	Team int    `json:"team,omitempty"`
+	Race string `json:"race,omitempty"`
+}
+
+// Parser извлекает метаданные из реплеев одного формата
+// Match получает первые байты файла (не меньше filetype.HeaderSize, если файл не короче);
+// Parse читает файл с начала и не обязан дочитывать его до конца
+type Parser interface {
+	Name() string
+	Extensions() []string
+	Match(head []byte) bool
+	Parse(r io.Reader) (*Metadata, error)
+}
+
+// Registry выбирает парсер для загружаемого файла
+type Registry struct {
+	parsers []Parser
+}
+
+func NewRegistry(parsers ...Parser) *Registry {
+	r := &Registry{}
+	for _, p := range parsers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register добавляет парсер; при совпадении магических байтов у нескольких парсеров побеждает
+// зарегистрированный раньше
+func (r *Registry) Register(p Parser) {
+	r.parsers = append(r.parsers, p)
+}
+
+// Find возвращает парсер по магическим байтам, а если ни один не подошел - по расширению имени файла
+// nil - формат не поддерживается
+func (r *Registry) Find(filename string, head []byte) Parser {
+	for _, p := range r.parsers {
+		if p.Match(head) {
+			return p
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return nil
+	}
+	for _, p := range r.parsers {
+		if slices.Contains(p.Extensions(), ext) {
+			return p
+		}
+	}
+	return nil
+}
+
+// unexpectedEOF превращает обрыв файла внутри структуры в ErrInvalid
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrInvalid
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package replayparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSourceDemo() []byte {
+	header := sourceDemoHeader{DemoProtocol: 4, NetworkProtocol: 13881, PlaybackTime: 1800, Ticks: 115200, Frames: 57000}
+	copy(header.Magic[:], sourceDemoMagic)
+	copy(header.ServerName[:], "Valve CS:GO EU West")
+	copy(header.ClientName[:], "GOTV Demo")
+	copy(header.MapName[:], "de_inferno")
+	copy(header.GameDirectory[:], "csgo")
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header)
+	buf.Write(make([]byte, 64))
+	return buf.Bytes()
+}
+
+const testFixture = "REPLAY-FIXTURE 1\ngame: fixture\nmap: Arena\nplayer: alice 1\nplayer: bob 2\nduration_ms: 90000\nwinner: alice\nmode: ranked\n"
+
+// TestRegistry_Find проверяет выбор парсера сначала по магическим байтам, потом по расширению
+func TestRegistry_Find(t *testing.T) {
+	registry := NewRegistry(SourceDemo{}, Fixture{})
+
+	assert.Equal(t, "source-demo", registry.Find("match.bin", testSourceDemo()[:512]).Name(), "магические байты важнее расширения")
+	assert.Equal(t, "fixture", registry.Find("match.dem", []byte(testFixture)).Name())
+	assert.Equal(t, "source-demo", registry.Find("MATCH.DEM", []byte("broken header")).Name(), "без магических байтов - по расширению")
+	assert.Nil(t, registry.Find("match.rep", []byte("unknown")))
+	assert.Nil(t, registry.Find("noext", nil))
+}
+
+// TestSourceDemo_Parse проверяет разбор заголовка демо Source
+func TestSourceDemo_Parse(t *testing.T) {
+	meta, err := SourceDemo{}.Parse(bytes.NewReader(testSourceDemo()))
+	require.NoError(t, err)
+
+	assert.Equal(t, "csgo", meta.Game)
+	assert.Equal(t, "de_inferno", meta.Map)
+	assert.Equal(t, "13881", meta.GameVersion)
+	assert.Equal(t, int64(1800000), meta.DurationMS)
+	assert.Equal(t, []Player{{Name: "GOTV Demo"}}, meta.Players)
+	assert.Equal(t, "Valve CS:GO EU West", meta.Extra["server"])
+	assert.Equal(t, 64.0, meta.Extra["tickrate"])
+
+	_, err = SourceDemo{}.Parse(bytes.NewReader(testSourceDemo()[:100]))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = SourceDemo{}.Parse(bytes.NewReader(make([]byte, 2048)))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// TestFixture_Parse проверяет тестовый формат, которым пользуются тесты сервиса
+func TestFixture_Parse(t *testing.T) {
+	meta, err := Fixture{}.Parse(bytes.NewReader([]byte(testFixture)))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Arena", meta.Map)
+	assert.Equal(t, []Player{{Name: "alice", Team: 1}, {Name: "bob", Team: 2}}, meta.Players)
+	assert.Equal(t, int64(90000), meta.DurationMS)
+	assert.Equal(t, "alice", meta.Winner)
+	assert.Equal(t, "ranked", meta.Extra["mode"])
+
+	_, err = Fixture{}.Parse(bytes.NewReader([]byte("not a fixture")))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -0,0 +1,83 @@
+package replayparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+)
+
+var sourceDemoMagic = []byte("HL2DEMO\x00")
+
+// sourceDemoHeader - заголовок демо движка Source (CS:S, CS:GO, TF2, L4D, HL2)
+type sourceDemoHeader struct {
+	Magic           [8]byte
+	DemoProtocol    int32
+	NetworkProtocol int32
+	ServerName      [260]byte
+	ClientName      [260]byte
+	MapName         [260]byte
+	GameDirectory   [260]byte
+	PlaybackTime    float32
+	Ticks           int32
+	Frames          int32
+	SignonLength    int32
+}
+
+// SourceDemo разбирает заголовок .dem движка Source
+// Победитель и состав команд в заголовок не попадают, для них нужен разбор всех пакетов демо
+type SourceDemo struct{}
+
+func (SourceDemo) Name() string {
+	return "source-demo"
+}
+
+func (SourceDemo) Extensions() []string {
+	return []string{".dem"}
+}
+
+func (SourceDemo) Match(head []byte) bool {
+	return bytes.HasPrefix(head, sourceDemoMagic)
+}
+
+func (SourceDemo) Parse(r io.Reader) (*Metadata, error) {
+	var header sourceDemoHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if !bytes.Equal(header.Magic[:], sourceDemoMagic) {
+		return nil, ErrInvalid
+	}
+
+	meta := &Metadata{
+		Game:        cString(header.GameDirectory[:]),
+		Map:         cString(header.MapName[:]),
+		GameVersion: strconv.Itoa(int(header.NetworkProtocol)),
+		Extra: map[string]any{
+			"server":        cString(header.ServerName[:]),
+			"demo_protocol": header.DemoProtocol,
+			"ticks":         header.Ticks,
+		},
+	}
+	if client := cString(header.ClientName[:]); client != "" {
+		meta.Players = []Player{{Name: client}}
+	}
+
+	seconds := float64(header.PlaybackTime)
+	if seconds > 0 && !math.IsInf(seconds, 0) {
+		meta.DurationMS = int64(seconds * 1000)
+		if header.Ticks > 0 {
+			meta.Extra["tickrate"] = math.Round(float64(header.Ticks) / seconds)
+		}
+	}
+	return meta, nil
+}
+
+// cString обрезает строку фиксированной длины по первому нулевому байту
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(bytes.ToValidUTF8(b, nil))
+}
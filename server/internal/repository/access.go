@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+)
+
+// replayAccess - условие: реплей alias доступен пользователю user с ролью не ниже required
+// Доступ дает владение реплеем или принятая выдача на сам реплей или на его игру
+// user - placeholder аргумента ($2); роли подставляются константами models.GrantRoles
+func replayAccess(alias, user string, required models.Role) string {
+	owner := alias + ".user_id = " + user
+	if required == models.RoleOwner {
+		return owner
+	}
+	return `(` + owner + ` OR EXISTS (
+		SELECT 1 FROM access_grants ag
+		WHERE ag.grantee_id = ` + user + ` AND ag.status = 'accepted' AND ag.role IN (` + roleList(required) + `)
+		  AND (ag.replay_id = ` + alias + `.id OR ag.game_id = ` + alias + `.game_id)))`
+}
+
+// replayRoleColumn - роль пользователя user на реплей alias: owner или старшая из принятых выдач
+func replayRoleColumn(alias, user string) string {
+	return `CASE WHEN ` + alias + `.user_id = ` + user + ` THEN '` + string(models.RoleOwner) + `' ELSE (
+		SELECT ag.role FROM access_grants ag
+		WHERE ag.grantee_id = ` + user + ` AND ag.status = 'accepted'
+		  AND (ag.replay_id = ` + alias + `.id OR ag.game_id = ` + alias + `.game_id)
+		ORDER BY array_position(ARRAY[` + roleList(models.RoleViewer) + `], ag.role) DESC
+		LIMIT 1) END`
+}
+
+// roleList - выдаваемые роли не ниже required списком SQL-литералов по возрастанию прав
+func roleList(required models.Role) string {
+	roles := models.RolesAtLeast(required)
+	quoted := make([]string, len(roles))
+	for i, role := range roles {
+		quoted[i] = "'" + string(role) + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
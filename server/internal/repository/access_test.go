@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReplayAccess проверяет условие доступа: владельцу хватает user_id, остальным - выдача нужной роли
+func TestReplayAccess(t *testing.T) {
+	assert.Equal(t, "r.user_id = $2", replayAccess("r", "$2", models.RoleOwner))
+
+	condition := replayAccess("r", "$2", models.RoleCommenter)
+	assert.Contains(t, condition, "r.user_id = $2 OR EXISTS")
+	assert.Contains(t, condition, "ag.role IN ('commenter', 'editor')")
+	assert.Contains(t, condition, "ag.game_id = r.game_id")
+	assert.Equal(t, "'viewer', 'commenter', 'editor'", roleList(models.RoleViewer))
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// acquireBlobQuery создает blob или блокирует существующую строку с тем же sha256
+// Пустой UPDATE нужен, чтобы RETURNING вернул существующую строку; xmax = 0 только у вставленной
+const acquireBlobQuery = `
+	INSERT INTO blobs (sha256, file_path, compression, compressed, size_bytes, key_id, wrapped_key)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+	ON CONFLICT (sha256) DO UPDATE SET sha256 = EXCLUDED.sha256
+	RETURNING file_path, compression, compressed, size_bytes, COALESCE(key_id, ''), wrapped_key, created_at, (xmax = 0)
+`
+
+// acquireBlob находит или создает blob в транзакции tx; для нового blob вызывает place до фиксации
+// Строка blob заблокирована до конца транзакции: параллельная загрузка того же содержимого ждет
+// и не увидит blob, файл которого еще не записан
+func acquireBlob(ctx context.Context, tx pgx.Tx, blob *models.Blob, place func(filePath string) error) (bool, error) {
+	var created bool
+	err := tx.QueryRow(ctx, acquireBlobQuery,
+		blob.SHA256, blob.FilePath, blob.Compression, blob.Compressed, blob.SizeBytes, blob.KeyID, blob.WrappedKey,
+	).Scan(&blob.FilePath, &blob.Compression, &blob.Compressed, &blob.SizeBytes, &blob.KeyID, &blob.WrappedKey, &blob.CreatedAt, &created)
+	if err != nil {
+		return false, wrapQueryError("acquire blob", err)
+	}
+
+	if created {
+		if err := place(blob.FilePath); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// lockUploadLease блокирует до конца транзакции tx сессию загрузки, пока ее аренда принадлежит lease
+// Зачем: аренду нельзя перехватить между проверкой и вставкой реплея; ErrNotFound - аренда потеряна
+func lockUploadLease(ctx context.Context, tx pgx.Tx, lease models.UploadLease) error {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `
+		SELECT id FROM upload_sessions
+		WHERE id = $1 AND lease_token = $2 AND replay_id IS NULL
+		FOR UPDATE
+	`, lease.UploadID, lease.Token).Scan(&id)
+	if err != nil {
+		return wrapQueryError("lock upload lease", err)
+	}
+	return nil
+}
+
+// CreateWithBlob сохраняет реплей, ссылающийся на blob с его содержимым
+// Если blob с таким sha256 уже есть, реплей ссылается на существующий файл (его путь, сжатие и ключ
+// записываются в replay и blob), а place не вызывается. Возвращает true, если blob создан
+// limit - квота пользователя, проверяется атомарно со вставкой (ErrQuotaBytes, ErrQuotaReplays); nil - без проверки
+// replay.Upload != nil - реплей закрепляется за сессией загрузки той же транзакцией (ErrNotFound - аренда потеряна)
+func (r *ReplayRepository) CreateWithBlob(ctx context.Context, replay *models.Replay, blob *models.Blob, limit *models.Quota, place func(filePath string) error) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, wrapQueryError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if limit != nil {
+		if err := checkQuota(ctx, tx, replay.UserID, replay.SizeBytes, *limit); err != nil {
+			return false, fmt.Errorf("failed to check quota: %w", err)
+		}
+	}
+
+	if replay.Upload != nil {
+		if err := lockUploadLease(ctx, tx, *replay.Upload); err != nil {
+			return false, err
+		}
+	}
+
+	created, err := acquireBlob(ctx, tx, blob, place)
+	if err != nil {
+		return false, err
+	}
+
+	replay.FilePath = blob.FilePath
+	replay.Compression = blob.Compression
+	replay.Compressed = blob.Compressed
+	replay.KeyID = blob.KeyID
+	replay.WrappedKey = blob.WrappedKey
+	err = tx.QueryRow(ctx, createReplayQuery,
+		replay.ID, replay.Title, replay.OriginalName, replay.FilePath, replay.SizeBytes,
+		replay.Compression, replay.Compressed, replay.SHA256, replay.Comment, replay.GameID, replay.UserID, replay.ContentType,
+		replay.DurationMS, replay.Width, replay.Height, replay.VideoCodec, replay.FrameRate, replay.Bitrate, replay.Remuxed, replay.SourceReplayID, replay.Metadata,
+	).Scan(&replay.UploadedAt)
+	if err != nil {
+		return false, wrapQueryError("create replay", err)
+	}
+
+	if replay.Upload != nil {
+		if _, err := tx.Exec(ctx, `UPDATE upload_sessions SET replay_id = $1 WHERE id = $2`, replay.ID, replay.Upload.UploadID); err != nil {
+			return false, wrapQueryError("bind upload session", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, wrapQueryError("commit replay", err)
+	}
+	return created, nil
+}
+
+// ReleaseFile удаляет файл filePath через remove, если на него больше не ссылается ни один реплей
+// Файл без строки в blobs (раскладка до дедупликации) принадлежал одному реплею и удаляется сразу
+// Возвращает true, если remove был вызван успешно
+func (r *ReplayRepository) ReleaseFile(ctx context.Context, filePath string, remove func(filePath string) error) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, wrapQueryError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var refCount int
+	err = tx.QueryRow(ctx, `SELECT ref_count FROM blobs WHERE file_path = $1 FOR UPDATE`, filePath).Scan(&refCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		tx.Rollback(ctx)
+		if err := remove(filePath); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, wrapQueryError("lock blob", err)
+	}
+	if refCount > 0 {
+		return false, nil
+	}
+
+	// Файл удаляется под блокировкой строки: загрузка того же содержимого дождется коммита
+	// и создаст blob заново вместо ссылки на удаленный файл
+	if err := remove(filePath); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM blobs WHERE file_path = $1`, filePath); err != nil {
+		return false, wrapQueryError("delete blob", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, wrapQueryError("commit blob release", err)
+	}
+	return true, nil
+}
+
+// ListLegacyAfter возвращает реплеи с id > afterID, файлы которых еще не перенесены в blobs
+func (r *ReplayRepository) ListLegacyAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error) {
+	query := `
+		SELECT r.id, r.original_name, r.file_path, r.size_bytes, r.compression, r.compressed, r.sha256, r.game_id, r.user_id
+		FROM replays r
+		WHERE r.id > $1 AND NOT EXISTS (SELECT 1 FROM blobs b WHERE b.file_path = r.file_path)
+		ORDER BY r.id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, wrapQueryError("list legacy replays", err)
+	}
+	defer rows.Close()
+
+	replays := make([]models.Replay, 0, limit)
+	for rows.Next() {
+		var replay models.Replay
+		if err := rows.Scan(&replay.ID, &replay.OriginalName, &replay.FilePath, &replay.SizeBytes,
+			&replay.Compression, &replay.Compressed, &replay.SHA256, &replay.GameID, &replay.UserID); err != nil {
+			return nil, wrapScanError("replay", err)
+		}
+		replays = append(replays, replay)
+	}
+
+	return replays, rows.Err()
+}
+
+// AdoptBlob переключает реплей со старого файла на blob, создавая blob через place при необходимости
+// Реплей обновляется, только если он все еще ссылается на replay.FilePath
+func (r *ReplayRepository) AdoptBlob(ctx context.Context, replay *models.Replay, blob *models.Blob, place func(filePath string) error) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, wrapQueryError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := acquireBlob(ctx, tx, blob, place)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE replays
+		SET file_path = $1, compression = $2, compressed = $3, sha256 = $4
+		WHERE id = $5 AND file_path = $6
+	`
+
+	result, err := tx.Exec(ctx, query, blob.FilePath, blob.Compression, blob.Compressed, blob.SHA256, replay.ID, replay.FilePath)
+	if err != nil {
+		return false, wrapQueryError("adopt blob", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, wrapNotFoundError("replay")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, wrapQueryError("commit blob adoption", err)
+	}
+	return created, nil
+}
+
+// ListStaleKeys возвращает зашифрованные blobs с sha256 > afterSHA, ключи которых обернуты не мастер-ключом activeKeyID
+func (r *ReplayRepository) ListStaleKeys(ctx context.Context, activeKeyID, afterSHA string, limit int) ([]models.Blob, error) {
+	query := `
+		SELECT sha256, file_path, key_id, wrapped_key
+		FROM blobs
+		WHERE key_id IS NOT NULL AND key_id <> $1 AND sha256 > $2
+		ORDER BY sha256
+		LIMIT $3
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, activeKeyID, afterSHA, limit)
+	if err != nil {
+		return nil, wrapQueryError("list stale blob keys", err)
+	}
+	defer rows.Close()
+
+	blobs := make([]models.Blob, 0, limit)
+	for rows.Next() {
+		var blob models.Blob
+		if err := rows.Scan(&blob.SHA256, &blob.FilePath, &blob.KeyID, &blob.WrappedKey); err != nil {
+			return nil, wrapScanError("blob", err)
+		}
+		blobs = append(blobs, blob)
+	}
+
+	return blobs, rows.Err()
+}
+
+// RewrapKey заменяет обернутый ключ данных blob, если он все еще обернут мастер-ключом oldKeyID
+func (r *ReplayRepository) RewrapKey(ctx context.Context, sha256, oldKeyID, newKeyID string, wrappedKey []byte) error {
+	query := `
+		UPDATE blobs
+		SET key_id = $3, wrapped_key = $4
+		WHERE sha256 = $1 AND key_id = $2
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, sha256, oldKeyID, newKeyID, wrappedKey)
+	if err != nil {
+		return wrapQueryError("rewrap blob key", err)
+	}
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("blob")
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type CommentRepository struct {
+	db *database.DB
+}
+
+func NewCommentRepository(db *database.DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+// commentColumns - поля комментария; текст удаленного комментария не отдается
+const commentColumns = `
+	c.id, c.replay_id, c.parent_id, c.author_id, u.login,
+	CASE WHEN c.deleted_at IS NULL THEN c.body ELSE '' END,
+	c.time_ms, c.created_at, c.edited_at, c.deleted_at IS NOT NULL,
+	(SELECT COUNT(*) FROM replay_comments rc WHERE rc.parent_id = c.id)`
+
+func scanComment(row pgx.Row, c *models.Comment) error {
+	return row.Scan(&c.ID, &c.ReplayID, &c.ParentID, &c.AuthorID, &c.AuthorLogin,
+		&c.Body, &c.TimeMS, &c.CreatedAt, &c.EditedAt, &c.Deleted, &c.ReplyCount)
+}
+
+// List возвращает комментарии верхнего уровня (parentID == nil) или ответы на parentID
+// в порядке написания, начиная после after
+func (r *CommentRepository) List(ctx context.Context, replayID, userID uuid.UUID, parentID *uuid.UUID, after *models.CommentCursor, limit int) ([]models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM replay_comments c
+		JOIN replays r ON r.id = c.replay_id
+		JOIN users u ON u.id = c.author_id
+		WHERE c.replay_id = $1 AND ` + replayAccess("r", "$2", models.RoleViewer) + `
+		  AND (($3::uuid IS NULL AND c.parent_id IS NULL) OR c.parent_id = $3)
+		  AND ($4::timestamptz IS NULL OR (c.created_at, c.id) > ($4, $5))
+		ORDER BY c.created_at, c.id
+		LIMIT $6
+	`
+
+	var afterTime *time.Time
+	var afterID uuid.UUID
+	if after != nil {
+		afterTime, afterID = &after.CreatedAt, after.ID
+	}
+
+	rows, err := r.db.Pool.Query(ctx, query, replayID, userID, parentID, afterTime, afterID, limit)
+	if err != nil {
+		return nil, wrapQueryError("query comments", err)
+	}
+	defer rows.Close()
+
+	comments := make([]models.Comment, 0)
+	for rows.Next() {
+		var c models.Comment
+		if err := scanComment(rows, &c); err != nil {
+			return nil, wrapScanError("comment", err)
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+func (r *CommentRepository) GetByID(ctx context.Context, commentID, replayID, userID uuid.UUID) (*models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM replay_comments c
+		JOIN replays r ON r.id = c.replay_id
+		JOIN users u ON u.id = c.author_id
+		WHERE c.id = $1 AND c.replay_id = $2 AND ` + replayAccess("r", "$3", models.RoleViewer) + `
+	`
+
+	var c models.Comment
+	if err := scanComment(r.db.Pool.QueryRow(ctx, query, commentID, replayID, userID), &c); err != nil {
+		return nil, wrapQueryError("get comment", err)
+	}
+	return &c, nil
+}
+
+// Create добавляет комментарий от имени comment.AuthorID
+// ErrNotFound - автору не выдана роль commenter на реплей или родительский комментарий удален
+func (r *CommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	query := `
+		INSERT INTO replay_comments (replay_id, parent_id, author_id, body, time_ms)
+		SELECT r.id, $2, $3, $4, $5
+		FROM replays r
+		WHERE r.id = $1 AND ` + replayAccess("r", "$3", models.RoleCommenter) + `
+		  AND ($2::uuid IS NULL OR EXISTS (
+		      SELECT 1 FROM replay_comments p WHERE p.id = $2 AND p.replay_id = r.id AND p.deleted_at IS NULL))
+		RETURNING id, created_at, (SELECT login FROM users WHERE id = $3)
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		comment.ReplayID, comment.ParentID, comment.AuthorID, comment.Body, comment.TimeMS,
+	).Scan(&comment.ID, &comment.CreatedAt, &comment.AuthorLogin)
+	if err != nil {
+		return wrapQueryError("create comment", err)
+	}
+	return nil
+}
+
+// UpdateBody меняет текст комментария автора, сохраняя прежнюю версию в истории правок
+// Автор, потерявший роль commenter, править комментарий не может
+func (r *CommentRepository) UpdateBody(ctx context.Context, commentID, replayID, authorID uuid.UUID, body string) (time.Time, error) {
+	query := `
+		WITH target AS (
+			SELECT c.id, c.body, COALESCE(c.edited_at, c.created_at) AS written_at
+			FROM replay_comments c
+			JOIN replays r ON r.id = c.replay_id
+			WHERE c.id = $1 AND c.replay_id = $2 AND c.author_id = $3 AND c.deleted_at IS NULL
+			  AND ` + replayAccess("r", "$3", models.RoleCommenter) + `
+			FOR UPDATE OF c
+		), revision AS (
+			INSERT INTO replay_comment_revisions (comment_id, body, created_at)
+			SELECT id, body, written_at FROM target
+		)
+		UPDATE replay_comments c
+		SET body = $4, edited_at = NOW()
+		FROM target
+		WHERE c.id = target.id
+		RETURNING c.edited_at
+	`
+
+	var editedAt time.Time
+	if err := r.db.Pool.QueryRow(ctx, query, commentID, replayID, authorID, body).Scan(&editedAt); err != nil {
+		return time.Time{}, wrapQueryError("update comment", err)
+	}
+	return editedAt, nil
+}
+
+// SoftDelete помечает комментарий удаленным; удалить может владелец реплея или автор с ролью commenter
+func (r *CommentRepository) SoftDelete(ctx context.Context, commentID, replayID, userID uuid.UUID) error {
+	query := `
+		UPDATE replay_comments c
+		SET deleted_at = NOW()
+		FROM replays r
+		WHERE c.id = $1 AND c.replay_id = $2 AND r.id = c.replay_id AND c.deleted_at IS NULL
+		  AND (r.user_id = $3 OR c.author_id = $3 AND ` + replayAccess("r", "$3", models.RoleCommenter) + `)
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, commentID, replayID, userID)
+	if err != nil {
+		return wrapQueryError("delete comment", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("comment")
+	}
+	return nil
+}
+
+// ListRevisions возвращает прежние версии текста, от первой к последней
+func (r *CommentRepository) ListRevisions(ctx context.Context, commentID, replayID, userID uuid.UUID) ([]models.CommentRevision, error) {
+	query := `
+		SELECT v.body, v.created_at, v.replaced_at
+		FROM replay_comment_revisions v
+		JOIN replay_comments c ON c.id = v.comment_id
+		JOIN replays r ON r.id = c.replay_id
+		WHERE v.comment_id = $1 AND c.replay_id = $2 AND c.deleted_at IS NULL
+		  AND ` + replayAccess("r", "$3", models.RoleViewer) + `
+		ORDER BY v.replaced_at
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, commentID, replayID, userID)
+	if err != nil {
+		return nil, wrapQueryError("query comment revisions", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]models.CommentRevision, 0)
+	for rows.Next() {
+		var v models.CommentRevision
+		if err := rows.Scan(&v.Body, &v.CreatedAt, &v.ReplacedAt); err != nil {
+			return nil, wrapScanError("comment revision", err)
+		}
+		revisions = append(revisions, v)
+	}
+
+	return revisions, rows.Err()
+}
@@ -1,8 +1,32 @@
 package repository
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNotFound = errors.New("not found or access denied")
+	// ErrConflict - запись нарушает ограничение уникальности
+	ErrConflict = errors.New("already exists")
+	// ErrQuotaBytes, ErrQuotaReplays - новый реплей не помещается в квоту пользователя
+	ErrQuotaBytes   = errors.New("storage quota exceeded")
+	ErrQuotaReplays = errors.New("replay count quota exceeded")
+)
+
+const uniqueViolation = "23505"
 
 func wrapQueryError(operation string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to %s: %w", operation, ErrNotFound)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("failed to %s: %w", operation, ErrConflict)
+	}
 	return fmt.Errorf("failed to %s: %w", operation, err)
 }
 
@@ -11,5 +35,5 @@ func wrapScanError(entity string, err error) error {
 }
 
 func wrapNotFoundError(entity string) error {
-	return fmt.Errorf("%s not found or access denied", entity)
+	return fmt.Errorf("%s %w", entity, ErrNotFound)
 }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/fckoffmw/replay-service/server/internal/database"
 	"github.com/fckoffmw/replay-service/server/internal/models"
@@ -16,32 +17,60 @@ func NewGameRepository(db *database.DB) *GameRepository {
 	return &GameRepository{db: db}
 }
 
-func (r *GameRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
-	query := `
-		SELECT g.id, g.name, g.created_at, COUNT(r.id) as replay_count
-		FROM games g
-		LEFT JOIN replays r ON r.game_id = g.id
-		WHERE g.user_id = $1
-		GROUP BY g.id, g.name, g.created_at
-		ORDER BY g.created_at DESC
-	`
+// GetByUserID возвращает страницу игр пользователя после курсора after и число всех игр под фильтром
+func (r *GameRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter models.GameFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Game, int, error) {
+	key, err := lookupSortKey(gameSortKeys, sort)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	rows, err := r.db.Pool.Query(ctx, query, userID)
+	args := []any{userID}
+	where := "g.user_id = $1"
+	if filter.Name != "" {
+		args = append(args, likePattern(filter.Name))
+		where += " AND g.name ILIKE $2"
+	}
+
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM games g WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, wrapQueryError("count games", err)
+	}
+
+	position := ""
+	if after != nil {
+		position = "WHERE " + key.after("g.id", sort.Desc, after, &args)
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT g.id, g.name, g.created_at, g.replay_count, g.allowed_types, ` + gameTagsColumn("$1") + `, (` + key.expr + `)::text
+		FROM (
+			SELECT g.id, g.name, g.created_at, g.allowed_types, COUNT(r.id) AS replay_count
+			FROM games g
+			LEFT JOIN replays r ON r.game_id = g.id
+			WHERE ` + where + `
+			GROUP BY g.id, g.name, g.created_at, g.allowed_types
+		) g
+		` + position + `
+		ORDER BY ` + key.orderBy("g.id", sort.Desc) + `
+		LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, wrapQueryError("query games", err)
+		return nil, 0, wrapQueryError("query games", err)
 	}
 	defer rows.Close()
 
 	games := make([]models.Game, 0)
 	for rows.Next() {
 		var game models.Game
-		if err := rows.Scan(&game.ID, &game.Name, &game.CreatedAt, &game.ReplayCount); err != nil {
-			return nil, wrapScanError("game", err)
+		if err := rows.Scan(&game.ID, &game.Name, &game.CreatedAt, &game.ReplayCount, &game.AllowedTypes, &game.Tags, &game.SortKey); err != nil {
+			return nil, 0, wrapScanError("game", err)
 		}
 		games = append(games, game)
 	}
 
-	return games, rows.Err()
+	return games, total, rows.Err()
 }
 
 func (r *GameRepository) Create(ctx context.Context, userID uuid.UUID, name string) (*models.Game, error) {
@@ -49,11 +78,11 @@ func (r *GameRepository) Create(ctx context.Context, userID uuid.UUID, name stri
 		INSERT INTO games (name, user_id)
 		VALUES ($1, $2)
 		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
-		RETURNING id, name, created_at
+		RETURNING id, name, created_at, allowed_types
 	`
 
 	var game models.Game
-	err := r.db.Pool.QueryRow(ctx, query, name, userID).Scan(&game.ID, &game.Name, &game.CreatedAt)
+	err := r.db.Pool.QueryRow(ctx, query, name, userID).Scan(&game.ID, &game.Name, &game.CreatedAt, &game.AllowedTypes)
 	if err != nil {
 		return nil, wrapQueryError("create game", err)
 	}
@@ -95,3 +124,68 @@ func (r *GameRepository) Delete(ctx context.Context, gameID, userID uuid.UUID) e
 
 	return nil
 }
+
+// GetAllowedTypes возвращает политику загрузки игры; nil - действует политика по умолчанию
+func (r *GameRepository) GetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID) ([]string, error) {
+	query := `SELECT allowed_types FROM games WHERE id = $1 AND user_id = $2`
+
+	var allowedTypes []string
+	err := r.db.Pool.QueryRow(ctx, query, gameID, userID).Scan(&allowedTypes)
+	if err != nil {
+		return nil, wrapQueryError("get allowed types", err)
+	}
+
+	return allowedTypes, nil
+}
+
+// SetAllowedTypes задает политику загрузки игры; nil возвращает политику по умолчанию
+func (r *GameRepository) SetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID, allowedTypes []string) error {
+	query := `
+		UPDATE games
+		SET allowed_types = $1
+		WHERE id = $2 AND user_id = $3
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, allowedTypes, gameID, userID)
+	if err != nil {
+		return wrapQueryError("set allowed types", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("game")
+	}
+
+	return nil
+}
+
+// GetShared возвращает чужие игры, доступ к которым пользователь принял, по названию
+// Role - выданная роль, OwnerLogin - владелец игры
+func (r *GameRepository) GetShared(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
+	query := `
+		SELECT g.id, g.name, g.created_at, (SELECT COUNT(*) FROM replays r WHERE r.game_id = g.id),
+		       g.allowed_types, ` + gameTagsColumn("$1") + `, ag.role, u.login
+		FROM access_grants ag
+		JOIN games g ON g.id = ag.game_id
+		JOIN users u ON u.id = g.user_id
+		WHERE ag.grantee_id = $1 AND ag.status = 'accepted'
+		ORDER BY g.name, g.id
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, wrapQueryError("query shared games", err)
+	}
+	defer rows.Close()
+
+	games := make([]models.Game, 0)
+	for rows.Next() {
+		var game models.Game
+		if err := rows.Scan(&game.ID, &game.Name, &game.CreatedAt, &game.ReplayCount, &game.AllowedTypes, &game.Tags,
+			&game.Role, &game.OwnerLogin); err != nil {
+			return nil, wrapScanError("game", err)
+		}
+		games = append(games, game)
+	}
+
+	return games, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -126,7 +127,7 @@ func TestGameRepository_GetByUserID(t *testing.T) {
 	game2, _ := repo.Create(ctx, userID, "Game 2")
 	
 	// Получаем список игр
-	games, err := repo.GetByUserID(ctx, userID)
+	games, _, err := repo.GetByUserID(ctx, userID, models.GameFilter{}, newestGamesFirst, nil, 100)
 	
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, len(games), 2, "должно быть минимум 2 игры")
@@ -148,6 +149,48 @@ func TestGameRepository_GetByUserID(t *testing.T) {
 	assert.True(t, foundGame2, "Game 2 должна быть в списке")
 }
 
+var newestGamesFirst = models.Sort{Field: models.GameSortCreatedAt, Desc: true}
+
+// TestGameRepository_GetByUserID_Keyset проверяет сортировку по названию, курсор и фильтр по подстроке
+func TestGameRepository_GetByUserID_Keyset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропускаем интеграционный тест в режиме short")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewGameRepository(db)
+	userID := uuid.New()
+	createTestUser(t, db, userID)
+	defer cleanupTestData(t, db, userID)
+
+	ctx := context.Background()
+	for _, name := range []string{"dota 2", "Counter-Strike 2", "StarCraft", "counter-strike 1.6"} {
+		_, err := repo.Create(ctx, userID, name)
+		require.NoError(t, err)
+	}
+
+	byName := models.Sort{Field: models.GameSortName}
+	first, total, err := repo.GetByUserID(ctx, userID, models.GameFilter{}, byName, nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, first, 2)
+	assert.Equal(t, "counter-strike 1.6", first[0].Name)
+
+	last := first[1]
+	rest, _, err := repo.GetByUserID(ctx, userID, models.GameFilter{}, byName, &models.Cursor{Key: last.SortKey, ID: last.ID}, 2)
+	require.NoError(t, err)
+	require.Len(t, rest, 2)
+	assert.Equal(t, "dota 2", rest[0].Name)
+	assert.Equal(t, "StarCraft", rest[1].Name)
+
+	filtered, total, err := repo.GetByUserID(ctx, userID, models.GameFilter{Name: "COUNTER"}, byName, nil, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, filtered, 2)
+}
+
 // TestGameRepository_Update проверяет обновление игры
 func TestGameRepository_Update(t *testing.T) {
 	if testing.Short() {
@@ -174,7 +217,7 @@ func TestGameRepository_Update(t *testing.T) {
 	assert.NoError(t, err)
 	
 	// Проверяем, что название изменилось
-	games, _ := repo.GetByUserID(ctx, userID)
+	games, _, _ := repo.GetByUserID(ctx, userID, models.GameFilter{}, newestGamesFirst, nil, 100)
 	for _, g := range games {
 		if g.ID == game.ID {
 			assert.Equal(t, newName, g.Name)
@@ -227,7 +270,7 @@ func TestGameRepository_Delete(t *testing.T) {
 	assert.NoError(t, err)
 	
 	// Проверяем, что игра удалена
-	games, _ := repo.GetByUserID(ctx, userID)
+	games, _, _ := repo.GetByUserID(ctx, userID, models.GameFilter{}, newestGamesFirst, nil, 100)
 	for _, g := range games {
 		assert.NotEqual(t, game.ID, g.ID, "удаленная игра не должна быть в списке")
 	}
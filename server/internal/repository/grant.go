@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type GrantRepository struct {
+	db *database.DB
+}
+
+func NewGrantRepository(db *database.DB) *GrantRepository {
+	return &GrantRepository{db: db}
+}
+
+// grantColumns - поля выдачи ag с логинами сторон и названием игры или реплея из grantJoins
+const grantColumns = `
+	ag.id, ag.owner_id, o.login, ag.grantee_id, e.login, ag.game_id, ag.replay_id,
+	COALESCE(g.name, r.title, r.original_name, ''), ag.role, ag.status, ag.created_at, ag.responded_at`
+
+const grantJoins = `
+	JOIN users o ON o.id = ag.owner_id
+	JOIN users e ON e.id = ag.grantee_id
+	LEFT JOIN games g ON g.id = ag.game_id
+	LEFT JOIN replays r ON r.id = ag.replay_id`
+
+func scanGrant(row pgx.Row, g *models.Grant) error {
+	return row.Scan(&g.ID, &g.OwnerID, &g.OwnerLogin, &g.GranteeID, &g.GranteeLogin, &g.GameID, &g.ReplayID,
+		&g.ResourceName, &g.Role, &g.Status, &g.CreatedAt, &g.RespondedAt)
+}
+
+// Upsert приглашает grant.GranteeID к игре или реплею grant.OwnerID или меняет роль уже выданного доступа
+// Отклоненное приглашение снова становится ожидающим, принятый доступ остается принятым
+// ErrNotFound - игра или реплей не принадлежат владельцу
+func (r *GrantRepository) Upsert(ctx context.Context, grant *models.Grant) error {
+	query := `
+		WITH ag AS (
+			INSERT INTO access_grants AS a (owner_id, grantee_id, game_id, replay_id, role)
+			SELECT $1, $2, $3, $4, $5
+			WHERE EXISTS (SELECT 1 FROM games WHERE id = $3 AND user_id = $1)
+			   OR EXISTS (SELECT 1 FROM replays WHERE id = $4 AND user_id = $1)
+			ON CONFLICT (grantee_id, game_id, replay_id) DO UPDATE
+			SET role = EXCLUDED.role,
+			    status = CASE WHEN a.status = 'declined' THEN 'pending' ELSE a.status END,
+			    responded_at = CASE WHEN a.status = 'declined' THEN NULL ELSE a.responded_at END
+			RETURNING *
+		)
+		SELECT ` + grantColumns + `
+		FROM ag` + grantJoins
+
+	err := scanGrant(r.db.Pool.QueryRow(ctx, query,
+		grant.OwnerID, grant.GranteeID, grant.GameID, grant.ReplayID, grant.Role,
+	), grant)
+	if err != nil {
+		return wrapQueryError("grant access", err)
+	}
+	return nil
+}
+
+// GetByOwner возвращает выданные владельцем доступы, новые первыми
+func (r *GrantRepository) GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.Grant, error) {
+	query := `
+		SELECT ` + grantColumns + `
+		FROM access_grants ag` + grantJoins + `
+		WHERE ag.owner_id = $1
+		ORDER BY ag.created_at DESC, ag.id DESC
+	`
+	return r.list(ctx, query, ownerID)
+}
+
+// GetByGrantee возвращает доступы пользователя со статусом status, новые первыми
+func (r *GrantRepository) GetByGrantee(ctx context.Context, granteeID uuid.UUID, status models.GrantStatus) ([]models.Grant, error) {
+	query := `
+		SELECT ` + grantColumns + `
+		FROM access_grants ag` + grantJoins + `
+		WHERE ag.grantee_id = $1 AND ag.status = $2
+		ORDER BY ag.created_at DESC, ag.id DESC
+	`
+	return r.list(ctx, query, granteeID, status)
+}
+
+func (r *GrantRepository) list(ctx context.Context, query string, args ...any) ([]models.Grant, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, wrapQueryError("query grants", err)
+	}
+	defer rows.Close()
+
+	grants := make([]models.Grant, 0)
+	for rows.Next() {
+		var grant models.Grant
+		if err := scanGrant(rows, &grant); err != nil {
+			return nil, wrapScanError("grant", err)
+		}
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// Respond записывает ответ приглашенного на ожидающее приглашение
+// ErrNotFound - приглашения нет, оно адресовано другому или на него уже ответили
+func (r *GrantRepository) Respond(ctx context.Context, grantID, granteeID uuid.UUID, status models.GrantStatus) (*models.Grant, error) {
+	query := `
+		WITH ag AS (
+			UPDATE access_grants
+			SET status = $3, responded_at = NOW()
+			WHERE id = $1 AND grantee_id = $2 AND status = 'pending'
+			RETURNING *
+		)
+		SELECT ` + grantColumns + `
+		FROM ag` + grantJoins
+
+	var grant models.Grant
+	if err := scanGrant(r.db.Pool.QueryRow(ctx, query, grantID, granteeID, status), &grant); err != nil {
+		return nil, wrapQueryError("respond to invitation", err)
+	}
+	return &grant, nil
+}
+
+// Delete удаляет доступ; удалить может владелец или получатель
+func (r *GrantRepository) Delete(ctx context.Context, grantID, userID uuid.UUID) error {
+	query := `DELETE FROM access_grants WHERE id = $1 AND (owner_id = $2 OR grantee_id = $2)`
+
+	result, err := r.db.Pool.Exec(ctx, query, grantID, userID)
+	if err != nil {
+		return wrapQueryError("delete grant", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("grant")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+)
+
+type MarkerRepository struct {
+	db *database.DB
+}
+
+func NewMarkerRepository(db *database.DB) *MarkerRepository {
+	return &MarkerRepository{db: db}
+}
+
+// ListByReplayID возвращает отметки реплея по порядку на шкале: сначала по времени, затем по кадру
+func (r *MarkerRepository) ListByReplayID(ctx context.Context, replayID, userID uuid.UUID) ([]models.Marker, error) {
+	query := `
+		SELECT m.id, m.replay_id, m.time_ms, m.frame, m.label, m.color, m.author_id, u.login, m.created_at, m.updated_at
+		FROM replay_markers m
+		JOIN replays r ON r.id = m.replay_id
+		JOIN users u ON u.id = m.author_id
+		WHERE m.replay_id = $1 AND ` + replayAccess("r", "$2", models.RoleViewer) + `
+		ORDER BY m.time_ms NULLS LAST, m.frame, m.created_at
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, replayID, userID)
+	if err != nil {
+		return nil, wrapQueryError("query markers", err)
+	}
+	defer rows.Close()
+
+	markers := make([]models.Marker, 0)
+	for rows.Next() {
+		var m models.Marker
+		if err := rows.Scan(&m.ID, &m.ReplayID, &m.TimeMS, &m.Frame, &m.Label, &m.Color,
+			&m.AuthorID, &m.AuthorLogin, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, wrapScanError("marker", err)
+		}
+		markers = append(markers, m)
+	}
+
+	return markers, rows.Err()
+}
+
+// Create добавляет отметку от имени marker.AuthorID; ErrNotFound - реплей не найден или автор не editor
+func (r *MarkerRepository) Create(ctx context.Context, marker *models.Marker) error {
+	query := `
+		INSERT INTO replay_markers (replay_id, author_id, time_ms, frame, label, color)
+		SELECT r.id, $2, $3, $4, $5, $6
+		FROM replays r
+		WHERE r.id = $1 AND ` + replayAccess("r", "$2", models.RoleEditor) + `
+		RETURNING id, created_at, updated_at, (SELECT login FROM users WHERE id = $2)
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		marker.ReplayID, marker.AuthorID, marker.TimeMS, marker.Frame, marker.Label, marker.Color,
+	).Scan(&marker.ID, &marker.CreatedAt, &marker.UpdatedAt, &marker.AuthorLogin)
+	if err != nil {
+		return wrapQueryError("create marker", err)
+	}
+
+	return nil
+}
+
+// Update заменяет момент, подпись и цвет отметки; автор и время создания не меняются
+// Менять и удалять отметки может владелец реплея и получивший роль editor
+func (r *MarkerRepository) Update(ctx context.Context, marker *models.Marker, userID uuid.UUID) error {
+	query := `
+		UPDATE replay_markers m
+		SET time_ms = $1, frame = $2, label = $3, color = $4, updated_at = NOW()
+		FROM replays r
+		WHERE m.id = $5 AND m.replay_id = $6 AND r.id = m.replay_id AND ` + replayAccess("r", "$7", models.RoleEditor) + `
+		RETURNING m.author_id, (SELECT login FROM users WHERE id = m.author_id), m.created_at, m.updated_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		marker.TimeMS, marker.Frame, marker.Label, marker.Color, marker.ID, marker.ReplayID, userID,
+	).Scan(&marker.AuthorID, &marker.AuthorLogin, &marker.CreatedAt, &marker.UpdatedAt)
+	if err != nil {
+		return wrapQueryError("update marker", err)
+	}
+
+	return nil
+}
+
+func (r *MarkerRepository) Delete(ctx context.Context, markerID, replayID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM replay_markers m
+		USING replays r
+		WHERE m.id = $1 AND m.replay_id = $2 AND r.id = m.replay_id AND ` + replayAccess("r", "$3", models.RoleEditor) + `
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, markerID, replayID, userID)
+	if err != nil {
+		return wrapQueryError("delete marker", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("marker")
+	}
+
+	return nil
+}
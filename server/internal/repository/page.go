@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+)
+
+// sortKey - выражение ключа сортировки и тип, к которому приводится ключ из курсора
+// Выражение не должно давать NULL: иначе сравнение с курсором теряет строки
+type sortKey struct {
+	expr string
+	typ  string
+}
+
+// replaySortKeys - ключи сортировки реплеев; без названия реплей сортируется по имени файла,
+// без длительности видео - по длительности матча из метаданных, иначе как 0
+var replaySortKeys = map[string]sortKey{
+	models.ReplaySortUploadedAt: {expr: "r.uploaded_at", typ: "timestamptz"},
+	models.ReplaySortSize:       {expr: "r.size_bytes", typ: "bigint"},
+	models.ReplaySortTitle:      {expr: "lower(COALESCE(r.title, r.original_name))", typ: "text"},
+	models.ReplaySortDuration:   {expr: "COALESCE(r.duration_ms, (r.metadata->>'duration_ms')::bigint, 0)", typ: "bigint"},
+}
+
+// gameSortKeys - ключи сортировки игр; g - выборка игр с числом реплеев replay_count
+var gameSortKeys = map[string]sortKey{
+	models.GameSortCreatedAt:   {expr: "g.created_at", typ: "timestamptz"},
+	models.GameSortName:        {expr: "lower(g.name)", typ: "text"},
+	models.GameSortReplayCount: {expr: "g.replay_count", typ: "bigint"},
+}
+
+func lookupSortKey(keys map[string]sortKey, sort models.Sort) (sortKey, error) {
+	key, ok := keys[sort.Field]
+	if !ok {
+		return sortKey{}, fmt.Errorf("unknown sort field %q", sort.Field)
+	}
+	return key, nil
+}
+
+// after - условие "строго после курсора" в порядке desc; id - столбец, разрешающий равные ключи
+// Ключ курсора уже проверен сервисом на соответствие typ, поэтому приведение не падает
+func (k sortKey) after(id string, desc bool, cursor *models.Cursor, args *[]any) string {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	*args = append(*args, cursor.Key, cursor.ID)
+	return fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)", k.expr, id, op, len(*args)-1, k.typ, len(*args))
+}
+
+func (k sortKey) orderBy(id string, desc bool) string {
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s, %s %s", k.expr, dir, id, dir)
+}
+
+// likePattern - шаблон ILIKE для поиска подстроки s; спецсимволы LIKE экранируются
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type QuotaRepository struct {
+	db *database.DB
+}
+
+func NewQuotaRepository(db *database.DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+const usageQuery = `SELECT COALESCE(SUM(size_bytes), 0), COUNT(*) FROM replays WHERE user_id = $1`
+
+// GetUsage возвращает суммарный исходный размер и число реплеев пользователя
+func (r *QuotaRepository) GetUsage(ctx context.Context, userID uuid.UUID) (int64, int64, error) {
+	var bytesUsed, replaysUsed int64
+	if err := r.db.Pool.QueryRow(ctx, usageQuery, userID).Scan(&bytesUsed, &replaysUsed); err != nil {
+		return 0, 0, wrapQueryError("get storage usage", err)
+	}
+
+	return bytesUsed, replaysUsed, nil
+}
+
+// checkQuota проверяет в транзакции tx, что еще один реплей размером size помещается в limit (0 - без ограничения)
+// Строка пользователя блокируется до конца транзакции: параллельные загрузки и фрагменты одного пользователя
+// проверяются по очереди, и каждая видит реплеи, вставленные до нее
+func checkQuota(ctx context.Context, tx pgx.Tx, userID uuid.UUID, size int64, limit models.Quota) error {
+	if limit.MaxBytes == 0 && limit.MaxReplays == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR NO KEY UPDATE`, userID); err != nil {
+		return wrapQueryError("lock user quota", err)
+	}
+
+	var bytesUsed, replaysUsed int64
+	if err := tx.QueryRow(ctx, usageQuery, userID).Scan(&bytesUsed, &replaysUsed); err != nil {
+		return wrapQueryError("get storage usage", err)
+	}
+
+	if limit.MaxReplays > 0 && replaysUsed+1 > limit.MaxReplays {
+		return ErrQuotaReplays
+	}
+	if limit.MaxBytes > 0 && bytesUsed+size > limit.MaxBytes {
+		return ErrQuotaBytes
+	}
+	return nil
+}
+
+// GetOverride возвращает переопределение квоты пользователя; пустое, если его нет
+func (r *QuotaRepository) GetOverride(ctx context.Context, userID uuid.UUID) (*models.QuotaOverride, error) {
+	query := `SELECT max_bytes, max_replays FROM user_quotas WHERE user_id = $1`
+
+	var override models.QuotaOverride
+	err := r.db.Pool.QueryRow(ctx, query, userID).Scan(&override.MaxBytes, &override.MaxReplays)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &override, nil
+	}
+	if err != nil {
+		return nil, wrapQueryError("get quota override", err)
+	}
+
+	return &override, nil
+}
+
+// SetOverride сохраняет переопределение квоты; override без значений удаляет его
+func (r *QuotaRepository) SetOverride(ctx context.Context, userID uuid.UUID, override *models.QuotaOverride) error {
+	if override.MaxBytes == nil && override.MaxReplays == nil {
+		if _, err := r.db.Pool.Exec(ctx, `DELETE FROM user_quotas WHERE user_id = $1`, userID); err != nil {
+			return wrapQueryError("delete quota override", err)
+		}
+		return nil
+	}
+
+	query := `
+		INSERT INTO user_quotas (user_id, max_bytes, max_replays)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET max_bytes = EXCLUDED.max_bytes, max_replays = EXCLUDED.max_replays, updated_at = NOW()
+	`
+
+	if _, err := r.db.Pool.Exec(ctx, query, userID, override.MaxBytes, override.MaxReplays); err != nil {
+		return wrapQueryError("set quota override", err)
+	}
+
+	return nil
+}
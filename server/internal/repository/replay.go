@@ -2,6 +2,10 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/fckoffmw/replay-service/server/internal/database"
 	"github.com/fckoffmw/replay-service/server/internal/models"
@@ -16,66 +20,187 @@ func NewReplayRepository(db *database.DB) *ReplayRepository {
 	return &ReplayRepository{db: db}
 }
 
-func (r *ReplayRepository) GetByGameID(ctx context.Context, gameID, userID uuid.UUID, limit int) ([]models.Replay, error) {
+func (r *ReplayRepository) GetByGameID(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error) {
+	return r.list(ctx, userID, &gameID, filter, sort, after, limit)
+}
+
+// GetByUserID возвращает реплеи пользователя из всех его игр
+func (r *ReplayRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error) {
+	return r.list(ctx, userID, nil, filter, sort, after, limit)
+}
+
+// list - страница реплеев пользователя после курсора after и число всех реплеев под фильтром
+// gameID == nil - по всем играм
+func (r *ReplayRepository) list(ctx context.Context, userID uuid.UUID, gameID *uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error) {
+	key, err := lookupSortKey(replaySortKeys, sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conditions, args := replayConditions(userID, gameID, filter)
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM replays r WHERE ` + strings.Join(conditions, " AND ")
+	if err := r.db.Pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, wrapQueryError("count replays", err)
+	}
+
+	if after != nil {
+		conditions = append(conditions, key.after("r.id", sort.Desc, after, &args))
+	}
+	args = append(args, limit)
+
 	query := `
-		SELECT r.id, r.title, r.original_name, r.uploaded_at, r.size_bytes, r.compression, r.compressed, r.comment, r.game_id
+		SELECT r.id, r.title, r.original_name, r.uploaded_at, r.size_bytes, r.content_type, r.compression, r.compressed, r.sha256, r.comment, r.game_id, g.name,
+		       r.duration_ms, r.width, r.height, r.video_codec, r.frame_rate, r.bitrate, r.remuxed, r.source_replay_id, r.metadata, ` + replayTagsColumn("$1") + `,
+		       (` + key.expr + `)::text
 		FROM replays r
-		WHERE r.game_id = $1 AND r.user_id = $2
-		ORDER BY r.uploaded_at DESC
-		LIMIT $3
-	`
+		JOIN games g ON g.id = r.game_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + key.orderBy("r.id", sort.Desc) + `
+		LIMIT $` + strconv.Itoa(len(args))
 
-	rows, err := r.db.Pool.Query(ctx, query, gameID, userID, limit)
+	rows, err := r.db.Pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, wrapQueryError("query replays", err)
+		return nil, 0, wrapQueryError("query replays", err)
 	}
 	defer rows.Close()
 
 	replays := make([]models.Replay, 0)
 	for rows.Next() {
 		var replay models.Replay
-		if err := rows.Scan(&replay.ID, &replay.Title, &replay.OriginalName, &replay.UploadedAt, &replay.SizeBytes, &replay.Compression, &replay.Compressed, &replay.Comment, &replay.GameID); err != nil {
-			return nil, wrapScanError("replay", err)
+		if err := rows.Scan(&replay.ID, &replay.Title, &replay.OriginalName, &replay.UploadedAt, &replay.SizeBytes, &replay.ContentType, &replay.Compression, &replay.Compressed, &replay.SHA256, &replay.Comment, &replay.GameID, &replay.GameName,
+			&replay.DurationMS, &replay.Width, &replay.Height, &replay.VideoCodec, &replay.FrameRate, &replay.Bitrate, &replay.Remuxed, &replay.SourceReplayID, &replay.Metadata, &replay.Tags,
+			&replay.SortKey); err != nil {
+			return nil, 0, wrapScanError("replay", err)
 		}
 		replays = append(replays, replay)
 	}
 
-	return replays, rows.Err()
+	return replays, total, rows.Err()
 }
 
+// replayConditions - условия WHERE на реплей r по фильтру и их аргументы
+// Реплеи одной игры видны и тем, кому выдан доступ к ней или к отдельным ее реплеям
+func replayConditions(userID uuid.UUID, gameID *uuid.UUID, filter models.ReplayFilter) ([]string, []any) {
+	args := []any{userID}
+	conditions := []string{"r.user_id = $1"}
+	if gameID != nil {
+		conditions[0] = replayAccess("r", "$1", models.RoleViewer)
+	}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if gameID != nil {
+		add("r.game_id = $%d", *gameID)
+	}
+	if containment := metadataContainment(filter.Metadata); containment != nil {
+		add("r.metadata @> $%d::jsonb", containment)
+	}
+	if filter.Tags != nil {
+		conditions = append(conditions, tagCondition(filter.Tags, "$1", &args))
+	}
+	if filter.UploadedFrom != nil {
+		add("r.uploaded_at >= $%d", *filter.UploadedFrom)
+	}
+	if filter.UploadedTo != nil {
+		add("r.uploaded_at < $%d", *filter.UploadedTo)
+	}
+	if filter.MinSize != nil {
+		add("r.size_bytes >= $%d", *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		add("r.size_bytes <= $%d", *filter.MaxSize)
+	}
+	if len(filter.Extensions) > 0 {
+		// Расширение - от последней точки имени, как filepath.Ext
+		add(`lower(substring(r.original_name from '\.[^.]*$')) = ANY($%d)`, filter.Extensions)
+	}
+	if filter.Compressed != nil {
+		add("r.compressed = $%d", *filter.Compressed)
+	}
+	return conditions, args
+}
+
+// GetByID возвращает реплей, доступный пользователю; Role - права пользователя, UserID - владелец
 func (r *ReplayRepository) GetByID(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
 	query := `
-		SELECT r.id, r.title, r.original_name, r.comment, r.uploaded_at, r.size_bytes, 
-		       r.compression, r.compressed, r.file_path, r.game_id, g.name as game_name
+		SELECT r.id, r.title, r.original_name, r.comment, r.uploaded_at, r.size_bytes, r.content_type,
+		       r.compression, r.compressed, r.sha256, r.file_path, r.game_id, g.name as game_name, r.user_id,
+		       COALESCE(b.key_id, ''), b.wrapped_key,
+		       r.duration_ms, r.width, r.height, r.video_codec, r.frame_rate, r.bitrate, r.remuxed, r.source_replay_id, r.metadata,
+		       ` + replayTagsColumn("$2") + `, ` + replayRoleColumn("r", "$2") + `
 		FROM replays r
 		JOIN games g ON r.game_id = g.id
-		WHERE r.id = $1 AND r.user_id = $2
+		LEFT JOIN blobs b ON b.file_path = r.file_path
+		WHERE r.id = $1 AND ` + replayAccess("r", "$2", models.RoleViewer) + `
 	`
 
 	var replay models.Replay
 	err := r.db.Pool.QueryRow(ctx, query, replayID, userID).Scan(
 		&replay.ID, &replay.Title, &replay.OriginalName, &replay.Comment, &replay.UploadedAt,
-		&replay.SizeBytes, &replay.Compression, &replay.Compressed, &replay.FilePath,
-		&replay.GameID, &replay.GameName,
+		&replay.SizeBytes, &replay.ContentType, &replay.Compression, &replay.Compressed, &replay.SHA256, &replay.FilePath,
+		&replay.GameID, &replay.GameName, &replay.UserID, &replay.KeyID, &replay.WrappedKey,
+		&replay.DurationMS, &replay.Width, &replay.Height, &replay.VideoCodec, &replay.FrameRate, &replay.Bitrate,
+		&replay.Remuxed, &replay.SourceReplayID, &replay.Metadata, &replay.Tags, &replay.Role,
 	)
 	if err != nil {
 		return nil, wrapQueryError("get replay", err)
 	}
 
-	replay.UserID = userID
 	return &replay, nil
 }
 
-func (r *ReplayRepository) Create(ctx context.Context, replay *models.Replay) error {
+// GetShared возвращает чужие реплеи, доступ к которым пользователь принял, недавно принятые первыми
+// Реплеи игр, выданных целиком, сюда не входят: они видны в списке реплеев игры
+func (r *ReplayRepository) GetShared(ctx context.Context, userID uuid.UUID) ([]models.Replay, error) {
 	query := `
-		INSERT INTO replays (id, title, original_name, file_path, size_bytes, compression, compressed, comment, game_id, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING uploaded_at
+		SELECT r.id, r.title, r.original_name, r.uploaded_at, r.size_bytes, r.content_type, r.compression, r.compressed, r.sha256, r.comment, r.game_id, g.name,
+		       r.duration_ms, r.width, r.height, r.video_codec, r.frame_rate, r.bitrate, r.remuxed, r.source_replay_id, r.metadata, ` + replayTagsColumn("$1") + `,
+		       ag.role, u.login
+		FROM access_grants ag
+		JOIN replays r ON r.id = ag.replay_id
+		JOIN games g ON g.id = r.game_id
+		JOIN users u ON u.id = r.user_id
+		WHERE ag.grantee_id = $1 AND ag.status = 'accepted'
+		ORDER BY ag.responded_at DESC, r.id DESC
 	`
 
-	err := r.db.Pool.QueryRow(ctx, query,
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, wrapQueryError("query shared replays", err)
+	}
+	defer rows.Close()
+
+	replays := make([]models.Replay, 0)
+	for rows.Next() {
+		var replay models.Replay
+		if err := rows.Scan(&replay.ID, &replay.Title, &replay.OriginalName, &replay.UploadedAt, &replay.SizeBytes, &replay.ContentType, &replay.Compression, &replay.Compressed, &replay.SHA256, &replay.Comment, &replay.GameID, &replay.GameName,
+			&replay.DurationMS, &replay.Width, &replay.Height, &replay.VideoCodec, &replay.FrameRate, &replay.Bitrate, &replay.Remuxed, &replay.SourceReplayID, &replay.Metadata, &replay.Tags,
+			&replay.Role, &replay.OwnerLogin); err != nil {
+			return nil, wrapScanError("replay", err)
+		}
+		replays = append(replays, replay)
+	}
+
+	return replays, rows.Err()
+}
+
+const createReplayQuery = `
+	INSERT INTO replays (id, title, original_name, file_path, size_bytes, compression, compressed, sha256, comment, game_id, user_id, content_type,
+	                     duration_ms, width, height, video_codec, frame_rate, bitrate, remuxed, source_replay_id, metadata)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), 'application/octet-stream'),
+	        $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	RETURNING uploaded_at
+`
+
+func (r *ReplayRepository) Create(ctx context.Context, replay *models.Replay) error {
+	err := r.db.Pool.QueryRow(ctx, createReplayQuery,
 		replay.ID, replay.Title, replay.OriginalName, replay.FilePath, replay.SizeBytes,
-		replay.Compression, replay.Compressed, replay.Comment, replay.GameID, replay.UserID,
+		replay.Compression, replay.Compressed, replay.SHA256, replay.Comment, replay.GameID, replay.UserID, replay.ContentType,
+		replay.DurationMS, replay.Width, replay.Height, replay.VideoCodec, replay.FrameRate, replay.Bitrate, replay.Remuxed, replay.SourceReplayID, replay.Metadata,
 	).Scan(&replay.UploadedAt)
 
 	if err != nil {
@@ -85,6 +210,8 @@ func (r *ReplayRepository) Create(ctx context.Context, replay *models.Replay) er
 	return nil
 }
 
+// Delete удаляет запись реплея и возвращает путь его файла
+// Сам файл не трогается: триггер отпускает ссылку на blob, удалить файл можно через ReleaseFile
 func (r *ReplayRepository) Delete(ctx context.Context, replayID, userID uuid.UUID) (string, error) {
 	query := `
 		DELETE FROM replays
@@ -101,9 +228,11 @@ func (r *ReplayRepository) Delete(ctx context.Context, replayID, userID uuid.UUI
 	return filePath, nil
 }
 
+// GetFilePathsByGameID возвращает различные пути файлов реплеев игры
+// После удаления игры ссылки на эти файлы отпущены каскадом, файлы освобождаются через ReleaseFile
 func (r *ReplayRepository) GetFilePathsByGameID(ctx context.Context, gameID, userID uuid.UUID) ([]string, error) {
 	query := `
-		SELECT file_path FROM replays WHERE game_id = $1 AND user_id = $2
+		SELECT DISTINCT file_path FROM replays WHERE game_id = $1 AND user_id = $2
 	`
 
 	rows, err := r.db.Pool.Query(ctx, query, gameID, userID)
@@ -124,17 +253,177 @@ func (r *ReplayRepository) GetFilePathsByGameID(ctx context.Context, gameID, use
 	return filePaths, rows.Err()
 }
 
+// Update меняет название и описание реплея; менять может владелец и получивший роль editor
 func (r *ReplayRepository) Update(ctx context.Context, replayID, userID uuid.UUID, title, comment *string) error {
 	query := `
-		UPDATE replays
-		SET title = COALESCE($1, title), comment = COALESCE($2, comment)
-		WHERE id = $3 AND user_id = $4
+		UPDATE replays r
+		SET title = COALESCE($1, r.title), comment = COALESCE($2, r.comment)
+		WHERE r.id = $3 AND ` + replayAccess("r", "$4", models.RoleEditor) + `
 	`
 
-	_, err := r.db.Pool.Exec(ctx, query, title, comment, replayID, userID)
+	result, err := r.db.Pool.Exec(ctx, query, title, comment, replayID, userID)
 	if err != nil {
 		return wrapQueryError("update replay", err)
 	}
 
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("replay")
+	}
+
+	return nil
+}
+
+// ListAfter возвращает реплеи всех пользователей с id > afterID, упорядоченные по id
+// Зачем: фоновые проверки хранилища проходят всю таблицу страницами, не держа ее в памяти
+func (r *ReplayRepository) ListAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error) {
+	query := `
+		SELECT r.id, r.original_name, r.file_path, r.size_bytes, r.compression, r.compressed, r.sha256, r.game_id, r.user_id,
+		       COALESCE(b.key_id, ''), b.wrapped_key
+		FROM replays r
+		LEFT JOIN blobs b ON b.file_path = r.file_path
+		WHERE r.id > $1
+		ORDER BY r.id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, wrapQueryError("list replays", err)
+	}
+	defer rows.Close()
+
+	replays := make([]models.Replay, 0, limit)
+	for rows.Next() {
+		var replay models.Replay
+		if err := rows.Scan(&replay.ID, &replay.OriginalName, &replay.FilePath, &replay.SizeBytes,
+			&replay.Compression, &replay.Compressed, &replay.SHA256, &replay.GameID, &replay.UserID,
+			&replay.KeyID, &replay.WrappedKey); err != nil {
+			return nil, wrapScanError("replay", err)
+		}
+		replays = append(replays, replay)
+	}
+
+	return replays, rows.Err()
+}
+
+// ListMissingMediaAfter возвращает реплеи с id > afterID типов contentTypes, у которых нет параметров видео
+// Зачем: обход для заполнения параметров видео, загруженных до их извлечения
+func (r *ReplayRepository) ListMissingMediaAfter(ctx context.Context, afterID uuid.UUID, contentTypes []string, limit int) ([]models.Replay, error) {
+	query := `
+		SELECT r.id, r.original_name, r.file_path, r.size_bytes, r.content_type, r.compression, r.compressed, r.game_id, r.user_id,
+		       COALESCE(b.key_id, ''), b.wrapped_key
+		FROM replays r
+		LEFT JOIN blobs b ON b.file_path = r.file_path
+		WHERE r.id > $1 AND r.content_type = ANY($2) AND r.duration_ms IS NULL
+		ORDER BY r.id
+		LIMIT $3
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, afterID, contentTypes, limit)
+	if err != nil {
+		return nil, wrapQueryError("list replays without media info", err)
+	}
+	defer rows.Close()
+
+	replays := make([]models.Replay, 0, limit)
+	for rows.Next() {
+		var replay models.Replay
+		if err := rows.Scan(&replay.ID, &replay.OriginalName, &replay.FilePath, &replay.SizeBytes, &replay.ContentType,
+			&replay.Compression, &replay.Compressed, &replay.GameID, &replay.UserID,
+			&replay.KeyID, &replay.WrappedKey); err != nil {
+			return nil, wrapScanError("replay", err)
+		}
+		replays = append(replays, replay)
+	}
+
+	return replays, rows.Err()
+}
+
+// SetMediaInfo записывает реплею параметры видео
+func (r *ReplayRepository) SetMediaInfo(ctx context.Context, replayID uuid.UUID, media models.MediaInfo) error {
+	query := `
+		UPDATE replays
+		SET duration_ms = $1, width = $2, height = $3, video_codec = $4, frame_rate = $5, bitrate = $6
+		WHERE id = $7
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query,
+		media.DurationMS, media.Width, media.Height, media.VideoCodec, media.FrameRate, media.Bitrate, replayID)
+	if err != nil {
+		return wrapQueryError("set replay media info", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("replay")
+	}
+
+	return nil
+}
+
+// SetSHA256 записывает хеш реплею, у которого его еще нет
+func (r *ReplayRepository) SetSHA256(ctx context.Context, replayID uuid.UUID, sum string) error {
+	query := `UPDATE replays SET sha256 = $1 WHERE id = $2 AND sha256 = ''`
+
+	if _, err := r.db.Pool.Exec(ctx, query, sum, replayID); err != nil {
+		return wrapQueryError("set replay sha256", err)
+	}
+
 	return nil
 }
+
+// quarantinedColumns - колонки replays, которые переносятся в карантин; все, кроме вычисляемого search_vector
+// Новая колонка replays добавляется и сюда, и в quarantined_replays
+const quarantinedColumns = `id, title, original_name, file_path, size_bytes, uploaded_at,
+	compression, compressed, sha256, comment, game_id, user_id, content_type,
+	duration_ms, width, height, video_codec, frame_rate, bitrate, metadata, remuxed, source_replay_id`
+
+// Quarantine переносит запись реплея в quarantined_replays с указанием причины
+func (r *ReplayRepository) Quarantine(ctx context.Context, replayID uuid.UUID, reason string) error {
+	query := `
+		WITH moved AS (
+			DELETE FROM replays WHERE id = $1
+			RETURNING ` + quarantinedColumns + `
+		)
+		INSERT INTO quarantined_replays (` + quarantinedColumns + `, reason)
+		SELECT ` + quarantinedColumns + `, $2
+		FROM moved
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, replayID, reason)
+	if err != nil {
+		return wrapQueryError("quarantine replay", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("replay")
+	}
+
+	return nil
+}
+
+// metadataContainment строит JSON для проверки вхождения metadata @> ...; nil - фильтра нет
+// player ищется среди players[].name, extra.<поле> - внутри extra
+func metadataContainment(filter map[string]string) []byte {
+	if len(filter) == 0 {
+		return nil
+	}
+
+	doc := make(map[string]any, len(filter))
+	extra := make(map[string]string)
+	for key, value := range filter {
+		switch {
+		case key == "player":
+			doc["players"] = []map[string]string{{"name": value}}
+		case strings.HasPrefix(key, "extra."):
+			extra[strings.TrimPrefix(key, "extra.")] = value
+		default:
+			doc[key] = value
+		}
+	}
+	if len(extra) > 0 {
+		doc["extra"] = extra
+	}
+
+	data, _ := json.Marshal(doc)
+	return data
+}
@@ -91,7 +91,7 @@ func TestReplayRepository_GetByGameID(t *testing.T) {
 	}
 	
 	// Получаем реплеи с лимитом 5
-	replays, err := replayRepo.GetByGameID(ctx, game.ID, userID, 5)
+	replays, _, err := replayRepo.GetByGameID(ctx, game.ID, userID, models.ReplayFilter{}, newestFirst, nil, 5)
 	
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(replays), "должно быть 3 реплея")
@@ -134,10 +134,78 @@ func TestReplayRepository_GetByGameID_Limit(t *testing.T) {
 	}
 	
 	// Получаем только 3 реплея
-	replays, err := replayRepo.GetByGameID(ctx, game.ID, userID, 3)
+	replays, total, err := replayRepo.GetByGameID(ctx, game.ID, userID, models.ReplayFilter{}, newestFirst, nil, 3)
 	
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(replays), "должно вернуться ровно 3 реплея (лимит)")
+	assert.Equal(t, 10, total, "total считает все реплеи игры")
+}
+
+var newestFirst = models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+
+// TestReplayRepository_GetByGameID_Keyset проверяет обход страниц по курсору и фильтры размера и расширения
+func TestReplayRepository_GetByGameID_Keyset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропускаем интеграционный тест в режиме short")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gameRepo := NewGameRepository(db)
+	replayRepo := NewReplayRepository(db)
+
+	userID := uuid.New()
+	createTestUser(t, db, userID)
+	defer cleanupTestData(t, db, userID)
+
+	ctx := context.Background()
+	game, err := gameRepo.Create(ctx, userID, "Test Game")
+	require.NoError(t, err)
+
+	// Два реплея одного размера: порядок между ними решает id
+	for i, size := range []int64{300, 100, 200, 200, 500} {
+		name := "replay.dem"
+		if i == 4 {
+			name = "clip.MP4"
+		}
+		replay := &models.Replay{
+			ID:           uuid.New(),
+			OriginalName: name,
+			FilePath:     "path/to/file",
+			SizeBytes:    size,
+			Compression:  "none",
+			GameID:       game.ID,
+			UserID:       userID,
+		}
+		require.NoError(t, replayRepo.Create(ctx, replay))
+	}
+
+	bySize := models.Sort{Field: models.ReplaySortSize}
+	var sizes []int64
+	var after *models.Cursor
+	for {
+		page, total, err := replayRepo.GetByGameID(ctx, game.ID, userID, models.ReplayFilter{}, bySize, after, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 5, total)
+		for _, replay := range page {
+			sizes = append(sizes, replay.SizeBytes)
+		}
+		if len(page) < 2 {
+			break
+		}
+		last := page[len(page)-1]
+		after = &models.Cursor{Key: last.SortKey, ID: last.ID}
+	}
+	assert.Equal(t, []int64{100, 200, 200, 300, 500}, sizes)
+
+	minSize := int64(200)
+	filter := models.ReplayFilter{MinSize: &minSize, Extensions: []string{".mp4"}}
+	replays, total, err := replayRepo.GetByGameID(ctx, game.ID, userID, filter, bySize, nil, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, replays, 1)
+	assert.Equal(t, "clip.MP4", replays[0].OriginalName)
 }
 
 // TestReplayRepository_GetByID проверяет получение одного реплея
@@ -190,6 +258,63 @@ func TestReplayRepository_GetByID(t *testing.T) {
 	assert.Equal(t, "Test Game", replay.GameName, "должно быть название игры из JOIN")
 }
 
+// TestReplayRepository_GetByID_GranteeTags проверяет, что получатель доступа не видит личные теги владельца
+func TestReplayRepository_GetByID_GranteeTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропускаем интеграционный тест в режиме short")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gameRepo := NewGameRepository(db)
+	replayRepo := NewReplayRepository(db)
+	tagRepo := NewTagRepository(db)
+	grantRepo := NewGrantRepository(db)
+
+	ownerID, granteeID := uuid.New(), uuid.New()
+	createTestUser(t, db, ownerID)
+	createTestUser(t, db, granteeID)
+	defer cleanupTestData(t, db, ownerID)
+	defer cleanupTestData(t, db, granteeID)
+
+	ctx := context.Background()
+
+	game, err := gameRepo.Create(ctx, ownerID, "Test Game")
+	require.NoError(t, err)
+	replay := &models.Replay{
+		ID:           uuid.New(),
+		OriginalName: "test.rep",
+		FilePath:     "path/to/test.rep",
+		SizeBytes:    2048,
+		Compression:  "none",
+		GameID:       game.ID,
+		UserID:       ownerID,
+	}
+	require.NoError(t, replayRepo.Create(ctx, replay))
+	require.NoError(t, tagRepo.Assign(ctx, ownerID, models.TagAssignment{
+		ReplayIDs: []uuid.UUID{replay.ID}, GameIDs: []uuid.UUID{game.ID}, Add: []string{"smurf"},
+	}))
+
+	grant := &models.Grant{OwnerID: ownerID, GranteeID: granteeID, ReplayID: &replay.ID, Role: models.RoleViewer}
+	require.NoError(t, grantRepo.Upsert(ctx, grant))
+	_, err = grantRepo.Respond(ctx, grant.ID, granteeID, models.GrantAccepted)
+	require.NoError(t, err)
+
+	owned, err := replayRepo.GetByID(ctx, replay.ID, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"smurf"}, owned.Tags)
+
+	shared, err := replayRepo.GetByID(ctx, replay.ID, granteeID)
+	require.NoError(t, err)
+	assert.Empty(t, shared.Tags)
+
+	list, err := replayRepo.GetShared(ctx, granteeID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Empty(t, list[0].Tags)
+}
+
 // TestReplayRepository_Update проверяет обновление реплея
 func TestReplayRepository_Update(t *testing.T) {
 	if testing.Short() {
@@ -376,3 +501,168 @@ func TestReplayRepository_CascadeDelete(t *testing.T) {
 	_, err := replayRepo.GetByID(ctx, replay.ID, userID)
 	assert.Error(t, err, "реплей должен быть удален каскадно вместе с игрой")
 }
+
+// TestReplayRepository_BlobRefCount проверяет общий файл для одинакового содержимого и подсчет ссылок
+// Что тестируем: второй реплей ссылается на существующий blob, файл освобождается после удаления последнего
+func TestReplayRepository_BlobRefCount(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропускаем интеграционный тест в режиме short")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gameRepo := NewGameRepository(db)
+	replayRepo := NewReplayRepository(db)
+
+	userID := uuid.New()
+	createTestUser(t, db, userID)
+	defer cleanupTestData(t, db, userID)
+
+	ctx := context.Background()
+	game, _ := gameRepo.Create(ctx, userID, "Test Game")
+
+	sum := uuid.New().String()
+	defer db.Pool.Exec(ctx, "DELETE FROM blobs WHERE sha256 = $1", sum)
+
+	placed := 0
+	place := func(filePath string) error {
+		placed++
+		return nil
+	}
+
+	var replays []*models.Replay
+	for i := 0; i < 2; i++ {
+		replay := &models.Replay{
+			ID:           uuid.New(),
+			OriginalName: "test.rep",
+			SizeBytes:    1024,
+			SHA256:       sum,
+			GameID:       game.ID,
+			UserID:       userID,
+		}
+		blob := &models.Blob{SHA256: sum, FilePath: "blobs/" + sum, Compression: "none", SizeBytes: 1024}
+		created, err := replayRepo.CreateWithBlob(ctx, replay, blob, nil, place)
+		require.NoError(t, err)
+		assert.Equal(t, i == 0, created, "blob создается только первой загрузкой")
+		assert.Equal(t, "blobs/"+sum, replay.FilePath)
+		replays = append(replays, replay)
+	}
+	assert.Equal(t, 1, placed)
+
+	removed := 0
+	remove := func(filePath string) error {
+		removed++
+		return nil
+	}
+
+	filePath, err := replayRepo.Delete(ctx, replays[0].ID, userID)
+	require.NoError(t, err)
+	released, err := replayRepo.ReleaseFile(ctx, filePath, remove)
+	require.NoError(t, err)
+	assert.False(t, released, "на файл еще ссылается второй реплей")
+
+	// Удаление игры каскадом отпускает последнюю ссылку
+	require.NoError(t, gameRepo.Delete(ctx, game.ID, userID))
+	released, err = replayRepo.ReleaseFile(ctx, filePath, remove)
+	require.NoError(t, err)
+	assert.True(t, released)
+	assert.Equal(t, 1, removed)
+}
+
+// TestReplayRepository_Quarantine проверяет, что карантин сохраняет запись реплея целиком
+// Что тестируем: все колонки replays, заполненные значениями, совпадают в quarantined_replays
+func TestReplayRepository_Quarantine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропускаем интеграционный тест в режиме short")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gameRepo := NewGameRepository(db)
+	replayRepo := NewReplayRepository(db)
+
+	userID := uuid.New()
+	createTestUser(t, db, userID)
+	defer cleanupTestData(t, db, userID)
+
+	ctx := context.Background()
+	game, _ := gameRepo.Create(ctx, userID, "Test Game")
+
+	title, comment := "Clip", "Retake B"
+	source := &models.Replay{ID: uuid.New(), OriginalName: "match.mp4", FilePath: "user/game/match.mp4", SizeBytes: 4096, GameID: game.ID, UserID: userID}
+	require.NoError(t, replayRepo.Create(ctx, source))
+	replay := &models.Replay{ID: uuid.New(), Title: &title, Comment: &comment, OriginalName: "clip.mp4", FilePath: "user/game/clip.mp4",
+		SizeBytes: 1024, GameID: game.ID, UserID: userID}
+	require.NoError(t, replayRepo.Create(ctx, replay))
+	defer db.Pool.Exec(ctx, "DELETE FROM quarantined_replays WHERE id = $1", replay.ID)
+
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE replays
+		SET compression = 'zstd', compressed = TRUE, sha256 = 'abc', content_type = 'video/mp4',
+		    duration_ms = 65000, width = 1920, height = 1080, video_codec = 'h264', frame_rate = 59.94,
+		    bitrate = 8000000, metadata = '{"map": "de_dust2"}', remuxed = TRUE, source_replay_id = $2
+		WHERE id = $1
+	`, replay.ID, source.ID)
+	require.NoError(t, err)
+
+	var before, after map[string]any
+	require.NoError(t, db.Pool.QueryRow(ctx,
+		`SELECT to_jsonb(r) - 'search_vector' FROM replays r WHERE id = $1`, replay.ID).Scan(&before))
+	for column, value := range before {
+		require.NotNil(t, value, "колонка %s должна быть заполнена", column)
+	}
+
+	require.NoError(t, replayRepo.Quarantine(ctx, replay.ID, "file missing in storage"))
+
+	require.NoError(t, db.Pool.QueryRow(ctx,
+		`SELECT to_jsonb(q) - 'reason' - 'quarantined_at' FROM quarantined_replays q WHERE id = $1`, replay.ID).Scan(&after))
+	assert.Equal(t, before, after, "карантин не должен терять колонки реплея")
+
+	_, err = replayRepo.GetByID(ctx, replay.ID, userID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestReplayRepository_CreateWithBlob_Quota проверяет, что параллельные вставки не превышают квоту вместе
+// Что тестируем: из двух загрузок, каждая из которых помещается в квоту по отдельности, проходит одна
+func TestReplayRepository_CreateWithBlob_Quota(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропускаем интеграционный тест в режиме short")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	gameRepo := NewGameRepository(db)
+	replayRepo := NewReplayRepository(db)
+
+	userID := uuid.New()
+	createTestUser(t, db, userID)
+	defer cleanupTestData(t, db, userID)
+
+	ctx := context.Background()
+	game, _ := gameRepo.Create(ctx, userID, "Test Game")
+	limit := &models.Quota{MaxBytes: 1500}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		sum := uuid.New().String()
+		defer db.Pool.Exec(ctx, "DELETE FROM blobs WHERE sha256 = $1", sum)
+		go func() {
+			replay := &models.Replay{ID: uuid.New(), OriginalName: "test.rep", SizeBytes: 1000, SHA256: sum, GameID: game.ID, UserID: userID}
+			blob := &models.Blob{SHA256: sum, FilePath: "blobs/" + sum, Compression: "none", SizeBytes: 1000}
+			_, err := replayRepo.CreateWithBlob(ctx, replay, blob, limit, func(string) error { return nil })
+			errs <- err
+		}()
+	}
+
+	var failed int
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			assert.ErrorIs(t, err, ErrQuotaBytes)
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed, "вторая загрузка должна увидеть первую и упереться в квоту")
+}
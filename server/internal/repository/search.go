@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+)
+
+// snippetStart, snippetStop - границы совпадений от ts_headline; управляющие символы не встречаются
+// в названиях и описаниях, поэтому текст можно экранировать, а затем заменить их на <mark>
+const (
+	snippetStart = "\x02"
+	snippetStop  = "\x03"
+)
+
+const snippetOptions = "StartSel=" + snippetStart + ", StopSel=" + snippetStop + ", MaxFragments=3, MaxWords=20, MinWords=5"
+
+var snippetMarks = strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>")
+
+// searchHits - реплеи пользователя, у которых с запросом $2 совпали название, имя файла, описание,
+// метаданные, название игры или неудаленный комментарий; rank - сумма рангов, игра и комментарии весят вдвое меньше
+const searchHits = `
+	SELECT r.id, r.uploaded_at, c.body AS comment_body,
+	       ts_rank(r.search_vector, query) + 0.5::real * (ts_rank(g.search_vector, query) + COALESCE(c.rank, 0)) AS rank
+	FROM replays r
+	JOIN games g ON g.id = r.game_id
+	CROSS JOIN websearch_to_tsquery('simple', $2) AS query
+	LEFT JOIN LATERAL (
+		SELECT rc.body, ts_rank(rc.search_vector, query) AS rank
+		FROM replay_comments rc
+		WHERE rc.replay_id = r.id AND rc.deleted_at IS NULL AND rc.search_vector @@ query
+		ORDER BY rank DESC
+		LIMIT 1
+	) c ON true
+	WHERE r.user_id = $1
+	  AND (r.search_vector @@ query OR g.search_vector @@ query OR c.body IS NOT NULL)`
+
+// searchDocument - текст, из которого ts_headline вырезает фрагменты: поля реплея, игра,
+// лучший из совпавших комментариев и строковые значения метаданных
+const searchDocument = `concat_ws(' … ', r.title, r.comment, r.original_name, g.name, h.comment_body,
+	(SELECT string_agg(v #>> '{}', ' ') FROM jsonb_path_query(r.metadata, 'strict $.** ? (@.type() == "string")') AS v))`
+
+type SearchRepository struct {
+	db *database.DB
+}
+
+func NewSearchRepository(db *database.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// Search возвращает страницу найденных реплеев по убыванию ранга и число всех совпадений
+// after - последний результат предыдущей страницы, nil - первая страница
+func (r *SearchRepository) Search(ctx context.Context, userID uuid.UUID, q string, after *models.SearchCursor, limit int) ([]models.SearchResult, int, error) {
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM (`+searchHits+`) hits`, userID, q).Scan(&total); err != nil {
+		return nil, 0, wrapQueryError("count search results", err)
+	}
+
+	args := []any{userID, q, limit, snippetOptions}
+	position := ""
+	if after != nil {
+		args = append(args, after.Rank, after.UploadedAt, after.ID)
+		position = "WHERE (rank, uploaded_at, id) < ($5::real, $6, $7)"
+	}
+
+	query := `
+		WITH hits AS (` + searchHits + `)
+		SELECT r.id, r.title, r.original_name, r.comment, r.size_bytes, r.content_type, r.uploaded_at, r.game_id, g.name,
+		       ` + replayTagsColumn("$1") + `, h.rank,
+		       ts_headline('simple', ` + searchDocument + `, websearch_to_tsquery('simple', $2), $4)
+		FROM (SELECT * FROM hits ` + position + ` ORDER BY rank DESC, uploaded_at DESC, id DESC LIMIT $3) h
+		JOIN replays r ON r.id = h.id
+		JOIN games g ON g.id = r.game_id
+		ORDER BY h.rank DESC, h.uploaded_at DESC, h.id DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, wrapQueryError("search replays", err)
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		var res models.SearchResult
+		if err := rows.Scan(&res.ID, &res.Title, &res.OriginalName, &res.Comment, &res.SizeBytes, &res.ContentType, &res.UploadedAt,
+			&res.GameID, &res.GameName, &res.Tags, &res.Rank, &res.Snippet); err != nil {
+			return nil, 0, wrapScanError("search result", err)
+		}
+		res.Snippet = highlightSnippet(res.Snippet)
+		results = append(results, res)
+	}
+
+	return results, total, rows.Err()
+}
+
+// highlightSnippet экранирует HTML в фрагментах и выделяет совпадения тегом <mark>
+func highlightSnippet(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
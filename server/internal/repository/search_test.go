@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHighlightSnippet проверяет экранирование текста фрагмента и выделение совпадений
+func TestHighlightSnippet(t *testing.T) {
+	snippet := "<b>" + snippetStart + "alice" + snippetStop + "</b> & bob"
+	assert.Equal(t, "&lt;b&gt;<mark>alice</mark>&lt;/b&gt; &amp; bob", highlightSnippet(snippet))
+}
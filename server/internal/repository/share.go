@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type ShareRepository struct {
+	db *database.DB
+}
+
+func NewShareRepository(db *database.DB) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+// shareColumns - поля ссылки s; название игры берется из games g, присоединенной к ссылке на игру
+const shareColumns = `
+	s.id, s.token_hash, s.user_id, s.replay_id, s.game_id, COALESCE(g.name, ''), COALESCE(s.password_hash, ''),
+	s.expires_at, s.max_views, s.view_count, s.created_at, s.revoked_at`
+
+func scanShare(row pgx.Row, s *models.ShareLink) error {
+	err := row.Scan(&s.ID, &s.TokenHash, &s.UserID, &s.ReplayID, &s.GameID, &s.GameName, &s.PasswordHash,
+		&s.ExpiresAt, &s.MaxViews, &s.ViewCount, &s.CreatedAt, &s.RevokedAt)
+	s.HasPassword = s.PasswordHash != ""
+	return err
+}
+
+// Create сохраняет ссылку на реплей или игру link.UserID
+// ErrNotFound - реплей или игра пользователю недоступны
+func (r *ShareRepository) Create(ctx context.Context, link *models.ShareLink) error {
+	query := `
+		INSERT INTO share_links (token_hash, user_id, replay_id, game_id, password_hash, expires_at, max_views)
+		SELECT $1, $2, $3, $4, NULLIF($5, ''), $6, $7
+		WHERE EXISTS (SELECT 1 FROM replays WHERE id = $3 AND user_id = $2)
+		   OR EXISTS (SELECT 1 FROM games WHERE id = $4 AND user_id = $2)
+		RETURNING id, created_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		link.TokenHash, link.UserID, link.ReplayID, link.GameID, link.PasswordHash, link.ExpiresAt, link.MaxViews,
+	).Scan(&link.ID, &link.CreatedAt)
+	if err != nil {
+		return wrapQueryError("create share link", err)
+	}
+	return nil
+}
+
+// GetByUserID возвращает ссылки пользователя, новые первыми, включая отозванные
+func (r *ShareRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM share_links s
+		LEFT JOIN games g ON g.id = s.game_id
+		WHERE s.user_id = $1
+		ORDER BY s.created_at DESC, s.id DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, wrapQueryError("query share links", err)
+	}
+	defer rows.Close()
+
+	links := make([]models.ShareLink, 0)
+	for rows.Next() {
+		var link models.ShareLink
+		if err := scanShare(rows, &link); err != nil {
+			return nil, wrapScanError("share link", err)
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// GetByTokenHash находит ссылку по хешу токена без проверки срока и отзыва
+func (r *ShareRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM share_links s
+		LEFT JOIN games g ON g.id = s.game_id
+		WHERE s.token_hash = $1
+	`
+
+	var link models.ShareLink
+	if err := scanShare(r.db.Pool.QueryRow(ctx, query, tokenHash), &link); err != nil {
+		return nil, wrapQueryError("get share link", err)
+	}
+	return &link, nil
+}
+
+// RecordView засчитывает просмотр и возвращает их новое число
+// ErrNotFound - ссылка тем временем отозвана, истекла или исчерпала лимит просмотров
+func (r *ShareRepository) RecordView(ctx context.Context, shareID uuid.UUID) (int, error) {
+	query := `
+		UPDATE share_links
+		SET view_count = view_count + 1
+		WHERE id = $1 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND (max_views IS NULL OR view_count < max_views)
+		RETURNING view_count
+	`
+
+	var views int
+	if err := r.db.Pool.QueryRow(ctx, query, shareID).Scan(&views); err != nil {
+		return 0, wrapQueryError("record share view", err)
+	}
+	return views, nil
+}
+
+// Revoke отзывает ссылку; повторный отзыв не меняет время первого
+func (r *ShareRepository) Revoke(ctx context.Context, shareID, userID uuid.UUID) error {
+	query := `
+		UPDATE share_links
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, shareID, userID)
+	if err != nil {
+		return wrapQueryError("revoke share link", err)
+	}
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("share link")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/tagexpr"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// replayTagsColumn - имена тегов пользователя viewer на реплее r по алфавиту
+// Теги личные: получатель доступа не видит теги владельца
+func replayTagsColumn(viewer string) string {
+	return `ARRAY(SELECT t.name FROM replay_tags rt JOIN tags t ON t.id = rt.tag_id WHERE rt.replay_id = r.id AND t.user_id = ` + viewer + ` ORDER BY t.name)`
+}
+
+// gameTagsColumn - имена тегов пользователя viewer на игре g по алфавиту
+func gameTagsColumn(viewer string) string {
+	return `ARRAY(SELECT t.name FROM game_tags gt JOIN tags t ON t.id = gt.tag_id WHERE gt.game_id = g.id AND t.user_id = ` + viewer + ` ORDER BY t.name)`
+}
+
+// tagColumns - поля тега t со счетчиками использования
+const tagColumns = `t.id, t.name, t.color, t.created_at,
+	(SELECT COUNT(*) FROM replay_tags rt WHERE rt.tag_id = t.id),
+	(SELECT COUNT(*) FROM game_tags gt WHERE gt.tag_id = t.id)`
+
+// tagCondition переводит выражение над тегами пользователя viewer в условие на реплей r; имена тегов уходят в args
+// Тег засчитывается, если он навешен на сам реплей или на его игру
+// Повторы одного имени используют один параметр
+func tagCondition(expr *tagexpr.Expr, viewer string, args *[]any) string {
+	params := make(map[string]int)
+	var build func(*tagexpr.Expr) string
+	build = func(expr *tagexpr.Expr) string {
+		switch expr.Op {
+		case tagexpr.OpTag:
+			param, ok := params[expr.Tag]
+			if !ok {
+				*args = append(*args, expr.Tag)
+				param = len(*args)
+				params[expr.Tag] = param
+			}
+			return fmt.Sprintf(`EXISTS (
+				SELECT 1 FROM tags t
+				WHERE t.user_id = %s AND t.name = $%d AND (
+					EXISTS (SELECT 1 FROM replay_tags rt WHERE rt.tag_id = t.id AND rt.replay_id = r.id) OR
+					EXISTS (SELECT 1 FROM game_tags gt WHERE gt.tag_id = t.id AND gt.game_id = r.game_id)))`, viewer, param)
+		case tagexpr.OpNot:
+			return "NOT " + build(expr.Args[0])
+		}
+
+		sep := " AND "
+		if expr.Op == tagexpr.OpOr {
+			sep = " OR "
+		}
+		condition := "("
+		for i, arg := range expr.Args {
+			if i > 0 {
+				condition += sep
+			}
+			condition += build(arg)
+		}
+		return condition + ")"
+	}
+	return build(expr)
+}
+
+type TagRepository struct {
+	db *database.DB
+}
+
+func NewTagRepository(db *database.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+func scanTag(row pgx.Row, tag *models.Tag) error {
+	return row.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.ReplayCount, &tag.GameCount)
+}
+
+// GetByUserID возвращает теги пользователя по алфавиту со счетчиками использования
+func (r *TagRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Tag, error) {
+	query := `
+		SELECT ` + tagColumns + `
+		FROM tags t
+		WHERE t.user_id = $1
+		ORDER BY t.name
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, wrapQueryError("query tags", err)
+	}
+	defer rows.Close()
+
+	tags := make([]models.Tag, 0)
+	for rows.Next() {
+		var tag models.Tag
+		if err := scanTag(rows, &tag); err != nil {
+			return nil, wrapScanError("tag", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Create создает тег; существующий тег с тем же именем возвращается, color == nil его цвет не меняет
+func (r *TagRepository) Create(ctx context.Context, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	query := `
+		INSERT INTO tags AS t (user_id, name, color)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE SET color = COALESCE(EXCLUDED.color, t.color)
+		RETURNING ` + tagColumns
+
+	var tag models.Tag
+	if err := scanTag(r.db.Pool.QueryRow(ctx, query, userID, name, color), &tag); err != nil {
+		return nil, wrapQueryError("create tag", err)
+	}
+	return &tag, nil
+}
+
+// Update переименовывает тег и задает ему цвет; ErrConflict - имя занято другим тегом
+func (r *TagRepository) Update(ctx context.Context, tagID, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	query := `
+		UPDATE tags t
+		SET name = $1, color = $2
+		WHERE t.id = $3 AND t.user_id = $4
+		RETURNING ` + tagColumns
+
+	var tag models.Tag
+	if err := scanTag(r.db.Pool.QueryRow(ctx, query, name, color, tagID, userID), &tag); err != nil {
+		return nil, wrapQueryError("update tag", err)
+	}
+	return &tag, nil
+}
+
+// Delete удаляет тег и снимает его со всех реплеев и игр
+func (r *TagRepository) Delete(ctx context.Context, tagID, userID uuid.UUID) error {
+	query := `DELETE FROM tags WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Pool.Exec(ctx, query, tagID, userID)
+	if err != nil {
+		return wrapQueryError("delete tag", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("tag")
+	}
+
+	return nil
+}
+
+// Assign навешивает и снимает теги одной транзакцией; недостающие теги из Add создаются
+// ErrNotFound - хотя бы один реплей или игра не принадлежат пользователю, тогда ничего не меняется
+func (r *TagRepository) Assign(ctx context.Context, userID uuid.UUID, a models.TagAssignment) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return wrapQueryError("begin transaction", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var replays, games int
+	err = tx.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM replays WHERE id = ANY($1) AND user_id = $3),
+		       (SELECT COUNT(*) FROM games WHERE id = ANY($2) AND user_id = $3)
+	`, a.ReplayIDs, a.GameIDs, userID).Scan(&replays, &games)
+	if err != nil {
+		return wrapQueryError("check tag targets", err)
+	}
+	if replays != len(a.ReplayIDs) {
+		return wrapNotFoundError("replay")
+	}
+	if games != len(a.GameIDs) {
+		return wrapNotFoundError("game")
+	}
+
+	if len(a.Add) > 0 {
+		batch := &pgx.Batch{}
+		batch.Queue(`INSERT INTO tags (user_id, name) SELECT $1, unnest($2::text[]) ON CONFLICT (user_id, name) DO NOTHING`,
+			userID, a.Add)
+		batch.Queue(`
+			INSERT INTO replay_tags (replay_id, tag_id)
+			SELECT target.id, t.id FROM unnest($3::uuid[]) AS target(id), tags t
+			WHERE t.user_id = $1 AND t.name = ANY($2)
+			ON CONFLICT DO NOTHING
+		`, userID, a.Add, a.ReplayIDs)
+		batch.Queue(`
+			INSERT INTO game_tags (game_id, tag_id)
+			SELECT target.id, t.id FROM unnest($3::uuid[]) AS target(id), tags t
+			WHERE t.user_id = $1 AND t.name = ANY($2)
+			ON CONFLICT DO NOTHING
+		`, userID, a.Add, a.GameIDs)
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return wrapQueryError("add tags", err)
+		}
+	}
+
+	if len(a.Remove) > 0 {
+		batch := &pgx.Batch{}
+		batch.Queue(`
+			DELETE FROM replay_tags rt USING tags t
+			WHERE rt.tag_id = t.id AND t.user_id = $1 AND t.name = ANY($2) AND rt.replay_id = ANY($3)
+		`, userID, a.Remove, a.ReplayIDs)
+		batch.Queue(`
+			DELETE FROM game_tags gt USING tags t
+			WHERE gt.tag_id = t.id AND t.user_id = $1 AND t.name = ANY($2) AND gt.game_id = ANY($3)
+		`, userID, a.Remove, a.GameIDs)
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return wrapQueryError("remove tags", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapQueryError("commit transaction", err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/tagexpr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTagCondition_RepeatedTag проверяет, что повторенный тег занимает один параметр
+func TestTagCondition_RepeatedTag(t *testing.T) {
+	expr, err := tagexpr.Parse("ranked AND (clutch OR ranked) AND NOT clutch")
+	require.NoError(t, err)
+
+	args := []any{"user"}
+	condition := tagCondition(expr, "$1", &args)
+
+	assert.Equal(t, []any{"user", "ranked", "clutch"}, args)
+	assert.Equal(t, 2, strings.Count(condition, "t.name = $2"))
+	assert.Equal(t, 2, strings.Count(condition, "t.name = $3"))
+	assert.NotContains(t, condition, "$4")
+}
+
+// TestTagCondition_Viewer проверяет, что фильтр и колонки тегов смотрят только на теги запросившего
+func TestTagCondition_Viewer(t *testing.T) {
+	expr, err := tagexpr.Parse("ranked")
+	require.NoError(t, err)
+
+	args := []any{"user"}
+	condition := tagCondition(expr, "$1", &args)
+	assert.Contains(t, condition, "t.user_id = $1 AND t.name = $2")
+	assert.NotContains(t, condition, "r.user_id")
+	assert.Contains(t, replayTagsColumn("$2"), "t.user_id = $2")
+	assert.Contains(t, gameTagsColumn("$1"), "t.user_id = $1")
+}
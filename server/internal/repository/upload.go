@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/database"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+)
+
+type UploadRepository struct {
+	db *database.DB
+}
+
+func NewUploadRepository(db *database.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+// Create создает сессию загрузки, только если игра принадлежит пользователю
+func (r *UploadRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	query := `
+		INSERT INTO upload_sessions (id, original_name, title, comment, size_bytes, expires_at, game_id, user_id)
+		SELECT $1, $2, $3, $4, $5, $6, g.id, g.user_id
+		FROM games g
+		WHERE g.id = $7 AND g.user_id = $8
+		RETURNING offset_bytes, created_at
+	`
+
+	err := r.db.Pool.QueryRow(ctx, query,
+		session.ID, session.OriginalName, session.Title, session.Comment, session.SizeBytes,
+		session.ExpiresAt, session.GameID, session.UserID,
+	).Scan(&session.Offset, &session.CreatedAt)
+	if err != nil {
+		return wrapQueryError("create upload session", err)
+	}
+
+	return nil
+}
+
+func (r *UploadRepository) GetByID(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.UploadSession, error) {
+	query := `
+		SELECT id, original_name, title, comment, size_bytes, offset_bytes, chunk_keys, created_at, expires_at, game_id, replay_id
+		FROM upload_sessions
+		WHERE id = $1 AND game_id = $2 AND user_id = $3 AND expires_at > NOW()
+	`
+
+	var session models.UploadSession
+	err := r.db.Pool.QueryRow(ctx, query, uploadID, gameID, userID).Scan(
+		&session.ID, &session.OriginalName, &session.Title, &session.Comment, &session.SizeBytes,
+		&session.Offset, &session.ChunkKeys, &session.CreatedAt, &session.ExpiresAt, &session.GameID, &session.ReplayID,
+	)
+	if err != nil {
+		return nil, wrapQueryError("get upload session", err)
+	}
+
+	session.UserID = userID
+	return &session, nil
+}
+
+// AppendChunk добавляет записанный кусок, если смещение сессии все еще равно expectedOffset
+// Возвращает ErrNotFound, если сессия истекла, финализируется или смещение уже сдвинулось
+func (r *UploadRepository) AppendChunk(ctx context.Context, uploadID, userID uuid.UUID, expectedOffset, written int64, chunkKey string, expiresAt time.Time) error {
+	query := `
+		UPDATE upload_sessions
+		SET offset_bytes = offset_bytes + $1, chunk_keys = array_append(chunk_keys, $2), expires_at = $3
+		WHERE id = $4 AND user_id = $5 AND offset_bytes = $6
+		  AND finalizing_at IS NULL AND replay_id IS NULL AND expires_at > NOW()
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, written, chunkKey, expiresAt, uploadID, userID, expectedOffset)
+	if err != nil {
+		return wrapQueryError("append upload chunk", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("upload session")
+	}
+
+	return nil
+}
+
+// Claim берет аренду на финализацию полностью загруженной сессии под токеном lease.Token
+// Зачем: два параллельных запроса на финализацию не должны создать два реплея
+// Аренда старше lease считается брошенной упавшим процессом и перехватывается, даже если срок сессии прошел
+// Срок жизни продлевается до expiresAt, чтобы после брошенной аренды финализацию можно было повторить
+// Сессия, из которой уже собран реплей, не захватывается
+func (r *UploadRepository) Claim(ctx context.Context, lease models.UploadLease, gameID, userID uuid.UUID, leaseTTL time.Duration, expiresAt time.Time) (*models.UploadSession, error) {
+	query := `
+		UPDATE upload_sessions
+		SET finalizing_at = NOW(), lease_token = $6, expires_at = GREATEST(expires_at, $4)
+		WHERE id = $1 AND game_id = $2 AND user_id = $3 AND offset_bytes = size_bytes AND replay_id IS NULL
+		  AND (finalizing_at IS NULL AND expires_at > NOW()
+		       OR finalizing_at <= NOW() - make_interval(secs => $5))
+		RETURNING id, original_name, title, comment, size_bytes, offset_bytes, chunk_keys, created_at, expires_at, game_id
+	`
+
+	var session models.UploadSession
+	err := r.db.Pool.QueryRow(ctx, query, lease.UploadID, gameID, userID, expiresAt, leaseTTL.Seconds(), lease.Token).Scan(
+		&session.ID, &session.OriginalName, &session.Title, &session.Comment, &session.SizeBytes,
+		&session.Offset, &session.ChunkKeys, &session.CreatedAt, &session.ExpiresAt, &session.GameID,
+	)
+	if err != nil {
+		return nil, wrapQueryError("claim upload session", err)
+	}
+
+	session.UserID = userID
+	return &session, nil
+}
+
+// RenewLease продлевает аренду финализации и срок жизни сессии до expiresAt
+// ErrNotFound - аренду перехватил другой запрос или сессия удалена
+func (r *UploadRepository) RenewLease(ctx context.Context, lease models.UploadLease, expiresAt time.Time) error {
+	query := `
+		UPDATE upload_sessions
+		SET finalizing_at = NOW(), expires_at = GREATEST(expires_at, $3)
+		WHERE id = $1 AND lease_token = $2 AND replay_id IS NULL
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, lease.UploadID, lease.Token, expiresAt)
+	if err != nil {
+		return wrapQueryError("renew upload lease", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return wrapNotFoundError("upload lease")
+	}
+
+	return nil
+}
+
+// Release снимает аренду после неудачной финализации; чужая аренда не снимается
+func (r *UploadRepository) Release(ctx context.Context, lease models.UploadLease) error {
+	query := `
+		UPDATE upload_sessions
+		SET finalizing_at = NULL, lease_token = NULL
+		WHERE id = $1 AND lease_token = $2 AND replay_id IS NULL
+	`
+
+	if _, err := r.db.Pool.Exec(ctx, query, lease.UploadID, lease.Token); err != nil {
+		return wrapQueryError("release upload session", err)
+	}
+
+	return nil
+}
+
+// Finish снимает аренду собранной сессии и забирает у нее ключи кусков для очистки хранилища
+// Сессия с replay_id живет до expiresAt, чтобы повторное завершение вернуло тот же реплей
+func (r *UploadRepository) Finish(ctx context.Context, uploadID uuid.UUID, expiresAt time.Time) ([]string, error) {
+	query := `
+		UPDATE upload_sessions u
+		SET chunk_keys = '{}', finalizing_at = NULL, lease_token = NULL, expires_at = $2
+		FROM (SELECT id, chunk_keys FROM upload_sessions WHERE id = $1 AND replay_id IS NOT NULL FOR UPDATE) old
+		WHERE u.id = old.id
+		RETURNING old.chunk_keys
+	`
+
+	var chunkKeys []string
+	if err := r.db.Pool.QueryRow(ctx, query, uploadID, expiresAt).Scan(&chunkKeys); err != nil {
+		return nil, wrapQueryError("finish upload session", err)
+	}
+
+	return chunkKeys, nil
+}
+
+// Delete удаляет сессию и возвращает ключи ее кусков для очистки хранилища
+func (r *UploadRepository) Delete(ctx context.Context, uploadID, userID uuid.UUID) ([]string, error) {
+	query := `
+		DELETE FROM upload_sessions
+		WHERE id = $1 AND user_id = $2
+		RETURNING chunk_keys
+	`
+
+	var chunkKeys []string
+	if err := r.db.Pool.QueryRow(ctx, query, uploadID, userID).Scan(&chunkKeys); err != nil {
+		return nil, wrapQueryError("delete upload session", err)
+	}
+
+	return chunkKeys, nil
+}
+
+// DeleteExpired удаляет истекшие сессии и возвращает ключи всех их кусков
+// Сессии с живой арендой (моложе lease) не трогаются: их куски читает идущая финализация
+func (r *UploadRepository) DeleteExpired(ctx context.Context, lease time.Duration) ([]string, error) {
+	query := `
+		DELETE FROM upload_sessions
+		WHERE expires_at <= NOW()
+		  AND (finalizing_at IS NULL OR finalizing_at <= NOW() - make_interval(secs => $1))
+		RETURNING chunk_keys
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, lease.Seconds())
+	if err != nil {
+		return nil, wrapQueryError("delete expired upload sessions", err)
+	}
+	defer rows.Close()
+
+	var chunkKeys []string
+	for rows.Next() {
+		var keys []string
+		if err := rows.Scan(&keys); err != nil {
+			return nil, wrapScanError("chunk keys", err)
+		}
+		chunkKeys = append(chunkKeys, keys...)
+	}
+
+	return chunkKeys, rows.Err()
+}
+
+// ListChunkKeys возвращает ключи кусков всех существующих сессий
+func (r *UploadRepository) ListChunkKeys(ctx context.Context) ([]string, error) {
+	query := `SELECT unnest(chunk_keys) FROM upload_sessions`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, wrapQueryError("list upload chunk keys", err)
+	}
+	defer rows.Close()
+
+	var chunkKeys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, wrapScanError("chunk key", err)
+		}
+		chunkKeys = append(chunkKeys, key)
+	}
+
+	return chunkKeys, rows.Err()
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+)
+
+const blobMigrationPageSize = 100
+
+// BlobMigrationReport - итог переноса файлов из раскладки {user_id}/{game_id}/{replay_id}.ext в blobs/
+// Migrated - файл стал новым blob, Deduplicated - реплей переключен на уже существующий blob
+type BlobMigrationReport struct {
+	Scanned      int      `json:"scanned"`
+	Migrated     int      `json:"migrated"`
+	Deduplicated int      `json:"deduplicated"`
+	FreedBytes   int64    `json:"freed_bytes"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// BlobMigrationService переносит файлы реплеев, загруженных до дедупликации, в content-addressed раскладку
+// Перенос идемпотентен: повторный запуск продолжает с реплеев, которые еще ссылаются на старые файлы
+type BlobMigrationService struct {
+	replayRepo BlobMigrationRepositoryInterface
+	storage    FileStorageInterface
+	logger     *slog.Logger
+}
+
+func NewBlobMigrationService(replayRepo BlobMigrationRepositoryInterface, storage FileStorageInterface, logger *slog.Logger) *BlobMigrationService {
+	return &BlobMigrationService{
+		replayRepo: replayRepo,
+		storage:    storage,
+		logger:     logger,
+	}
+}
+
+func (s *BlobMigrationService) MigrateLegacyFiles(ctx context.Context) (*BlobMigrationReport, error) {
+	s.logger.Info("starting blob migration")
+
+	report := &BlobMigrationReport{}
+	afterID := uuid.Nil
+	for {
+		replays, err := s.replayRepo.ListLegacyAfter(ctx, afterID, blobMigrationPageSize)
+		if err != nil {
+			s.logger.Error("failed to list legacy replays", slog.String("error", err.Error()))
+			return report, wrapError("list legacy replays", err)
+		}
+
+		for i := range replays {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			report.Scanned++
+			if err := s.migrateReplay(ctx, &replays[i], report); err != nil {
+				s.logger.Warn("failed to migrate replay file",
+					slog.String("replay_id", replays[i].ID.String()),
+					slog.String("file_path", replays[i].FilePath),
+					slog.String("error", err.Error()))
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+
+		if len(replays) < blobMigrationPageSize {
+			break
+		}
+		afterID = replays[len(replays)-1].ID
+	}
+
+	s.logger.Info("blob migration finished",
+		slog.Int("migrated", report.Migrated),
+		slog.Int("deduplicated", report.Deduplicated),
+		slog.Int64("freed_bytes", report.FreedBytes),
+		slog.Int("errors", len(report.Errors)))
+	return report, nil
+}
+
+func (s *BlobMigrationService) migrateReplay(ctx context.Context, replay *models.Replay, report *BlobMigrationReport) error {
+	content, info, err := s.storage.Open(ctx, replay.FilePath)
+	if err != nil {
+		return wrapError("open legacy file", err)
+	}
+	sum, err := hashReplayContent(replay, content)
+	content.Close()
+	if err != nil {
+		return wrapError("hash legacy file", err)
+	}
+
+	// Поврежденный файл нельзя делать общим: под его хешем оказалось бы чужое содержимое
+	if replay.SHA256 != "" && sum != replay.SHA256 {
+		return wrapError("verify legacy file", ErrChecksumMismatch)
+	}
+
+	legacyPath := replay.FilePath
+	blob := &models.Blob{
+		SHA256:      sum,
+		FilePath:    storage.BlobKey(sum, replay.Compression),
+		Compression: replay.Compression,
+		Compressed:  replay.Compressed,
+		SizeBytes:   info.Size,
+	}
+
+	// Файл копируется, а не переносится: если транзакция откатится, реплей по-прежнему ссылается на старый файл
+	created, err := s.replayRepo.AdoptBlob(ctx, replay, blob, func(filePath string) error {
+		return s.copyFile(ctx, legacyPath, filePath)
+	})
+	if err != nil {
+		return wrapError("adopt blob", err)
+	}
+
+	if created {
+		report.Migrated++
+	} else {
+		report.Deduplicated++
+		report.FreedBytes += info.Size
+	}
+
+	if err := s.storage.Delete(ctx, legacyPath); err != nil {
+		return wrapError("delete legacy file", err)
+	}
+	return nil
+}
+
+func (s *BlobMigrationService) copyFile(ctx context.Context, src, dst string) error {
+	content, info, err := s.storage.Open(ctx, src)
+	if err != nil {
+		return wrapError("open legacy file", err)
+	}
+	defer content.Close()
+
+	if err := s.storage.Put(ctx, dst, content, info.Size); err != nil {
+		return wrapError("copy legacy file", err)
+	}
+	return nil
+}
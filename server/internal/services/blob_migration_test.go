@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockBlobMigrationRepository - мок для переноса файлов в blobs
+type MockBlobMigrationRepository struct {
+	mock.Mock
+}
+
+func (m *MockBlobMigrationRepository) ListLegacyAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Replay), args.Error(1)
+}
+
+func (m *MockBlobMigrationRepository) AdoptBlob(ctx context.Context, replay *models.Replay, blob *models.Blob, place func(filePath string) error) (bool, error) {
+	args := m.Called(ctx, replay.ID, blob.FilePath)
+	if err := args.Error(1); err != nil {
+		return false, err
+	}
+	created := args.Bool(0)
+	if created {
+		if err := place(blob.FilePath); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+func setupBlobMigrationTest() (*BlobMigrationService, *MockBlobMigrationRepository, *MockFileStorage) {
+	mockRepo := new(MockBlobMigrationRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	return NewBlobMigrationService(mockRepo, mockStorage, logger), mockRepo, mockStorage
+}
+
+// TestMigrateLegacyFiles_Dedup проверяет перенос двух одинаковых файлов
+// Что тестируем: первый файл копируется в blobs/, второй реплей переключается на него, оба старых файла удаляются
+func TestMigrateLegacyFiles_Dedup(t *testing.T) {
+	service, mockRepo, mockStorage := setupBlobMigrationTest()
+
+	content := []byte("same replay")
+	sum := sha256Hex(content)
+	blobKey := storage.BlobKey(sum, "none")
+	replays := []models.Replay{
+		{ID: uuid.New(), FilePath: "user/game1/a.rep", Compression: "none", SHA256: sum},
+		{ID: uuid.New(), FilePath: "user/game2/b.rep", Compression: "none"},
+	}
+
+	mockRepo.On("ListLegacyAfter", mock.Anything, uuid.Nil, blobMigrationPageSize).Return(replays, nil)
+	for _, replay := range replays {
+		mockStorage.On("Open", mock.Anything, replay.FilePath).
+			Return(nopSeekCloser{bytes.NewReader(content)}, storage.ObjectInfo{Size: int64(len(content))}, nil)
+		mockStorage.On("Delete", mock.Anything, replay.FilePath).Return(nil)
+	}
+	mockRepo.On("AdoptBlob", mock.Anything, replays[0].ID, blobKey).Return(true, nil)
+	mockRepo.On("AdoptBlob", mock.Anything, replays[1].ID, blobKey).Return(false, nil)
+	mockStorage.On("Put", mock.Anything, blobKey, mock.Anything, int64(len(content))).Return(nil)
+
+	report, err := service.MigrateLegacyFiles(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Scanned)
+	assert.Equal(t, 1, report.Migrated)
+	assert.Equal(t, 1, report.Deduplicated)
+	assert.Equal(t, int64(len(content)), report.FreedBytes)
+	assert.Empty(t, report.Errors)
+	mockStorage.AssertNumberOfCalls(t, "Put", 1)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestMigrateLegacyFiles_ChecksumMismatch проверяет, что поврежденный файл не становится общим blob
+func TestMigrateLegacyFiles_ChecksumMismatch(t *testing.T) {
+	service, mockRepo, mockStorage := setupBlobMigrationTest()
+
+	replay := models.Replay{ID: uuid.New(), FilePath: "user/game/a.rep", Compression: "none", SHA256: sha256Hex([]byte("original"))}
+
+	mockRepo.On("ListLegacyAfter", mock.Anything, uuid.Nil, blobMigrationPageSize).Return([]models.Replay{replay}, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).
+		Return(nopSeekCloser{bytes.NewReader([]byte("damaged"))}, storage.ObjectInfo{Size: 7}, nil)
+
+	report, err := service.MigrateLegacyFiles(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], ErrChecksumMismatch.Error())
+	mockRepo.AssertNotCalled(t, "AdoptBlob", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/compression"
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/fckoffmw/replay-service/server/internal/media"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReplayNotFound = errors.New("replay not found")
+	// ErrClipUnsupported - фрагмент можно вырезать только из нефрагментированного MP4
+	ErrClipUnsupported  = errors.New("clips are supported only for mp4 replays")
+	ErrInvalidClipRange = errors.New("invalid clip range")
+)
+
+// CreateClip вырезает из MP4-реплея фрагмент [start, end) без перекодирования и сохраняет его
+// новым реплеем той же игры со ссылкой на исходный; начало сдвигается к ключевому кадру
+// Фрагмент ложится в игру и квоту владельца, поэтому вырезать его может только владелец
+func (s *ReplayService) CreateClip(ctx context.Context, replayID, userID uuid.UUID, start, end time.Duration, title string) (*models.Replay, error) {
+	s.logger.Info("creating clip",
+		slog.String("replay_id", replayID.String()),
+		slog.String("user_id", userID.String()),
+		slog.Duration("start", start),
+		slog.Duration("end", end))
+
+	source, err := s.replayRepo.GetByID(ctx, replayID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrReplayNotFound
+		}
+		return nil, wrapError("get replay", err)
+	}
+	if source.UserID != userID {
+		return nil, ErrForbidden
+	}
+	if !media.IsMP4(source.ContentType) {
+		return nil, ErrClipUnsupported
+	}
+
+	plan, err := planClip(ctx, s.storage, s.keyring, source, start, end)
+	switch {
+	case errors.Is(err, media.ErrInvalidRange):
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClipRange, err)
+	case errors.Is(err, media.ErrUnsupported):
+		return nil, ErrClipUnsupported
+	case err != nil:
+		s.logger.Error("failed to plan clip", slog.String("error", err.Error()))
+		return nil, wrapError("plan clip", err)
+	}
+
+	if s.quota != nil {
+		if err := s.quota.CheckUpload(ctx, userID, plan.Size()); err != nil {
+			return nil, wrapError("check quota", err)
+		}
+	}
+
+	name := clipName(source.OriginalName, plan.Start, plan.End)
+	algorithm := s.compression.AlgorithmFor(name, filetype.Lookup(source.ContentType))
+	clip := &models.Replay{
+		ID:             uuid.New(),
+		Title:          stringPtr(title),
+		OriginalName:   name,
+		SizeBytes:      plan.Size(),
+		ContentType:    source.ContentType,
+		Compression:    algorithm,
+		Compressed:     algorithm != compression.None,
+		GameID:         source.GameID,
+		UserID:         userID,
+		SourceReplayID: &source.ID,
+	}
+
+	src, err := openStoredContent(ctx, s.storage, s.keyring, source)
+	if err != nil {
+		s.logger.Error("failed to open source file", slog.String("error", err.Error()))
+		return nil, wrapError("open replay file", err)
+	}
+	defer src.Close()
+
+	stagingKey := storage.StagingKey(clip.ID)
+	storedSize, err := s.stageWritten(ctx, stagingKey, plan.Size(), clip, func(w io.Writer) error {
+		return plan.Write(w, src)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.probeMedia(ctx, clip, stagingKey)
+	if err := s.commit(ctx, clip, stagingKey, storedSize); err != nil {
+		return nil, err
+	}
+	return clip, nil
+}
+
+func planClip(ctx context.Context, files FileStorageInterface, keyring *encryption.Keyring, replay *models.Replay, start, end time.Duration) (*media.ClipPlan, error) {
+	src, err := openStoredContent(ctx, files, keyring, replay)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return media.PlanClip(src, replay.SizeBytes, start, end)
+}
+
+// clipName - имя файла фрагмента: match.mp4 -> match_clip_120-185.mp4 (границы в секундах)
+func clipName(original string, start, end time.Duration) string {
+	ext := filepath.Ext(original)
+	base := strings.TrimSuffix(original, ext)
+	return fmt.Sprintf("%s_clip_%d-%d%s", base, int64(start.Seconds()), int64(end.Seconds()), ext)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testClipSource собирает 2 с MP4: одна видеодорожка 30 fps, ключевые кадры на 0 и 1 с, сэмплы по 10 байт
+func testClipSource() []byte {
+	box := func(typ string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+		return append(append(out, typ...), body...)
+	}
+	u32 := func(values ...uint32) []byte {
+		var out []byte
+		for _, v := range values {
+			out = binary.BigEndian.AppendUint32(out, v)
+		}
+		return out
+	}
+
+	moov := func(mdatAt uint32) []byte {
+		stbl := box("stbl",
+			box("stsd", u32(0, 1), box("avc1", make([]byte, 78))),
+			box("stts", u32(0, 1, 60, 512)),
+			box("stss", u32(0, 2, 1, 31)),
+			box("stsz", u32(0, 10, 60)),
+			box("stsc", u32(0, 1, 1, 60, 1)),
+			box("stco", u32(0, 1, mdatAt+8)))
+		mdia := box("mdia",
+			box("mdhd", u32(0, 0, 0, 15360, 60*512), make([]byte, 4)),
+			box("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12)),
+			box("minf", stbl))
+		return box("moov", box("mvhd", u32(0, 0, 0, 1000, 2000), make([]byte, 80)), box("trak", box("tkhd", make([]byte, 84)), mdia))
+	}
+
+	ftyp := box("ftyp", []byte("isom"), u32(512), []byte("isomavc1"))
+	mdatAt := uint32(len(ftyp) + len(moov(0)))
+	return bytes.Join([][]byte{ftyp, moov(mdatAt), box("mdat", make([]byte, 600))}, nil)
+}
+
+// TestCreateClip_Success проверяет, что фрагмент сохраняется новым реплеем той же игры со ссылкой на исходный
+func TestCreateClip_Success(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+
+	video := testClipSource()
+	userID := uuid.New()
+	source := &models.Replay{ID: uuid.New(), GameID: uuid.New(), UserID: userID, OriginalName: "match.mp4", FilePath: "blobs/ab/source",
+		ContentType: "video/mp4", Compression: "none", SizeBytes: int64(len(video))}
+	mockReplayRepo.On("GetByID", mock.Anything, source.ID, userID).Return(source, nil)
+	mockStorage.On("Open", mock.Anything, source.FilePath).Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{}, nil).Once()
+	mockStorage.On("Open", mock.Anything, source.FilePath).Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{}, nil).Once()
+
+	var clipFile bytes.Buffer
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		io.Copy(&clipFile, args.Get(2).(io.Reader))
+	}).Return(nil)
+	mockStorage.On("Open", mock.Anything, stagingKey).Return(nil, storage.ObjectInfo{}, storage.ErrNotFound)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.MatchedBy(func(r *models.Replay) bool {
+		return r.SourceReplayID != nil && *r.SourceReplayID == source.ID && r.GameID == source.GameID
+	}), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+
+	clip, err := service.CreateClip(context.Background(), source.ID, userID, 1500*time.Millisecond, 2*time.Second, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "match_clip_1-2.mp4", clip.OriginalName, "начало сдвинуто к ключевому кадру на 1 с")
+	assert.Equal(t, "video/mp4", clip.ContentType)
+	assert.Equal(t, int64(clipFile.Len()), clip.SizeBytes)
+	assert.Less(t, clip.SizeBytes, source.SizeBytes)
+	mockReplayRepo.AssertExpectations(t)
+}
+
+// TestCreateClip_Rejected проверяет отказ для не-MP4 и для границ за пределами видео
+func TestCreateClip_Rejected(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+
+	userID := uuid.New()
+	demo := &models.Replay{ID: uuid.New(), UserID: userID, ContentType: "application/x-source-demo"}
+	mockReplayRepo.On("GetByID", mock.Anything, demo.ID, userID).Return(demo, nil)
+	_, err := service.CreateClip(context.Background(), demo.ID, userID, 0, time.Second, "")
+	assert.ErrorIs(t, err, ErrClipUnsupported)
+
+	video := testClipSource()
+	source := &models.Replay{ID: uuid.New(), UserID: userID, FilePath: "blobs/ab/source", ContentType: "video/mp4", Compression: "none",
+		SizeBytes: int64(len(video))}
+	mockReplayRepo.On("GetByID", mock.Anything, source.ID, userID).Return(source, nil)
+	mockStorage.On("Open", mock.Anything, source.FilePath).Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{}, nil)
+	_, err = service.CreateClip(context.Background(), source.ID, userID, 5*time.Second, 6*time.Second, "")
+	assert.ErrorIs(t, err, ErrInvalidClipRange)
+	mockStorage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrCommentForbidden = errors.New("only the author can edit a comment")
+	ErrInvalidComment   = errors.New("invalid comment")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+)
+
+const (
+	maxCommentBody   = 10000
+	maxCommentsLimit = 100
+)
+
+// CommentService ведет обсуждения реплеев: комментарии с ответами, историей правок и мягким удалением
+// Комментарии видит тот, кому виден реплей, а пишет владелец и получивший роль commenter
+type CommentService struct {
+	commentRepo CommentRepositoryInterface
+	replayRepo  ReplayRepositoryInterface
+	logger      *slog.Logger
+}
+
+func NewCommentService(commentRepo CommentRepositoryInterface, replayRepo ReplayRepositoryInterface, logger *slog.Logger) *CommentService {
+	return &CommentService{
+		commentRepo: commentRepo,
+		replayRepo:  replayRepo,
+		logger:      logger,
+	}
+}
+
+// ListComments возвращает страницу комментариев верхнего уровня, а при parentID - ответов на него
+// cursor - next_cursor предыдущей страницы, "" - первая страница
+func (s *CommentService) ListComments(ctx context.Context, replayID, userID uuid.UUID, parentID *uuid.UUID, cursor string, limit int) (*models.CommentPage, error) {
+	after, err := decodeCommentCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), maxCommentsLimit)
+
+	if parentID != nil {
+		if _, err := s.getComment(ctx, *parentID, replayID, userID); err != nil {
+			return nil, err
+		}
+	} else if err := s.checkReplay(ctx, replayID, userID); err != nil {
+		return nil, err
+	}
+
+	comments, err := s.commentRepo.List(ctx, replayID, userID, parentID, after, limit+1)
+	if err != nil {
+		s.logger.Error("failed to get comments", slog.String("error", err.Error()))
+		return nil, wrapError("get comments", err)
+	}
+
+	page := &models.CommentPage{}
+	page.Comments, page.NextCursor = nextPage(comments, limit, func(last *models.Comment) string {
+		return encodeCommentCursor(models.CommentCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	})
+	return page, nil
+}
+
+// CreateComment добавляет комментарий или, при comment.ParentID, ответ; автор - userID
+func (s *CommentService) CreateComment(ctx context.Context, replayID, userID uuid.UUID, comment models.Comment) (*models.Comment, error) {
+	s.logger.Info("creating comment",
+		slog.String("replay_id", replayID.String()),
+		slog.String("user_id", userID.String()))
+
+	body, err := normalizeCommentBody(comment.Body)
+	if err != nil {
+		return nil, err
+	}
+	if comment.TimeMS != nil && *comment.TimeMS < 0 {
+		return nil, fmt.Errorf("%w: time_ms must not be negative", ErrInvalidComment)
+	}
+
+	if comment.ParentID != nil {
+		parent, err := s.getComment(ctx, *comment.ParentID, replayID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.Deleted {
+			return nil, fmt.Errorf("%w: cannot reply to a deleted comment", ErrInvalidComment)
+		}
+	}
+
+	created := models.Comment{
+		ReplayID: replayID,
+		ParentID: comment.ParentID,
+		AuthorID: userID,
+		Body:     body,
+		TimeMS:   comment.TimeMS,
+	}
+	if err := s.commentRepo.Create(ctx, &created); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleCommenter, ErrReplayNotFound)
+		}
+		s.logger.Error("failed to create comment", slog.String("error", err.Error()))
+		return nil, wrapError("create comment", err)
+	}
+
+	s.logger.Info("comment created", slog.String("comment_id", created.ID.String()))
+	return &created, nil
+}
+
+// UpdateComment меняет текст комментария; править может только автор
+func (s *CommentService) UpdateComment(ctx context.Context, commentID, replayID, userID uuid.UUID, body string) (*models.Comment, error) {
+	s.logger.Info("updating comment",
+		slog.String("comment_id", commentID.String()),
+		slog.String("user_id", userID.String()))
+
+	body, err := normalizeCommentBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := s.getComment(ctx, commentID, replayID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Deleted {
+		return nil, ErrCommentNotFound
+	}
+	if comment.AuthorID != userID {
+		return nil, ErrCommentForbidden
+	}
+	if comment.Body == body {
+		return comment, nil
+	}
+
+	editedAt, err := s.commentRepo.UpdateBody(ctx, commentID, replayID, userID, body)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleCommenter, ErrCommentNotFound)
+		}
+		s.logger.Error("failed to update comment", slog.String("error", err.Error()))
+		return nil, wrapError("update comment", err)
+	}
+
+	comment.Body, comment.EditedAt = body, &editedAt
+	return comment, nil
+}
+
+// DeleteComment помечает комментарий удаленным; ответы на него остаются
+// Удалить может владелец реплея или автор, у которого осталась роль commenter
+func (s *CommentService) DeleteComment(ctx context.Context, commentID, replayID, userID uuid.UUID) error {
+	s.logger.Info("deleting comment",
+		slog.String("comment_id", commentID.String()),
+		slog.String("user_id", userID.String()))
+
+	if err := s.commentRepo.SoftDelete(ctx, commentID, replayID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Видимый, но не удаленный комментарий удалить не дали права
+			if comment, getErr := s.getComment(ctx, commentID, replayID, userID); getErr == nil && !comment.Deleted {
+				return ErrForbidden
+			}
+			return ErrCommentNotFound
+		}
+		s.logger.Error("failed to delete comment", slog.String("error", err.Error()))
+		return wrapError("delete comment", err)
+	}
+	return nil
+}
+
+// ListRevisions возвращает историю правок комментария
+func (s *CommentService) ListRevisions(ctx context.Context, commentID, replayID, userID uuid.UUID) ([]models.CommentRevision, error) {
+	comment, err := s.getComment(ctx, commentID, replayID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Deleted {
+		return nil, ErrCommentNotFound
+	}
+
+	revisions, err := s.commentRepo.ListRevisions(ctx, commentID, replayID, userID)
+	if err != nil {
+		s.logger.Error("failed to get comment revisions", slog.String("error", err.Error()))
+		return nil, wrapError("get comment revisions", err)
+	}
+	return revisions, nil
+}
+
+func (s *CommentService) checkReplay(ctx context.Context, replayID, userID uuid.UUID) error {
+	if _, err := s.replayRepo.GetByID(ctx, replayID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrReplayNotFound
+		}
+		return wrapError("get replay", err)
+	}
+	return nil
+}
+
+func (s *CommentService) getComment(ctx context.Context, commentID, replayID, userID uuid.UUID) (*models.Comment, error) {
+	comment, err := s.commentRepo.GetByID(ctx, commentID, replayID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, wrapError("get comment", err)
+	}
+	return comment, nil
+}
+
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("%w: body is required", ErrInvalidComment)
+	}
+	if utf8.RuneCountInString(body) > maxCommentBody {
+		return "", fmt.Errorf("%w: body is longer than %d characters", ErrInvalidComment, maxCommentBody)
+	}
+	return body, nil
+}
+
+// encodeCommentCursor кодирует позицию в непрозрачную строку: время создания и id последнего комментария
+func encodeCommentCursor(c models.CommentCursor) string {
+	return encodeCursorParts(c.CreatedAt.UTC().Format(time.RFC3339Nano), c.ID.String())
+}
+
+func decodeCommentCursor(cursor string) (*models.CommentCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	parts, ok := decodeCursorParts(cursor, 2)
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	var c models.CommentCursor
+	var err error
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID, err = uuid.Parse(parts[1]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCommentRepository - мок для обсуждений реплеев
+type MockCommentRepository struct {
+	mock.Mock
+}
+
+func (m *MockCommentRepository) List(ctx context.Context, replayID, userID uuid.UUID, parentID *uuid.UUID, after *models.CommentCursor, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, replayID, userID, parentID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetByID(ctx context.Context, commentID, replayID, userID uuid.UUID) (*models.Comment, error) {
+	args := m.Called(ctx, commentID, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	args := m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) UpdateBody(ctx context.Context, commentID, replayID, authorID uuid.UUID, body string) (time.Time, error) {
+	args := m.Called(ctx, commentID, replayID, authorID, body)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockCommentRepository) SoftDelete(ctx context.Context, commentID, replayID, userID uuid.UUID) error {
+	args := m.Called(ctx, commentID, replayID, userID)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) ListRevisions(ctx context.Context, commentID, replayID, userID uuid.UUID) ([]models.CommentRevision, error) {
+	args := m.Called(ctx, commentID, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.CommentRevision), args.Error(1)
+}
+
+func newTestCommentService() (*CommentService, *MockCommentRepository, *MockReplayRepository) {
+	commentRepo := new(MockCommentRepository)
+	replayRepo := new(MockReplayRepository)
+	return NewCommentService(commentRepo, replayRepo, slog.New(slog.NewTextHandler(os.Stdout, nil))), commentRepo, replayRepo
+}
+
+// TestListComments_Pagination проверяет курсор следующей страницы и продолжение с него
+func TestListComments_Pagination(t *testing.T) {
+	service, commentRepo, replayRepo := newTestCommentService()
+	replayID, userID := uuid.New(), uuid.New()
+	replayRepo.On("GetByID", mock.Anything, replayID, userID).Return(&models.Replay{ID: replayID}, nil)
+
+	base := time.Date(2025, 11, 24, 14, 0, 0, 0, time.UTC)
+	comments := make([]models.Comment, 3)
+	for i := range comments {
+		comments[i] = models.Comment{ID: uuid.New(), ReplayID: replayID, Body: "c", CreatedAt: base.Add(time.Duration(i) * time.Second)}
+	}
+	commentRepo.On("List", mock.Anything, replayID, userID, (*uuid.UUID)(nil), (*models.CommentCursor)(nil), 3).Return(comments, nil)
+
+	page, err := service.ListComments(context.Background(), replayID, userID, nil, "", 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Comments, 2)
+	require.NotEmpty(t, page.NextCursor)
+
+	after := &models.CommentCursor{CreatedAt: comments[1].CreatedAt, ID: comments[1].ID}
+	commentRepo.On("List", mock.Anything, replayID, userID, (*uuid.UUID)(nil), after, 3).Return(comments[2:], nil)
+
+	page, err = service.ListComments(context.Background(), replayID, userID, nil, page.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Comments, 1)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = service.ListComments(context.Background(), replayID, userID, nil, "not a cursor", 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+// TestCreateComment_Reply проверяет ответ на комментарий и отказ отвечать на удаленный
+func TestCreateComment_Reply(t *testing.T) {
+	service, commentRepo, _ := newTestCommentService()
+	replayID, userID := uuid.New(), uuid.New()
+	parent := &models.Comment{ID: uuid.New(), ReplayID: replayID}
+	deleted := &models.Comment{ID: uuid.New(), ReplayID: replayID, Deleted: true}
+	commentRepo.On("GetByID", mock.Anything, parent.ID, replayID, userID).Return(parent, nil)
+	commentRepo.On("GetByID", mock.Anything, deleted.ID, replayID, userID).Return(deleted, nil)
+	commentRepo.On("Create", mock.Anything, mock.MatchedBy(func(c *models.Comment) bool {
+		return c.ParentID != nil && *c.ParentID == parent.ID && c.AuthorID == userID && c.Body == "nice flick"
+	})).Return(nil)
+
+	reply, err := service.CreateComment(context.Background(), replayID, userID, models.Comment{ParentID: &parent.ID, Body: "  nice flick "})
+	require.NoError(t, err)
+	assert.Equal(t, "nice flick", reply.Body)
+
+	_, err = service.CreateComment(context.Background(), replayID, userID, models.Comment{ParentID: &deleted.ID, Body: "late"})
+	assert.ErrorIs(t, err, ErrInvalidComment)
+
+	_, err = service.CreateComment(context.Background(), replayID, userID, models.Comment{Body: " "})
+	assert.ErrorIs(t, err, ErrInvalidComment)
+}
+
+// TestUpdateComment проверяет, что править может только автор, а правка возвращает время изменения
+func TestUpdateComment(t *testing.T) {
+	service, commentRepo, _ := newTestCommentService()
+	replayID, authorID, otherID := uuid.New(), uuid.New(), uuid.New()
+	comment := &models.Comment{ID: uuid.New(), ReplayID: replayID, AuthorID: authorID, Body: "first"}
+	commentRepo.On("GetByID", mock.Anything, comment.ID, replayID, mock.Anything).Return(comment, nil)
+
+	_, err := service.UpdateComment(context.Background(), comment.ID, replayID, otherID, "hijack")
+	assert.ErrorIs(t, err, ErrCommentForbidden)
+
+	editedAt := time.Date(2025, 11, 24, 15, 0, 0, 0, time.UTC)
+	commentRepo.On("UpdateBody", mock.Anything, comment.ID, replayID, authorID, "second").Return(editedAt, nil)
+
+	updated, err := service.UpdateComment(context.Background(), comment.ID, replayID, authorID, "second")
+	require.NoError(t, err)
+	assert.Equal(t, "second", updated.Body)
+	assert.Equal(t, editedAt, *updated.EditedAt)
+}
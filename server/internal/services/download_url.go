@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// DownloadURLService выдает короткоживущие подписанные ссылки на файл реплея
+// Ссылка подставляется в <video src> и прямые скачивания вместо JWT в адресе
+type DownloadURLService struct {
+	replayRepo ReplayRepositoryInterface
+	signer     URLSignerInterface
+	logger     *slog.Logger
+}
+
+func NewDownloadURLService(replayRepo ReplayRepositoryInterface, signer URLSignerInterface, logger *slog.Logger) *DownloadURLService {
+	return &DownloadURLService{
+		replayRepo: replayRepo,
+		signer:     signer,
+		logger:     logger,
+	}
+}
+
+// SignReplayDownload подписывает ссылку filePath на файл реплея, доступного userID
+func (s *DownloadURLService) SignReplayDownload(ctx context.Context, replayID, userID uuid.UUID, filePath string) (*models.SignedURL, error) {
+	if _, err := s.replayRepo.GetByID(ctx, replayID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrReplayNotFound
+		}
+		s.logger.Error("failed to get replay", slog.String("error", err.Error()))
+		return nil, wrapError("get replay", err)
+	}
+
+	query, expiresAt := s.signer.Sign(replayID, userID)
+	s.logger.Info("signed replay download url",
+		slog.String("replay_id", replayID.String()),
+		slog.String("user_id", userID.String()),
+		slog.Time("expires_at", expiresAt))
+	return &models.SignedURL{URL: filePath + "?" + query.Encode(), ExpiresAt: expiresAt}, nil
+}
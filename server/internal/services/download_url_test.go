@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/signedurl"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSignReplayDownload_Success проверяет, что выданная ссылка проходит проверку подписи для своего реплея
+func TestSignReplayDownload_Success(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	signer := signedurl.NewSigner("secret", 5*time.Minute)
+	service := NewDownloadURLService(mockReplayRepo, signer, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	replayID, userID := uuid.New(), uuid.New()
+
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(&models.Replay{ID: replayID}, nil)
+
+	filePath := "/api/v1/replays/" + replayID.String() + "/file"
+	signed, err := service.SignReplayDownload(context.Background(), replayID, userID, filePath)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), signed.ExpiresAt, 2*time.Second)
+
+	path, rawQuery, ok := strings.Cut(signed.URL, "?")
+	require.True(t, ok)
+	assert.Equal(t, filePath, path)
+	query, err := url.ParseQuery(rawQuery)
+	require.NoError(t, err)
+
+	got, err := signer.Verify(replayID, query)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+}
+
+// TestSignReplayDownload_NotFound проверяет, что ссылка на недоступный реплей не выдается
+func TestSignReplayDownload_NotFound(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	service := NewDownloadURLService(mockReplayRepo, signedurl.NewSigner("secret", time.Minute), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	replayID, userID := uuid.New(), uuid.New()
+
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).
+		Return(nil, fmt.Errorf("failed to get replay: %w", repository.ErrNotFound))
+
+	_, err := service.SignReplayDownload(context.Background(), replayID, userID, "/file")
+	assert.ErrorIs(t, err, ErrReplayNotFound)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/media"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+)
+
+// WithFaststart переносит moov перед mdat в MP4, где он записан в конце файла,
+// чтобы браузер начинал воспроизведение, не скачивая файл целиком
+func WithFaststart() ReplayOption {
+	return func(s *ReplayService) {
+		s.faststart = true
+	}
+}
+
+// remuxFaststart переписывает временный файл MP4 с moov в начале и удаляет исходный
+// Возвращает ключ и размер файла, который дальше становится blob; при ошибке остается исходный файл
+func (s *ReplayService) remuxFaststart(ctx context.Context, replay *models.Replay, key string, size int64) (string, int64) {
+	staged := *replay
+	staged.FilePath = key
+	logger := s.logger.With(slog.String("replay_id", replay.ID.String()))
+
+	plan, err := planFaststart(ctx, s.storage, s.keyring, &staged)
+	if err != nil {
+		logger.Warn("failed to plan faststart remux", slog.String("error", err.Error()))
+		return key, size
+	}
+	if plan == nil {
+		return key, size
+	}
+
+	src, err := openStoredContent(ctx, s.storage, s.keyring, &staged)
+	if err != nil {
+		logger.Warn("failed to open file for faststart remux", slog.String("error", err.Error()))
+		return key, size
+	}
+	defer src.Close()
+
+	// stage перезаписывает хеш и ключ данных, исходные остаются у replay до успешной записи
+	remuxed := *replay
+	remuxKey := key + ".faststart"
+	storedSize, err := s.stageWritten(ctx, remuxKey, replay.SizeBytes, &remuxed, func(w io.Writer) error {
+		return plan.Write(w, src)
+	})
+	if err != nil {
+		logger.Warn("failed to write remuxed file", slog.String("error", err.Error()))
+		return key, size
+	}
+
+	if err := s.storage.Delete(ctx, key); err != nil {
+		logger.Warn("failed to delete staging file", slog.String("error", err.Error()))
+	}
+	replay.SHA256, replay.KeyID, replay.WrappedKey = remuxed.SHA256, remuxed.KeyID, remuxed.WrappedKey
+	replay.Remuxed = true
+
+	logger.Info("moved moov to the front of the file")
+	return remuxKey, storedSize
+}
+
+func planFaststart(ctx context.Context, files FileStorageInterface, keyring *encryption.Keyring, replay *models.Replay) (*media.FaststartPlan, error) {
+	src, err := openStoredContent(ctx, files, keyring, replay)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return media.PlanFaststart(src)
+}
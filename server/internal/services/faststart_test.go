@@ -0,0 +1,93 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/media"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateReplay_Faststart проверяет, что MP4 с moov в конце переписывается и сохраняется с отметкой remuxed
+func TestCreateReplay_Faststart(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithFaststart())
+
+	video := testMP4WithDuration()
+	plan, err := media.PlanFaststart(bytes.NewReader(video))
+	require.NoError(t, err)
+	var expected bytes.Buffer
+	require.NoError(t, plan.Write(&expected, bytes.NewReader(video)))
+
+	originalKey := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "staging/") && !strings.HasSuffix(key, ".faststart")
+	})
+	remuxedKey := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "staging/") && strings.HasSuffix(key, ".faststart")
+	})
+
+	var remuxed bytes.Buffer
+	mockStorage.On("Put", mock.Anything, originalKey, mock.Anything, int64(len(video))).Return(nil)
+	mockStorage.On("Open", mock.Anything, originalKey).Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{}, nil).Once()
+	mockStorage.On("Open", mock.Anything, originalKey).Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{}, nil).Once()
+	mockStorage.On("Put", mock.Anything, remuxedKey, mock.Anything, int64(len(video))).Run(func(args mock.Arguments) {
+		io.Copy(&remuxed, args.Get(2).(io.Reader))
+	}).Return(nil)
+	mockStorage.On("Delete", mock.Anything, originalKey).Return(nil)
+	mockStorage.On("Open", mock.Anything, remuxedKey).Return(nopSeekCloser{bytes.NewReader(expected.Bytes())}, storage.ObjectInfo{}, nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, remuxedKey, mock.Anything).Return(nil)
+
+	file := newTestFileHeader(t, "clip.mp4", video)
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), uuid.New(), "", "")
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(expected.Bytes())
+	assert.True(t, replay.Remuxed)
+	assert.Equal(t, expected.Bytes(), remuxed.Bytes())
+	assert.Equal(t, hex.EncodeToString(sum[:]), replay.SHA256, "хеш считается по переписанному файлу")
+	require.NotNil(t, replay.DurationMS)
+	assert.Equal(t, int64(5000), *replay.DurationMS)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestCreateReplay_FaststartNotNeeded проверяет, что файл с moov в начале не переписывается
+func TestCreateReplay_FaststartNotNeeded(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithFaststart())
+
+	plan, err := media.PlanFaststart(bytes.NewReader(testMP4WithDuration()))
+	require.NoError(t, err)
+	var video bytes.Buffer
+	require.NoError(t, plan.Write(&video, bytes.NewReader(testMP4WithDuration())))
+
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(video.Len())).Return(nil)
+	mockStorage.On("Open", mock.Anything, stagingKey).Return(nopSeekCloser{bytes.NewReader(video.Bytes())}, storage.ObjectInfo{}, nil).Once()
+	mockStorage.On("Open", mock.Anything, stagingKey).Return(nopSeekCloser{bytes.NewReader(video.Bytes())}, storage.ObjectInfo{}, nil).Once()
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.MatchedBy(func(r *models.Replay) bool {
+		return !r.Remuxed
+	}), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+
+	file := newTestFileHeader(t, "clip.mp4", video.Bytes())
+	_, err = service.CreateReplay(context.Background(), file, uuid.New(), uuid.New(), "", "")
+	require.NoError(t, err)
+	mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	mockReplayRepo.AssertExpectations(t)
+}
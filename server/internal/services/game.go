@@ -29,17 +29,33 @@ func NewGameService(
 	}
 }
 
-func (s *GameService) GetUserGames(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
+// GetUserGames возвращает страницу игр пользователя
+// cursor - next_cursor предыдущей страницы той же сортировки, "" - первая страница
+func (s *GameService) GetUserGames(ctx context.Context, userID uuid.UUID, filter models.GameFilter, sort models.Sort, cursor string, limit int) (*models.GamePage, error) {
 	s.logger.Info("getting user games", slog.String("user_id", userID.String()))
 
-	games, err := s.gameRepo.GetByUserID(ctx, userID)
+	if err := validateSort(sort, gameSortFields); err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(cursor, sort)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), maxListLimit)
+
+	games, total, err := s.gameRepo.GetByUserID(ctx, userID, filter, sort, after, limit+1)
 	if err != nil {
 		s.logger.Error("failed to get games", slog.String("error", err.Error()))
 		return nil, wrapError("get games", err)
 	}
 
-	s.logger.Info("games retrieved", slog.Int("count", len(games)))
-	return games, nil
+	page := &models.GamePage{Total: total}
+	page.Games, page.NextCursor = nextPage(games, limit, func(last *models.Game) string {
+		return encodeCursor(sort, models.Cursor{Key: last.SortKey, ID: last.ID})
+	})
+
+	s.logger.Info("games retrieved", slog.Int("count", len(page.Games)), slog.Int("total", total))
+	return page, nil
 }
 
 func (s *GameService) CreateGame(ctx context.Context, userID uuid.UUID, name string) (*models.Game, error) {
@@ -88,11 +104,10 @@ func (s *GameService) DeleteGame(ctx context.Context, gameID, userID uuid.UUID)
 		return wrapError("delete game", err)
 	}
 
-	s.logger.Info("removing replay files", slog.Int("count", len(filePaths)))
-	if errs := s.storage.DeleteFiles(filePaths); len(errs) > 0 {
-		for _, err := range errs {
-			s.logger.Warn("failed to delete file", slog.String("error", err.Error()))
-		}
+	// Ссылки на файлы отпущены каскадным удалением реплеев; удаляются только файлы без других ссылок
+	s.logger.Info("releasing replay files", slog.Int("count", len(filePaths)))
+	for _, filePath := range filePaths {
+		releaseFile(ctx, s.replayRepo, s.storage, s.logger, filePath)
 	}
 
 	s.logger.Info("game deleted")
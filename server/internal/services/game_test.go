@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"log/slog"
-	"mime/multipart"
+	"io"
 	"os"
 	"testing"
 
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -20,12 +21,12 @@ type MockGameRepository struct {
 	mock.Mock
 }
 
-func (m *MockGameRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
-	args := m.Called(ctx, userID)
+func (m *MockGameRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter models.GameFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Game, int, error) {
+	args := m.Called(ctx, userID, filter, sort, after, limit)
 	if args.Get(0) == nil {
-		return nil, args.Error(1)
+		return nil, 0, args.Error(2)
 	}
-	return args.Get(0).([]models.Game), args.Error(1)
+	return args.Get(0).([]models.Game), args.Int(1), args.Error(2)
 }
 
 func (m *MockGameRepository) Create(ctx context.Context, userID uuid.UUID, name string) (*models.Game, error) {
@@ -49,14 +50,24 @@ func (m *MockGameRepository) Delete(ctx context.Context, gameID, userID uuid.UUI
 // MockReplayRepository - мок для ReplayRepository
 type MockReplayRepository struct {
 	mock.Mock
+	// quotaLimit - квота, переданная в последний вызов CreateWithBlob
+	quotaLimit *models.Quota
 }
 
-func (m *MockReplayRepository) GetByGameID(ctx context.Context, gameID, userID uuid.UUID, limit int) ([]models.Replay, error) {
-	args := m.Called(ctx, gameID, userID, limit)
+func (m *MockReplayRepository) GetByUserID(ctx context.Context, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error) {
+	args := m.Called(ctx, userID, filter, sort, after, limit)
 	if args.Get(0) == nil {
-		return nil, args.Error(1)
+		return nil, 0, args.Error(2)
 	}
-	return args.Get(0).([]models.Replay), args.Error(1)
+	return args.Get(0).([]models.Replay), args.Int(1), args.Error(2)
+}
+
+func (m *MockReplayRepository) GetByGameID(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error) {
+	args := m.Called(ctx, gameID, userID, filter, sort, after, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.Replay), args.Int(1), args.Error(2)
 }
 
 func (m *MockReplayRepository) GetByID(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
@@ -67,9 +78,21 @@ func (m *MockReplayRepository) GetByID(ctx context.Context, replayID, userID uui
 	return args.Get(0).(*models.Replay), args.Error(1)
 }
 
-func (m *MockReplayRepository) Create(ctx context.Context, replay *models.Replay) error {
-	args := m.Called(ctx, replay)
-	return args.Error(0)
+// CreateWithBlob ведет себя как репозиторий: новый blob кладется через place, реплей получает путь и сжатие blob
+func (m *MockReplayRepository) CreateWithBlob(ctx context.Context, replay *models.Replay, blob *models.Blob, limit *models.Quota, place func(filePath string) error) (bool, error) {
+	m.quotaLimit = limit
+	args := m.Called(ctx, replay, blob)
+	if err := args.Error(1); err != nil {
+		return false, err
+	}
+	created := args.Bool(0)
+	if created {
+		if err := place(blob.FilePath); err != nil {
+			return false, err
+		}
+	}
+	replay.FilePath, replay.Compression, replay.Compressed = blob.FilePath, blob.Compression, blob.Compressed
+	return created, nil
 }
 
 func (m *MockReplayRepository) Update(ctx context.Context, replayID, userID uuid.UUID, title, comment *string) error {
@@ -90,32 +113,68 @@ func (m *MockReplayRepository) GetFilePathsByGameID(ctx context.Context, gameID,
 	return args.Get(0).([]string), args.Error(1)
 }
 
+// ReleaseFile вызывает remove, если мок вернул true (на файл не осталось ссылок)
+func (m *MockReplayRepository) ReleaseFile(ctx context.Context, filePath string, remove func(filePath string) error) (bool, error) {
+	args := m.Called(ctx, filePath)
+	return callRemove(args, filePath, remove)
+}
+
+func callRemove(args mock.Arguments, filePath string, remove func(filePath string) error) (bool, error) {
+	if err := args.Error(1); err != nil {
+		return false, err
+	}
+	if !args.Bool(0) {
+		return false, nil
+	}
+	if err := remove(filePath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // MockFileStorage - мок для FileStorage
 type MockFileStorage struct {
 	mock.Mock
 }
 
-func (m *MockFileStorage) SaveReplayFile(file *multipart.FileHeader, userID, gameID, replayID uuid.UUID) (string, error) {
-	args := m.Called(file, userID, gameID, replayID)
-	return args.String(0), args.Error(1)
-}
-
-func (m *MockFileStorage) DeleteFile(filePath string) error {
-	args := m.Called(filePath)
+func (m *MockFileStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	args := m.Called(ctx, key, r, size)
 	return args.Error(0)
 }
 
-func (m *MockFileStorage) DeleteFiles(filePaths []string) []error {
-	args := m.Called(filePaths)
+func (m *MockFileStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, storage.ObjectInfo, error) {
+	args := m.Called(ctx, key)
 	if args.Get(0) == nil {
-		return nil
+		return nil, storage.ObjectInfo{}, args.Error(2)
 	}
-	return args.Get(0).([]error)
+	return args.Get(0).(io.ReadSeekCloser), args.Get(1).(storage.ObjectInfo), args.Error(2)
+}
+
+func (m *MockFileStorage) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(storage.ObjectInfo), args.Error(1)
 }
 
-func (m *MockFileStorage) GetFilePath(relativePath string) string {
-	args := m.Called(relativePath)
-	return args.String(0)
+func (m *MockFileStorage) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockFileStorage) Move(ctx context.Context, src, dst string) error {
+	args := m.Called(ctx, src, dst)
+	return args.Error(0)
+}
+
+func (m *MockFileStorage) Walk(ctx context.Context, prefix string, fn func(storage.ObjectInfo) error) error {
+	args := m.Called(ctx, prefix, fn)
+	if objects, ok := args.Get(0).([]storage.ObjectInfo); ok {
+		for _, info := range objects {
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
 }
 
 // TestGetUserGames_Success проверяет успешное получение списка игр
@@ -136,15 +195,18 @@ func TestGetUserGames_Success(t *testing.T) {
 	}
 	
 	// Настраиваем мок: при вызове GetByUserID вернуть expectedGames
-	mockGameRepo.On("GetByUserID", mock.Anything, userID).Return(expectedGames, nil)
+	newest := models.Sort{Field: models.GameSortCreatedAt, Desc: true}
+	mockGameRepo.On("GetByUserID", mock.Anything, userID, models.GameFilter{}, newest, (*models.Cursor)(nil), 51).Return(expectedGames, 2, nil)
 	
 	// Act - выполнение тестируемого действия
-	games, err := service.GetUserGames(context.Background(), userID)
+	page, err := service.GetUserGames(context.Background(), userID, models.GameFilter{}, newest, "", 50)
 	
 	// Assert - проверка результатов
 	assert.NoError(t, err, "не должно быть ошибки")
-	assert.Equal(t, 2, len(games), "должно вернуться 2 игры")
-	assert.Equal(t, expectedGames, games, "игры должны совпадать")
+	assert.Equal(t, 2, len(page.Games), "должно вернуться 2 игры")
+	assert.Equal(t, expectedGames, page.Games, "игры должны совпадать")
+	assert.Equal(t, 2, page.Total)
+	assert.Empty(t, page.NextCursor, "следующей страницы нет")
 	
 	// Проверяем, что мок был вызван с правильными параметрами
 	mockGameRepo.AssertExpectations(t)
@@ -164,12 +226,12 @@ func TestGetUserGames_RepositoryError(t *testing.T) {
 	expectedError := errors.New("database connection failed")
 	
 	// Настраиваем мок: при вызове GetByUserID вернуть ошибку
-	mockGameRepo.On("GetByUserID", mock.Anything, userID).Return(nil, expectedError)
+	mockGameRepo.On("GetByUserID", mock.Anything, userID, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, 0, expectedError)
 	
-	games, err := service.GetUserGames(context.Background(), userID)
+	page, err := service.GetUserGames(context.Background(), userID, models.GameFilter{}, models.Sort{Field: models.GameSortName}, "", 50)
 	
 	assert.Error(t, err, "должна быть ошибка")
-	assert.Nil(t, games, "игры должны быть nil")
+	assert.Nil(t, page, "страница должна быть nil")
 	assert.Contains(t, err.Error(), "get games", "ошибка должна содержать контекст")
 	
 	mockGameRepo.AssertExpectations(t)
@@ -239,10 +301,13 @@ func TestDeleteGame_Success(t *testing.T) {
 	userID := uuid.New()
 	filePaths := []string{"path/to/replay1.rep", "path/to/replay2.rep"}
 	
-	// Настраиваем моки: сначала получаем пути файлов, потом удаляем игру, потом файлы
+	// Настраиваем моки: сначала получаем пути файлов, потом удаляем игру, потом освобождаем файлы
+	// На второй файл ссылается реплей другой игры, поэтому он остается в хранилище
 	mockReplayRepo.On("GetFilePathsByGameID", mock.Anything, gameID, userID).Return(filePaths, nil)
 	mockGameRepo.On("Delete", mock.Anything, gameID, userID).Return(nil)
-	mockStorage.On("DeleteFiles", filePaths).Return([]error(nil))
+	mockReplayRepo.On("ReleaseFile", mock.Anything, filePaths[0]).Return(true, nil)
+	mockReplayRepo.On("ReleaseFile", mock.Anything, filePaths[1]).Return(false, nil)
+	mockStorage.On("Delete", mock.Anything, filePaths[0]).Return(nil)
 	
 	err := service.DeleteGame(context.Background(), gameID, userID)
 	
@@ -272,8 +337,8 @@ func TestDeleteGame_RepositoryError(t *testing.T) {
 	err := service.DeleteGame(context.Background(), gameID, userID)
 	
 	assert.Error(t, err)
-	// Проверяем, что DeleteFiles НЕ был вызван (файлы не удаляются при ошибке БД)
-	mockStorage.AssertNotCalled(t, "DeleteFiles")
+	// Проверяем, что Delete НЕ был вызван (файлы не удаляются при ошибке БД)
+	mockStorage.AssertNotCalled(t, "Delete")
 	
 	mockReplayRepo.AssertExpectations(t)
 	mockGameRepo.AssertExpectations(t)
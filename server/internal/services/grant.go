@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrForbidden - ресурс пользователю виден, но его роли не хватает для действия
+	ErrForbidden          = errors.New("insufficient permissions")
+	ErrGrantNotFound      = errors.New("grant not found")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvalidGrant       = errors.New("invalid grant")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
+// GrantService выдает зарегистрированным пользователям доступ к играм и реплеям владельца
+// Доступ начинает действовать, когда приглашенный его примет; сами права проверяют репозитории
+type GrantService struct {
+	grantRepo     GrantRepositoryInterface
+	users         UserLookupInterface
+	sharedGames   SharedGamesRepositoryInterface
+	sharedReplays SharedReplaysRepositoryInterface
+	logger        *slog.Logger
+}
+
+func NewGrantService(grantRepo GrantRepositoryInterface, users UserLookupInterface, sharedGames SharedGamesRepositoryInterface, sharedReplays SharedReplaysRepositoryInterface, logger *slog.Logger) *GrantService {
+	return &GrantService{
+		grantRepo:     grantRepo,
+		users:         users,
+		sharedGames:   sharedGames,
+		sharedReplays: sharedReplays,
+		logger:        logger,
+	}
+}
+
+// GrantGame приглашает пользователя в игру со всеми ее реплеями, в том числе загруженными позже
+// Повторное приглашение меняет роль, а отклоненное приглашение отправляется заново
+func (s *GrantService) GrantGame(ctx context.Context, gameID, ownerID uuid.UUID, req models.GrantRequest) (*models.Grant, error) {
+	grant, err := s.grant(ctx, &models.Grant{OwnerID: ownerID, GameID: &gameID}, req)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrGameNotFound
+	}
+	return grant, err
+}
+
+// GrantReplay приглашает пользователя к одному реплею
+func (s *GrantService) GrantReplay(ctx context.Context, replayID, ownerID uuid.UUID, req models.GrantRequest) (*models.Grant, error) {
+	grant, err := s.grant(ctx, &models.Grant{OwnerID: ownerID, ReplayID: &replayID}, req)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrReplayNotFound
+	}
+	return grant, err
+}
+
+func (s *GrantService) grant(ctx context.Context, grant *models.Grant, req models.GrantRequest) (*models.Grant, error) {
+	s.logger.Info("granting access",
+		slog.String("owner_id", grant.OwnerID.String()),
+		slog.String("role", string(req.Role)))
+
+	if !req.Role.Grantable() {
+		return nil, fmt.Errorf("%w: role must be one of viewer, commenter, editor", ErrInvalidGrant)
+	}
+	login := strings.TrimSpace(req.Login)
+	if login == "" {
+		return nil, fmt.Errorf("%w: login is required", ErrInvalidGrant)
+	}
+
+	grantee, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, wrapError("get user", err)
+	}
+	if grantee == nil {
+		return nil, ErrUserNotFound
+	}
+	if grantee.ID == grant.OwnerID {
+		return nil, fmt.Errorf("%w: cannot share with yourself", ErrInvalidGrant)
+	}
+
+	grant.GranteeID, grant.Role = grantee.ID, req.Role
+	if err := s.grantRepo.Upsert(ctx, grant); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.Error("failed to grant access", slog.String("error", err.Error()))
+		}
+		return nil, wrapError("grant access", err)
+	}
+
+	s.logger.Info("access granted",
+		slog.String("grant_id", grant.ID.String()),
+		slog.String("status", string(grant.Status)))
+	return grant, nil
+}
+
+// GetGrants возвращает выданные пользователем доступы со всеми статусами
+func (s *GrantService) GetGrants(ctx context.Context, ownerID uuid.UUID) ([]models.Grant, error) {
+	grants, err := s.grantRepo.GetByOwner(ctx, ownerID)
+	if err != nil {
+		s.logger.Error("failed to get grants", slog.String("error", err.Error()))
+		return nil, wrapError("get grants", err)
+	}
+	return grants, nil
+}
+
+// GetInvitations возвращает приглашения пользователю, на которые он еще не ответил
+func (s *GrantService) GetInvitations(ctx context.Context, userID uuid.UUID) ([]models.Grant, error) {
+	grants, err := s.grantRepo.GetByGrantee(ctx, userID, models.GrantPending)
+	if err != nil {
+		s.logger.Error("failed to get invitations", slog.String("error", err.Error()))
+		return nil, wrapError("get invitations", err)
+	}
+	return grants, nil
+}
+
+func (s *GrantService) AcceptInvitation(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error) {
+	return s.respond(ctx, grantID, userID, models.GrantAccepted)
+}
+
+func (s *GrantService) DeclineInvitation(ctx context.Context, grantID, userID uuid.UUID) (*models.Grant, error) {
+	return s.respond(ctx, grantID, userID, models.GrantDeclined)
+}
+
+// respond отвечает на приглашение; ответить можно только один раз
+func (s *GrantService) respond(ctx context.Context, grantID, userID uuid.UUID, status models.GrantStatus) (*models.Grant, error) {
+	s.logger.Info("responding to invitation",
+		slog.String("grant_id", grantID.String()),
+		slog.String("status", string(status)))
+
+	grant, err := s.grantRepo.Respond(ctx, grantID, userID, status)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		s.logger.Error("failed to respond to invitation", slog.String("error", err.Error()))
+		return nil, wrapError("respond to invitation", err)
+	}
+	return grant, nil
+}
+
+// DeleteGrant отзывает доступ; владелец отзывает выданный доступ, получатель - отказывается от своего
+func (s *GrantService) DeleteGrant(ctx context.Context, grantID, userID uuid.UUID) error {
+	s.logger.Info("deleting grant",
+		slog.String("grant_id", grantID.String()),
+		slog.String("user_id", userID.String()))
+
+	if err := s.grantRepo.Delete(ctx, grantID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrGrantNotFound
+		}
+		s.logger.Error("failed to delete grant", slog.String("error", err.Error()))
+		return wrapError("delete grant", err)
+	}
+	return nil
+}
+
+// GetSharedGames возвращает чужие игры, доступ к которым пользователь принял
+func (s *GrantService) GetSharedGames(ctx context.Context, userID uuid.UUID) ([]models.Game, error) {
+	games, err := s.sharedGames.GetShared(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get shared games", slog.String("error", err.Error()))
+		return nil, wrapError("get shared games", err)
+	}
+	return games, nil
+}
+
+// GetSharedReplays возвращает чужие реплеи, выданные пользователю по одному
+func (s *GrantService) GetSharedReplays(ctx context.Context, userID uuid.UUID) ([]models.Replay, error) {
+	replays, err := s.sharedReplays.GetShared(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get shared replays", slog.String("error", err.Error()))
+		return nil, wrapError("get shared replays", err)
+	}
+	return replays, nil
+}
+
+// replayAccessError уточняет отказ репозитория в действии над реплеем: если реплей пользователю
+// виден, но его роль ниже required, - ErrForbidden, иначе notFound
+func replayAccessError(ctx context.Context, replayRepo ReplayRepositoryInterface, replayID, userID uuid.UUID, required models.Role, notFound error) error {
+	replay, err := replayRepo.GetByID(ctx, replayID, userID)
+	if err == nil && !replay.Role.Allows(required) {
+		return ErrForbidden
+	}
+	return notFound
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockGrantRepository - мок для доступа к чужим играм и реплеям
+type MockGrantRepository struct {
+	mock.Mock
+}
+
+func (m *MockGrantRepository) Upsert(ctx context.Context, grant *models.Grant) error {
+	args := m.Called(ctx, grant)
+	return args.Error(0)
+}
+
+func (m *MockGrantRepository) GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.Grant, error) {
+	args := m.Called(ctx, ownerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Grant), args.Error(1)
+}
+
+func (m *MockGrantRepository) GetByGrantee(ctx context.Context, granteeID uuid.UUID, status models.GrantStatus) ([]models.Grant, error) {
+	args := m.Called(ctx, granteeID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Grant), args.Error(1)
+}
+
+func (m *MockGrantRepository) Respond(ctx context.Context, grantID, granteeID uuid.UUID, status models.GrantStatus) (*models.Grant, error) {
+	args := m.Called(ctx, grantID, granteeID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Grant), args.Error(1)
+}
+
+func (m *MockGrantRepository) Delete(ctx context.Context, grantID, userID uuid.UUID) error {
+	args := m.Called(ctx, grantID, userID)
+	return args.Error(0)
+}
+
+// MockUserLookup - мок поиска пользователя по логину
+type MockUserLookup struct {
+	mock.Mock
+}
+
+func (m *MockUserLookup) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	args := m.Called(ctx, login)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func newTestGrantService(grantRepo *MockGrantRepository, users *MockUserLookup) *GrantService {
+	return NewGrantService(grantRepo, users, nil, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+}
+
+// TestGrantGame_Success проверяет приглашение по логину: в репозиторий уходят получатель и роль
+func TestGrantGame_Success(t *testing.T) {
+	grantRepo, users := new(MockGrantRepository), new(MockUserLookup)
+	service := newTestGrantService(grantRepo, users)
+	ownerID, gameID := uuid.New(), uuid.New()
+	coach := &models.User{ID: uuid.New(), Login: "coach"}
+
+	users.On("GetByLogin", mock.Anything, "coach").Return(coach, nil)
+	grantRepo.On("Upsert", mock.Anything, mock.MatchedBy(func(g *models.Grant) bool {
+		return g.OwnerID == ownerID && *g.GameID == gameID && g.ReplayID == nil &&
+			g.GranteeID == coach.ID && g.Role == models.RoleCommenter
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Grant).Status = models.GrantPending
+	}).Return(nil)
+
+	grant, err := service.GrantGame(context.Background(), gameID, ownerID, models.GrantRequest{Login: " coach ", Role: models.RoleCommenter})
+	require.NoError(t, err)
+	assert.Equal(t, models.GrantPending, grant.Status)
+	grantRepo.AssertExpectations(t)
+}
+
+// TestGrantReplay_Rejected проверяет отказы: роль, логин, приглашение себя и чужой реплей
+func TestGrantReplay_Rejected(t *testing.T) {
+	ownerID, replayID := uuid.New(), uuid.New()
+	owner := &models.User{ID: ownerID, Login: "player"}
+	coach := &models.User{ID: uuid.New(), Login: "coach"}
+
+	tests := []struct {
+		name string
+		req  models.GrantRequest
+		err  error
+	}{
+		{name: "owner role", req: models.GrantRequest{Login: "coach", Role: models.RoleOwner}, err: ErrInvalidGrant},
+		{name: "unknown role", req: models.GrantRequest{Login: "coach", Role: "admin"}, err: ErrInvalidGrant},
+		{name: "empty login", req: models.GrantRequest{Login: " ", Role: models.RoleViewer}, err: ErrInvalidGrant},
+		{name: "unknown user", req: models.GrantRequest{Login: "nobody", Role: models.RoleViewer}, err: ErrUserNotFound},
+		{name: "self", req: models.GrantRequest{Login: "player", Role: models.RoleViewer}, err: ErrInvalidGrant},
+		{name: "not owner", req: models.GrantRequest{Login: "coach", Role: models.RoleViewer}, err: ErrReplayNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grantRepo, users := new(MockGrantRepository), new(MockUserLookup)
+			service := newTestGrantService(grantRepo, users)
+			users.On("GetByLogin", mock.Anything, "nobody").Return(nil, nil)
+			users.On("GetByLogin", mock.Anything, "player").Return(owner, nil)
+			users.On("GetByLogin", mock.Anything, "coach").Return(coach, nil)
+			grantRepo.On("Upsert", mock.Anything, mock.Anything).Return(fmt.Errorf("failed to grant access: %w", repository.ErrNotFound))
+
+			_, err := service.GrantReplay(context.Background(), replayID, ownerID, tt.req)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// TestAcceptInvitation_Answered проверяет, что на приглашение нельзя ответить повторно
+func TestAcceptInvitation_Answered(t *testing.T) {
+	grantRepo := new(MockGrantRepository)
+	service := newTestGrantService(grantRepo, new(MockUserLookup))
+	grantID, userID := uuid.New(), uuid.New()
+
+	grantRepo.On("Respond", mock.Anything, grantID, userID, models.GrantAccepted).
+		Return(nil, fmt.Errorf("failed to respond to invitation: %w", repository.ErrNotFound))
+
+	_, err := service.AcceptInvitation(context.Background(), grantID, userID)
+	assert.ErrorIs(t, err, ErrInvitationNotFound)
+}
+
+// TestRole_Allows проверяет порядок ролей: каждая следующая включает предыдущие
+func TestRole_Allows(t *testing.T) {
+	assert.True(t, models.RoleEditor.Allows(models.RoleCommenter))
+	assert.True(t, models.RoleOwner.Allows(models.RoleEditor))
+	assert.False(t, models.RoleViewer.Allows(models.RoleCommenter))
+	assert.False(t, models.RoleEditor.Allows(models.RoleOwner))
+	assert.False(t, models.Role("").Allows(models.RoleViewer))
+	assert.Equal(t, []models.Role{models.RoleCommenter, models.RoleEditor}, models.RolesAtLeast(models.RoleCommenter))
+}
+
+// TestUpdateReplay_Access проверяет ответ на отказ репозитория: 403 для видимого реплея, иначе 404
+func TestUpdateReplay_Access(t *testing.T) {
+	tests := []struct {
+		name   string
+		replay *models.Replay
+		err    error
+	}{
+		{name: "viewer", replay: &models.Replay{Role: models.RoleViewer}, err: ErrForbidden},
+		{name: "hidden", err: ErrReplayNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockReplayRepo := new(MockReplayRepository)
+			service := NewReplayService(mockReplayRepo, new(MockFileStorage), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+			replayID, userID := uuid.New(), uuid.New()
+			title := "new title"
+
+			mockReplayRepo.On("Update", mock.Anything, replayID, userID, &title, (*string)(nil)).
+				Return(fmt.Errorf("replay %w", repository.ErrNotFound))
+			if tt.replay != nil {
+				mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(tt.replay, nil)
+			} else {
+				mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(nil, fmt.Errorf("failed to get replay: %w", repository.ErrNotFound))
+			}
+
+			err := service.UpdateReplay(context.Background(), replayID, userID, &title, nil)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/compression"
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+)
+
+var ErrChecksumMismatch = errors.New("replay checksum mismatch")
+
+const (
+	ScrubProblemMissing    = "missing"
+	ScrubProblemMismatch   = "mismatch"
+	ScrubProblemUnreadable = "unreadable"
+
+	scrubPageSize = 100
+)
+
+// ScrubIssue - реплей, файл которого не прошел проверку
+type ScrubIssue struct {
+	ReplayID uuid.UUID `json:"replay_id"`
+	FilePath string    `json:"file_path"`
+	Problem  string    `json:"problem"`
+	Expected string    `json:"expected,omitempty"`
+	Actual   string    `json:"actual,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type ScrubReport struct {
+	Checked    int          `json:"checked"`
+	Backfilled int          `json:"backfilled"`
+	Issues     []ScrubIssue `json:"issues"`
+}
+
+// IntegrityService перечитывает сохраненные файлы и сверяет их SHA-256 с базой
+type IntegrityService struct {
+	replayRepo ReplayScanRepositoryInterface
+	storage    FileStorageInterface
+	keyring    *encryption.Keyring
+	logger     *slog.Logger
+}
+
+// IntegrityOption настраивает необязательные возможности IntegrityService
+type IntegrityOption func(*IntegrityService)
+
+// WithScrubEncryption позволяет проверять зашифрованные файлы
+func WithScrubEncryption(keyring *encryption.Keyring) IntegrityOption {
+	return func(s *IntegrityService) {
+		s.keyring = keyring
+	}
+}
+
+func NewIntegrityService(
+	replayRepo ReplayScanRepositoryInterface,
+	storage FileStorageInterface,
+	logger *slog.Logger,
+	opts ...IntegrityOption,
+) *IntegrityService {
+	s := &IntegrityService{
+		replayRepo: replayRepo,
+		storage:    storage,
+		logger:     logger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Scrub проверяет все реплеи; реплеям без хеша (загруженным до его появления) хеш дописывается
+func (s *IntegrityService) Scrub(ctx context.Context) (*ScrubReport, error) {
+	s.logger.Info("starting integrity scrub")
+
+	report := &ScrubReport{Issues: make([]ScrubIssue, 0)}
+	afterID := uuid.Nil
+	for {
+		replays, err := s.replayRepo.ListAfter(ctx, afterID, scrubPageSize)
+		if err != nil {
+			s.logger.Error("failed to list replays", slog.String("error", err.Error()))
+			return report, wrapError("list replays", err)
+		}
+
+		for i := range replays {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			s.scrubReplay(ctx, &replays[i], report)
+		}
+
+		if len(replays) < scrubPageSize {
+			break
+		}
+		afterID = replays[len(replays)-1].ID
+	}
+
+	s.logger.Info("integrity scrub finished",
+		slog.Int("checked", report.Checked),
+		slog.Int("backfilled", report.Backfilled),
+		slog.Int("issues", len(report.Issues)))
+	return report, nil
+}
+
+func (s *IntegrityService) scrubReplay(ctx context.Context, replay *models.Replay, report *ScrubReport) {
+	report.Checked++
+	issue := ScrubIssue{ReplayID: replay.ID, FilePath: replay.FilePath, Expected: replay.SHA256}
+
+	content, _, err := openStoredFile(ctx, s.storage, s.keyring, replay)
+	if err != nil {
+		issue.Problem = scrubProblem(err)
+		if errors.Is(err, storage.ErrNotFound) {
+			issue.Problem = ScrubProblemMissing
+		}
+		issue.Error = err.Error()
+		s.reportIssue(report, issue)
+		return
+	}
+	defer content.Close()
+
+	sum, err := hashReplayContent(replay, content)
+	if err != nil {
+		issue.Problem = scrubProblem(err)
+		issue.Error = err.Error()
+		s.reportIssue(report, issue)
+		return
+	}
+
+	if replay.SHA256 == "" {
+		if err := s.replayRepo.SetSHA256(ctx, replay.ID, sum); err != nil {
+			s.logger.Warn("failed to backfill replay sha256",
+				slog.String("replay_id", replay.ID.String()),
+				slog.String("error", err.Error()))
+			return
+		}
+		report.Backfilled++
+		return
+	}
+
+	if sum != replay.SHA256 {
+		issue.Problem = ScrubProblemMismatch
+		issue.Actual = sum
+		s.reportIssue(report, issue)
+	}
+}
+
+// scrubProblem отличает поврежденный зашифрованный файл (его выдает проверка AEAD) от нечитаемого
+func scrubProblem(err error) string {
+	if errors.Is(err, encryption.ErrCorrupted) {
+		return ScrubProblemMismatch
+	}
+	return ScrubProblemUnreadable
+}
+
+func (s *IntegrityService) reportIssue(report *ScrubReport, issue ScrubIssue) {
+	s.logger.Warn("replay failed integrity check",
+		slog.String("replay_id", issue.ReplayID.String()),
+		slog.String("file_path", issue.FilePath),
+		slog.String("problem", issue.Problem))
+	report.Issues = append(report.Issues, issue)
+}
+
+// RunScrub периодически вызывает Scrub, пока ctx не отменен
+func (s *IntegrityService) RunScrub(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Scrub(ctx)
+		}
+	}
+}
+
+// hashReplayContent считает SHA-256 исходных байтов реплея, распаковывая сжатый файл
+func hashReplayContent(replay *models.Replay, content io.Reader) (string, error) {
+	reader := io.NopCloser(content)
+	if replay.Compressed {
+		decoded, err := compression.NewReader(replay.Compression, reader)
+		if err != nil {
+			return "", err
+		}
+		defer decoded.Close()
+		reader = decoded
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
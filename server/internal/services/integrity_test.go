@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/compression"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockReplayScanRepository - мок для обхода реплеев
+type MockReplayScanRepository struct {
+	mock.Mock
+}
+
+func (m *MockReplayScanRepository) ListAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Replay), args.Error(1)
+}
+
+func (m *MockReplayScanRepository) SetSHA256(ctx context.Context, replayID uuid.UUID, sum string) error {
+	args := m.Called(ctx, replayID, sum)
+	return args.Error(0)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// TestScrub_ReportsProblems проверяет отчет о поврежденных и отсутствующих файлах
+func TestScrub_ReportsProblems(t *testing.T) {
+	mockRepo := new(MockReplayScanRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewIntegrityService(mockRepo, mockStorage, logger)
+
+	original := []byte("replay frames")
+	compressed := compressForTest(t, compression.Gzip, original)
+	replays := []models.Replay{
+		{ID: uuid.New(), FilePath: "ok.rep", SHA256: sha256Hex(original)},
+		{ID: uuid.New(), FilePath: "ok.dem", SHA256: sha256Hex(original), Compression: compression.Gzip, Compressed: true},
+		{ID: uuid.New(), FilePath: "corrupt.rep", SHA256: sha256Hex(original)},
+		{ID: uuid.New(), FilePath: "missing.rep", SHA256: sha256Hex(original)},
+	}
+
+	mockRepo.On("ListAfter", mock.Anything, uuid.Nil, scrubPageSize).Return(replays, nil)
+	mockStorage.On("Open", mock.Anything, "ok.rep").Return(nopSeekCloser{bytes.NewReader(original)}, storage.ObjectInfo{}, nil)
+	mockStorage.On("Open", mock.Anything, "ok.dem").Return(nopSeekCloser{bytes.NewReader(compressed)}, storage.ObjectInfo{}, nil)
+	mockStorage.On("Open", mock.Anything, "corrupt.rep").Return(nopSeekCloser{bytes.NewReader([]byte("replay frameZ"))}, storage.ObjectInfo{}, nil)
+	mockStorage.On("Open", mock.Anything, "missing.rep").Return(nil, storage.ObjectInfo{}, fmt.Errorf("open: %w", storage.ErrNotFound))
+
+	report, err := service.Scrub(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Checked)
+	require.Len(t, report.Issues, 2)
+	assert.Equal(t, ScrubProblemMismatch, report.Issues[0].Problem)
+	assert.Equal(t, replays[2].ID, report.Issues[0].ReplayID)
+	assert.Equal(t, ScrubProblemMissing, report.Issues[1].Problem)
+	assert.Equal(t, replays[3].ID, report.Issues[1].ReplayID)
+}
+
+// TestScrub_BackfillsMissingHash проверяет, что старым реплеям без хеша он дописывается
+func TestScrub_BackfillsMissingHash(t *testing.T) {
+	mockRepo := new(MockReplayScanRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewIntegrityService(mockRepo, mockStorage, logger)
+
+	content := []byte("legacy replay")
+	replay := models.Replay{ID: uuid.New(), FilePath: "legacy.rep"}
+
+	mockRepo.On("ListAfter", mock.Anything, uuid.Nil, scrubPageSize).Return([]models.Replay{replay}, nil)
+	mockStorage.On("Open", mock.Anything, "legacy.rep").Return(nopSeekCloser{bytes.NewReader(content)}, storage.ObjectInfo{}, nil)
+	mockRepo.On("SetSHA256", mock.Anything, replay.ID, sha256Hex(content)).Return(nil)
+
+	report, err := service.Scrub(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Backfilled)
+	assert.Empty(t, report.Issues)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestScrub_Paginates проверяет обход таблицы страницами
+func TestScrub_Paginates(t *testing.T) {
+	mockRepo := new(MockReplayScanRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewIntegrityService(mockRepo, mockStorage, logger)
+
+	// Пустой файл: один и тот же reader мока можно читать много раз
+	content := []byte{}
+	page := make([]models.Replay, scrubPageSize)
+	for i := range page {
+		page[i] = models.Replay{ID: uuid.New(), FilePath: "same.rep", SHA256: sha256Hex(content)}
+	}
+	last := page[len(page)-1].ID
+
+	mockRepo.On("ListAfter", mock.Anything, uuid.Nil, scrubPageSize).Return(page, nil)
+	mockRepo.On("ListAfter", mock.Anything, last, scrubPageSize).Return([]models.Replay{}, nil)
+	mockStorage.On("Open", mock.Anything, "same.rep").Return(nopSeekCloser{bytes.NewReader(content)}, storage.ObjectInfo{}, nil)
+
+	report, err := service.Scrub(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, scrubPageSize, report.Checked)
+	mockRepo.AssertExpectations(t)
+}
@@ -2,16 +2,20 @@ package services
 
 import (
 	"context"
+	"io"
+	"net/url"
+	"time"
 
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
 	"github.com/google/uuid"
-	"mime/multipart"
 )
 
 // GameRepositoryInterface определяет методы для работы с играми в БД
 // Зачем: позволяет использовать моки в тестах вместо реального репозитория
 type GameRepositoryInterface interface {
-	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Game, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, filter models.GameFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Game, int, error)
 	Create(ctx context.Context, userID uuid.UUID, name string) (*models.Game, error)
 	Update(ctx context.Context, gameID, userID uuid.UUID, name string) error
 	Delete(ctx context.Context, gameID, userID uuid.UUID) error
@@ -19,18 +23,204 @@ type GameRepositoryInterface interface {
 
 // ReplayRepositoryInterface определяет методы для работы с реплеями в БД
 type ReplayRepositoryInterface interface {
-	GetByGameID(ctx context.Context, gameID, userID uuid.UUID, limit int) ([]models.Replay, error)
+	GetByGameID(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, after *models.Cursor, limit int) ([]models.Replay, int, error)
 	GetByID(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error)
-	Create(ctx context.Context, replay *models.Replay) error
+	CreateWithBlob(ctx context.Context, replay *models.Replay, blob *models.Blob, limit *models.Quota, place func(filePath string) error) (bool, error)
 	Update(ctx context.Context, replayID, userID uuid.UUID, title, comment *string) error
 	Delete(ctx context.Context, replayID, userID uuid.UUID) (string, error)
 	GetFilePathsByGameID(ctx context.Context, gameID, userID uuid.UUID) ([]string, error)
+	FileReleaserInterface
 }
 
-// FileStorageInterface определяет методы для работы с файловой системой
+// FileReleaserInterface освобождает файл, на который больше не ссылается ни один реплей
+// Зачем: одинаковые реплеи делят один файл, и удаление реплея удаляет только ссылку на него
+type FileReleaserInterface interface {
+	ReleaseFile(ctx context.Context, filePath string, remove func(filePath string) error) (bool, error)
+}
+
+// FileStorageInterface определяет методы для работы с хранилищем файлов
+// Зачем: сервисы работают с потоками и ключами и не знают, где лежат файлы (диск, S3)
 type FileStorageInterface interface {
-	SaveReplayFile(file *multipart.FileHeader, userID, gameID, replayID uuid.UUID) (string, error)
-	DeleteFile(filePath string) error
-	DeleteFiles(filePaths []string) []error
-	GetFilePath(relativePath string) string
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, storage.ObjectInfo, error)
+	Stat(ctx context.Context, key string) (storage.ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	Move(ctx context.Context, src, dst string) error
+	Walk(ctx context.Context, prefix string, fn func(storage.ObjectInfo) error) error
+}
+
+// UploadRepositoryInterface определяет методы для работы с сессиями resumable-загрузок
+type UploadRepositoryInterface interface {
+	Create(ctx context.Context, session *models.UploadSession) error
+	GetByID(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.UploadSession, error)
+	AppendChunk(ctx context.Context, uploadID, userID uuid.UUID, expectedOffset, written int64, chunkKey string, expiresAt time.Time) error
+	Claim(ctx context.Context, lease models.UploadLease, gameID, userID uuid.UUID, leaseTTL time.Duration, expiresAt time.Time) (*models.UploadSession, error)
+	RenewLease(ctx context.Context, lease models.UploadLease, expiresAt time.Time) error
+	Release(ctx context.Context, lease models.UploadLease) error
+	Finish(ctx context.Context, uploadID uuid.UUID, expiresAt time.Time) ([]string, error)
+	Delete(ctx context.Context, uploadID, userID uuid.UUID) ([]string, error)
+	DeleteExpired(ctx context.Context, lease time.Duration) ([]string, error)
+}
+
+// ReplayCreatorInterface создает реплей из готового потока
+// Зачем: финализация загрузки проходит через тот же конвейер, что и обычная загрузка файла
+type ReplayCreatorInterface interface {
+	CreateReplayFromStream(ctx context.Context, src io.Reader, filename string, size int64, gameID, userID uuid.UUID, title, comment string, upload *models.UploadLease) (*models.Replay, error)
+	GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error)
+}
+
+// ReplayScanRepositoryInterface определяет методы для обхода всех реплеев фоновыми проверками
+type ReplayScanRepositoryInterface interface {
+	ListAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error)
+	SetSHA256(ctx context.Context, replayID uuid.UUID, sum string) error
+}
+
+// ReconcileRepositoryInterface определяет методы БД, нужные сверке хранилища с таблицей replays
+type ReconcileRepositoryInterface interface {
+	ListAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error)
+	Quarantine(ctx context.Context, replayID uuid.UUID, reason string) error
+	FileReleaserInterface
+}
+
+// UploadKeysRepositoryInterface отдает ключи кусков живых сессий загрузки
+// Зачем: куски незавершенных загрузок не должны считаться сиротами
+type UploadKeysRepositoryInterface interface {
+	ListChunkKeys(ctx context.Context) ([]string, error)
+}
+
+// BlobMigrationRepositoryInterface определяет методы БД для переноса файлов в content-addressed раскладку
+type BlobMigrationRepositoryInterface interface {
+	ListLegacyAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error)
+	AdoptBlob(ctx context.Context, replay *models.Replay, blob *models.Blob, place func(filePath string) error) (bool, error)
+}
+
+// MediaBackfillRepositoryInterface определяет методы БД для заполнения параметров видео
+type MediaBackfillRepositoryInterface interface {
+	ListMissingMediaAfter(ctx context.Context, afterID uuid.UUID, contentTypes []string, limit int) ([]models.Replay, error)
+	SetMediaInfo(ctx context.Context, replayID uuid.UUID, media models.MediaInfo) error
+}
+
+// QuotaRepositoryInterface определяет методы БД для подсчета использования и переопределений квот
+type QuotaRepositoryInterface interface {
+	GetUsage(ctx context.Context, userID uuid.UUID) (int64, int64, error)
+	GetOverride(ctx context.Context, userID uuid.UUID) (*models.QuotaOverride, error)
+	SetOverride(ctx context.Context, userID uuid.UUID, override *models.QuotaOverride) error
+}
+
+// QuotaCheckerInterface проверяет квоту перед записью файла и отдает ее для проверки при вставке реплея
+// Зачем: обычная и resumable-загрузка отказывают до того, как байты попадут в хранилище, а окончательно
+// квоту проверяет транзакция вставки, чтобы параллельные загрузки не превысили ее вместе
+type QuotaCheckerInterface interface {
+	CheckUpload(ctx context.Context, userID uuid.UUID, size int64) error
+	GetLimits(ctx context.Context, userID uuid.UUID) (models.Quota, error)
+}
+
+// KeyRotationRepositoryInterface определяет методы БД для переобертки ключей данных новым мастер-ключом
+type KeyRotationRepositoryInterface interface {
+	ListStaleKeys(ctx context.Context, activeKeyID, afterSHA string, limit int) ([]models.Blob, error)
+	RewrapKey(ctx context.Context, sha256, oldKeyID, newKeyID string, wrappedKey []byte) error
+}
+
+// UploadPolicyRepositoryInterface определяет методы БД для политик загрузки игр
+type UploadPolicyRepositoryInterface interface {
+	GetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID) ([]string, error)
+	SetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID, allowedTypes []string) error
+}
+
+// FileTypeCheckerInterface проверяет формат файла по политике игры
+// Зачем: файл неразрешенного формата отклоняется до записи в хранилище
+type FileTypeCheckerInterface interface {
+	CheckFileType(ctx context.Context, gameID, userID uuid.UUID, detected filetype.Type) error
+}
+
+// MarkerRepositoryInterface определяет методы БД для отметок на временной шкале реплеев
+type MarkerRepositoryInterface interface {
+	MarkerListerInterface
+	Create(ctx context.Context, marker *models.Marker) error
+	Update(ctx context.Context, marker *models.Marker, userID uuid.UUID) error
+	Delete(ctx context.Context, markerID, replayID, userID uuid.UUID) error
+}
+
+// MarkerListerInterface отдает отметки реплея
+// Зачем: детали реплея возвращаются вместе с отметками одним запросом
+type MarkerListerInterface interface {
+	ListByReplayID(ctx context.Context, replayID, userID uuid.UUID) ([]models.Marker, error)
+}
+
+// CommentRepositoryInterface определяет методы БД для обсуждений реплеев
+type CommentRepositoryInterface interface {
+	List(ctx context.Context, replayID, userID uuid.UUID, parentID *uuid.UUID, after *models.CommentCursor, limit int) ([]models.Comment, error)
+	GetByID(ctx context.Context, commentID, replayID, userID uuid.UUID) (*models.Comment, error)
+	Create(ctx context.Context, comment *models.Comment) error
+	UpdateBody(ctx context.Context, commentID, replayID, authorID uuid.UUID, body string) (time.Time, error)
+	SoftDelete(ctx context.Context, commentID, replayID, userID uuid.UUID) error
+	ListRevisions(ctx context.Context, commentID, replayID, userID uuid.UUID) ([]models.CommentRevision, error)
+}
+
+// TagRepositoryInterface определяет методы БД для тегов пользователя
+type TagRepositoryInterface interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Tag, error)
+	Create(ctx context.Context, userID uuid.UUID, name string, color *string) (*models.Tag, error)
+	Update(ctx context.Context, tagID, userID uuid.UUID, name string, color *string) (*models.Tag, error)
+	Delete(ctx context.Context, tagID, userID uuid.UUID) error
+	Assign(ctx context.Context, userID uuid.UUID, assignment models.TagAssignment) error
+}
+
+// SearchRepositoryInterface определяет методы БД для полнотекстового поиска
+type SearchRepositoryInterface interface {
+	Search(ctx context.Context, userID uuid.UUID, q string, after *models.SearchCursor, limit int) ([]models.SearchResult, int, error)
+}
+
+// ShareRepositoryInterface определяет методы БД для публичных ссылок
+type ShareRepositoryInterface interface {
+	Create(ctx context.Context, link *models.ShareLink) error
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.ShareLink, error)
+	RecordView(ctx context.Context, shareID uuid.UUID) (int, error)
+	Revoke(ctx context.Context, shareID, userID uuid.UUID) error
+}
+
+// SharedReplayReaderInterface читает реплеи от имени владельца ссылки
+// Зачем: по ссылке файл отдается тем же путем, что и владельцу, - с расшифровкой, распаковкой и сверкой хеша
+type SharedReplayReaderInterface interface {
+	GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error)
+	GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error)
+	OpenReplayFile(ctx context.Context, replayID, userID uuid.UUID, acceptEncodings []string) (*ReplayFile, error)
+}
+
+// URLSignerInterface подписывает ссылку на файл реплея от имени пользователя
+type URLSignerInterface interface {
+	Sign(replayID, userID uuid.UUID) (url.Values, time.Time)
+}
+
+// ShareViewSignerInterface подписывает ссылку на файл по публичной ссылке на время засчитанного просмотра
+type ShareViewSignerInterface interface {
+	SignShareView(shareID, replayID uuid.UUID) (url.Values, time.Time)
+	VerifyShareView(shareID, replayID uuid.UUID, query url.Values) error
+}
+
+// GrantRepositoryInterface определяет методы БД для доступа пользователей к чужим играм и реплеям
+type GrantRepositoryInterface interface {
+	Upsert(ctx context.Context, grant *models.Grant) error
+	GetByOwner(ctx context.Context, ownerID uuid.UUID) ([]models.Grant, error)
+	GetByGrantee(ctx context.Context, granteeID uuid.UUID, status models.GrantStatus) ([]models.Grant, error)
+	Respond(ctx context.Context, grantID, granteeID uuid.UUID, status models.GrantStatus) (*models.Grant, error)
+	Delete(ctx context.Context, grantID, userID uuid.UUID) error
+}
+
+// UserLookupInterface находит пользователя по логину; nil - пользователя нет
+// Зачем: доступ выдается по логину, который знает владелец, а не по id
+type UserLookupInterface interface {
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+}
+
+// SharedGamesRepositoryInterface определяет методы БД для чужих игр, доступных пользователю
+type SharedGamesRepositoryInterface interface {
+	GetShared(ctx context.Context, userID uuid.UUID) ([]models.Game, error)
+}
+
+// SharedReplaysRepositoryInterface определяет методы БД для чужих реплеев, выданных пользователю
+type SharedReplaysRepositoryInterface interface {
+	GetShared(ctx context.Context, userID uuid.UUID) ([]models.Replay, error)
 }
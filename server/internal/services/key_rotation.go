@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+)
+
+// ErrEncryptionDisabled - файл зашифрован, но мастер-ключи не настроены
+var ErrEncryptionDisabled = errors.New("replay file is encrypted but no encryption key is configured")
+
+const keyRotationPageSize = 100
+
+// KeyRotationReport - итог переобертки ключей данных активным мастер-ключом
+type KeyRotationReport struct {
+	ActiveKeyID string   `json:"active_key_id"`
+	Scanned     int      `json:"scanned"`
+	Rewrapped   int      `json:"rewrapped"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// KeyRotationService переоборачивает ключи данных файлов активным мастер-ключом
+// Файлы не перечитываются и не переписываются: меняются только обернутые ключи в blobs
+type KeyRotationService struct {
+	blobRepo KeyRotationRepositoryInterface
+	keyring  *encryption.Keyring
+	logger   *slog.Logger
+}
+
+func NewKeyRotationService(blobRepo KeyRotationRepositoryInterface, keyring *encryption.Keyring, logger *slog.Logger) *KeyRotationService {
+	return &KeyRotationService{
+		blobRepo: blobRepo,
+		keyring:  keyring,
+		logger:   logger,
+	}
+}
+
+// RotateKeys переоборачивает все ключи, обернутые не активным мастер-ключом
+// После успешного запуска старые мастер-ключи можно убрать из конфигурации
+func (s *KeyRotationService) RotateKeys(ctx context.Context) (*KeyRotationReport, error) {
+	if s.keyring == nil {
+		return nil, ErrEncryptionDisabled
+	}
+
+	activeKeyID := s.keyring.ActiveKeyID()
+	s.logger.Info("starting key rotation", slog.String("active_key_id", activeKeyID))
+
+	report := &KeyRotationReport{ActiveKeyID: activeKeyID}
+	afterSHA := ""
+	for {
+		blobs, err := s.blobRepo.ListStaleKeys(ctx, activeKeyID, afterSHA, keyRotationPageSize)
+		if err != nil {
+			s.logger.Error("failed to list stale keys", slog.String("error", err.Error()))
+			return report, wrapError("list stale keys", err)
+		}
+
+		for _, blob := range blobs {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			report.Scanned++
+
+			keyID, wrappedKey, err := s.keyring.Rewrap(blob.KeyID, blob.WrappedKey)
+			if err == nil {
+				err = s.blobRepo.RewrapKey(ctx, blob.SHA256, blob.KeyID, keyID, wrappedKey)
+			}
+			if errors.Is(err, repository.ErrNotFound) {
+				// blob удален или уже переобернут параллельно
+				continue
+			}
+			if err != nil {
+				s.logger.Warn("failed to rewrap data key",
+					slog.String("sha256", blob.SHA256),
+					slog.String("key_id", blob.KeyID),
+					slog.String("error", err.Error()))
+				report.Errors = append(report.Errors, blob.SHA256+": "+err.Error())
+				continue
+			}
+			report.Rewrapped++
+		}
+
+		if len(blobs) < keyRotationPageSize {
+			break
+		}
+		afterSHA = blobs[len(blobs)-1].SHA256
+	}
+
+	s.logger.Info("key rotation finished",
+		slog.Int("rewrapped", report.Rewrapped),
+		slog.Int("errors", len(report.Errors)))
+	return report, nil
+}
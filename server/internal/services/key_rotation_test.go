@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockKeyRotationRepository - мок для методов ротации ключей ReplayRepository
+type MockKeyRotationRepository struct {
+	mock.Mock
+}
+
+func (m *MockKeyRotationRepository) ListStaleKeys(ctx context.Context, activeKeyID, afterSHA string, limit int) ([]models.Blob, error) {
+	args := m.Called(ctx, activeKeyID, afterSHA, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Blob), args.Error(1)
+}
+
+func (m *MockKeyRotationRepository) RewrapKey(ctx context.Context, sha256, oldKeyID, newKeyID string, wrappedKey []byte) error {
+	args := m.Called(ctx, sha256, oldKeyID, newKeyID, wrappedKey)
+	return args.Error(0)
+}
+
+// TestRotateKeys проверяет, что ключи данных переоборачиваются активным мастер-ключом без чтения файлов
+func TestRotateKeys(t *testing.T) {
+	mockRepo := new(MockKeyRotationRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	oldMaster, err := encryption.NewDataKey()
+	require.NoError(t, err)
+	newMaster, err := encryption.NewDataKey()
+	require.NoError(t, err)
+	oldRing, err := encryption.NewKeyring(oldMaster)
+	require.NoError(t, err)
+	keyring, err := encryption.NewKeyring(oldMaster, newMaster)
+	require.NoError(t, err)
+
+	dataKey, err := encryption.NewDataKey()
+	require.NoError(t, err)
+	oldID, wrapped, err := oldRing.Wrap(dataKey)
+	require.NoError(t, err)
+
+	blobs := []models.Blob{
+		{SHA256: "aaa", KeyID: oldID, WrappedKey: wrapped},
+		{SHA256: "bbb", KeyID: oldID, WrappedKey: wrapped},
+		{SHA256: "ccc", KeyID: "unknown", WrappedKey: wrapped},
+	}
+	mockRepo.On("ListStaleKeys", mock.Anything, keyring.ActiveKeyID(), "", keyRotationPageSize).Return(blobs, nil)
+
+	var rewrapped []byte
+	mockRepo.On("RewrapKey", mock.Anything, "aaa", oldID, keyring.ActiveKeyID(), mock.Anything).
+		Run(func(args mock.Arguments) {
+			rewrapped = args.Get(4).([]byte)
+		}).Return(nil)
+	mockRepo.On("RewrapKey", mock.Anything, "bbb", oldID, keyring.ActiveKeyID(), mock.Anything).
+		Return(fmt.Errorf("rewrap blob key: %w", repository.ErrNotFound))
+
+	service := NewKeyRotationService(mockRepo, keyring, logger)
+	report, err := service.RotateKeys(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Scanned)
+	assert.Equal(t, 1, report.Rewrapped, "blob, переобернутый параллельно, пропускается")
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], "ccc")
+
+	unwrapped, err := keyring.Unwrap(keyring.ActiveKeyID(), rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestRotateKeys_Disabled проверяет, что без мастер-ключей ротация не запускается
+func TestRotateKeys_Disabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewKeyRotationService(new(MockKeyRotationRepository), nil, logger)
+
+	_, err := service.RotateKeys(context.Background())
+
+	assert.ErrorIs(t, err, ErrEncryptionDisabled)
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMarkerNotFound = errors.New("marker not found")
+	ErrInvalidMarker  = errors.New("invalid marker")
+)
+
+const (
+	maxMarkerLabel = 200
+	// lastChapterLength - длина последней главы, если длительность реплея неизвестна
+	lastChapterLength = 10 * time.Second
+)
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+
+// MarkerService управляет отметками на временной шкале реплеев и выгружает их главами WebVTT
+// Отметки видит тот, кому виден реплей, а ставит и меняет владелец и получивший роль editor
+type MarkerService struct {
+	markerRepo MarkerRepositoryInterface
+	replayRepo ReplayRepositoryInterface
+	logger     *slog.Logger
+}
+
+func NewMarkerService(markerRepo MarkerRepositoryInterface, replayRepo ReplayRepositoryInterface, logger *slog.Logger) *MarkerService {
+	return &MarkerService{
+		markerRepo: markerRepo,
+		replayRepo: replayRepo,
+		logger:     logger,
+	}
+}
+
+func (s *MarkerService) ListMarkers(ctx context.Context, replayID, userID uuid.UUID) ([]models.Marker, error) {
+	if _, err := s.getReplay(ctx, replayID, userID); err != nil {
+		return nil, err
+	}
+
+	markers, err := s.markerRepo.ListByReplayID(ctx, replayID, userID)
+	if err != nil {
+		s.logger.Error("failed to get markers", slog.String("error", err.Error()))
+		return nil, wrapError("get markers", err)
+	}
+	return markers, nil
+}
+
+// CreateMarker добавляет отметку; автор - userID
+func (s *MarkerService) CreateMarker(ctx context.Context, replayID, userID uuid.UUID, marker models.Marker) (*models.Marker, error) {
+	s.logger.Info("creating marker",
+		slog.String("replay_id", replayID.String()),
+		slog.String("user_id", userID.String()))
+
+	if err := normalizeMarker(&marker); err != nil {
+		return nil, err
+	}
+	marker.ReplayID, marker.AuthorID = replayID, userID
+
+	if err := s.markerRepo.Create(ctx, &marker); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleEditor, ErrReplayNotFound)
+		}
+		s.logger.Error("failed to create marker", slog.String("error", err.Error()))
+		return nil, wrapError("create marker", err)
+	}
+
+	s.logger.Info("marker created", slog.String("marker_id", marker.ID.String()))
+	return &marker, nil
+}
+
+// UpdateMarker заменяет момент, подпись и цвет отметки
+func (s *MarkerService) UpdateMarker(ctx context.Context, markerID, replayID, userID uuid.UUID, marker models.Marker) (*models.Marker, error) {
+	s.logger.Info("updating marker",
+		slog.String("marker_id", markerID.String()),
+		slog.String("replay_id", replayID.String()))
+
+	if err := normalizeMarker(&marker); err != nil {
+		return nil, err
+	}
+	marker.ID, marker.ReplayID = markerID, replayID
+
+	if err := s.markerRepo.Update(ctx, &marker, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleEditor, ErrMarkerNotFound)
+		}
+		s.logger.Error("failed to update marker", slog.String("error", err.Error()))
+		return nil, wrapError("update marker", err)
+	}
+	return &marker, nil
+}
+
+func (s *MarkerService) DeleteMarker(ctx context.Context, markerID, replayID, userID uuid.UUID) error {
+	s.logger.Info("deleting marker",
+		slog.String("marker_id", markerID.String()),
+		slog.String("replay_id", replayID.String()))
+
+	if err := s.markerRepo.Delete(ctx, markerID, replayID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleEditor, ErrMarkerNotFound)
+		}
+		s.logger.Error("failed to delete marker", slog.String("error", err.Error()))
+		return wrapError("delete marker", err)
+	}
+	return nil
+}
+
+// ExportChapters выгружает отметки реплея дорожкой глав WebVTT для <track kind="chapters">
+// Отметки только с номером кадра переводятся во время по частоте кадров видео или по длительности
+// и числу кадров (тиков) из метаданных; если перевести нельзя, отметка в главы не попадает
+func (s *MarkerService) ExportChapters(ctx context.Context, replayID, userID uuid.UUID) ([]byte, error) {
+	replay, err := s.getReplay(ctx, replayID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	markers, err := s.markerRepo.ListByReplayID(ctx, replayID, userID)
+	if err != nil {
+		s.logger.Error("failed to get markers", slog.String("error", err.Error()))
+		return nil, wrapError("get markers", err)
+	}
+
+	return webVTTChapters(markers, replayTimeline(replay)), nil
+}
+
+func (s *MarkerService) getReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
+	replay, err := s.replayRepo.GetByID(ctx, replayID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrReplayNotFound
+		}
+		return nil, wrapError("get replay", err)
+	}
+	return replay, nil
+}
+
+// normalizeMarker проверяет отметку и приводит подпись и цвет к хранимому виду
+func normalizeMarker(m *models.Marker) error {
+	if m.TimeMS == nil && m.Frame == nil {
+		return fmt.Errorf("%w: time_ms or frame is required", ErrInvalidMarker)
+	}
+	if (m.TimeMS != nil && *m.TimeMS < 0) || (m.Frame != nil && *m.Frame < 0) {
+		return fmt.Errorf("%w: time_ms and frame must not be negative", ErrInvalidMarker)
+	}
+
+	m.Label = strings.TrimSpace(m.Label)
+	if m.Label == "" {
+		return fmt.Errorf("%w: label is required", ErrInvalidMarker)
+	}
+	if utf8.RuneCountInString(m.Label) > maxMarkerLabel {
+		return fmt.Errorf("%w: label is longer than %d characters", ErrInvalidMarker, maxMarkerLabel)
+	}
+
+	color, err := normalizeColor(m.Color)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMarker, err)
+	}
+	m.Color = color
+	return nil
+}
+
+// normalizeColor проверяет цвет #rgb или #rrggbb и приводит его к нижнему регистру; пустой цвет - nil
+func normalizeColor(color *string) (*string, error) {
+	if color == nil {
+		return nil, nil
+	}
+	normalized := strings.ToLower(strings.TrimSpace(*color))
+	if normalized == "" {
+		return nil, nil
+	}
+	if !colorPattern.MatchString(normalized) {
+		return nil, errors.New("color must be #rgb or #rrggbb")
+	}
+	return &normalized, nil
+}
+
+// timeline - длительность реплея и длительность одного кадра (тика); 0 - неизвестно
+type timeline struct {
+	duration time.Duration
+	frame    time.Duration
+}
+
+func replayTimeline(replay *models.Replay) timeline {
+	var t timeline
+	if replay.DurationMS != nil {
+		t.duration = time.Duration(*replay.DurationMS) * time.Millisecond
+	}
+	if replay.FrameRate != nil && *replay.FrameRate > 0 {
+		t.frame = time.Duration(float64(time.Second) / *replay.FrameRate)
+	}
+
+	if len(replay.Metadata) == 0 {
+		return t
+	}
+	var meta struct {
+		DurationMS int64          `json:"duration_ms"`
+		Extra      map[string]any `json:"extra"`
+	}
+	if err := json.Unmarshal(replay.Metadata, &meta); err != nil || meta.DurationMS <= 0 {
+		return t
+	}
+	gameDuration := time.Duration(meta.DurationMS) * time.Millisecond
+	if t.duration == 0 {
+		t.duration = gameDuration
+	}
+	if t.frame == 0 {
+		// Brood War хранит число кадров, демо Source - число тиков
+		for _, field := range []string{"frames", "ticks"} {
+			if frames, ok := meta.Extra[field].(float64); ok && frames > 0 {
+				t.frame = time.Duration(float64(gameDuration) / frames)
+				break
+			}
+		}
+	}
+	return t
+}
+
+// offset - момент отметки от начала реплея
+func (t timeline) offset(m models.Marker) (time.Duration, bool) {
+	if m.TimeMS != nil {
+		return time.Duration(*m.TimeMS) * time.Millisecond, true
+	}
+	if t.frame > 0 {
+		return time.Duration(*m.Frame) * t.frame, true
+	}
+	return 0, false
+}
+
+// webVTTChapters собирает главы: глава длится до следующей отметки, последняя - до конца реплея
+// Отметки в один момент объединяются в одну главу
+func webVTTChapters(markers []models.Marker, t timeline) []byte {
+	type chapter struct {
+		id     uuid.UUID
+		start  time.Duration
+		labels []string
+	}
+	var chapters []chapter
+	for _, m := range markers {
+		start, ok := t.offset(m)
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, chapter{id: m.ID, start: start, labels: []string{m.Label}})
+	}
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].start < chapters[j].start })
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := 0; i < len(chapters); i++ {
+		c := chapters[i]
+		for i+1 < len(chapters) && chapters[i+1].start == c.start {
+			i++
+			c.labels = append(c.labels, chapters[i].labels...)
+		}
+
+		end := t.duration
+		if i+1 < len(chapters) {
+			end = chapters[i+1].start
+		}
+		if end <= c.start {
+			end = c.start + lastChapterLength
+		}
+
+		fmt.Fprintf(&b, "\n%s\n%s --> %s\n%s\n", c.id, webVTTTimestamp(c.start), webVTTTimestamp(end),
+			webVTTText(strings.Join(c.labels, " / ")))
+	}
+	return []byte(b.String())
+}
+
+func webVTTTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// webVTTEscaper экранирует текст реплики: & и < начинают разметку, > - часть "-->"; перевод строки завершает реплику
+var webVTTEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r\n", " ", "\n", " ", "\r", " ")
+
+func webVTTText(s string) string {
+	return webVTTEscaper.Replace(s)
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMarkerRepository - мок для отметок реплеев
+type MockMarkerRepository struct {
+	mock.Mock
+}
+
+func (m *MockMarkerRepository) ListByReplayID(ctx context.Context, replayID, userID uuid.UUID) ([]models.Marker, error) {
+	args := m.Called(ctx, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Marker), args.Error(1)
+}
+
+func (m *MockMarkerRepository) Create(ctx context.Context, marker *models.Marker) error {
+	args := m.Called(ctx, marker)
+	return args.Error(0)
+}
+
+func (m *MockMarkerRepository) Update(ctx context.Context, marker *models.Marker, userID uuid.UUID) error {
+	args := m.Called(ctx, marker, userID)
+	return args.Error(0)
+}
+
+func (m *MockMarkerRepository) Delete(ctx context.Context, markerID, replayID, userID uuid.UUID) error {
+	args := m.Called(ctx, markerID, replayID, userID)
+	return args.Error(0)
+}
+
+// TestCreateMarker_Validation проверяет обязательные поля отметки и приведение цвета
+func TestCreateMarker_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		marker  models.Marker
+		wantErr bool
+	}{
+		{name: "time", marker: models.Marker{TimeMS: int64Ptr(1500), Label: "push B"}},
+		{name: "frame", marker: models.Marker{Frame: int64Ptr(4200), Label: "expand", Color: stringPtr("#FFaa00")}},
+		{name: "no moment", marker: models.Marker{Label: "push B"}, wantErr: true},
+		{name: "negative time", marker: models.Marker{TimeMS: int64Ptr(-1), Label: "push B"}, wantErr: true},
+		{name: "blank label", marker: models.Marker{TimeMS: int64Ptr(0), Label: "  "}, wantErr: true},
+		{name: "bad color", marker: models.Marker{TimeMS: int64Ptr(0), Label: "x", Color: stringPtr("red")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMarkerRepo := new(MockMarkerRepository)
+			service := NewMarkerService(mockMarkerRepo, new(MockReplayRepository), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+			replayID, userID := uuid.New(), uuid.New()
+			mockMarkerRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Marker")).Return(nil)
+
+			marker, err := service.CreateMarker(context.Background(), replayID, userID, tt.marker)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMarker)
+				mockMarkerRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, replayID, marker.ReplayID)
+			assert.Equal(t, userID, marker.AuthorID)
+			if marker.Color != nil {
+				assert.Equal(t, "#ffaa00", *marker.Color)
+			}
+		})
+	}
+}
+
+// TestCreateMarker_ReplayNotFound проверяет перевод ErrNotFound репозитория в ErrReplayNotFound
+func TestCreateMarker_ReplayNotFound(t *testing.T) {
+	mockMarkerRepo := new(MockMarkerRepository)
+	mockReplayRepo := new(MockReplayRepository)
+	service := NewMarkerService(mockMarkerRepo, mockReplayRepo, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	mockMarkerRepo.On("Create", mock.Anything, mock.Anything).Return(fmt.Errorf("failed to create marker: %w", repository.ErrNotFound))
+	mockReplayRepo.On("GetByID", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("failed to get replay: %w", repository.ErrNotFound))
+
+	_, err := service.CreateMarker(context.Background(), uuid.New(), uuid.New(), models.Marker{TimeMS: int64Ptr(0), Label: "start"})
+	assert.ErrorIs(t, err, ErrReplayNotFound)
+}
+
+// TestExportChapters проверяет главы WebVTT: кадры переводятся во время по метаданным, отметки в один момент объединяются
+func TestExportChapters(t *testing.T) {
+	mockMarkerRepo := new(MockMarkerRepository)
+	mockReplayRepo := new(MockReplayRepository)
+	service := NewMarkerService(mockMarkerRepo, mockReplayRepo, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	replayID, userID := uuid.New(), uuid.New()
+	replay := &models.Replay{ID: replayID, Metadata: []byte(`{"duration_ms": 84000, "extra": {"frames": 2000}}`)}
+	markers := []models.Marker{
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), TimeMS: int64Ptr(0), Label: "start"},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000002"), Frame: int64Ptr(1000), Label: "<b>push</b> & hold"},
+		{ID: uuid.MustParse("00000000-0000-0000-0000-000000000003"), TimeMS: int64Ptr(42000), Label: "gg\nwp"},
+	}
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockMarkerRepo.On("ListByReplayID", mock.Anything, replayID, userID).Return(markers, nil)
+
+	vtt, err := service.ExportChapters(context.Background(), replayID, userID)
+	require.NoError(t, err)
+
+	want := "WEBVTT\n" +
+		"\n00000000-0000-0000-0000-000000000001\n00:00:00.000 --> 00:00:42.000\nstart\n" +
+		"\n00000000-0000-0000-0000-000000000002\n00:00:42.000 --> 00:01:24.000\n&lt;b&gt;push&lt;/b&gt; &amp; hold / gg wp\n"
+	assert.Equal(t, want, string(vtt))
+}
+
+// TestExportChapters_UnknownFrameRate проверяет, что отметка по кадру без частоты кадров пропускается
+func TestExportChapters_UnknownFrameRate(t *testing.T) {
+	markers := []models.Marker{
+		{ID: uuid.New(), Frame: int64Ptr(10), Label: "skipped"},
+		{ID: uuid.New(), TimeMS: int64Ptr(3_723_004), Label: "late"},
+	}
+
+	vtt := string(webVTTChapters(markers, replayTimeline(&models.Replay{})))
+	assert.NotContains(t, vtt, "skipped")
+	assert.Contains(t, vtt, "01:02:03.004 --> 01:02:13.004\nlate\n")
+}
+
+// TestGetReplay_WithMarkers проверяет, что детали реплея приходят вместе с отметками
+func TestGetReplay_WithMarkers(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockMarkerRepo := new(MockMarkerRepository)
+	service := NewReplayService(mockReplayRepo, new(MockFileStorage), slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		WithMarkers(mockMarkerRepo))
+
+	replayID, userID := uuid.New(), uuid.New()
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(&models.Replay{ID: replayID}, nil)
+	mockMarkerRepo.On("ListByReplayID", mock.Anything, replayID, userID).
+		Return([]models.Marker{{ID: uuid.New(), TimeMS: int64Ptr(5000), Label: "first blood"}}, nil)
+
+	replay, err := service.GetReplay(context.Background(), replayID, userID)
+	require.NoError(t, err)
+	require.Len(t, replay.Markers, 1)
+	assert.Equal(t, "first blood", replay.Markers[0].Label)
+}
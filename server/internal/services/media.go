@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/compression"
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/media"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+const mediaBackfillPageSize = 100
+
+// MediaBackfillReport - итог заполнения параметров видео у ранее загруженных реплеев
+type MediaBackfillReport struct {
+	Scanned int      `json:"scanned"`
+	Updated int      `json:"updated"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// MediaBackfillService извлекает параметры видео у реплеев, загруженных до появления извлечения
+type MediaBackfillService struct {
+	replayRepo MediaBackfillRepositoryInterface
+	storage    FileStorageInterface
+	keyring    *encryption.Keyring
+	logger     *slog.Logger
+}
+
+func NewMediaBackfillService(
+	replayRepo MediaBackfillRepositoryInterface,
+	storage FileStorageInterface,
+	keyring *encryption.Keyring,
+	logger *slog.Logger,
+) *MediaBackfillService {
+	return &MediaBackfillService{
+		replayRepo: replayRepo,
+		storage:    storage,
+		keyring:    keyring,
+		logger:     logger,
+	}
+}
+
+// BackfillMedia разбирает файлы видео-реплеев без параметров и записывает результат в БД
+// Файлы, которые не удалось разобрать, попадают в отчет и будут перепроверены следующим запуском
+func (s *MediaBackfillService) BackfillMedia(ctx context.Context) (*MediaBackfillReport, error) {
+	s.logger.Info("starting media backfill")
+
+	report := &MediaBackfillReport{}
+	afterID := uuid.Nil
+	for {
+		replays, err := s.replayRepo.ListMissingMediaAfter(ctx, afterID, media.ContentTypes, mediaBackfillPageSize)
+		if err != nil {
+			s.logger.Error("failed to list replays without media info", slog.String("error", err.Error()))
+			return report, wrapError("list replays without media info", err)
+		}
+
+		for i := range replays {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			replay := &replays[i]
+			afterID = replay.ID
+			report.Scanned++
+
+			info, err := probeStoredMedia(ctx, s.storage, s.keyring, replay)
+			if err == nil {
+				err = s.replayRepo.SetMediaInfo(ctx, replay.ID, info)
+			}
+			if errors.Is(err, repository.ErrNotFound) {
+				// реплей удален параллельно
+				continue
+			}
+			if err != nil {
+				s.logger.Warn("failed to extract media info",
+					slog.String("replay_id", replay.ID.String()),
+					slog.String("error", err.Error()))
+				report.Errors = append(report.Errors, replay.ID.String()+": "+err.Error())
+				continue
+			}
+			report.Updated++
+		}
+
+		if len(replays) < mediaBackfillPageSize {
+			break
+		}
+	}
+
+	s.logger.Info("media backfill finished",
+		slog.Int("scanned", report.Scanned),
+		slog.Int("updated", report.Updated),
+		slog.Int("errors", len(report.Errors)))
+	return report, nil
+}
+
+// probeStoredMedia разбирает заголовки видео из файла реплея в хранилище
+// Зашифрованный файл расшифровывается с произвольным доступом, поэтому данные кадров не читаются;
+// сжатый файл приходится распаковывать последовательно
+func probeStoredMedia(ctx context.Context, files FileStorageInterface, keyring *encryption.Keyring, replay *models.Replay) (models.MediaInfo, error) {
+	src, err := openStoredContent(ctx, files, keyring, replay)
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+	defer src.Close()
+
+	info, err := media.Probe(src, replay.SizeBytes, replay.ContentType)
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+	return newMediaInfo(info), nil
+}
+
+// openStoredContent открывает хранимый файл и возвращает исходные байты: расшифрованные и распакованные
+func openStoredContent(ctx context.Context, files FileStorageInterface, keyring *encryption.Keyring, replay *models.Replay) (io.ReadCloser, error) {
+	content, _, err := openStoredFile(ctx, files, keyring, replay)
+	if err != nil {
+		return nil, err
+	}
+	if !replay.Compressed {
+		return content, nil
+	}
+
+	decoded, err := compression.NewReader(replay.Compression, content)
+	if err != nil {
+		content.Close()
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// newMediaInfo переводит результат разбора в модель; неизвестные параметры остаются nil
+func newMediaInfo(info media.Info) models.MediaInfo {
+	var m models.MediaInfo
+	if info.Duration > 0 {
+		m.DurationMS = ptr(info.Duration.Milliseconds())
+	}
+	if info.Width > 0 && info.Height > 0 {
+		m.Width, m.Height = ptr(info.Width), ptr(info.Height)
+	}
+	if info.Codec != "" {
+		m.VideoCodec = ptr(info.Codec)
+	}
+	if info.FrameRate > 0 {
+		m.FrameRate = ptr(info.FrameRate)
+	}
+	if info.Bitrate > 0 {
+		m.Bitrate = ptr(info.Bitrate)
+	}
+	return m
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMediaBackfillRepository - мок для методов ReplayRepository, нужных заполнению параметров видео
+type MockMediaBackfillRepository struct {
+	mock.Mock
+}
+
+func (m *MockMediaBackfillRepository) ListMissingMediaAfter(ctx context.Context, afterID uuid.UUID, contentTypes []string, limit int) ([]models.Replay, error) {
+	args := m.Called(ctx, afterID, contentTypes, limit)
+	return args.Get(0).([]models.Replay), args.Error(1)
+}
+
+func (m *MockMediaBackfillRepository) SetMediaInfo(ctx context.Context, replayID uuid.UUID, media models.MediaInfo) error {
+	args := m.Called(ctx, replayID, media)
+	return args.Error(0)
+}
+
+// testMP4WithDuration собирает минимальный MP4 (ftyp, mdat, moov с одним mvhd) длительностью 5 секунд
+func testMP4WithDuration() []byte {
+	box := func(typ string, payload []byte) []byte {
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+		return append(append(out, typ...), payload...)
+	}
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 5000)
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00isom")),
+		box("mdat", make([]byte, 1000)),
+		box("moov", box("mvhd", mvhd)),
+	}, nil)
+}
+
+// TestBackfillMedia проверяет, что разобранные файлы обновляются, а битые попадают в отчет
+func TestBackfillMedia(t *testing.T) {
+	mockRepo := new(MockMediaBackfillRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewMediaBackfillService(mockRepo, mockStorage, nil, logger)
+
+	video := testMP4WithDuration()
+	good := models.Replay{ID: uuid.New(), FilePath: "blobs/good", SizeBytes: int64(len(video)), ContentType: "video/mp4", Compression: "none"}
+	broken := models.Replay{ID: uuid.New(), FilePath: "blobs/broken", SizeBytes: 4, ContentType: "video/webm", Compression: "none"}
+	deleted := models.Replay{ID: uuid.New(), FilePath: "blobs/deleted", SizeBytes: int64(len(video)), ContentType: "video/mp4", Compression: "none"}
+
+	mockRepo.On("ListMissingMediaAfter", mock.Anything, uuid.Nil, mock.Anything, mediaBackfillPageSize).
+		Return([]models.Replay{good, broken, deleted}, nil)
+	mockStorage.On("Open", mock.Anything, "blobs/good").Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{Size: int64(len(video))}, nil)
+	mockStorage.On("Open", mock.Anything, "blobs/broken").Return(nopSeekCloser{bytes.NewReader([]byte("junk"))}, storage.ObjectInfo{Size: 4}, nil)
+	mockStorage.On("Open", mock.Anything, "blobs/deleted").Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{Size: int64(len(video))}, nil)
+	mockRepo.On("SetMediaInfo", mock.Anything, good.ID, mock.MatchedBy(func(m models.MediaInfo) bool {
+		return m.DurationMS != nil && *m.DurationMS == 5000 && m.Bitrate != nil && m.Width == nil
+	})).Return(nil)
+	mockRepo.On("SetMediaInfo", mock.Anything, deleted.ID, mock.Anything).Return(repository.ErrNotFound)
+
+	report, err := service.BackfillMedia(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Scanned)
+	assert.Equal(t, 1, report.Updated)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0], broken.ID.String())
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCreateReplay_ExtractsMedia проверяет, что параметры видео сохраняются вместе с реплеем
+func TestCreateReplay_ExtractsMedia(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+
+	video := testMP4WithDuration()
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(len(video))).Return(nil)
+	mockStorage.On("Open", mock.Anything, stagingKey).Return(nopSeekCloser{bytes.NewReader(video)}, storage.ObjectInfo{Size: int64(len(video))}, nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.MatchedBy(func(r *models.Replay) bool {
+		return r.DurationMS != nil && *r.DurationMS == 5000
+	}), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+
+	file := newTestFileHeader(t, "clip.mp4", video)
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), uuid.New(), "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, "video/mp4", replay.ContentType)
+	require.NotNil(t, replay.Bitrate)
+	assert.Equal(t, int64(len(video)*8/5), *replay.Bitrate)
+	mockReplayRepo.AssertExpectations(t)
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidSort - список нельзя отсортировать по запрошенному полю
+var ErrInvalidSort = errors.New("invalid sort")
+
+// maxListLimit - предел размера страницы списков реплеев и игр
+const maxListLimit = 100
+
+var (
+	replaySortFields = []string{models.ReplaySortUploadedAt, models.ReplaySortSize, models.ReplaySortTitle, models.ReplaySortDuration}
+	gameSortFields   = []string{models.GameSortCreatedAt, models.GameSortName, models.GameSortReplayCount}
+)
+
+// encodeCursor кодирует позицию в непрозрачную строку вместе с сортировкой:
+// курсор, выданный для одной сортировки, для другой не принимается
+func encodeCursor(sort models.Sort, c models.Cursor) string {
+	// Ключ последний: в названии может встретиться разделитель
+	return encodeCursorParts(sortString(sort), c.ID.String(), c.Key)
+}
+
+func decodeCursor(cursor string, sort models.Sort) (*models.Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	parts, ok := decodeCursorParts(cursor, 3)
+	if !ok || parts[0] != sortString(sort) {
+		return nil, ErrInvalidCursor
+	}
+
+	c := models.Cursor{Key: parts[2]}
+	var err error
+	if c.ID, err = uuid.Parse(parts[1]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !validSortKey(sort.Field, c.Key) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// encodeCursorParts кодирует части позиции в непрозрачную строку курсора любого списка
+// Части разделяются "|"; разделитель допустим только в последней части
+func encodeCursorParts(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, "|")))
+}
+
+// decodeCursorParts разбирает курсор ровно из n частей; false - курсор поврежден или подделан
+func decodeCursorParts(cursor string, n int) ([]string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, false
+	}
+	parts := strings.SplitN(string(raw), "|", n)
+	if len(parts) != n {
+		return nil, false
+	}
+	return parts, true
+}
+
+// nextPage обрезает выборку до limit и строит курсор по последней записи страницы
+// Выборка запрашивается с limit+1 записями: лишняя показывает, есть ли следующая страница
+func nextPage[T any](items []T, limit int, cursor func(last *T) string) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	return items, cursor(&items[limit-1])
+}
+
+// timestampKeyLayouts - вид timestamptz::text в Postgres (DateStyle ISO): смещение +00 или +05:30
+var timestampKeyLayouts = []string{"2006-01-02 15:04:05.999999999Z07", "2006-01-02 15:04:05.999999999Z07:00"}
+
+// validSortKey - ключ курсора имеет тип поля сортировки
+// Ключ приводится к этому типу в SQL: подмененный курсор иначе ломает запрос вместо ответа 400
+func validSortKey(field, key string) bool {
+	switch field {
+	case models.ReplaySortUploadedAt, models.GameSortCreatedAt:
+		for _, layout := range timestampKeyLayouts {
+			if _, err := time.Parse(layout, key); err == nil {
+				return true
+			}
+		}
+		return false
+	case models.ReplaySortSize, models.ReplaySortDuration, models.GameSortReplayCount:
+		_, err := strconv.ParseInt(key, 10, 64)
+		return err == nil
+	default:
+		// Postgres не принимает в text невалидный UTF-8 и нулевой байт
+		return utf8.ValidString(key) && !strings.ContainsRune(key, 0)
+	}
+}
+
+// sortString - сортировка в виде size или -size (по убыванию)
+func sortString(sort models.Sort) string {
+	if sort.Desc {
+		return "-" + sort.Field
+	}
+	return sort.Field
+}
+
+func validateSort(sort models.Sort, fields []string) error {
+	if slices.Contains(fields, sort.Field) {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown field %q", ErrInvalidSort, sort.Field)
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetGameReplays_Cursor проверяет курсор следующей страницы и его привязку к сортировке
+func TestGetGameReplays_Cursor(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	service := NewReplayService(mockReplayRepo, new(MockFileStorage), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	gameID, userID := uuid.New(), uuid.New()
+
+	bySize := models.Sort{Field: models.ReplaySortSize}
+	replays := []models.Replay{
+		{ID: uuid.New(), SizeBytes: 100, SortKey: "100"},
+		{ID: uuid.New(), SizeBytes: 200, SortKey: "200"},
+		{ID: uuid.New(), SizeBytes: 300, SortKey: "300"},
+	}
+	mockReplayRepo.On("GetByGameID", mock.Anything, gameID, userID, models.ReplayFilter{}, bySize, (*models.Cursor)(nil), 3).
+		Return(replays, 7, nil)
+
+	page, err := service.GetGameReplays(context.Background(), gameID, userID, models.ReplayFilter{}, bySize, "", 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Replays, 2)
+	assert.Equal(t, 7, page.Total)
+	require.NotEmpty(t, page.NextCursor)
+
+	after := &models.Cursor{Key: "200", ID: replays[1].ID}
+	mockReplayRepo.On("GetByGameID", mock.Anything, gameID, userID, models.ReplayFilter{}, bySize, after, 3).
+		Return(replays[2:], 7, nil)
+	page, err = service.GetGameReplays(context.Background(), gameID, userID, models.ReplayFilter{}, bySize, page.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Replays, 1)
+	assert.Empty(t, page.NextCursor)
+
+	// Курсор сортировки по возрастанию не подходит для сортировки по убыванию
+	cursor := encodeCursor(bySize, *after)
+	_, err = service.GetGameReplays(context.Background(), gameID, userID, models.ReplayFilter{}, models.Sort{Field: models.ReplaySortSize, Desc: true}, cursor, 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	mockReplayRepo.AssertExpectations(t)
+}
+
+// TestGetGameReplays_InvalidParams проверяет отказ на неизвестную сортировку и противоречивые диапазоны
+func TestGetGameReplays_InvalidParams(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	service := NewReplayService(mockReplayRepo, new(MockFileStorage), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	newest := models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+
+	_, err := service.GetGameReplays(context.Background(), uuid.New(), uuid.New(), models.ReplayFilter{}, models.Sort{Field: "game_id"}, "", 20)
+	assert.ErrorIs(t, err, ErrInvalidSort)
+
+	minSize, maxSize := int64(200), int64(100)
+	_, err = service.GetGameReplays(context.Background(), uuid.New(), uuid.New(), models.ReplayFilter{MinSize: &minSize, MaxSize: &maxSize}, newest, "", 20)
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+
+	_, err = service.GetGameReplays(context.Background(), uuid.New(), uuid.New(), models.ReplayFilter{Extensions: []string{".d%m"}}, newest, "", 20)
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+
+	mockReplayRepo.AssertNotCalled(t, "GetByGameID", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestGetUserReplays_Page проверяет выборку по всем играм пользователя с курсором следующей страницы
+func TestGetUserReplays_Page(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	service := NewReplayService(mockReplayRepo, new(MockFileStorage), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	userID := uuid.New()
+
+	newest := models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+	replays := []models.Replay{
+		{ID: uuid.New(), GameName: "Dota 2", SortKey: "2025-11-24 14:00:00+00"},
+		{ID: uuid.New(), GameName: "Counter-Strike 2", SortKey: "2025-11-23 09:30:00+00"},
+	}
+	mockReplayRepo.On("GetByUserID", mock.Anything, userID, models.ReplayFilter{}, newest, (*models.Cursor)(nil), 2).
+		Return(replays, 12, nil)
+
+	page, err := service.GetUserReplays(context.Background(), userID, models.ReplayFilter{}, newest, "", 1)
+	require.NoError(t, err)
+	require.Len(t, page.Replays, 1)
+	assert.Equal(t, "Dota 2", page.Replays[0].GameName)
+	assert.Equal(t, 12, page.Total)
+
+	after, err := decodeCursor(page.NextCursor, newest)
+	require.NoError(t, err)
+	assert.Equal(t, &models.Cursor{Key: "2025-11-24 14:00:00+00", ID: replays[0].ID}, after)
+	mockReplayRepo.AssertExpectations(t)
+}
+
+// TestDecodeCursor_Key проверяет, что ключ курсора разбирается по типу поля сортировки
+func TestDecodeCursor_Key(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		key   string
+		valid bool
+	}{
+		{name: "timestamp", field: models.ReplaySortUploadedAt, key: "2025-11-24 14:00:00.123456+00", valid: true},
+		{name: "timestamp with minutes offset", field: models.GameSortCreatedAt, key: "2025-11-24 14:00:00+05:30", valid: true},
+		{name: "bad timestamp", field: models.ReplaySortUploadedAt, key: "yesterday", valid: false},
+		{name: "integer", field: models.ReplaySortSize, key: "1048576", valid: true},
+		{name: "bad integer", field: models.GameSortReplayCount, key: "1e3", valid: false},
+		{name: "text", field: models.ReplaySortTitle, key: "a|b", valid: true},
+		{name: "nul in text", field: models.GameSortName, key: "a\x00b", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sort := models.Sort{Field: tt.field}
+			c := models.Cursor{Key: tt.key, ID: uuid.New()}
+
+			got, err := decodeCursor(encodeCursor(sort, c), sort)
+			if !tt.valid {
+				assert.ErrorIs(t, err, ErrInvalidCursor)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c, *got)
+		})
+	}
+}
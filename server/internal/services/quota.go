@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrQuotaBytesExceeded   = errors.New("storage quota exceeded")
+	ErrQuotaReplaysExceeded = errors.New("replay count quota exceeded")
+)
+
+// QuotaService считает использование хранилища и проверяет квоты пользователей
+// Квота по умолчанию задается конфигом, переопределения для отдельных пользователей лежат в user_quotas
+type QuotaService struct {
+	quotaRepo QuotaRepositoryInterface
+	defaults  models.Quota
+	logger    *slog.Logger
+}
+
+func NewQuotaService(quotaRepo QuotaRepositoryInterface, defaults models.Quota, logger *slog.Logger) *QuotaService {
+	return &QuotaService{
+		quotaRepo: quotaRepo,
+		defaults:  defaults,
+		logger:    logger,
+	}
+}
+
+func (s *QuotaService) GetUsage(ctx context.Context, userID uuid.UUID) (*models.QuotaUsage, error) {
+	bytesUsed, replaysUsed, err := s.quotaRepo.GetUsage(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get storage usage", slog.String("error", err.Error()))
+		return nil, wrapError("get storage usage", err)
+	}
+
+	quota, err := s.GetLimits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.QuotaUsage{BytesUsed: bytesUsed, ReplaysUsed: replaysUsed, Quota: quota}, nil
+}
+
+// GetLimits возвращает действующую квоту пользователя: значения по умолчанию с его переопределением
+func (s *QuotaService) GetLimits(ctx context.Context, userID uuid.UUID) (models.Quota, error) {
+	override, err := s.quotaRepo.GetOverride(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get quota override", slog.String("error", err.Error()))
+		return models.Quota{}, wrapError("get quota override", err)
+	}
+
+	quota := s.defaults
+	if override.MaxBytes != nil {
+		quota.MaxBytes = *override.MaxBytes
+	}
+	if override.MaxReplays != nil {
+		quota.MaxReplays = *override.MaxReplays
+	}
+	return quota, nil
+}
+
+// CheckUpload проверяет, что пользователь может загрузить еще один реплей размером size
+// Проверка предварительная: отказывает до записи файла, но не резервирует место. Окончательно квота
+// проверяется при вставке реплея (ReplayRepository.CreateWithBlob)
+func (s *QuotaService) CheckUpload(ctx context.Context, userID uuid.UUID, size int64) error {
+	usage, err := s.GetUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if usage.MaxReplays > 0 && usage.ReplaysUsed+1 > usage.MaxReplays {
+		s.logger.Warn("replay count quota exceeded",
+			slog.String("user_id", userID.String()),
+			slog.Int64("replays_used", usage.ReplaysUsed),
+			slog.Int64("max_replays", usage.MaxReplays))
+		return ErrQuotaReplaysExceeded
+	}
+
+	if usage.MaxBytes > 0 && usage.BytesUsed+size > usage.MaxBytes {
+		s.logger.Warn("storage quota exceeded",
+			slog.String("user_id", userID.String()),
+			slog.Int64("bytes_used", usage.BytesUsed),
+			slog.Int64("size", size),
+			slog.Int64("max_bytes", usage.MaxBytes))
+		return ErrQuotaBytesExceeded
+	}
+
+	return nil
+}
+
+// SetOverride задает пользователю собственную квоту; nil-поля возвращают значения по умолчанию
+func (s *QuotaService) SetOverride(ctx context.Context, userID uuid.UUID, override *models.QuotaOverride) error {
+	s.logger.Info("setting quota override", slog.String("user_id", userID.String()))
+
+	if err := s.quotaRepo.SetOverride(ctx, userID, override); err != nil {
+		s.logger.Error("failed to set quota override", slog.String("error", err.Error()))
+		return wrapError("set quota override", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockQuotaRepository - мок для QuotaRepository
+type MockQuotaRepository struct {
+	mock.Mock
+}
+
+func (m *MockQuotaRepository) GetUsage(ctx context.Context, userID uuid.UUID) (int64, int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockQuotaRepository) GetOverride(ctx context.Context, userID uuid.UUID) (*models.QuotaOverride, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.QuotaOverride), args.Error(1)
+}
+
+func (m *MockQuotaRepository) SetOverride(ctx context.Context, userID uuid.UUID, override *models.QuotaOverride) error {
+	args := m.Called(ctx, userID, override)
+	return args.Error(0)
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+// TestQuotaGetUsage_Defaults проверяет, что без переопределения действует квота из конфига
+func TestQuotaGetUsage_Defaults(t *testing.T) {
+	mockRepo := new(MockQuotaRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewQuotaService(mockRepo, models.Quota{MaxBytes: 1000, MaxReplays: 10}, logger)
+
+	userID := uuid.New()
+	mockRepo.On("GetUsage", mock.Anything, userID).Return(int64(300), int64(2), nil)
+	mockRepo.On("GetOverride", mock.Anything, userID).Return(&models.QuotaOverride{}, nil)
+
+	usage, err := service.GetUsage(context.Background(), userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), usage.BytesUsed)
+	assert.Equal(t, int64(2), usage.ReplaysUsed)
+	assert.Equal(t, int64(1000), usage.MaxBytes)
+	assert.Equal(t, int64(10), usage.MaxReplays)
+}
+
+// TestQuotaGetUsage_Override проверяет, что переопределение заменяет только заданные поля
+func TestQuotaGetUsage_Override(t *testing.T) {
+	mockRepo := new(MockQuotaRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewQuotaService(mockRepo, models.Quota{MaxBytes: 1000, MaxReplays: 10}, logger)
+
+	userID := uuid.New()
+	mockRepo.On("GetUsage", mock.Anything, userID).Return(int64(0), int64(0), nil)
+	mockRepo.On("GetOverride", mock.Anything, userID).Return(&models.QuotaOverride{MaxBytes: int64Ptr(0)}, nil)
+
+	usage, err := service.GetUsage(context.Background(), userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage.MaxBytes, "0 в переопределении снимает ограничение")
+	assert.Equal(t, int64(10), usage.MaxReplays)
+}
+
+// TestQuotaCheckUpload проверяет пограничные случаи квоты по байтам и количеству
+func TestQuotaCheckUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		bytesUsed   int64
+		replaysUsed int64
+		size        int64
+		want        error
+	}{
+		{name: "fits exactly", bytesUsed: 900, replaysUsed: 9, size: 100},
+		{name: "bytes exceeded", bytesUsed: 900, replaysUsed: 0, size: 101, want: ErrQuotaBytesExceeded},
+		{name: "replays exceeded", bytesUsed: 0, replaysUsed: 10, size: 1, want: ErrQuotaReplaysExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockQuotaRepository)
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			service := NewQuotaService(mockRepo, models.Quota{MaxBytes: 1000, MaxReplays: 10}, logger)
+
+			userID := uuid.New()
+			mockRepo.On("GetUsage", mock.Anything, userID).Return(tt.bytesUsed, tt.replaysUsed, nil)
+			mockRepo.On("GetOverride", mock.Anything, userID).Return(&models.QuotaOverride{}, nil)
+
+			err := service.CheckUpload(context.Background(), userID, tt.size)
+
+			if tt.want == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestQuotaCheckUpload_Unlimited проверяет, что нулевая квота ничего не ограничивает
+func TestQuotaCheckUpload_Unlimited(t *testing.T) {
+	mockRepo := new(MockQuotaRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewQuotaService(mockRepo, models.Quota{}, logger)
+
+	userID := uuid.New()
+	mockRepo.On("GetUsage", mock.Anything, userID).Return(int64(1<<40), int64(100000), nil)
+	mockRepo.On("GetOverride", mock.Anything, userID).Return(&models.QuotaOverride{}, nil)
+
+	assert.NoError(t, service.CheckUpload(context.Background(), userID, 1<<30))
+}
+
+// TestCreateReplay_QuotaExceeded проверяет, что при превышении квоты файл не пишется в хранилище
+func TestCreateReplay_QuotaExceeded(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	mockQuotaRepo := new(MockQuotaRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	quota := NewQuotaService(mockQuotaRepo, models.Quota{MaxBytes: 100}, logger)
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithQuota(quota))
+
+	userID := uuid.New()
+	mockQuotaRepo.On("GetUsage", mock.Anything, userID).Return(int64(50), int64(1), nil)
+	mockQuotaRepo.On("GetOverride", mock.Anything, userID).Return(&models.QuotaOverride{}, nil)
+
+	file := newTestFileHeader(t, "big.rep", make([]byte, 51))
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), userID, "", "")
+
+	assert.Nil(t, replay)
+	assert.True(t, errors.Is(err, ErrQuotaBytesExceeded))
+	mockStorage.AssertNotCalled(t, "Put")
+	mockReplayRepo.AssertNotCalled(t, "CreateWithBlob")
+}
+
+// TestCreateReplay_QuotaRace проверяет отказ, когда параллельная загрузка заняла место после предварительной
+// проверки: квота передается во вставку реплея, а ее отказ превращается в ошибку квоты и удаляет временный файл
+func TestCreateReplay_QuotaRace(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	mockQuotaRepo := new(MockQuotaRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	quota := NewQuotaService(mockQuotaRepo, models.Quota{MaxBytes: 100}, logger)
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithQuota(quota))
+
+	userID := uuid.New()
+	mockQuotaRepo.On("GetUsage", mock.Anything, userID).Return(int64(50), int64(1), nil)
+	mockQuotaRepo.On("GetOverride", mock.Anything, userID).Return(&models.QuotaOverride{MaxReplays: int64Ptr(5)}, nil)
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(40)).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).
+		Return(false, fmt.Errorf("failed to check quota: %w", repository.ErrQuotaBytes))
+	mockStorage.On("Delete", mock.Anything, stagingKey).Return(nil)
+
+	file := newTestFileHeader(t, "race.rep", make([]byte, 40))
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), userID, "", "")
+
+	assert.Nil(t, replay)
+	assert.ErrorIs(t, err, ErrQuotaBytesExceeded)
+	assert.Equal(t, &models.Quota{MaxBytes: 100, MaxReplays: 5}, mockReplayRepo.quotaLimit)
+	mockStorage.AssertCalled(t, "Delete", mock.Anything, stagingKey)
+	mockStorage.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultReconcileGrace - файлы моложе этого возраста не считаются сиротами:
+	// запись в БД создается уже после записи файла
+	DefaultReconcileGrace = time.Hour
+
+	reconcileQuarantineReason = "file missing in storage"
+	reconcilePageSize         = 500
+)
+
+type ReconcileOptions struct {
+	// DryRun - только отчет, без переноса в карантин
+	DryRun bool
+	Grace  time.Duration
+}
+
+type OrphanFile struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+type DanglingReplay struct {
+	ReplayID uuid.UUID `json:"replay_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	FilePath string    `json:"file_path"`
+}
+
+type ReconcileReport struct {
+	DryRun          bool             `json:"dry_run"`
+	ScannedFiles    int              `json:"scanned_files"`
+	ScannedReplays  int              `json:"scanned_replays"`
+	OrphanFiles     []OrphanFile     `json:"orphan_files"`
+	DanglingReplays []DanglingReplay `json:"dangling_replays"`
+	Quarantined     int              `json:"quarantined"`
+	Errors          []string         `json:"errors,omitempty"`
+}
+
+// Found сообщает, нашла ли сверка расхождения
+func (r *ReconcileReport) Found() bool {
+	return len(r.OrphanFiles) > 0 || len(r.DanglingReplays) > 0
+}
+
+// ReconcileService сверяет хранилище с таблицей replays
+// Файлы без записи переносятся под quarantine/, записи без файла - в таблицу quarantined_replays
+type ReconcileService struct {
+	replayRepo ReconcileRepositoryInterface
+	uploadRepo UploadKeysRepositoryInterface
+	storage    FileStorageInterface
+	logger     *slog.Logger
+}
+
+func NewReconcileService(
+	replayRepo ReconcileRepositoryInterface,
+	uploadRepo UploadKeysRepositoryInterface,
+	storage FileStorageInterface,
+	logger *slog.Logger,
+) *ReconcileService {
+	return &ReconcileService{
+		replayRepo: replayRepo,
+		uploadRepo: uploadRepo,
+		storage:    storage,
+		logger:     logger,
+	}
+}
+
+func (s *ReconcileService) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	s.logger.Info("starting storage reconciliation", slog.Bool("dry_run", opts.DryRun))
+
+	report := &ReconcileReport{
+		DryRun:          opts.DryRun,
+		OrphanFiles:     make([]OrphanFile, 0),
+		DanglingReplays: make([]DanglingReplay, 0),
+	}
+	startedAt := time.Now()
+
+	files := make(map[string]storage.ObjectInfo)
+	err := s.storage.Walk(ctx, "", func(info storage.ObjectInfo) error {
+		if strings.HasPrefix(info.Key, storage.QuarantinePrefix+"/") {
+			return nil
+		}
+		files[info.Key] = info
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to walk storage", slog.String("error", err.Error()))
+		return report, wrapError("walk storage", err)
+	}
+	report.ScannedFiles = len(files)
+
+	chunkKeys, err := s.uploadRepo.ListChunkKeys(ctx)
+	if err != nil {
+		s.logger.Error("failed to list upload chunks", slog.String("error", err.Error()))
+		return report, wrapError("list upload chunks", err)
+	}
+	for _, key := range chunkKeys {
+		delete(files, key)
+	}
+
+	var dangling []DanglingReplay
+	afterID := uuid.Nil
+	for {
+		replays, err := s.replayRepo.ListAfter(ctx, afterID, reconcilePageSize)
+		if err != nil {
+			s.logger.Error("failed to list replays", slog.String("error", err.Error()))
+			return report, wrapError("list replays", err)
+		}
+
+		for _, replay := range replays {
+			report.ScannedReplays++
+			if _, ok := files[replay.FilePath]; ok {
+				delete(files, replay.FilePath)
+				continue
+			}
+			dangling = append(dangling, DanglingReplay{ReplayID: replay.ID, UserID: replay.UserID, FilePath: replay.FilePath})
+		}
+
+		if len(replays) < reconcilePageSize {
+			break
+		}
+		afterID = replays[len(replays)-1].ID
+	}
+
+	for _, info := range files {
+		if startedAt.Sub(info.ModTime) < opts.Grace {
+			continue
+		}
+		report.OrphanFiles = append(report.OrphanFiles, OrphanFile{Key: info.Key, Size: info.Size, ModTime: info.ModTime})
+	}
+	sort.Slice(report.OrphanFiles, func(i, j int) bool {
+		return report.OrphanFiles[i].Key < report.OrphanFiles[j].Key
+	})
+
+	// Файл мог появиться после обхода хранилища (реплей загружен во время сверки), поэтому перепроверяем
+	for _, replay := range dangling {
+		if _, err := s.storage.Stat(ctx, replay.FilePath); !errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		report.DanglingReplays = append(report.DanglingReplays, replay)
+	}
+
+	if !opts.DryRun {
+		s.quarantine(ctx, report)
+	}
+
+	s.logger.Info("storage reconciliation finished",
+		slog.Int("orphan_files", len(report.OrphanFiles)),
+		slog.Int("dangling_replays", len(report.DanglingReplays)),
+		slog.Int("quarantined", report.Quarantined))
+	return report, nil
+}
+
+func (s *ReconcileService) quarantine(ctx context.Context, report *ReconcileReport) {
+	for _, orphan := range report.OrphanFiles {
+		// Файл переносится через ReleaseFile: строка blob удаляется вместе с ним,
+		// а blob, на который успел сослаться новый реплей, остается на месте
+		moved, err := s.replayRepo.ReleaseFile(ctx, orphan.Key, func(key string) error {
+			return s.moveToQuarantine(ctx, key)
+		})
+		if err != nil {
+			s.logger.Warn("failed to quarantine orphan file",
+				slog.String("key", orphan.Key),
+				slog.String("error", err.Error()))
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if moved {
+			report.Quarantined++
+		}
+	}
+
+	for _, replay := range report.DanglingReplays {
+		if err := s.replayRepo.Quarantine(ctx, replay.ReplayID, reconcileQuarantineReason); err != nil {
+			s.logger.Warn("failed to quarantine dangling replay",
+				slog.String("replay_id", replay.ReplayID.String()),
+				slog.String("error", err.Error()))
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Quarantined++
+
+		// Строка blob без файла не должна достаться следующей загрузке того же содержимого
+		releaseFile(ctx, s.replayRepo, s.storage, s.logger, replay.FilePath)
+	}
+}
+
+func (s *ReconcileService) moveToQuarantine(ctx context.Context, key string) error {
+	if err := s.storage.Move(ctx, key, storage.QuarantineKey(key)); err != nil {
+		return wrapError("move orphan file", err)
+	}
+	return nil
+}
+
+// RunReconcile периодически вызывает Reconcile, пока ctx не отменен
+func (s *ReconcileService) RunReconcile(ctx context.Context, interval time.Duration, opts ReconcileOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Reconcile(ctx, opts)
+		}
+	}
+}
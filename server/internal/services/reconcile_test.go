@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockReconcileRepository - мок для ReplayRepository в сверке
+type MockReconcileRepository struct {
+	mock.Mock
+}
+
+func (m *MockReconcileRepository) ListAfter(ctx context.Context, afterID uuid.UUID, limit int) ([]models.Replay, error) {
+	args := m.Called(ctx, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Replay), args.Error(1)
+}
+
+func (m *MockReconcileRepository) Quarantine(ctx context.Context, replayID uuid.UUID, reason string) error {
+	args := m.Called(ctx, replayID, reason)
+	return args.Error(0)
+}
+
+func (m *MockReconcileRepository) ReleaseFile(ctx context.Context, filePath string, remove func(filePath string) error) (bool, error) {
+	args := m.Called(ctx, filePath)
+	return callRemove(args, filePath, remove)
+}
+
+// MockUploadKeysRepository - мок для ключей кусков загрузок
+type MockUploadKeysRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadKeysRepository) ListChunkKeys(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// setupReconcileTest готовит хранилище с файлами:
+// user/game/ok.rep - у реплея есть файл; user/game/orphan.rep - старый сирота;
+// user/game/fresh.rep - только что записанный файл; uploads/u/0 - кусок живой загрузки;
+// quarantine/old.rep - уже в карантине. Реплей dangling ссылается на отсутствующий файл
+func setupReconcileTest() (*ReconcileService, *MockReconcileRepository, *MockFileStorage, models.Replay) {
+	mockReplayRepo := new(MockReconcileRepository)
+	mockUploadRepo := new(MockUploadKeysRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	old := time.Now().Add(-48 * time.Hour)
+	objects := []storage.ObjectInfo{
+		{Key: "user/game/ok.rep", Size: 1, ModTime: old},
+		{Key: "user/game/orphan.rep", Size: 2, ModTime: old},
+		{Key: "user/game/fresh.rep", Size: 3, ModTime: time.Now()},
+		{Key: "uploads/u/0", Size: 4, ModTime: old},
+		{Key: "quarantine/old.rep", Size: 5, ModTime: old},
+	}
+	dangling := models.Replay{ID: uuid.New(), FilePath: "user/game/dangling.rep"}
+	replays := []models.Replay{
+		{ID: uuid.New(), FilePath: "user/game/ok.rep"},
+		dangling,
+	}
+
+	mockStorage.On("Walk", mock.Anything, "", mock.Anything).Return(objects, nil)
+	mockUploadRepo.On("ListChunkKeys", mock.Anything).Return([]string{"uploads/u/0"}, nil)
+	mockReplayRepo.On("ListAfter", mock.Anything, uuid.Nil, reconcilePageSize).Return(replays, nil)
+	mockStorage.On("Stat", mock.Anything, "user/game/dangling.rep").
+		Return(storage.ObjectInfo{}, fmt.Errorf("stat: %w", storage.ErrNotFound))
+
+	service := NewReconcileService(mockReplayRepo, mockUploadRepo, mockStorage, logger)
+	return service, mockReplayRepo, mockStorage, dangling
+}
+
+// TestReconcile_DryRun проверяет отчет без изменений в хранилище и БД
+func TestReconcile_DryRun(t *testing.T) {
+	service, mockReplayRepo, mockStorage, dangling := setupReconcileTest()
+
+	report, err := service.Reconcile(context.Background(), ReconcileOptions{DryRun: true, Grace: time.Hour})
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.ScannedFiles, "карантин не сканируется")
+	assert.Equal(t, 2, report.ScannedReplays)
+	require.Len(t, report.OrphanFiles, 1, "свежий файл и кусок загрузки не сироты")
+	assert.Equal(t, "user/game/orphan.rep", report.OrphanFiles[0].Key)
+	require.Len(t, report.DanglingReplays, 1)
+	assert.Equal(t, dangling.ID, report.DanglingReplays[0].ReplayID)
+	assert.Equal(t, 0, report.Quarantined)
+
+	mockStorage.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	mockReplayRepo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestReconcile_Quarantine проверяет перенос сирот под quarantine/ и висячих записей в quarantined_replays
+func TestReconcile_Quarantine(t *testing.T) {
+	service, mockReplayRepo, mockStorage, dangling := setupReconcileTest()
+
+	mockReplayRepo.On("ReleaseFile", mock.Anything, "user/game/orphan.rep").Return(true, nil)
+	mockStorage.On("Move", mock.Anything, "user/game/orphan.rep", "quarantine/user/game/orphan.rep").Return(nil)
+	mockReplayRepo.On("Quarantine", mock.Anything, dangling.ID, reconcileQuarantineReason).Return(nil)
+	// После переноса записи освобождается и blob без файла
+	mockReplayRepo.On("ReleaseFile", mock.Anything, dangling.FilePath).Return(true, nil)
+	mockStorage.On("Delete", mock.Anything, dangling.FilePath).Return(nil)
+
+	report, err := service.Reconcile(context.Background(), ReconcileOptions{Grace: time.Hour})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Quarantined)
+	assert.Empty(t, report.Errors)
+	mockStorage.AssertExpectations(t)
+	mockReplayRepo.AssertExpectations(t)
+}
+
+// TestReconcile_BlobReferencedDuringScan проверяет, что blob, на который успел сослаться новый реплей, не переносится
+func TestReconcile_BlobReferencedDuringScan(t *testing.T) {
+	mockReplayRepo := new(MockReconcileRepository)
+	mockUploadRepo := new(MockUploadKeysRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	old := time.Now().Add(-48 * time.Hour)
+	mockStorage.On("Walk", mock.Anything, "", mock.Anything).
+		Return([]storage.ObjectInfo{{Key: "blobs/ab/abcd", Size: 1, ModTime: old}}, nil)
+	mockUploadRepo.On("ListChunkKeys", mock.Anything).Return([]string{}, nil)
+	mockReplayRepo.On("ListAfter", mock.Anything, uuid.Nil, reconcilePageSize).Return([]models.Replay{}, nil)
+	mockReplayRepo.On("ReleaseFile", mock.Anything, "blobs/ab/abcd").Return(false, nil)
+
+	service := NewReconcileService(mockReplayRepo, mockUploadRepo, mockStorage, logger)
+	report, err := service.Reconcile(context.Background(), ReconcileOptions{Grace: time.Hour})
+
+	require.NoError(t, err)
+	assert.Len(t, report.OrphanFiles, 1)
+	assert.Equal(t, 0, report.Quarantined)
+	mockStorage.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestReconcile_FileAppearedDuringScan проверяет, что запись не считается висячей, если файл появился после обхода
+func TestReconcile_FileAppearedDuringScan(t *testing.T) {
+	mockReplayRepo := new(MockReconcileRepository)
+	mockUploadRepo := new(MockUploadKeysRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	replay := models.Replay{ID: uuid.New(), FilePath: "user/game/new.rep"}
+	mockStorage.On("Walk", mock.Anything, "", mock.Anything).Return([]storage.ObjectInfo{}, nil)
+	mockUploadRepo.On("ListChunkKeys", mock.Anything).Return([]string{}, nil)
+	mockReplayRepo.On("ListAfter", mock.Anything, uuid.Nil, reconcilePageSize).Return([]models.Replay{replay}, nil)
+	mockStorage.On("Stat", mock.Anything, "user/game/new.rep").Return(storage.ObjectInfo{Key: "user/game/new.rep"}, nil)
+
+	service := NewReconcileService(mockReplayRepo, mockUploadRepo, mockStorage, logger)
+	report, err := service.Reconcile(context.Background(), ReconcileOptions{Grace: time.Hour})
+
+	require.NoError(t, err)
+	assert.Empty(t, report.DanglingReplays)
+	mockReplayRepo.AssertNotCalled(t, "Quarantine", mock.Anything, mock.Anything, mock.Anything)
+}
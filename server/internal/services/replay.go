@@ -1,51 +1,180 @@
 package services
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
 	"log/slog"
 	"mime/multipart"
-	"path/filepath"
+	"slices"
+	"time"
 
+	"github.com/fckoffmw/replay-service/server/internal/compression"
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/fckoffmw/replay-service/server/internal/media"
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/replayparser"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
 	"github.com/google/uuid"
 )
 
-const (
-	compressionNone = "none"
-)
+// ReplayFile - открытый файл реплея вместе с его метаданными
+// Content должен быть закрыт вызывающей стороной
+// Encoding - content-coding, в котором отдается Content ("" - исходные байты)
+type ReplayFile struct {
+	Replay   *models.Replay
+	Content  io.ReadCloser
+	Size     int64
+	ModTime  time.Time
+	Encoding string
+}
 
 type ReplayService struct {
-	replayRepo ReplayRepositoryInterface
-	storage    FileStorageInterface
-	logger     *slog.Logger
+	replayRepo      ReplayRepositoryInterface
+	storage         FileStorageInterface
+	compression     compression.Policy
+	verifyDownloads bool
+	quota           QuotaCheckerInterface
+	typePolicy      FileTypeCheckerInterface
+	faststart       bool
+	parsers         *replayparser.Registry
+	markers         MarkerListerInterface
+	keyring         *encryption.Keyring
+	logger          *slog.Logger
+}
+
+// ReplayOption настраивает необязательные возможности ReplayService
+type ReplayOption func(*ReplayService)
+
+func WithCompression(policy compression.Policy) ReplayOption {
+	return func(s *ReplayService) {
+		s.compression = policy
+	}
+}
+
+// WithDownloadVerification включает сверку SHA-256 файла перед отдачей клиенту
+// Файл читается дважды, зато поврежденные данные не уходят клиенту
+func WithDownloadVerification() ReplayOption {
+	return func(s *ReplayService) {
+		s.verifyDownloads = true
+	}
+}
+
+// WithQuota включает проверку квоты пользователя перед записью файла
+func WithQuota(quota QuotaCheckerInterface) ReplayOption {
+	return func(s *ReplayService) {
+		s.quota = quota
+	}
+}
+
+// WithTypePolicy включает проверку формата файла, определенного по содержимому, политикой игры
+func WithTypePolicy(checker FileTypeCheckerInterface) ReplayOption {
+	return func(s *ReplayService) {
+		s.typePolicy = checker
+	}
+}
+
+// WithEncryption шифрует новые файлы ключом данных, обернутым активным мастер-ключом keyring
+// Без этой опции уже зашифрованные файлы прочитать нельзя
+func WithEncryption(keyring *encryption.Keyring) ReplayOption {
+	return func(s *ReplayService) {
+		s.keyring = keyring
+	}
+}
+
+// WithMarkers добавляет к деталям реплея отметки на временной шкале
+func WithMarkers(markers MarkerListerInterface) ReplayOption {
+	return func(s *ReplayService) {
+		s.markers = markers
+	}
 }
 
 func NewReplayService(
 	replayRepo ReplayRepositoryInterface,
 	storage FileStorageInterface,
 	logger *slog.Logger,
+	opts ...ReplayOption,
 ) *ReplayService {
-	return &ReplayService{
-		replayRepo: replayRepo,
-		storage:    storage,
-		logger:     logger,
+	s := &ReplayService{
+		replayRepo:  replayRepo,
+		storage:     storage,
+		compression: compression.NewPolicy(compression.None, nil),
+		logger:      logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *ReplayService) GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, limit int) ([]models.Replay, error) {
+// GetGameReplays возвращает страницу реплеев игры
+// cursor - next_cursor предыдущей страницы той же сортировки, "" - первая страница
+func (s *ReplayService) GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error) {
 	s.logger.Info("getting game replays",
 		slog.String("game_id", gameID.String()),
 		slog.String("user_id", userID.String()),
 		slog.Int("limit", limit))
 
-	replays, err := s.replayRepo.GetByGameID(ctx, gameID, userID, limit)
+	after, err := s.prepareList(filter, sort, cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), maxListLimit)
+
+	replays, total, err := s.replayRepo.GetByGameID(ctx, gameID, userID, filter, sort, after, limit+1)
+	if err != nil {
+		s.logger.Error("failed to get replays", slog.String("error", err.Error()))
+		return nil, wrapError("get replays", err)
+	}
+
+	s.logger.Info("replays retrieved", slog.Int("count", len(replays)), slog.Int("total", total))
+	return replayPage(replays, total, sort, limit), nil
+}
+
+// GetUserReplays возвращает страницу реплеев пользователя из всех его игр
+func (s *ReplayService) GetUserReplays(ctx context.Context, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error) {
+	s.logger.Info("getting user replays",
+		slog.String("user_id", userID.String()),
+		slog.Int("limit", limit))
+
+	after, err := s.prepareList(filter, sort, cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), maxListLimit)
+
+	replays, total, err := s.replayRepo.GetByUserID(ctx, userID, filter, sort, after, limit+1)
 	if err != nil {
 		s.logger.Error("failed to get replays", slog.String("error", err.Error()))
 		return nil, wrapError("get replays", err)
 	}
 
-	s.logger.Info("replays retrieved", slog.Int("count", len(replays)))
-	return replays, nil
+	s.logger.Info("replays retrieved", slog.Int("count", len(replays)), slog.Int("total", total))
+	return replayPage(replays, total, sort, limit), nil
+}
+
+func (s *ReplayService) prepareList(filter models.ReplayFilter, sort models.Sort, cursor string) (*models.Cursor, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	if err := validateSort(sort, replaySortFields); err != nil {
+		return nil, err
+	}
+	return decodeCursor(cursor, sort)
+}
+
+// replayPage обрезает выборку до limit и строит курсор по последнему реплею страницы
+func replayPage(replays []models.Replay, total int, sort models.Sort, limit int) *models.ReplayPage {
+	page := &models.ReplayPage{Total: total}
+	page.Replays, page.NextCursor = nextPage(replays, limit, func(last *models.Replay) string {
+		return encodeCursor(sort, models.Cursor{Key: last.SortKey, ID: last.ID})
+	})
+	return page
 }
 
 func (s *ReplayService) GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
@@ -59,6 +188,14 @@ func (s *ReplayService) GetReplay(ctx context.Context, replayID, userID uuid.UUI
 		return nil, notFoundError("replay", err)
 	}
 
+	if s.markers != nil {
+		replay.Markers, err = s.markers.ListByReplayID(ctx, replayID, userID)
+		if err != nil {
+			s.logger.Error("failed to get markers", slog.String("error", err.Error()))
+			return nil, wrapError("get markers", err)
+		}
+	}
+
 	s.logger.Info("replay retrieved", slog.String("filename", replay.OriginalName))
 	return replay, nil
 }
@@ -68,48 +205,254 @@ func (s *ReplayService) CreateReplay(
 	file *multipart.FileHeader,
 	gameID, userID uuid.UUID,
 	title, comment string,
+) (*models.Replay, error) {
+	src, err := file.Open()
+	if err != nil {
+		s.logger.Error("failed to open uploaded file", slog.String("error", err.Error()))
+		return nil, wrapError("open uploaded file", err)
+	}
+	defer src.Close()
+
+	return s.CreateReplayFromStream(ctx, src, file.Filename, file.Size, gameID, userID, title, comment, nil)
+}
+
+// CreateReplayFromStream сохраняет содержимое src как новый реплей
+// Общий конвейер для обычной загрузки и финализации resumable-загрузки
+// upload - аренда сессии, из которой собран поток: реплей закрепляется за ней при вставке; nil - обычная загрузка
+func (s *ReplayService) CreateReplayFromStream(
+	ctx context.Context,
+	src io.Reader,
+	filename string,
+	size int64,
+	gameID, userID uuid.UUID,
+	title, comment string,
+	upload *models.UploadLease,
 ) (*models.Replay, error) {
 	s.logger.Info("creating replay",
 		slog.String("game_id", gameID.String()),
 		slog.String("user_id", userID.String()),
-		slog.String("filename", file.Filename),
+		slog.String("filename", filename),
 		slog.String("title", title))
 
+	if s.quota != nil {
+		if err := s.quota.CheckUpload(ctx, userID, size); err != nil {
+			return nil, wrapError("check quota", err)
+		}
+	}
+
+	// Формат определяется по первым байтам: расширение задает клиент, и ему нельзя доверять
+	sniffer := bufio.NewReaderSize(src, filetype.HeaderSize)
+	head, err := sniffer.Peek(filetype.HeaderSize)
+	if err != nil && err != io.EOF {
+		s.logger.Error("failed to read file header", slog.String("error", err.Error()))
+		return nil, wrapError("read file header", err)
+	}
+	detected := filetype.Detect(head)
+
+	var parser replayparser.Parser
+	if s.parsers != nil {
+		parser = s.parsers.Find(filename, head)
+	}
+
+	if s.typePolicy != nil {
+		if err := s.typePolicy.CheckFileType(ctx, gameID, userID, detected); err != nil {
+			return nil, wrapError("check file type", err)
+		}
+	}
+
+	algorithm := s.compression.AlgorithmFor(filename, detected)
 	replay := &models.Replay{
 		ID:           uuid.New(),
 		Title:        stringPtr(title),
-		OriginalName: file.Filename,
-		SizeBytes:    file.Size,
-		Compression:  compressionNone,
-		Compressed:   false,
+		OriginalName: filename,
+		SizeBytes:    size,
+		ContentType:  detected.MIME,
+		Compression:  algorithm,
+		Compressed:   algorithm != compression.None,
 		Comment:      stringPtr(comment),
 		GameID:       gameID,
 		UserID:       userID,
+		Upload:       upload,
 	}
 
-	filePath, err := s.storage.SaveReplayFile(file, userID, gameID, replay.ID)
+	// Хеш известен только после записи, поэтому файл сначала пишется под временным ключом
+	stagingKey := storage.StagingKey(replay.ID)
+	storedSize, err := s.stage(ctx, stagingKey, sniffer, size, replay)
 	if err != nil {
-		s.logger.Error("failed to save file", slog.String("error", err.Error()))
-		return nil, wrapError("save file", err)
+		return nil, err
 	}
-	replay.FilePath = filePath
 
-	if err := s.replayRepo.Create(ctx, replay); err != nil {
-		s.logger.Error("failed to save replay to database", slog.String("error", err.Error()))
-		s.storage.DeleteFile(filePath)
-		return nil, wrapError("create replay", err)
+	if s.faststart && media.IsMP4(replay.ContentType) {
+		stagingKey, storedSize = s.remuxFaststart(ctx, replay, stagingKey, storedSize)
+	}
+	if media.Supported(replay.ContentType) {
+		s.probeMedia(ctx, replay, stagingKey)
+	}
+	if parser != nil {
+		s.parseMetadata(ctx, replay, stagingKey, parser)
 	}
 
-	s.logger.Info("replay created", slog.String("replay_id", replay.ID.String()))
+	if err := s.commit(ctx, replay, stagingKey, storedSize); err != nil {
+		return nil, err
+	}
 	return replay, nil
 }
 
+// commit вставляет строку реплея и переносит временный файл под ключ blob
+// Если такой blob уже есть, временный файл удаляется
+func (s *ReplayService) commit(ctx context.Context, replay *models.Replay, stagingKey string, storedSize int64) error {
+	blob := &models.Blob{
+		SHA256:      replay.SHA256,
+		FilePath:    storage.BlobKey(replay.SHA256, replay.Compression),
+		Compression: replay.Compression,
+		Compressed:  replay.Compressed,
+		SizeBytes:   storedSize,
+		KeyID:       replay.KeyID,
+		WrappedKey:  replay.WrappedKey,
+	}
+	var limit *models.Quota
+	if s.quota != nil {
+		quota, err := s.quota.GetLimits(ctx, replay.UserID)
+		if err != nil {
+			s.storage.Delete(ctx, stagingKey)
+			return wrapError("get quota", err)
+		}
+		limit = &quota
+	}
+
+	created, err := s.replayRepo.CreateWithBlob(ctx, replay, blob, limit, func(filePath string) error {
+		return s.storage.Move(ctx, stagingKey, filePath)
+	})
+	if err != nil {
+		s.storage.Delete(ctx, stagingKey)
+		switch {
+		case errors.Is(err, repository.ErrQuotaBytes):
+			s.logger.Warn("storage quota exceeded", slog.String("user_id", replay.UserID.String()))
+			return wrapError("check quota", ErrQuotaBytesExceeded)
+		case errors.Is(err, repository.ErrQuotaReplays):
+			s.logger.Warn("replay count quota exceeded", slog.String("user_id", replay.UserID.String()))
+			return wrapError("check quota", ErrQuotaReplaysExceeded)
+		case replay.Upload != nil && errors.Is(err, repository.ErrNotFound):
+			s.logger.Warn("upload lease lost before commit", slog.String("upload_id", replay.Upload.UploadID.String()))
+			return ErrUploadLeaseLost
+		}
+		s.logger.Error("failed to save replay to database", slog.String("error", err.Error()))
+		return wrapError("create replay", err)
+	}
+	if !created {
+		if err := s.storage.Delete(ctx, stagingKey); err != nil {
+			s.logger.Warn("failed to delete staging file", slog.String("error", err.Error()))
+		}
+	}
+
+	s.logger.Info("replay created",
+		slog.String("replay_id", replay.ID.String()),
+		slog.String("content_type", replay.ContentType),
+		slog.String("compression", replay.Compression),
+		slog.Bool("encrypted", replay.WrappedKey != nil),
+		slog.Bool("deduplicated", !created))
+	return nil
+}
+
+// stageWritten пишет под key то, что write выдает в поток, по тем же правилам, что и stage
+func (s *ReplayService) stageWritten(ctx context.Context, key string, size int64, replay *models.Replay, write func(io.Writer) error) (int64, error) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(write(pw))
+	}()
+
+	storedSize, err := s.stage(ctx, key, pr, size, replay)
+	// Закрытие читающей стороны останавливает write, если запись оборвалась раньше
+	pr.Close()
+	<-done
+	return storedSize, err
+}
+
+// stage пишет src под key: сжимает и шифрует его по настройкам replay, считает SHA-256 исходных байтов
+// Заполняет replay.SHA256 и ключ данных; возвращает размер записанного файла
+func (s *ReplayService) stage(ctx context.Context, key string, src io.Reader, size int64, replay *models.Replay) (int64, error) {
+	hash := sha256.New()
+	var body io.Reader = io.TeeReader(src, hash)
+	if replay.Compressed {
+		compressed, err := compression.Compress(replay.Compression, body)
+		if err != nil {
+			s.logger.Error("failed to start compression", slog.String("error", err.Error()))
+			return 0, wrapError("compress file", err)
+		}
+		defer compressed.Close()
+		body, size = compressed, -1
+	}
+
+	if s.keyring != nil {
+		encrypted, keyID, wrappedKey, err := s.encrypt(body)
+		if err != nil {
+			s.logger.Error("failed to start encryption", slog.String("error", err.Error()))
+			return 0, wrapError("encrypt file", err)
+		}
+		body, replay.KeyID, replay.WrappedKey = encrypted, keyID, wrappedKey
+		if size >= 0 {
+			size = encryption.CiphertextSize(size)
+		}
+	}
+
+	stored := &countingReader{r: body}
+	if err := s.storage.Put(ctx, key, stored, size); err != nil {
+		s.logger.Error("failed to save file", slog.String("error", err.Error()))
+		s.storage.Delete(ctx, key)
+		return 0, wrapError("save file", err)
+	}
+	replay.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return stored.n, nil
+}
+
+// probeMedia извлекает параметры видео из уже записанного файла
+// Ошибка разбора не мешает загрузке: параметры останутся пустыми, их можно досчитать командой probe-media
+func (s *ReplayService) probeMedia(ctx context.Context, replay *models.Replay, key string) {
+	staged := *replay
+	staged.FilePath = key
+
+	info, err := probeStoredMedia(ctx, s.storage, s.keyring, &staged)
+	if err != nil {
+		s.logger.Warn("failed to extract media info",
+			slog.String("replay_id", replay.ID.String()),
+			slog.String("content_type", replay.ContentType),
+			slog.String("error", err.Error()))
+		return
+	}
+	replay.MediaInfo = info
+}
+
+// encrypt шифрует body новым ключом данных и возвращает этот ключ, обернутый мастер-ключом
+func (s *ReplayService) encrypt(body io.Reader) (io.Reader, string, []byte, error) {
+	dataKey, err := encryption.NewDataKey()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	keyID, wrappedKey, err := s.keyring.Wrap(dataKey)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	encrypted, err := encryption.Encrypt(dataKey, body)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return encrypted, keyID, wrappedKey, nil
+}
+
+// UpdateReplay меняет название и описание реплея; нужна роль editor
 func (s *ReplayService) UpdateReplay(ctx context.Context, replayID, userID uuid.UUID, title, comment *string) error {
 	s.logger.Info("updating replay",
 		slog.String("replay_id", replayID.String()),
 		slog.String("user_id", userID.String()))
 
 	if err := s.replayRepo.Update(ctx, replayID, userID, title, comment); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleEditor, ErrReplayNotFound)
+		}
 		s.logger.Error("failed to update replay", slog.String("error", err.Error()))
 		return wrapError("update replay", err)
 	}
@@ -118,6 +461,7 @@ func (s *ReplayService) UpdateReplay(ctx context.Context, replayID, userID uuid.
 	return nil
 }
 
+// DeleteReplay удаляет реплей; удалить может только владелец
 func (s *ReplayService) DeleteReplay(ctx context.Context, replayID, userID uuid.UUID) error {
 	s.logger.Info("deleting replay",
 		slog.String("replay_id", replayID.String()),
@@ -126,27 +470,135 @@ func (s *ReplayService) DeleteReplay(ctx context.Context, replayID, userID uuid.
 	filePath, err := s.replayRepo.Delete(ctx, replayID, userID)
 	if err != nil {
 		s.logger.Error("replay not found", slog.String("error", err.Error()))
+		if errors.Is(err, repository.ErrNotFound) {
+			return replayAccessError(ctx, s.replayRepo, replayID, userID, models.RoleOwner, notFoundError("replay", err))
+		}
 		return notFoundError("replay", err)
 	}
 
-	if err := s.storage.DeleteFile(filePath); err != nil {
-		s.logger.Warn("failed to delete file", slog.String("error", err.Error()))
-	}
+	releaseFile(ctx, s.replayRepo, s.storage, s.logger, filePath)
 
 	s.logger.Info("replay deleted")
 	return nil
 }
 
-func (s *ReplayService) GetReplayFilePath(ctx context.Context, replayID, userID uuid.UUID) (string, string, error) {
+// OpenReplayFile открывает файл реплея для скачивания
+// Сжатый файл отдается как есть, если его алгоритм есть в acceptEncodings, иначе распаковывается на лету
+func (s *ReplayService) OpenReplayFile(ctx context.Context, replayID, userID uuid.UUID, acceptEncodings []string) (*ReplayFile, error) {
 	replay, err := s.GetReplay(ctx, replayID, userID)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+
+	content, info, err := openStoredFile(ctx, s.storage, s.keyring, replay)
+	if err != nil {
+		s.logger.Error("failed to open replay file",
+			slog.String("replay_id", replayID.String()),
+			slog.String("error", err.Error()))
+		return nil, wrapError("open replay file", err)
+	}
+
+	if s.verifyDownloads && replay.SHA256 != "" {
+		if err := s.verifyContent(replay, content); err != nil {
+			content.Close()
+			return nil, err
+		}
+	}
+
+	replayFile := &ReplayFile{
+		Replay:  replay,
+		Content: content,
+		Size:    info.Size,
+		ModTime: info.ModTime,
+	}
+
+	if !replay.Compressed {
+		return replayFile, nil
+	}
+
+	if slices.Contains(acceptEncodings, replay.Compression) {
+		replayFile.Encoding = replay.Compression
+		return replayFile, nil
+	}
+
+	decoded, err := compression.NewReader(replay.Compression, content)
+	if err != nil {
+		content.Close()
+		s.logger.Error("failed to decompress replay file",
+			slog.String("replay_id", replayID.String()),
+			slog.String("error", err.Error()))
+		return nil, wrapError("decompress replay file", err)
+	}
+
+	replayFile.Content = decoded
+	replayFile.Size = replay.SizeBytes
+	return replayFile, nil
+}
+
+// verifyContent сверяет хеш содержимого с сохраненным и возвращает content в начало
+func (s *ReplayService) verifyContent(replay *models.Replay, content io.ReadSeeker) error {
+	sum, err := hashReplayContent(replay, content)
+	if err != nil {
+		s.logger.Error("failed to hash replay file",
+			slog.String("replay_id", replay.ID.String()),
+			slog.String("error", err.Error()))
+		return wrapError("verify replay file", err)
+	}
+
+	if sum != replay.SHA256 {
+		s.logger.Error("replay file checksum mismatch",
+			slog.String("replay_id", replay.ID.String()),
+			slog.String("expected", replay.SHA256),
+			slog.String("actual", sum))
+		return wrapError("verify replay file", ErrChecksumMismatch)
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return wrapError("rewind replay file", err)
+	}
+	return nil
+}
+
+// openStoredFile открывает файл реплея в хранилище, расшифровывая его, если у blob есть ключ данных
+// info.Size - размер расшифрованного (но, возможно, сжатого) содержимого
+func openStoredFile(ctx context.Context, files FileStorageInterface, keyring *encryption.Keyring, replay *models.Replay) (io.ReadSeekCloser, storage.ObjectInfo, error) {
+	content, info, err := files.Open(ctx, replay.FilePath)
+	if err != nil || replay.WrappedKey == nil {
+		return content, info, err
+	}
+
+	if keyring == nil {
+		content.Close()
+		return nil, info, ErrEncryptionDisabled
+	}
+
+	dataKey, err := keyring.Unwrap(replay.KeyID, replay.WrappedKey)
+	if err != nil {
+		content.Close()
+		return nil, info, err
 	}
 
-	fullPath := s.storage.GetFilePath(replay.FilePath)
-	ext := filepath.Ext(replay.OriginalName)
+	decrypted, err := encryption.NewReader(dataKey, content, info.Size)
+	if err != nil {
+		content.Close()
+		return nil, info, err
+	}
 
-	return fullPath, ext, nil
+	info.Size = decrypted.Size()
+	return decrypted, info, nil
+}
+
+// releaseFile удаляет файл, если на него не осталось ссылок; ошибка только логируется,
+// неудаленный файл позже найдет сверка хранилища
+func releaseFile(ctx context.Context, repo FileReleaserInterface, blobs FileStorageInterface, logger *slog.Logger, filePath string) {
+	_, err := repo.ReleaseFile(ctx, filePath, func(filePath string) error {
+		return blobs.Delete(ctx, filePath)
+	})
+	if err != nil {
+		logger.Warn("failed to release file",
+			slog.String("file_path", filePath),
+			slog.String("error", err.Error()))
+	}
 }
 
 func stringPtr(s string) *string {
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/replayparser"
+)
+
+// ErrInvalidFilter - фильтр списка реплеев ссылается на неизвестное поле
+var ErrInvalidFilter = errors.New("invalid replay filter")
+
+// extensionPattern - расширение в фильтре: точка и буквы, цифры, _ или - в нижнем регистре
+var extensionPattern = regexp.MustCompile(`^\.[a-z0-9_-]{1,20}$`)
+
+// metadataFilterFields - строковые поля метаданных, по которым можно фильтровать; кроме них - extra.<поле>
+var metadataFilterFields = []string{"game", "map", "game_version", "winner", "player"}
+
+// WithParsers включает извлечение метаданных игровых реплеев парсерами registry
+func WithParsers(registry *replayparser.Registry) ReplayOption {
+	return func(s *ReplayService) {
+		s.parsers = registry
+	}
+}
+
+func validateFilter(filter models.ReplayFilter) error {
+	if filter.UploadedFrom != nil && filter.UploadedTo != nil && !filter.UploadedFrom.Before(*filter.UploadedTo) {
+		return fmt.Errorf("%w: uploaded_from must be before uploaded_to", ErrInvalidFilter)
+	}
+	if (filter.MinSize != nil && *filter.MinSize < 0) || (filter.MaxSize != nil && *filter.MaxSize < 0) {
+		return fmt.Errorf("%w: size must not be negative", ErrInvalidFilter)
+	}
+	if filter.MinSize != nil && filter.MaxSize != nil && *filter.MinSize > *filter.MaxSize {
+		return fmt.Errorf("%w: min_size is greater than max_size", ErrInvalidFilter)
+	}
+	for _, ext := range filter.Extensions {
+		if !extensionPattern.MatchString(ext) {
+			return fmt.Errorf("%w: invalid extension %q", ErrInvalidFilter, ext)
+		}
+	}
+	for key := range filter.Metadata {
+		if extra, ok := strings.CutPrefix(key, "extra."); ok && extra != "" {
+			continue
+		}
+		if !slices.Contains(metadataFilterFields, key) {
+			return fmt.Errorf("%w: unknown metadata field %q", ErrInvalidFilter, key)
+		}
+	}
+	return nil
+}
+
+// parseMetadata разбирает уже записанный файл парсером его формата
+// Ошибка разбора не мешает загрузке: метаданные останутся пустыми
+func (s *ReplayService) parseMetadata(ctx context.Context, replay *models.Replay, key string, parser replayparser.Parser) {
+	staged := *replay
+	staged.FilePath = key
+
+	logger := s.logger.With(
+		slog.String("replay_id", replay.ID.String()),
+		slog.String("parser", parser.Name()))
+
+	src, err := openStoredContent(ctx, s.storage, s.keyring, &staged)
+	if err != nil {
+		logger.Warn("failed to open file for metadata", slog.String("error", err.Error()))
+		return
+	}
+	defer src.Close()
+
+	meta, err := parser.Parse(src)
+	if err != nil {
+		logger.Warn("failed to parse replay metadata", slog.String("error", err.Error()))
+		return
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		logger.Warn("failed to encode replay metadata", slog.String("error", err.Error()))
+		return
+	}
+	replay.Metadata = data
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/replayparser"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateReplay_ParsesMetadata проверяет, что метаданные парсера сохраняются вместе с реплеем
+func TestCreateReplay_ParsesMetadata(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger,
+		WithParsers(replayparser.NewRegistry(replayparser.Fixture{})))
+
+	content := []byte("REPLAY-FIXTURE 1\nmap: Arena\nplayer: alice 1\nwinner: alice\n")
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(len(content))).Return(nil)
+	mockStorage.On("Open", mock.Anything, stagingKey).Return(nopSeekCloser{bytes.NewReader(content)}, storage.ObjectInfo{Size: int64(len(content))}, nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.MatchedBy(func(r *models.Replay) bool {
+		return r.Metadata != nil
+	}), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+
+	file := newTestFileHeader(t, "match.txt", content)
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), uuid.New(), "", "")
+	require.NoError(t, err)
+
+	var meta replayparser.Metadata
+	require.NoError(t, json.Unmarshal(replay.Metadata, &meta))
+	assert.Equal(t, "Arena", meta.Map)
+	assert.Equal(t, "alice", meta.Winner)
+	assert.Equal(t, []replayparser.Player{{Name: "alice", Team: 1}}, meta.Players)
+	mockReplayRepo.AssertExpectations(t)
+}
+
+// TestGetGameReplays_InvalidFilter проверяет отказ на неизвестное поле метаданных
+func TestGetGameReplays_InvalidFilter(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, new(MockFileStorage), logger)
+
+	filter := models.ReplayFilter{Metadata: map[string]string{"players": "alice"}}
+	newest := models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+	_, err := service.GetGameReplays(context.Background(), uuid.New(), uuid.New(), filter, newest, "", 5)
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+
+	filter = models.ReplayFilter{Metadata: map[string]string{"player": "alice", "extra.mode": "ranked"}}
+	mockReplayRepo.On("GetByGameID", mock.Anything, mock.Anything, mock.Anything, filter, newest, (*models.Cursor)(nil), 6).Return([]models.Replay{}, 0, nil)
+	_, err = service.GetGameReplays(context.Background(), uuid.New(), uuid.New(), filter, newest, "", 5)
+	assert.NoError(t, err)
+	mockReplayRepo.AssertExpectations(t)
+}
@@ -1,21 +1,54 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"mime/multipart"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/fckoffmw/replay-service/server/internal/compression"
+	"github.com/fckoffmw/replay-service/server/internal/encryption"
 	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Используем те же моки, что и в game_test.go
 
+// newTestFileHeader создает multipart.FileHeader с реальным содержимым
+// Зачем: сервис открывает загруженный файл и передает поток в хранилище
+func newTestFileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(int64(len(content)) + 1024)
+	require.NoError(t, err)
+	return form.File["file"][0]
+}
+
+// stagingKey проверяет, что новый файл сначала пишется под staging/{replay_id}
+var stagingKey = mock.MatchedBy(func(key string) bool {
+	return strings.HasPrefix(key, "staging/")
+})
+
 // TestGetGameReplays_Success проверяет получение списка реплеев
 func TestGetGameReplays_Success(t *testing.T) {
 	mockReplayRepo := new(MockReplayRepository)
@@ -33,13 +66,15 @@ func TestGetGameReplays_Success(t *testing.T) {
 		{ID: uuid.New(), OriginalName: "replay2.rep", GameID: gameID},
 	}
 	
-	mockReplayRepo.On("GetByGameID", mock.Anything, gameID, userID, limit).Return(expectedReplays, nil)
+	newest := models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+	mockReplayRepo.On("GetByGameID", mock.Anything, gameID, userID, models.ReplayFilter{}, newest, (*models.Cursor)(nil), limit+1).Return(expectedReplays, 2, nil)
 	
-	replays, err := service.GetGameReplays(context.Background(), gameID, userID, limit)
+	page, err := service.GetGameReplays(context.Background(), gameID, userID, models.ReplayFilter{}, newest, "", limit)
 	
 	assert.NoError(t, err)
-	assert.Equal(t, 2, len(replays))
-	assert.Equal(t, expectedReplays, replays)
+	assert.Equal(t, 2, len(page.Replays))
+	assert.Equal(t, expectedReplays, page.Replays)
+	assert.Equal(t, 2, page.Total)
 	
 	mockReplayRepo.AssertExpectations(t)
 }
@@ -106,18 +141,15 @@ func TestCreateReplay_Success(t *testing.T) {
 	gameID := uuid.New()
 	userID := uuid.New()
 	
-	file := &multipart.FileHeader{
-		Filename: "test_replay.rep",
-		Size:     1024,
-	}
+	file := newTestFileHeader(t, "test_replay.rep", bytes.Repeat([]byte("r"), 1024))
 	
 	title := "Epic Game"
 	comment := "Best game ever"
-	filePath := "user/game/replay.rep"
 	
 	// Настраиваем моки: сначала сохраняется файл, потом создается запись в БД
-	mockStorage.On("SaveReplayFile", file, userID, gameID, mock.AnythingOfType("uuid.UUID")).Return(filePath, nil)
-	mockReplayRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Replay")).Return(nil)
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(1024)).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
 	
 	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, title, comment)
 	
@@ -127,7 +159,7 @@ func TestCreateReplay_Success(t *testing.T) {
 	assert.Equal(t, int64(1024), replay.SizeBytes)
 	assert.Equal(t, title, *replay.Title)
 	assert.Equal(t, comment, *replay.Comment)
-	assert.Equal(t, filePath, replay.FilePath)
+	assert.Equal(t, storage.BlobKey(replay.SHA256, "none"), replay.FilePath, "файл хранится по хешу содержимого")
 	assert.Equal(t, "none", replay.Compression)
 	assert.False(t, replay.Compressed)
 	
@@ -147,13 +179,11 @@ func TestCreateReplay_StorageError(t *testing.T) {
 	gameID := uuid.New()
 	userID := uuid.New()
 	
-	file := &multipart.FileHeader{
-		Filename: "test_replay.rep",
-		Size:     1024,
-	}
+	file := newTestFileHeader(t, "test_replay.rep", bytes.Repeat([]byte("r"), 1024))
 	
-	// Настраиваем мок: SaveReplayFile возвращает ошибку
-	mockStorage.On("SaveReplayFile", file, userID, gameID, mock.AnythingOfType("uuid.UUID")).Return("", errors.New("disk full"))
+	// Настраиваем мок: Put возвращает ошибку
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(1024)).Return(errors.New("disk full"))
+	mockStorage.On("Delete", mock.Anything, stagingKey).Return(nil)
 	
 	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, "", "")
 	
@@ -162,7 +192,7 @@ func TestCreateReplay_StorageError(t *testing.T) {
 	assert.Contains(t, err.Error(), "save file")
 	
 	// Проверяем, что Create НЕ был вызван (запись в БД не создается при ошибке файла)
-	mockReplayRepo.AssertNotCalled(t, "Create")
+	mockReplayRepo.AssertNotCalled(t, "CreateWithBlob")
 	
 	mockStorage.AssertExpectations(t)
 }
@@ -179,18 +209,13 @@ func TestCreateReplay_DatabaseError(t *testing.T) {
 	gameID := uuid.New()
 	userID := uuid.New()
 	
-	file := &multipart.FileHeader{
-		Filename: "test_replay.rep",
-		Size:     1024,
-	}
-	
-	filePath := "user/game/replay.rep"
+	file := newTestFileHeader(t, "test_replay.rep", bytes.Repeat([]byte("r"), 1024))
 	
 	// Настраиваем моки: файл сохраняется, но БД возвращает ошибку
-	mockStorage.On("SaveReplayFile", file, userID, gameID, mock.AnythingOfType("uuid.UUID")).Return(filePath, nil)
-	mockReplayRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.Replay")).Return(errors.New("db constraint violation"))
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(1024)).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(false, errors.New("db constraint violation"))
 	// Важно: при ошибке БД файл должен быть удален
-	mockStorage.On("DeleteFile", filePath).Return(nil)
+	mockStorage.On("Delete", mock.Anything, stagingKey).Return(nil)
 	
 	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, "", "")
 	
@@ -198,8 +223,8 @@ func TestCreateReplay_DatabaseError(t *testing.T) {
 	assert.Nil(t, replay)
 	assert.Contains(t, err.Error(), "create replay")
 	
-	// Проверяем, что DeleteFile был вызван (rollback)
-	mockStorage.AssertCalled(t, "DeleteFile", filePath)
+	// Проверяем, что Delete был вызван (rollback)
+	mockStorage.AssertCalled(t, "Delete", mock.Anything, stagingKey)
 	
 	mockStorage.AssertExpectations(t)
 	mockReplayRepo.AssertExpectations(t)
@@ -240,7 +265,8 @@ func TestDeleteReplay_Success(t *testing.T) {
 	filePath := "user/game/replay.rep"
 	
 	mockReplayRepo.On("Delete", mock.Anything, replayID, userID).Return(filePath, nil)
-	mockStorage.On("DeleteFile", filePath).Return(nil)
+	mockReplayRepo.On("ReleaseFile", mock.Anything, filePath).Return(true, nil)
+	mockStorage.On("Delete", mock.Anything, filePath).Return(nil)
 	
 	err := service.DeleteReplay(context.Background(), replayID, userID)
 	
@@ -249,6 +275,27 @@ func TestDeleteReplay_Success(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
+// TestDeleteReplay_SharedFile проверяет, что файл, на который ссылаются другие реплеи, не удаляется
+func TestDeleteReplay_SharedFile(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+
+	replayID := uuid.New()
+	userID := uuid.New()
+	filePath := "blobs/ab/abcd"
+
+	mockReplayRepo.On("Delete", mock.Anything, replayID, userID).Return(filePath, nil)
+	mockReplayRepo.On("ReleaseFile", mock.Anything, filePath).Return(false, nil)
+
+	err := service.DeleteReplay(context.Background(), replayID, userID)
+
+	assert.NoError(t, err)
+	mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
 // TestDeleteReplay_NotFound проверяет удаление несуществующего реплея
 func TestDeleteReplay_NotFound(t *testing.T) {
 	mockReplayRepo := new(MockReplayRepository)
@@ -265,14 +312,14 @@ func TestDeleteReplay_NotFound(t *testing.T) {
 	err := service.DeleteReplay(context.Background(), replayID, userID)
 	
 	assert.Error(t, err)
-	// Проверяем, что DeleteFile НЕ был вызван (файл не удаляется, если реплей не найден)
-	mockStorage.AssertNotCalled(t, "DeleteFile")
+	// Проверяем, что Delete НЕ был вызван (файл не удаляется, если реплей не найден)
+	mockStorage.AssertNotCalled(t, "Delete")
 	
 	mockReplayRepo.AssertExpectations(t)
 }
 
-// TestGetReplayFilePath_Success проверяет получение пути к файлу
-func TestGetReplayFilePath_Success(t *testing.T) {
+// TestOpenReplayFile_Success проверяет открытие файла реплея через хранилище
+func TestOpenReplayFile_Success(t *testing.T) {
 	mockReplayRepo := new(MockReplayRepository)
 	mockStorage := new(MockFileStorage)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
@@ -287,16 +334,412 @@ func TestGetReplayFilePath_Success(t *testing.T) {
 		OriginalName: "game.rep",
 		FilePath:     "user/game/replay.rep",
 	}
+	content := nopSeekCloser{bytes.NewReader([]byte("replay data"))}
+	info := storage.ObjectInfo{Key: replay.FilePath, Size: 11, ModTime: time.Now()}
 	
 	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
-	mockStorage.On("GetFilePath", "user/game/replay.rep").Return("/full/path/user/game/replay.rep")
+	mockStorage.On("Open", mock.Anything, "user/game/replay.rep").Return(content, info, nil)
 	
-	fullPath, ext, err := service.GetReplayFilePath(context.Background(), replayID, userID)
+	replayFile, err := service.OpenReplayFile(context.Background(), replayID, userID, nil)
 	
 	assert.NoError(t, err)
-	assert.Equal(t, "/full/path/user/game/replay.rep", fullPath)
-	assert.Equal(t, ".rep", ext)
+	assert.Equal(t, replay, replayFile.Replay)
+	assert.Equal(t, int64(11), replayFile.Size)
+	assert.Equal(t, info.ModTime, replayFile.ModTime)
 	
 	mockReplayRepo.AssertExpectations(t)
 	mockStorage.AssertExpectations(t)
 }
+
+// TestOpenReplayFile_Missing проверяет ошибку, когда файла нет в хранилище
+func TestOpenReplayFile_Missing(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+	
+	replayID := uuid.New()
+	userID := uuid.New()
+	
+	replay := &models.Replay{ID: replayID, OriginalName: "game.rep", FilePath: "user/game/replay.rep"}
+	
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, "user/game/replay.rep").Return(nil, storage.ObjectInfo{}, storage.ErrNotFound)
+	
+	replayFile, err := service.OpenReplayFile(context.Background(), replayID, userID, nil)
+	
+	assert.Nil(t, replayFile)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+// TestCreateReplay_Compressed проверяет сжатие игрового реплея перед записью в хранилище
+func TestCreateReplay_Compressed(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	service := NewReplayService(mockReplayRepo, mockStorage, logger,
+		WithCompression(compression.NewPolicy(compression.Zstd, nil)))
+	
+	gameID := uuid.New()
+	userID := uuid.New()
+	content := bytes.Repeat([]byte("frame "), 4096)
+	file := newTestFileHeader(t, "match.dem", content)
+	
+	var stored []byte
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(-1)).
+		Run(func(args mock.Arguments) {
+			stored, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+	
+	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, "", "")
+	
+	require.NoError(t, err)
+	assert.Equal(t, "zstd", replay.Compression)
+	assert.True(t, replay.Compressed)
+	assert.Equal(t, int64(len(content)), replay.SizeBytes, "size_bytes хранит исходный размер")
+	assert.Less(t, len(stored), len(content))
+	sum := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), replay.SHA256, "хеш считается по исходным байтам")
+	
+	decoded, err := compression.NewReader(compression.Zstd, io.NopCloser(bytes.NewReader(stored)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+// TestCreateReplay_SkipsVideo проверяет, что видео не сжимается повторно
+func TestCreateReplay_SkipsVideo(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	service := NewReplayService(mockReplayRepo, mockStorage, logger,
+		WithCompression(compression.NewPolicy(compression.Gzip, nil)))
+	
+	gameID := uuid.New()
+	userID := uuid.New()
+	file := newTestFileHeader(t, "clip.mp4", []byte("video"))
+	
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(5)).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+	
+	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, "", "")
+	
+	require.NoError(t, err)
+	assert.Equal(t, "none", replay.Compression)
+	assert.False(t, replay.Compressed)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestCreateReplay_SkipsDetectedVideo проверяет, что видео не сжимается и под расширением реплея
+func TestCreateReplay_SkipsDetectedVideo(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger,
+		WithCompression(compression.NewPolicy(compression.Zstd, nil)))
+
+	file := newTestFileHeader(t, "match.dem", mp4Header)
+
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(len(mp4Header))).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+	mockStorage.On("Open", mock.Anything, stagingKey).
+		Return(nopSeekCloser{bytes.NewReader(mp4Header)}, storage.ObjectInfo{Size: int64(len(mp4Header))}, nil)
+
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), uuid.New(), "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, "video/mp4", replay.ContentType)
+	assert.Equal(t, compression.None, replay.Compression)
+	assert.False(t, replay.Compressed)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestCreateReplayFromStream_UploadLeaseLost проверяет, что реплей не создается из сессии с потерянной арендой
+func TestCreateReplayFromStream_UploadLeaseLost(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+
+	lease := &models.UploadLease{UploadID: uuid.New(), Token: uuid.New()}
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(6)).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.MatchedBy(func(r *models.Replay) bool { return r.Upload == lease }), mock.AnythingOfType("*models.Blob")).
+		Return(false, fmt.Errorf("lock upload lease: %w", repository.ErrNotFound))
+	mockStorage.On("Delete", mock.Anything, stagingKey).Return(nil)
+
+	_, err := service.CreateReplayFromStream(context.Background(), bytes.NewReader([]byte("frames")), "match.dem", 6,
+		uuid.New(), uuid.New(), "", "", lease)
+
+	assert.ErrorIs(t, err, ErrUploadLeaseLost)
+	mockStorage.AssertCalled(t, "Delete", mock.Anything, stagingKey)
+}
+
+// TestOpenReplayFile_Decompress проверяет распаковку на лету для клиента без нужной кодировки
+func TestOpenReplayFile_Decompress(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+	
+	replayID := uuid.New()
+	userID := uuid.New()
+	original := []byte("original replay data")
+	
+	replay := &models.Replay{
+		ID:           replayID,
+		OriginalName: "game.rep",
+		FilePath:     "user/game/replay.rep",
+		SizeBytes:    int64(len(original)),
+		Compression:  compression.Gzip,
+		Compressed:   true,
+	}
+	compressed := compressForTest(t, compression.Gzip, original)
+	info := storage.ObjectInfo{Key: replay.FilePath, Size: int64(len(compressed))}
+	
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).Return(nopSeekCloser{bytes.NewReader(compressed)}, info, nil)
+	
+	replayFile, err := service.OpenReplayFile(context.Background(), replayID, userID, []string{"zstd"})
+	require.NoError(t, err)
+	defer replayFile.Content.Close()
+	
+	data, err := io.ReadAll(replayFile.Content)
+	require.NoError(t, err)
+	assert.Equal(t, original, data)
+	assert.Equal(t, "", replayFile.Encoding)
+	assert.Equal(t, int64(len(original)), replayFile.Size)
+}
+
+// TestOpenReplayFile_PassThrough проверяет отдачу сжатых байтов, если клиент принимает кодировку
+func TestOpenReplayFile_PassThrough(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+	
+	replayID := uuid.New()
+	userID := uuid.New()
+	
+	replay := &models.Replay{
+		ID:          replayID,
+		FilePath:    "user/game/replay.rep",
+		SizeBytes:   100,
+		Compression: compression.Gzip,
+		Compressed:  true,
+	}
+	compressed := compressForTest(t, compression.Gzip, bytes.Repeat([]byte("a"), 100))
+	info := storage.ObjectInfo{Key: replay.FilePath, Size: int64(len(compressed))}
+	
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).Return(nopSeekCloser{bytes.NewReader(compressed)}, info, nil)
+	
+	replayFile, err := service.OpenReplayFile(context.Background(), replayID, userID, []string{"gzip"})
+	require.NoError(t, err)
+	
+	assert.Equal(t, "gzip", replayFile.Encoding)
+	assert.Equal(t, int64(len(compressed)), replayFile.Size)
+}
+
+// compressForTest сжимает данные указанным алгоритмом
+func compressForTest(t *testing.T, algorithm string, data []byte) []byte {
+	stream, err := compression.Compress(algorithm, bytes.NewReader(data))
+	require.NoError(t, err)
+	defer stream.Close()
+	
+	compressed, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	return compressed
+}
+
+// nopSeekCloser добавляет Close к io.ReadSeeker
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// TestOpenReplayFile_VerifyMismatch проверяет, что поврежденный файл не отдается при включенной проверке
+func TestOpenReplayFile_VerifyMismatch(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithDownloadVerification())
+
+	replayID := uuid.New()
+	userID := uuid.New()
+	replay := &models.Replay{ID: replayID, FilePath: "user/game/replay.rep", SHA256: sha256Hex([]byte("original"))}
+
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).Return(nopSeekCloser{bytes.NewReader([]byte("corrupted"))}, storage.ObjectInfo{}, nil)
+
+	_, err := service.OpenReplayFile(context.Background(), replayID, userID, nil)
+
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+// TestOpenReplayFile_VerifyRewinds проверяет, что после проверки файл читается с начала
+func TestOpenReplayFile_VerifyRewinds(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithDownloadVerification())
+
+	replayID := uuid.New()
+	userID := uuid.New()
+	content := []byte("original")
+	replay := &models.Replay{ID: replayID, FilePath: "user/game/replay.rep", SHA256: sha256Hex(content)}
+
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).Return(nopSeekCloser{bytes.NewReader(content)}, storage.ObjectInfo{Size: 8}, nil)
+
+	replayFile, err := service.OpenReplayFile(context.Background(), replayID, userID, nil)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(replayFile.Content)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+// TestCreateReplay_Deduplicated проверяет загрузку уже хранящегося содержимого
+// Что тестируем: реплей ссылается на существующий blob с его сжатием, временный файл удаляется
+func TestCreateReplay_Deduplicated(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+
+	content := []byte("same demo")
+	existingKey := storage.BlobKey(sha256Hex(content), "zstd")
+	file := newTestFileHeader(t, "copy.dem", content)
+
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(len(content))).
+		Run(func(args mock.Arguments) {
+			io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.MatchedBy(func(blob *models.Blob) bool {
+		return blob.SHA256 == sha256Hex(content) && blob.FilePath == storage.BlobKey(sha256Hex(content), "none")
+	})).Run(func(args mock.Arguments) {
+		blob := args.Get(2).(*models.Blob)
+		blob.FilePath, blob.Compression, blob.Compressed = existingKey, "zstd", true
+	}).Return(false, nil)
+	mockStorage.On("Delete", mock.Anything, stagingKey).Return(nil)
+
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), uuid.New(), "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, existingKey, replay.FilePath)
+	assert.Equal(t, "zstd", replay.Compression)
+	assert.True(t, replay.Compressed)
+	mockStorage.AssertNotCalled(t, "Move", mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertExpectations(t)
+}
+
+// newTestKeyring создает связку мастер-ключей со случайными ключами
+func newTestKeyring(t *testing.T, count int) *encryption.Keyring {
+	masterKeys := make([][]byte, count)
+	for i := range masterKeys {
+		key, err := encryption.NewDataKey()
+		require.NoError(t, err)
+		masterKeys[i] = key
+	}
+	keyring, err := encryption.NewKeyring(masterKeys...)
+	require.NoError(t, err)
+	return keyring
+}
+
+// TestCreateReplay_Encrypted проверяет, что в хранилище попадает шифротекст, который читается обратно
+func TestCreateReplay_Encrypted(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	keyring := newTestKeyring(t, 1)
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithEncryption(keyring))
+	
+	userID := uuid.New()
+	content := bytes.Repeat([]byte("secret frame "), 10000)
+	file := newTestFileHeader(t, "match.rep", content)
+	
+	var stored []byte
+	var blob *models.Blob
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, encryption.CiphertextSize(int64(len(content)))).
+		Run(func(args mock.Arguments) {
+			stored, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).
+		Run(func(args mock.Arguments) {
+			blob = args.Get(2).(*models.Blob)
+		}).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+	
+	replay, err := service.CreateReplay(context.Background(), file, uuid.New(), userID, "", "")
+	require.NoError(t, err)
+	
+	assert.False(t, bytes.Contains(stored, []byte("secret frame")), "файл не должен лежать открытым")
+	assert.Equal(t, keyring.ActiveKeyID(), blob.KeyID)
+	assert.Equal(t, int64(len(stored)), blob.SizeBytes)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), replay.SHA256, "хеш считается по открытым байтам")
+	
+	info := storage.ObjectInfo{Key: replay.FilePath, Size: int64(len(stored))}
+	mockReplayRepo.On("GetByID", mock.Anything, replay.ID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).Return(nopSeekCloser{bytes.NewReader(stored)}, info, nil)
+	
+	verifying := NewReplayService(mockReplayRepo, mockStorage, logger, WithEncryption(keyring), WithDownloadVerification())
+	replayFile, err := verifying.OpenReplayFile(context.Background(), replay.ID, userID, nil)
+	require.NoError(t, err)
+	defer replayFile.Content.Close()
+	
+	assert.Equal(t, int64(len(content)), replayFile.Size)
+	_, seekable := replayFile.Content.(io.ReadSeeker)
+	assert.True(t, seekable, "расшифрованный файл должен поддерживать Range")
+	data, err := io.ReadAll(replayFile.Content)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+// trackingCloser запоминает, что файл был закрыт
+type trackingCloser struct {
+	io.ReadSeeker
+	closed bool
+}
+
+func (c *trackingCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+// TestOpenReplayFile_EncryptionDisabled проверяет отказ читать зашифрованный файл без мастер-ключей
+func TestOpenReplayFile_EncryptionDisabled(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	
+	service := NewReplayService(mockReplayRepo, mockStorage, logger)
+	
+	replayID, userID := uuid.New(), uuid.New()
+	replay := &models.Replay{ID: replayID, FilePath: "blobs/ab/abc", KeyID: "deadbeef", WrappedKey: []byte("wrapped")}
+	content := &trackingCloser{ReadSeeker: bytes.NewReader([]byte("ciphertext"))}
+	
+	mockReplayRepo.On("GetByID", mock.Anything, replayID, userID).Return(replay, nil)
+	mockStorage.On("Open", mock.Anything, replay.FilePath).Return(content, storage.ObjectInfo{Size: 10}, nil)
+	
+	_, err := service.OpenReplayFile(context.Background(), replayID, userID, nil)
+	
+	assert.ErrorIs(t, err, ErrEncryptionDisabled)
+	assert.True(t, content.closed)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+const (
+	maxSearchQuery = 200
+	maxSearchLimit = 100
+)
+
+// SearchService ищет по реплеям пользователя во всех его играх
+type SearchService struct {
+	searchRepo SearchRepositoryInterface
+	logger     *slog.Logger
+}
+
+func NewSearchService(searchRepo SearchRepositoryInterface, logger *slog.Logger) *SearchService {
+	return &SearchService{
+		searchRepo: searchRepo,
+		logger:     logger,
+	}
+}
+
+// Search ищет q в названиях, описаниях, именах файлов, метаданных реплеев, названиях игр и комментариях
+// Синтаксис запроса - как у поисковиков: "точная фраза", OR, -исключение
+// cursor - next_cursor предыдущей страницы, "" - первая страница
+func (s *SearchService) Search(ctx context.Context, userID uuid.UUID, q, cursor string, limit int) (*models.SearchPage, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidSearchQuery)
+	}
+	if utf8.RuneCountInString(q) > maxSearchQuery {
+		return nil, fmt.Errorf("%w: q is longer than %d characters", ErrInvalidSearchQuery, maxSearchQuery)
+	}
+	after, err := decodeSearchCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(max(limit, 1), maxSearchLimit)
+
+	results, total, err := s.searchRepo.Search(ctx, userID, q, after, limit+1)
+	if err != nil {
+		s.logger.Error("failed to search replays", slog.String("error", err.Error()))
+		return nil, wrapError("search replays", err)
+	}
+
+	page := &models.SearchPage{Total: total}
+	page.Results, page.NextCursor = nextPage(results, limit, func(last *models.SearchResult) string {
+		return encodeSearchCursor(models.SearchCursor{Rank: last.Rank, UploadedAt: last.UploadedAt, ID: last.ID})
+	})
+	return page, nil
+}
+
+// encodeSearchCursor кодирует позицию в непрозрачную строку: ранг, время загрузки и id последнего результата
+func encodeSearchCursor(c models.SearchCursor) string {
+	return encodeCursorParts(strconv.FormatFloat(float64(c.Rank), 'g', -1, 32), c.UploadedAt.UTC().Format(time.RFC3339Nano), c.ID.String())
+}
+
+func decodeSearchCursor(cursor string) (*models.SearchCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	parts, ok := decodeCursorParts(cursor, 3)
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	var c models.SearchCursor
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c.Rank = float32(rank)
+	if c.UploadedAt, err = time.Parse(time.RFC3339Nano, parts[1]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID, err = uuid.Parse(parts[2]); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSearchRepository - мок для полнотекстового поиска
+type MockSearchRepository struct {
+	mock.Mock
+}
+
+func (m *MockSearchRepository) Search(ctx context.Context, userID uuid.UUID, q string, after *models.SearchCursor, limit int) ([]models.SearchResult, int, error) {
+	args := m.Called(ctx, userID, q, after, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.SearchResult), args.Int(1), args.Error(2)
+}
+
+// TestSearch_Pagination проверяет выдачу next_cursor и передачу позиции на следующую страницу
+func TestSearch_Pagination(t *testing.T) {
+	mockSearchRepo := new(MockSearchRepository)
+	service := NewSearchService(mockSearchRepo, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	userID := uuid.New()
+
+	uploadedAt := time.Date(2025, 11, 24, 14, 0, 0, 123456789, time.UTC)
+	results := []models.SearchResult{
+		{ID: uuid.New(), Rank: 0.6079271, UploadedAt: uploadedAt},
+		{ID: uuid.New(), Rank: 0.1, UploadedAt: uploadedAt},
+		{ID: uuid.New(), Rank: 0.05, UploadedAt: uploadedAt},
+	}
+	mockSearchRepo.On("Search", mock.Anything, userID, "alice inferno", (*models.SearchCursor)(nil), 3).Return(results, 5, nil)
+
+	page, err := service.Search(context.Background(), userID, "  alice inferno ", "", 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Results, 2)
+	assert.Equal(t, 5, page.Total)
+	require.NotEmpty(t, page.NextCursor)
+
+	after := &models.SearchCursor{Rank: 0.1, UploadedAt: uploadedAt, ID: results[1].ID}
+	mockSearchRepo.On("Search", mock.Anything, userID, "alice inferno", after, 3).Return(results[2:], 5, nil)
+
+	page, err = service.Search(context.Background(), userID, "alice inferno", page.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Results, 1)
+	assert.Empty(t, page.NextCursor)
+	mockSearchRepo.AssertExpectations(t)
+}
+
+// TestSearch_Invalid проверяет отказ на пустой запрос и испорченный курсор без обращения к БД
+func TestSearch_Invalid(t *testing.T) {
+	mockSearchRepo := new(MockSearchRepository)
+	service := NewSearchService(mockSearchRepo, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	_, err := service.Search(context.Background(), uuid.New(), "   ", "", 20)
+	assert.ErrorIs(t, err, ErrInvalidSearchQuery)
+
+	_, err = service.Search(context.Background(), uuid.New(), "alice", "bm90LWEtY3Vyc29y", 20)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	mockSearchRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrShareNotFound = errors.New("share link not found")
+	// ErrShareGone - ссылка отозвана, истекла или исчерпала лимит просмотров
+	ErrShareGone             = errors.New("share link is no longer available")
+	ErrSharePasswordRequired = errors.New("share link password required")
+	ErrInvalidSharePassword  = errors.New("invalid share link password")
+	ErrInvalidShare          = errors.New("invalid share link")
+	// ErrSharePasswordThrottled - слишком много неверных паролей к ссылке, проверка временно отключена
+	ErrSharePasswordThrottled = errors.New("too many share link password attempts")
+	// ErrInvalidShareView - ссылка на просмотр файла неверна или истекла; просмотр нужно начать заново
+	ErrInvalidShareView = errors.New("invalid or expired share view url")
+)
+
+const (
+	shareTokenBytes = 32
+	// bcrypt учитывает только первые 72 байта пароля
+	maxSharePassword = 72
+	// maxSharePasswordFailures неверных паролей за sharePasswordWindow блокируют проверку пароля ссылки
+	maxSharePasswordFailures = 5
+	sharePasswordWindow      = time.Minute
+)
+
+// ShareService выдает публичные ссылки на реплеи и игры и открывает их без входа в систему
+// Содержимое по ссылке читается от имени владельца ссылки
+type ShareService struct {
+	shareRepo ShareRepositoryInterface
+	replays   SharedReplayReaderInterface
+	signer    ShareViewSignerInterface
+	failures  *passwordFailures
+	logger    *slog.Logger
+}
+
+func NewShareService(shareRepo ShareRepositoryInterface, replays SharedReplayReaderInterface, signer ShareViewSignerInterface, logger *slog.Logger) *ShareService {
+	return &ShareService{
+		shareRepo: shareRepo,
+		replays:   replays,
+		signer:    signer,
+		failures:  newPasswordFailures(maxSharePasswordFailures, sharePasswordWindow),
+		logger:    logger,
+	}
+}
+
+// CreateReplayShare создает ссылку на реплей; токен ссылки возвращается только здесь
+func (s *ShareService) CreateReplayShare(ctx context.Context, replayID, userID uuid.UUID, opts models.ShareOptions) (*models.ShareLink, error) {
+	link, err := s.create(ctx, &models.ShareLink{UserID: userID, ReplayID: &replayID}, opts)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrReplayNotFound
+	}
+	return link, err
+}
+
+// CreateGameShare создает ссылку на игру со всеми ее реплеями, в том числе загруженными позже
+func (s *ShareService) CreateGameShare(ctx context.Context, gameID, userID uuid.UUID, opts models.ShareOptions) (*models.ShareLink, error) {
+	link, err := s.create(ctx, &models.ShareLink{UserID: userID, GameID: &gameID}, opts)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrGameNotFound
+	}
+	return link, err
+}
+
+func (s *ShareService) create(ctx context.Context, link *models.ShareLink, opts models.ShareOptions) (*models.ShareLink, error) {
+	s.logger.Info("creating share link", slog.String("user_id", link.UserID.String()))
+
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidShare)
+	}
+	if opts.MaxViews != nil && *opts.MaxViews < 1 {
+		return nil, fmt.Errorf("%w: max_views must be positive", ErrInvalidShare)
+	}
+	if len(opts.Password) > maxSharePassword {
+		return nil, fmt.Errorf("%w: password must be at most %d bytes", ErrInvalidShare, maxSharePassword)
+	}
+
+	if opts.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, wrapError("hash share password", err)
+		}
+		link.PasswordHash = string(hash)
+		link.HasPassword = true
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, wrapError("generate share token", err)
+	}
+	link.Token = token
+	link.TokenHash = hashShareToken(token)
+	link.ExpiresAt = opts.ExpiresAt
+	link.MaxViews = opts.MaxViews
+
+	if err := s.shareRepo.Create(ctx, link); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.Error("failed to create share link", slog.String("error", err.Error()))
+		}
+		return nil, wrapError("create share link", err)
+	}
+
+	s.logger.Info("share link created", slog.String("share_id", link.ID.String()))
+	return link, nil
+}
+
+// GetUserShares возвращает ссылки пользователя вместе с отозванными и истекшими
+func (s *ShareService) GetUserShares(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error) {
+	links, err := s.shareRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get share links", slog.String("error", err.Error()))
+		return nil, wrapError("get share links", err)
+	}
+	return links, nil
+}
+
+// RevokeShare отзывает ссылку: после этого она отвечает как истекшая
+func (s *ShareService) RevokeShare(ctx context.Context, shareID, userID uuid.UUID) error {
+	s.logger.Info("revoking share link",
+		slog.String("share_id", shareID.String()),
+		slog.String("user_id", userID.String()))
+
+	if err := s.shareRepo.Revoke(ctx, shareID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrShareNotFound
+		}
+		s.logger.Error("failed to revoke share link", slog.String("error", err.Error()))
+		return wrapError("revoke share link", err)
+	}
+	return nil
+}
+
+// OpenShare возвращает то, на что указывает ссылка; просмотр не засчитывается
+// Для ссылки на игру cursor и limit листают ее реплеи, новые первыми
+func (s *ShareService) OpenShare(ctx context.Context, token, password, cursor string, limit int) (*models.SharedContent, error) {
+	link, err := s.resolve(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+	if exhausted(link) {
+		return nil, ErrShareGone
+	}
+
+	content := &models.SharedContent{
+		ExpiresAt: link.ExpiresAt,
+		MaxViews:  link.MaxViews,
+		ViewCount: link.ViewCount,
+	}
+
+	if link.ReplayID != nil {
+		replay, err := s.replays.GetReplay(ctx, *link.ReplayID, link.UserID)
+		if err != nil {
+			return nil, sharedReplayError(err)
+		}
+		content.Replay = sharedReplay(replay)
+		return content, nil
+	}
+
+	newest := models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+	page, err := s.replays.GetGameReplays(ctx, *link.GameID, link.UserID, models.ReplayFilter{}, newest, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Replays {
+		page.Replays[i] = *sharedReplay(&page.Replays[i])
+	}
+	content.Game = &models.SharedGame{ID: *link.GameID, Name: link.GameName, ReplayPage: *page}
+	return content, nil
+}
+
+// StartSharedView засчитывает просмотр файла реплея по ссылке и подписывает ссылку filePath на этот файл
+// Пока подписанная ссылка действует, файл отдается без пароля и без новых просмотров: ее подставляют в <video>
+// replayID обязателен для ссылки на игру и должен указывать на реплей этой игры
+func (s *ShareService) StartSharedView(ctx context.Context, token, password string, replayID *uuid.UUID, filePath string) (*models.SignedURL, error) {
+	link, err := s.resolve(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+	if exhausted(link) {
+		return nil, ErrShareGone
+	}
+
+	target, err := s.sharedReplayID(ctx, link, replayID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordView(ctx, link); err != nil {
+		return nil, err
+	}
+
+	query, expiresAt := s.signer.SignShareView(link.ID, target)
+	s.logger.Info("started share view",
+		slog.String("share_id", link.ID.String()),
+		slog.String("replay_id", target.String()),
+		slog.Time("expires_at", expiresAt))
+	return &models.SignedURL{URL: filePath + "?" + query.Encode(), ExpiresAt: expiresAt}, nil
+}
+
+// OpenSharedFile открывает файл реплея по ссылке тем же путем, что и OpenReplayFile владельца
+// replayID обязателен для ссылки на игру и должен указывать на реплей этой игры
+// Запрос без подписанной ссылки просмотра каждый раз проверяет пароль и лимит просмотров;
+// countView засчитывает его как новый просмотр
+func (s *ShareService) OpenSharedFile(ctx context.Context, token, password string, replayID *uuid.UUID, acceptEncodings []string, countView bool) (*ReplayFile, error) {
+	link, err := s.resolve(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+	if exhausted(link) {
+		return nil, ErrShareGone
+	}
+
+	target, err := s.sharedReplayID(ctx, link, replayID)
+	if err != nil {
+		return nil, err
+	}
+
+	replayFile, err := s.openSharedFile(ctx, link, target, acceptEncodings)
+	if err != nil {
+		return nil, err
+	}
+	if !countView {
+		return replayFile, nil
+	}
+
+	if err := s.recordView(ctx, link); err != nil {
+		replayFile.Content.Close()
+		return nil, err
+	}
+	return replayFile, nil
+}
+
+// OpenSharedViewFile открывает файл по подписанной ссылке из StartSharedView
+// Просмотр уже засчитан, поэтому пароль и лимит не проверяются; отзыв и срок ссылки действуют сразу
+func (s *ShareService) OpenSharedViewFile(ctx context.Context, token string, replayID *uuid.UUID, view url.Values, acceptEncodings []string) (*ReplayFile, error) {
+	link, err := s.lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.sharedReplayID(ctx, link, replayID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.signer.VerifyShareView(link.ID, target, view); err != nil {
+		s.logger.Warn("invalid share view url",
+			slog.String("share_id", link.ID.String()),
+			slog.String("error", err.Error()))
+		return nil, ErrInvalidShareView
+	}
+
+	return s.openSharedFile(ctx, link, target, acceptEncodings)
+}
+
+func (s *ShareService) openSharedFile(ctx context.Context, link *models.ShareLink, replayID uuid.UUID, acceptEncodings []string) (*ReplayFile, error) {
+	replayFile, err := s.replays.OpenReplayFile(ctx, replayID, link.UserID, acceptEncodings)
+	if err != nil {
+		return nil, sharedReplayError(err)
+	}
+	replayFile.Replay = sharedReplay(replayFile.Replay)
+	return replayFile, nil
+}
+
+// recordView засчитывает просмотр; ErrShareGone - лимит исчерпан другим запросом после проверки
+func (s *ShareService) recordView(ctx context.Context, link *models.ShareLink) error {
+	if _, err := s.shareRepo.RecordView(ctx, link.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrShareGone
+		}
+		s.logger.Error("failed to record share view", slog.String("error", err.Error()))
+		return wrapError("record share view", err)
+	}
+	return nil
+}
+
+// resolve находит действующую ссылку по токену и проверяет пароль
+// После maxSharePasswordFailures неверных паролей ссылка до конца окна отвечает отказом без bcrypt
+func (s *ShareService) resolve(ctx context.Context, token, password string) (*models.ShareLink, error) {
+	link, err := s.lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if link.HasPassword {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if s.failures.blocked(link.ID) {
+			return nil, ErrSharePasswordThrottled
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			s.failures.add(link.ID)
+			s.logger.Warn("invalid share link password", slog.String("share_id", link.ID.String()))
+			return nil, ErrInvalidSharePassword
+		}
+	}
+	return link, nil
+}
+
+// lookup находит ссылку по токену и проверяет, что она не отозвана и не истекла
+func (s *ShareService) lookup(ctx context.Context, token string) (*models.ShareLink, error) {
+	link, err := s.shareRepo.GetByTokenHash(ctx, hashShareToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrShareNotFound
+		}
+		s.logger.Error("failed to get share link", slog.String("error", err.Error()))
+		return nil, wrapError("get share link", err)
+	}
+
+	if link.RevokedAt != nil || link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return nil, ErrShareGone
+	}
+	return link, nil
+}
+
+// sharedReplayID выбирает реплей, файл которого запрошен по ссылке
+func (s *ShareService) sharedReplayID(ctx context.Context, link *models.ShareLink, replayID *uuid.UUID) (uuid.UUID, error) {
+	if link.ReplayID != nil {
+		if replayID != nil && *replayID != *link.ReplayID {
+			return uuid.Nil, ErrReplayNotFound
+		}
+		return *link.ReplayID, nil
+	}
+
+	if replayID == nil {
+		return uuid.Nil, ErrReplayNotFound
+	}
+	replay, err := s.replays.GetReplay(ctx, *replayID, link.UserID)
+	if err != nil {
+		return uuid.Nil, sharedReplayError(err)
+	}
+	if replay.GameID != *link.GameID {
+		return uuid.Nil, ErrReplayNotFound
+	}
+	return replay.ID, nil
+}
+
+// exhausted - ссылка исчерпала лимит просмотров
+func exhausted(link *models.ShareLink) bool {
+	return link.MaxViews != nil && link.ViewCount >= *link.MaxViews
+}
+
+// sharedReplay - реплей для получателя ссылки: без тегов и роли владельца
+func sharedReplay(replay *models.Replay) *models.Replay {
+	shared := *replay
+	shared.Tags = nil
+	shared.Role = ""
+	return &shared
+}
+
+func sharedReplayError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrReplayNotFound
+	}
+	return err
+}
+
+// newShareToken - 256 случайных бит в base64url без паддинга
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// passwordFailures считает неверные пароли к каждой ссылке в фиксированном окне
+// Счетчики живут в памяти процесса: окно короткое, и после перезапуска их можно начать заново
+type passwordFailures struct {
+	mu     sync.Mutex
+	max    int
+	window time.Duration
+	now    func() time.Time
+	links  map[uuid.UUID]*failureWindow
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+func newPasswordFailures(max int, window time.Duration) *passwordFailures {
+	return &passwordFailures{
+		max:    max,
+		window: window,
+		now:    time.Now,
+		links:  make(map[uuid.UUID]*failureWindow),
+	}
+}
+
+// blocked - лимит неверных паролей в текущем окне исчерпан
+func (f *passwordFailures) blocked(shareID uuid.UUID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, ok := f.links[shareID]
+	return ok && f.now().Sub(w.start) < f.window && w.count >= f.max
+}
+
+func (f *passwordFailures) add(shareID uuid.UUID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	// Истекшие окна удаляются здесь же, чтобы карта не росла от давних ошибок
+	for id, w := range f.links {
+		if now.Sub(w.start) >= f.window {
+			delete(f.links, id)
+		}
+	}
+
+	w, ok := f.links[shareID]
+	if !ok {
+		w = &failureWindow{start: now}
+		f.links[shareID] = w
+	}
+	w.count++
+}
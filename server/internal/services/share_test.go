@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/signedurl"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MockShareRepository - мок для публичных ссылок
+type MockShareRepository struct {
+	mock.Mock
+}
+
+func (m *MockShareRepository) Create(ctx context.Context, link *models.ShareLink) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *MockShareRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ShareLink), args.Error(1)
+}
+
+func (m *MockShareRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ShareLink), args.Error(1)
+}
+
+func (m *MockShareRepository) RecordView(ctx context.Context, shareID uuid.UUID) (int, error) {
+	args := m.Called(ctx, shareID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockShareRepository) Revoke(ctx context.Context, shareID, userID uuid.UUID) error {
+	args := m.Called(ctx, shareID, userID)
+	return args.Error(0)
+}
+
+// MockSharedReplayReader - мок чтения реплеев владельца ссылки
+type MockSharedReplayReader struct {
+	mock.Mock
+}
+
+func (m *MockSharedReplayReader) GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
+	args := m.Called(ctx, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Replay), args.Error(1)
+}
+
+func (m *MockSharedReplayReader) GetGameReplays(ctx context.Context, gameID, userID uuid.UUID, filter models.ReplayFilter, sort models.Sort, cursor string, limit int) (*models.ReplayPage, error) {
+	args := m.Called(ctx, gameID, userID, filter, sort, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ReplayPage), args.Error(1)
+}
+
+func (m *MockSharedReplayReader) OpenReplayFile(ctx context.Context, replayID, userID uuid.UUID, acceptEncodings []string) (*ReplayFile, error) {
+	args := m.Called(ctx, replayID, userID, acceptEncodings)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ReplayFile), args.Error(1)
+}
+
+const testShareToken = "share-token"
+
+func newTestShareService(repo *MockShareRepository, replays *MockSharedReplayReader) *ShareService {
+	return NewShareService(repo, replays, signedurl.NewSigner("secret", 5*time.Minute), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// TestCreateReplayShare_StoresHashes проверяет, что в БД уходят хеши токена и пароля, а токен возвращается
+func TestCreateReplayShare_StoresHashes(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	service := newTestShareService(mockShareRepo, new(MockSharedReplayReader))
+	userID, replayID := uuid.New(), uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+
+	var stored *models.ShareLink
+	mockShareRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.ShareLink")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.ShareLink) }).
+		Return(nil)
+
+	link, err := service.CreateReplayShare(context.Background(), replayID, userID, models.ShareOptions{
+		ExpiresAt: &expiresAt,
+		MaxViews:  intPtr(5),
+		Password:  "hunter2",
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, link.Token, 43)
+	assert.Equal(t, hashShareToken(link.Token), stored.TokenHash)
+	assert.NotContains(t, stored.TokenHash, link.Token)
+	assert.Equal(t, &replayID, stored.ReplayID)
+	assert.Nil(t, stored.GameID)
+	assert.True(t, stored.HasPassword)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte("hunter2")))
+	assert.Equal(t, 5, *stored.MaxViews)
+}
+
+// TestCreateShare_Invalid проверяет отказ на прошедший срок, нулевой лимит, длинный пароль и чужую игру
+func TestCreateShare_Invalid(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	service := newTestShareService(mockShareRepo, new(MockSharedReplayReader))
+	userID, gameID := uuid.New(), uuid.New()
+	past := time.Now().Add(-time.Minute)
+
+	_, err := service.CreateGameShare(context.Background(), gameID, userID, models.ShareOptions{ExpiresAt: &past})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+
+	_, err = service.CreateGameShare(context.Background(), gameID, userID, models.ShareOptions{MaxViews: intPtr(0)})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+
+	_, err = service.CreateGameShare(context.Background(), gameID, userID, models.ShareOptions{Password: strings.Repeat("x", 73)})
+	assert.ErrorIs(t, err, ErrInvalidShare)
+
+	mockShareRepo.On("Create", mock.Anything, mock.Anything).
+		Return(fmt.Errorf("failed to create share link: %w", repository.ErrNotFound))
+	_, err = service.CreateGameShare(context.Background(), gameID, userID, models.ShareOptions{})
+	assert.ErrorIs(t, err, ErrGameNotFound)
+}
+
+// TestOpenShare_Unavailable проверяет ответы на неизвестную, отозванную, истекшую и исчерпанную ссылку
+func TestOpenShare_Unavailable(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name string
+		link *models.ShareLink
+		err  error
+		want error
+	}{
+		{name: "unknown", err: fmt.Errorf("failed to get share link: %w", repository.ErrNotFound), want: ErrShareNotFound},
+		{name: "revoked", link: &models.ShareLink{RevokedAt: &past}, want: ErrShareGone},
+		{name: "expired", link: &models.ShareLink{ExpiresAt: &past}, want: ErrShareGone},
+		{name: "exhausted", link: &models.ShareLink{MaxViews: intPtr(2), ViewCount: 2}, want: ErrShareGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockShareRepo := new(MockShareRepository)
+			service := newTestShareService(mockShareRepo, new(MockSharedReplayReader))
+			if tt.link != nil {
+				mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(tt.link, nil)
+			} else {
+				mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(nil, tt.err)
+			}
+
+			_, err := service.OpenShare(context.Background(), testShareToken, "", "", 20)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+// TestOpenShare_Password проверяет, что ссылка с паролем открывается только с верным паролем
+func TestOpenShare_Password(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	mockReplays := new(MockSharedReplayReader)
+	service := newTestShareService(mockShareRepo, mockReplays)
+	ownerID, replayID := uuid.New(), uuid.New()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+	link := &models.ShareLink{UserID: ownerID, ReplayID: &replayID, PasswordHash: string(hash), HasPassword: true}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+	mockReplays.On("GetReplay", mock.Anything, replayID, ownerID).
+		Return(&models.Replay{ID: replayID, OriginalName: "match.dem", Tags: []string{"smurf"}}, nil)
+
+	_, err = service.OpenShare(context.Background(), testShareToken, "", "", 20)
+	assert.ErrorIs(t, err, ErrSharePasswordRequired)
+
+	_, err = service.OpenShare(context.Background(), testShareToken, "wrong", "", 20)
+	assert.ErrorIs(t, err, ErrInvalidSharePassword)
+
+	content, err := service.OpenShare(context.Background(), testShareToken, "hunter2", "", 20)
+	require.NoError(t, err)
+	assert.Equal(t, "match.dem", content.Replay.OriginalName)
+	assert.Nil(t, content.Replay.Tags)
+	assert.Nil(t, content.Game)
+}
+
+// TestOpenShare_Game проверяет, что ссылка на игру отдает страницу ее реплеев от имени владельца
+func TestOpenShare_Game(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	mockReplays := new(MockSharedReplayReader)
+	service := newTestShareService(mockShareRepo, mockReplays)
+	ownerID, gameID := uuid.New(), uuid.New()
+
+	link := &models.ShareLink{UserID: ownerID, GameID: &gameID, GameName: "Dota 2"}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+	newest := models.Sort{Field: models.ReplaySortUploadedAt, Desc: true}
+	mockReplays.On("GetGameReplays", mock.Anything, gameID, ownerID, models.ReplayFilter{}, newest, "", 10).
+		Return(&models.ReplayPage{Replays: []models.Replay{{ID: uuid.New()}}, Total: 3, NextCursor: "next"}, nil)
+
+	content, err := service.OpenShare(context.Background(), testShareToken, "", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, "Dota 2", content.Game.Name)
+	assert.Len(t, content.Game.Replays, 1)
+	assert.Equal(t, 3, content.Game.Total)
+	assert.Equal(t, "next", content.Game.NextCursor)
+}
+
+// TestOpenShare_PasswordThrottled проверяет, что после серии неверных паролей ссылка не проверяет даже верный
+func TestOpenShare_PasswordThrottled(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	service := newTestShareService(mockShareRepo, new(MockSharedReplayReader))
+	replayID := uuid.New()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+	link := &models.ShareLink{ID: uuid.New(), ReplayID: &replayID, PasswordHash: string(hash), HasPassword: true}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+
+	for i := 0; i < maxSharePasswordFailures; i++ {
+		_, err = service.OpenShare(context.Background(), testShareToken, "wrong", "", 20)
+		assert.ErrorIs(t, err, ErrInvalidSharePassword)
+	}
+	_, err = service.OpenShare(context.Background(), testShareToken, "hunter2", "", 20)
+	assert.ErrorIs(t, err, ErrSharePasswordThrottled)
+
+	// Окно закончилось - пароль снова проверяется
+	service.failures.now = func() time.Time { return time.Now().Add(sharePasswordWindow) }
+	_, err = service.OpenShare(context.Background(), testShareToken, "wrong", "", 20)
+	assert.ErrorIs(t, err, ErrInvalidSharePassword)
+}
+
+// TestOpenSharedFile_CountsView проверяет, что GET без ссылки просмотра засчитывается,
+// а после исчерпания лимита отказ получает любой запрос файла
+func TestOpenSharedFile_CountsView(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	mockReplays := new(MockSharedReplayReader)
+	service := newTestShareService(mockShareRepo, mockReplays)
+	ownerID, replayID, shareID := uuid.New(), uuid.New(), uuid.New()
+
+	link := &models.ShareLink{ID: shareID, UserID: ownerID, ReplayID: &replayID, MaxViews: intPtr(1)}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+	mockReplays.On("OpenReplayFile", mock.Anything, replayID, ownerID, []string(nil)).
+		Return(&ReplayFile{Replay: &models.Replay{ID: replayID}, Content: io.NopCloser(strings.NewReader("data"))}, nil)
+	mockShareRepo.On("RecordView", mock.Anything, shareID).Return(1, nil).Once()
+
+	_, err := service.OpenSharedFile(context.Background(), testShareToken, "", nil, nil, true)
+	require.NoError(t, err)
+
+	link.ViewCount = 1
+	_, err = service.OpenSharedFile(context.Background(), testShareToken, "", nil, nil, false)
+	assert.ErrorIs(t, err, ErrShareGone)
+
+	_, err = service.OpenSharedFile(context.Background(), testShareToken, "", nil, nil, true)
+	assert.ErrorIs(t, err, ErrShareGone)
+	mockShareRepo.AssertNumberOfCalls(t, "RecordView", 1)
+}
+
+// TestStartSharedView проверяет, что ссылка просмотра засчитывает один просмотр и открывает файл
+// без пароля и лимита, пока действует, но не открывает другой реплей и отозванную ссылку
+func TestStartSharedView(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	mockReplays := new(MockSharedReplayReader)
+	service := newTestShareService(mockShareRepo, mockReplays)
+	ownerID, gameID, shareID := uuid.New(), uuid.New(), uuid.New()
+	ownReplay, siblingReplay := uuid.New(), uuid.New()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+	link := &models.ShareLink{ID: shareID, UserID: ownerID, GameID: &gameID, MaxViews: intPtr(1),
+		PasswordHash: string(hash), HasPassword: true}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+	mockReplays.On("GetReplay", mock.Anything, ownReplay, ownerID).Return(&models.Replay{ID: ownReplay, GameID: gameID}, nil)
+	mockReplays.On("GetReplay", mock.Anything, siblingReplay, ownerID).Return(&models.Replay{ID: siblingReplay, GameID: gameID}, nil)
+	mockReplays.On("OpenReplayFile", mock.Anything, ownReplay, ownerID, []string(nil)).
+		Return(&ReplayFile{Replay: &models.Replay{ID: ownReplay}, Content: io.NopCloser(strings.NewReader("data"))}, nil)
+	mockShareRepo.On("RecordView", mock.Anything, shareID).Return(1, nil).Once()
+
+	_, err = service.StartSharedView(context.Background(), testShareToken, "", &ownReplay, "/s/tok/file")
+	assert.ErrorIs(t, err, ErrSharePasswordRequired)
+
+	signed, err := service.StartSharedView(context.Background(), testShareToken, "hunter2", &ownReplay, "/s/tok/file")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed.URL, "/s/tok/file?"))
+	view, err := url.ParseQuery(strings.SplitN(signed.URL, "?", 2)[1])
+	require.NoError(t, err)
+
+	// Лимит исчерпан, но засчитанный просмотр продолжается
+	link.ViewCount = 1
+	_, err = service.OpenSharedViewFile(context.Background(), testShareToken, &ownReplay, view, nil)
+	require.NoError(t, err)
+
+	_, err = service.StartSharedView(context.Background(), testShareToken, "hunter2", &ownReplay, "/s/tok/file")
+	assert.ErrorIs(t, err, ErrShareGone)
+
+	_, err = service.OpenSharedViewFile(context.Background(), testShareToken, &siblingReplay, view, nil)
+	assert.ErrorIs(t, err, ErrInvalidShareView)
+
+	revokedAt := time.Now()
+	link.RevokedAt = &revokedAt
+	_, err = service.OpenSharedViewFile(context.Background(), testShareToken, &ownReplay, view, nil)
+	assert.ErrorIs(t, err, ErrShareGone)
+	mockShareRepo.AssertNumberOfCalls(t, "RecordView", 1)
+}
+
+// TestOpenSharedFile_ViewLimitRace проверяет отказ, если лимит исчерпан между проверкой и записью просмотра
+func TestOpenSharedFile_ViewLimitRace(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	mockReplays := new(MockSharedReplayReader)
+	service := newTestShareService(mockShareRepo, mockReplays)
+	ownerID, replayID, shareID := uuid.New(), uuid.New(), uuid.New()
+
+	link := &models.ShareLink{ID: shareID, UserID: ownerID, ReplayID: &replayID, MaxViews: intPtr(1)}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+	content := &trackingCloser{ReadSeeker: strings.NewReader("data")}
+	mockReplays.On("OpenReplayFile", mock.Anything, replayID, ownerID, []string(nil)).
+		Return(&ReplayFile{Replay: &models.Replay{ID: replayID}, Content: content}, nil)
+	mockShareRepo.On("RecordView", mock.Anything, shareID).
+		Return(0, fmt.Errorf("failed to record share view: %w", repository.ErrNotFound))
+
+	_, err := service.OpenSharedFile(context.Background(), testShareToken, "", nil, nil, true)
+	assert.ErrorIs(t, err, ErrShareGone)
+	assert.True(t, content.closed)
+}
+
+// TestOpenSharedFile_GameLink проверяет, что по ссылке на игру отдаются только реплеи этой игры
+func TestOpenSharedFile_GameLink(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	mockReplays := new(MockSharedReplayReader)
+	service := newTestShareService(mockShareRepo, mockReplays)
+	ownerID, gameID, shareID := uuid.New(), uuid.New(), uuid.New()
+	ownReplay, otherReplay := uuid.New(), uuid.New()
+
+	link := &models.ShareLink{ID: shareID, UserID: ownerID, GameID: &gameID}
+	mockShareRepo.On("GetByTokenHash", mock.Anything, hashShareToken(testShareToken)).Return(link, nil)
+	mockReplays.On("GetReplay", mock.Anything, ownReplay, ownerID).Return(&models.Replay{ID: ownReplay, GameID: gameID}, nil)
+	mockReplays.On("GetReplay", mock.Anything, otherReplay, ownerID).Return(&models.Replay{ID: otherReplay, GameID: uuid.New()}, nil)
+	mockReplays.On("OpenReplayFile", mock.Anything, ownReplay, ownerID, []string{"gzip"}).
+		Return(&ReplayFile{Replay: &models.Replay{ID: ownReplay}, Content: io.NopCloser(strings.NewReader("data"))}, nil)
+	mockShareRepo.On("RecordView", mock.Anything, shareID).Return(1, nil)
+
+	_, err := service.OpenSharedFile(context.Background(), testShareToken, "", nil, nil, true)
+	assert.ErrorIs(t, err, ErrReplayNotFound)
+
+	_, err = service.OpenSharedFile(context.Background(), testShareToken, "", &otherReplay, nil, true)
+	assert.ErrorIs(t, err, ErrReplayNotFound)
+
+	replayFile, err := service.OpenSharedFile(context.Background(), testShareToken, "", &ownReplay, []string{"gzip"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, ownReplay, replayFile.Replay.ID)
+	mockReplays.AssertNotCalled(t, "OpenReplayFile", mock.Anything, otherReplay, mock.Anything, mock.Anything)
+}
+
+// TestRevokeShare_NotFound проверяет отзыв чужой или несуществующей ссылки
+func TestRevokeShare_NotFound(t *testing.T) {
+	mockShareRepo := new(MockShareRepository)
+	service := newTestShareService(mockShareRepo, new(MockSharedReplayReader))
+	shareID, userID := uuid.New(), uuid.New()
+
+	mockShareRepo.On("Revoke", mock.Anything, shareID, userID).Return(fmt.Errorf("share link %w", repository.ErrNotFound))
+
+	err := service.RevokeShare(context.Background(), shareID, userID)
+	assert.ErrorIs(t, err, ErrShareNotFound)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/tagexpr"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTagNotFound       = errors.New("tag not found")
+	ErrTagAlreadyExists  = errors.New("tag already exists")
+	ErrInvalidTag        = errors.New("invalid tag")
+	ErrTagTargetNotFound = errors.New("replay or game not found")
+)
+
+const (
+	// maxTagTargets - предел числа реплеев и игр в одном массовом изменении
+	maxTagTargets = 100
+	// maxTagsPerChange - предел числа тегов, навешиваемых или снимаемых за раз
+	maxTagsPerChange = 20
+)
+
+// TagService управляет тегами пользователя и их привязкой к реплеям и играм
+type TagService struct {
+	tagRepo TagRepositoryInterface
+	logger  *slog.Logger
+}
+
+func NewTagService(tagRepo TagRepositoryInterface, logger *slog.Logger) *TagService {
+	return &TagService{
+		tagRepo: tagRepo,
+		logger:  logger,
+	}
+}
+
+func (s *TagService) GetUserTags(ctx context.Context, userID uuid.UUID) ([]models.Tag, error) {
+	tags, err := s.tagRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get tags", slog.String("error", err.Error()))
+		return nil, wrapError("get tags", err)
+	}
+	return tags, nil
+}
+
+// CreateTag создает тег; если тег с таким именем уже есть, он возвращается с новым цветом
+func (s *TagService) CreateTag(ctx context.Context, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	name, color, err := normalizeTag(name, color)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := s.tagRepo.Create(ctx, userID, name, color)
+	if err != nil {
+		s.logger.Error("failed to create tag", slog.String("error", err.Error()))
+		return nil, wrapError("create tag", err)
+	}
+
+	s.logger.Info("tag created", slog.String("tag_id", tag.ID.String()), slog.String("name", tag.Name))
+	return tag, nil
+}
+
+// UpdateTag переименовывает тег и задает ему цвет
+func (s *TagService) UpdateTag(ctx context.Context, tagID, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	name, color, err := normalizeTag(name, color)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := s.tagRepo.Update(ctx, tagID, userID, name, color)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrTagNotFound
+		case errors.Is(err, repository.ErrConflict):
+			return nil, fmt.Errorf("%w: %s", ErrTagAlreadyExists, name)
+		}
+		s.logger.Error("failed to update tag", slog.String("error", err.Error()))
+		return nil, wrapError("update tag", err)
+	}
+	return tag, nil
+}
+
+func (s *TagService) DeleteTag(ctx context.Context, tagID, userID uuid.UUID) error {
+	if err := s.tagRepo.Delete(ctx, tagID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTagNotFound
+		}
+		s.logger.Error("failed to delete tag", slog.String("error", err.Error()))
+		return wrapError("delete tag", err)
+	}
+	return nil
+}
+
+// AssignTags навешивает теги Add и снимает теги Remove со всех реплеев и игр за одну транзакцию
+func (s *TagService) AssignTags(ctx context.Context, userID uuid.UUID, assignment models.TagAssignment) error {
+	s.logger.Info("assigning tags",
+		slog.String("user_id", userID.String()),
+		slog.Int("replays", len(assignment.ReplayIDs)),
+		slog.Int("games", len(assignment.GameIDs)))
+
+	a := models.TagAssignment{
+		ReplayIDs: uniqueIDs(assignment.ReplayIDs),
+		GameIDs:   uniqueIDs(assignment.GameIDs),
+	}
+	if len(a.ReplayIDs)+len(a.GameIDs) == 0 {
+		return fmt.Errorf("%w: replay_ids or game_ids is required", ErrInvalidTag)
+	}
+	if len(a.ReplayIDs)+len(a.GameIDs) > maxTagTargets {
+		return fmt.Errorf("%w: more than %d replays and games", ErrInvalidTag, maxTagTargets)
+	}
+
+	var err error
+	if a.Add, err = normalizeTagNames(assignment.Add); err != nil {
+		return err
+	}
+	if a.Remove, err = normalizeTagNames(assignment.Remove); err != nil {
+		return err
+	}
+	if len(a.Add)+len(a.Remove) == 0 {
+		return fmt.Errorf("%w: add or remove is required", ErrInvalidTag)
+	}
+	for _, name := range a.Add {
+		if slices.Contains(a.Remove, name) {
+			return fmt.Errorf("%w: %s is both added and removed", ErrInvalidTag, name)
+		}
+	}
+
+	if err := s.tagRepo.Assign(ctx, userID, a); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTagTargetNotFound
+		}
+		s.logger.Error("failed to assign tags", slog.String("error", err.Error()))
+		return wrapError("assign tags", err)
+	}
+	return nil
+}
+
+func normalizeTag(name string, color *string) (string, *string, error) {
+	name, err := tagexpr.NormalizeName(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+	color, err = normalizeColor(color)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+	return name, color, nil
+}
+
+// normalizeTagNames приводит имена к хранимому виду и убирает повторы
+func normalizeTagNames(names []string) ([]string, error) {
+	if len(names) > maxTagsPerChange {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidTag, maxTagsPerChange)
+	}
+	var out []string
+	for _, name := range names {
+		normalized, err := tagexpr.NormalizeName(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+		}
+		if !slices.Contains(out, normalized) {
+			out = append(out, normalized)
+		}
+	}
+	return out, nil
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTagRepository - мок для тегов пользователя
+type MockTagRepository struct {
+	mock.Mock
+}
+
+func (m *MockTagRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Tag, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) Create(ctx context.Context, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	args := m.Called(ctx, userID, name, color)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) Update(ctx context.Context, tagID, userID uuid.UUID, name string, color *string) (*models.Tag, error) {
+	args := m.Called(ctx, tagID, userID, name, color)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockTagRepository) Delete(ctx context.Context, tagID, userID uuid.UUID) error {
+	args := m.Called(ctx, tagID, userID)
+	return args.Error(0)
+}
+
+func (m *MockTagRepository) Assign(ctx context.Context, userID uuid.UUID, assignment models.TagAssignment) error {
+	args := m.Called(ctx, userID, assignment)
+	return args.Error(0)
+}
+
+func newTestTagService(repo *MockTagRepository) *TagService {
+	return NewTagService(repo, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+}
+
+// TestAssignTags_Normalize проверяет приведение имен тегов и удаление повторов перед записью
+func TestAssignTags_Normalize(t *testing.T) {
+	mockTagRepo := new(MockTagRepository)
+	service := newTestTagService(mockTagRepo)
+	userID, replayID, gameID := uuid.New(), uuid.New(), uuid.New()
+
+	want := models.TagAssignment{
+		ReplayIDs: []uuid.UUID{replayID},
+		GameIDs:   []uuid.UUID{gameID},
+		Add:       []string{"ranked", "ladder:s3"},
+		Remove:    []string{"todo"},
+	}
+	mockTagRepo.On("Assign", mock.Anything, userID, want).Return(nil)
+
+	err := service.AssignTags(context.Background(), userID, models.TagAssignment{
+		ReplayIDs: []uuid.UUID{replayID, replayID},
+		GameIDs:   []uuid.UUID{gameID},
+		Add:       []string{"Ranked", " ranked ", "LADDER:S3"},
+		Remove:    []string{"todo"},
+	})
+	require.NoError(t, err)
+	mockTagRepo.AssertExpectations(t)
+}
+
+// TestAssignTags_Validation проверяет отказ без обращения к БД
+func TestAssignTags_Validation(t *testing.T) {
+	replayID := uuid.New()
+	tests := []struct {
+		name       string
+		assignment models.TagAssignment
+	}{
+		{name: "no targets", assignment: models.TagAssignment{Add: []string{"ranked"}}},
+		{name: "no tags", assignment: models.TagAssignment{ReplayIDs: []uuid.UUID{replayID}}},
+		{name: "bad name", assignment: models.TagAssignment{ReplayIDs: []uuid.UUID{replayID}, Add: []string{"two words"}}},
+		{name: "reserved name", assignment: models.TagAssignment{ReplayIDs: []uuid.UUID{replayID}, Add: []string{"NOT"}}},
+		{name: "add and remove", assignment: models.TagAssignment{ReplayIDs: []uuid.UUID{replayID}, Add: []string{"x"}, Remove: []string{"X"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTagRepo := new(MockTagRepository)
+			service := newTestTagService(mockTagRepo)
+
+			err := service.AssignTags(context.Background(), uuid.New(), tt.assignment)
+			assert.ErrorIs(t, err, ErrInvalidTag)
+			mockTagRepo.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// TestAssignTags_TargetNotFound проверяет ошибку для чужого или несуществующего реплея
+func TestAssignTags_TargetNotFound(t *testing.T) {
+	mockTagRepo := new(MockTagRepository)
+	service := newTestTagService(mockTagRepo)
+	userID := uuid.New()
+
+	mockTagRepo.On("Assign", mock.Anything, userID, mock.Anything).
+		Return(fmt.Errorf("assign tags: %w", repository.ErrNotFound))
+
+	err := service.AssignTags(context.Background(), userID, models.TagAssignment{
+		ReplayIDs: []uuid.UUID{uuid.New()},
+		Add:       []string{"ranked"},
+	})
+	assert.ErrorIs(t, err, ErrTagTargetNotFound)
+}
+
+// TestUpdateTag_Conflict проверяет ошибку при переименовании в уже занятое имя
+func TestUpdateTag_Conflict(t *testing.T) {
+	mockTagRepo := new(MockTagRepository)
+	service := newTestTagService(mockTagRepo)
+	tagID, userID := uuid.New(), uuid.New()
+
+	mockTagRepo.On("Update", mock.Anything, tagID, userID, "ranked", stringPtr("#00ff00")).
+		Return(nil, repository.ErrConflict)
+
+	_, err := service.UpdateTag(context.Background(), tagID, userID, "Ranked", stringPtr("#00FF00"))
+	assert.ErrorIs(t, err, ErrTagAlreadyExists)
+}
@@ -0,0 +1,433 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGameNotFound         = errors.New("game not found")
+	ErrUploadNotFound       = errors.New("upload session not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadTooLarge       = errors.New("chunk exceeds declared upload length")
+	ErrUploadIncomplete     = errors.New("upload is not complete")
+	ErrUploadLeaseLost      = errors.New("upload finalization lease lost")
+)
+
+// uploadFinalizeLease - аренда финализации: пока она продлевается, сессию не финализирует другой запрос
+// и не удаляет очистка; аренду упавшего процесса можно перехватить по ее истечении
+const uploadFinalizeLease = 10 * time.Minute
+
+type UploadService struct {
+	uploadRepo UploadRepositoryInterface
+	replays    ReplayCreatorInterface
+	storage    FileStorageInterface
+	ttl        time.Duration
+	lease      time.Duration
+	quota      QuotaCheckerInterface
+	logger     *slog.Logger
+}
+
+// UploadOption настраивает необязательные возможности UploadService
+type UploadOption func(*UploadService)
+
+// WithUploadQuota отклоняет сессию загрузки сразу, если объявленный размер не помещается в квоту
+// Квота все равно перепроверяется при финализации: за время загрузки могли появиться другие реплеи
+func WithUploadQuota(quota QuotaCheckerInterface) UploadOption {
+	return func(s *UploadService) {
+		s.quota = quota
+	}
+}
+
+func NewUploadService(
+	uploadRepo UploadRepositoryInterface,
+	replays ReplayCreatorInterface,
+	storage FileStorageInterface,
+	ttl time.Duration,
+	logger *slog.Logger,
+	opts ...UploadOption,
+) *UploadService {
+	s := &UploadService{
+		uploadRepo: uploadRepo,
+		replays:    replays,
+		storage:    storage,
+		ttl:        ttl,
+		lease:      uploadFinalizeLease,
+		logger:     logger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *UploadService) CreateUpload(
+	ctx context.Context,
+	gameID, userID uuid.UUID,
+	filename string,
+	size int64,
+	title, comment string,
+) (*models.UploadSession, error) {
+	s.logger.Info("creating upload session",
+		slog.String("game_id", gameID.String()),
+		slog.String("user_id", userID.String()),
+		slog.String("filename", filename),
+		slog.Int64("size", size))
+
+	if s.quota != nil {
+		if err := s.quota.CheckUpload(ctx, userID, size); err != nil {
+			return nil, wrapError("check quota", err)
+		}
+	}
+
+	session := &models.UploadSession{
+		ID:           uuid.New(),
+		OriginalName: filename,
+		Title:        stringPtr(title),
+		Comment:      stringPtr(comment),
+		SizeBytes:    size,
+		ExpiresAt:    time.Now().Add(s.ttl),
+		GameID:       gameID,
+		UserID:       userID,
+	}
+
+	if err := s.uploadRepo.Create(ctx, session); err != nil {
+		s.logger.Error("failed to create upload session", slog.String("error", err.Error()))
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, wrapError("create upload session", err)
+	}
+
+	s.logger.Info("upload session created", slog.String("upload_id", session.ID.String()))
+	return session, nil
+}
+
+func (s *UploadService) GetUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.UploadSession, error) {
+	session, err := s.uploadRepo.GetByID(ctx, uploadID, gameID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		s.logger.Error("failed to get upload session", slog.String("error", err.Error()))
+		return nil, wrapError("get upload session", err)
+	}
+	return session, nil
+}
+
+// AppendChunk дописывает body к загрузке, если offset совпадает с текущим смещением сессии
+// Кусок сохраняется в хранилище отдельным объектом; при любой ошибке он удаляется, смещение не меняется
+func (s *UploadService) AppendChunk(
+	ctx context.Context,
+	uploadID, gameID, userID uuid.UUID,
+	offset int64,
+	body io.Reader,
+	size int64,
+) (int64, error) {
+	session, err := s.GetUpload(ctx, uploadID, gameID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset != session.Offset {
+		return session.Offset, ErrUploadOffsetMismatch
+	}
+
+	remaining := session.SizeBytes - session.Offset
+	if size > remaining {
+		return session.Offset, ErrUploadTooLarge
+	}
+
+	chunkKey := storage.UploadChunkKey(uploadID, offset)
+	counter := &countingReader{r: io.LimitReader(body, remaining+1)}
+	if err := s.storage.Put(ctx, chunkKey, counter, size); err != nil {
+		s.logger.Warn("failed to store upload chunk",
+			slog.String("upload_id", uploadID.String()),
+			slog.String("error", err.Error()))
+		s.deleteChunks(ctx, []string{chunkKey})
+		return session.Offset, wrapError("store upload chunk", err)
+	}
+
+	if counter.n > remaining {
+		s.deleteChunks(ctx, []string{chunkKey})
+		return session.Offset, ErrUploadTooLarge
+	}
+	if counter.n == 0 {
+		s.deleteChunks(ctx, []string{chunkKey})
+		return session.Offset, nil
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	if err := s.uploadRepo.AppendChunk(ctx, uploadID, userID, offset, counter.n, chunkKey, expiresAt); err != nil {
+		s.deleteChunks(ctx, []string{chunkKey})
+		if errors.Is(err, repository.ErrNotFound) {
+			return session.Offset, ErrUploadOffsetMismatch
+		}
+		s.logger.Error("failed to append upload chunk", slog.String("error", err.Error()))
+		return session.Offset, wrapError("append upload chunk", err)
+	}
+
+	s.logger.Debug("upload chunk appended",
+		slog.String("upload_id", uploadID.String()),
+		slog.Int64("offset", offset+counter.n))
+	return offset + counter.n, nil
+}
+
+// FinalizeUpload собирает куски в обычный реплей через конвейер ReplayService
+// Повторное завершение уже собранной сессии возвращает тот же реплей
+func (s *UploadService) FinalizeUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.Replay, error) {
+	s.logger.Info("finalizing upload",
+		slog.String("upload_id", uploadID.String()),
+		slog.String("user_id", userID.String()))
+
+	lease := models.UploadLease{UploadID: uploadID, Token: uuid.New()}
+	session, err := s.uploadRepo.Claim(ctx, lease, gameID, userID, s.lease, s.leaseExpiry())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return s.finalized(ctx, uploadID, gameID, userID)
+		}
+		s.logger.Error("failed to claim upload session", slog.String("error", err.Error()))
+		return nil, wrapError("claim upload session", err)
+	}
+
+	leaseCtx, stopLease := s.holdLease(ctx, lease)
+	content := newChunkReader(leaseCtx, s.storage, session.ChunkKeys)
+	replay, err := s.replays.CreateReplayFromStream(leaseCtx, content, session.OriginalName, session.SizeBytes,
+		session.GameID, userID, derefString(session.Title), derefString(session.Comment), &lease)
+	content.Close()
+	if leaseErr := stopLease(); err != nil {
+		if leaseErr != nil {
+			err = leaseErr
+		}
+		if releaseErr := s.uploadRepo.Release(ctx, lease); releaseErr != nil {
+			s.logger.Warn("failed to release upload session", slog.String("error", releaseErr.Error()))
+		}
+		return nil, err
+	}
+
+	s.cleanupFinalized(ctx, uploadID)
+	s.logger.Info("upload finalized",
+		slog.String("upload_id", uploadID.String()),
+		slog.String("replay_id", replay.ID.String()))
+	return replay, nil
+}
+
+// finalized отвечает на завершение сессии, которую не удалось захватить:
+// уже собранная сессия возвращает свой реплей, иначе загрузка не закончена или ее собирает другой запрос
+func (s *UploadService) finalized(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.Replay, error) {
+	session, err := s.GetUpload(ctx, uploadID, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.ReplayID == nil {
+		return nil, ErrUploadIncomplete
+	}
+
+	replay, err := s.replays.GetReplay(ctx, *session.ReplayID, userID)
+	if err != nil {
+		return nil, wrapError("get finalized replay", err)
+	}
+
+	if len(session.ChunkKeys) > 0 {
+		s.cleanupFinalized(ctx, uploadID)
+	}
+	s.logger.Info("upload already finalized",
+		slog.String("upload_id", uploadID.String()),
+		slog.String("replay_id", replay.ID.String()))
+	return replay, nil
+}
+
+// cleanupFinalized удаляет куски собранной сессии; сама сессия живет еще ttl для повторных завершений
+func (s *UploadService) cleanupFinalized(ctx context.Context, uploadID uuid.UUID) {
+	chunkKeys, err := s.uploadRepo.Finish(ctx, uploadID, time.Now().Add(s.ttl))
+	if err != nil {
+		s.logger.Warn("failed to finish upload session", slog.String("error", err.Error()))
+		return
+	}
+	s.deleteChunks(ctx, chunkKeys)
+}
+
+// holdLease продлевает аренду финализации, пока не вызвана возвращенная функция stop
+// Зачем: сборка большого файла может идти дольше аренды, и ее не должны перехватить или удалить
+// Если аренду перехватили, возвращенный контекст отменяется, а stop возвращает ErrUploadLeaseLost
+func (s *UploadService) holdLease(ctx context.Context, lease models.UploadLease) (context.Context, func() error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.uploadRepo.RenewLease(ctx, lease, s.leaseExpiry())
+				switch {
+				case errors.Is(err, repository.ErrNotFound):
+					s.logger.Warn("upload lease lost, cancelling finalization",
+						slog.String("upload_id", lease.UploadID.String()))
+					cancel(ErrUploadLeaseLost)
+					return
+				case err != nil && ctx.Err() == nil:
+					s.logger.Warn("failed to renew upload lease",
+						slog.String("upload_id", lease.UploadID.String()),
+						slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+
+	return ctx, func() error {
+		close(stopped)
+		<-done
+		lost := context.Cause(ctx)
+		cancel(nil)
+		if errors.Is(lost, ErrUploadLeaseLost) {
+			return ErrUploadLeaseLost
+		}
+		return nil
+	}
+}
+
+// leaseExpiry - срок жизни сессии на время финализации: после брошенной аренды
+// у клиента остается ttl, чтобы повторить финализацию
+func (s *UploadService) leaseExpiry() time.Time {
+	return time.Now().Add(s.lease + s.ttl)
+}
+
+func (s *UploadService) AbortUpload(ctx context.Context, uploadID, gameID, userID uuid.UUID) error {
+	if _, err := s.GetUpload(ctx, uploadID, gameID, userID); err != nil {
+		return err
+	}
+
+	chunkKeys, err := s.uploadRepo.Delete(ctx, uploadID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUploadNotFound
+		}
+		s.logger.Error("failed to delete upload session", slog.String("error", err.Error()))
+		return wrapError("delete upload session", err)
+	}
+
+	s.deleteChunks(ctx, chunkKeys)
+	s.logger.Info("upload aborted", slog.String("upload_id", uploadID.String()))
+	return nil
+}
+
+// CleanupExpired удаляет истекшие сессии вместе с их кусками в хранилище
+func (s *UploadService) CleanupExpired(ctx context.Context) error {
+	chunkKeys, err := s.uploadRepo.DeleteExpired(ctx, s.lease)
+	if err != nil {
+		s.logger.Error("failed to delete expired uploads", slog.String("error", err.Error()))
+		return wrapError("delete expired uploads", err)
+	}
+
+	if len(chunkKeys) > 0 {
+		s.logger.Info("removing expired upload chunks", slog.Int("count", len(chunkKeys)))
+	}
+	s.deleteChunks(ctx, chunkKeys)
+	return nil
+}
+
+// RunCleanup периодически вызывает CleanupExpired, пока ctx не отменен
+func (s *UploadService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.CleanupExpired(ctx)
+		}
+	}
+}
+
+func (s *UploadService) deleteChunks(ctx context.Context, chunkKeys []string) {
+	for _, key := range chunkKeys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			s.logger.Warn("failed to delete upload chunk",
+				slog.String("key", key),
+				slog.String("error", err.Error()))
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// chunkReader последовательно читает куски загрузки, открывая каждый только когда до него дошла очередь
+type chunkReader struct {
+	ctx     context.Context
+	storage FileStorageInterface
+	keys    []string
+	current io.ReadCloser
+}
+
+func newChunkReader(ctx context.Context, storage FileStorageInterface, keys []string) *chunkReader {
+	return &chunkReader{ctx: ctx, storage: storage, keys: keys}
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.keys) == 0 {
+				return 0, io.EOF
+			}
+			content, _, err := c.storage.Open(c.ctx, c.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			c.current = content
+			c.keys = c.keys[1:]
+		}
+
+		n, err := c.current.Read(p)
+		if err == io.EOF {
+			c.current.Close()
+			c.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.current != nil {
+		return c.current.Close()
+	}
+	return nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
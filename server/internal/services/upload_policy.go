@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFileTypeNotAllowed  = errors.New("file type is not allowed")
+	ErrInvalidUploadPolicy = errors.New("invalid upload policy")
+)
+
+// UploadPolicyService проверяет формат загружаемого файла по политике игры
+// Политика по умолчанию задается конфигом, собственные политики игр лежат в games.allowed_types
+type UploadPolicyService struct {
+	gameRepo UploadPolicyRepositoryInterface
+	defaults filetype.Policy
+	logger   *slog.Logger
+}
+
+func NewUploadPolicyService(gameRepo UploadPolicyRepositoryInterface, defaults filetype.Policy, logger *slog.Logger) *UploadPolicyService {
+	return &UploadPolicyService{
+		gameRepo: gameRepo,
+		defaults: defaults,
+		logger:   logger,
+	}
+}
+
+// CheckFileType проверяет, что формат detected разрешено загружать в игру
+func (s *UploadPolicyService) CheckFileType(ctx context.Context, gameID, userID uuid.UUID, detected filetype.Type) error {
+	policy, _, err := s.policy(ctx, gameID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !policy.Allows(detected) {
+		s.logger.Warn("file type not allowed",
+			slog.String("game_id", gameID.String()),
+			slog.String("content_type", detected.MIME),
+			slog.String("kind", detected.Kind))
+		return fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, detected.MIME)
+	}
+	return nil
+}
+
+func (s *UploadPolicyService) GetPolicy(ctx context.Context, gameID, userID uuid.UUID) (*models.UploadPolicy, error) {
+	policy, isDefault, err := s.policy(ctx, gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UploadPolicy{AllowedTypes: policy.Entries(), Default: isDefault}, nil
+}
+
+// SetAllowedTypes задает игре собственную политику загрузки; nil возвращает политику по умолчанию
+func (s *UploadPolicyService) SetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID, allowedTypes []string) (*models.UploadPolicy, error) {
+	s.logger.Info("setting upload policy",
+		slog.String("game_id", gameID.String()),
+		slog.Any("allowed_types", allowedTypes))
+
+	var entries []string
+	if allowedTypes != nil {
+		policy, err := filetype.ParsePolicy(allowedTypes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUploadPolicy, err)
+		}
+		if len(policy.Entries()) == 0 {
+			return nil, fmt.Errorf("%w: at least one file type is required", ErrInvalidUploadPolicy)
+		}
+		entries = policy.Entries()
+	}
+
+	if err := s.gameRepo.SetAllowedTypes(ctx, gameID, userID, entries); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrGameNotFound
+		}
+		s.logger.Error("failed to set upload policy", slog.String("error", err.Error()))
+		return nil, wrapError("set upload policy", err)
+	}
+
+	if entries == nil {
+		return &models.UploadPolicy{AllowedTypes: s.defaults.Entries(), Default: true}, nil
+	}
+	return &models.UploadPolicy{AllowedTypes: entries}, nil
+}
+
+// policy возвращает действующую политику игры и признак того, что это политика по умолчанию
+func (s *UploadPolicyService) policy(ctx context.Context, gameID, userID uuid.UUID) (filetype.Policy, bool, error) {
+	allowedTypes, err := s.gameRepo.GetAllowedTypes(ctx, gameID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return filetype.Policy{}, false, ErrGameNotFound
+		}
+		s.logger.Error("failed to get upload policy", slog.String("error", err.Error()))
+		return filetype.Policy{}, false, wrapError("get upload policy", err)
+	}
+	if allowedTypes == nil {
+		return s.defaults, true, nil
+	}
+
+	policy, err := filetype.ParsePolicy(allowedTypes)
+	if err != nil {
+		return filetype.Policy{}, false, wrapError("parse upload policy", err)
+	}
+	return policy, false, nil
+}
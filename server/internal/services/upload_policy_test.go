@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/fckoffmw/replay-service/server/internal/filetype"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockUploadPolicyRepository - мок для политик загрузки в GameRepository
+type MockUploadPolicyRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadPolicyRepository) GetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, gameID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockUploadPolicyRepository) SetAllowedTypes(ctx context.Context, gameID, userID uuid.UUID, allowedTypes []string) error {
+	args := m.Called(ctx, gameID, userID, allowedTypes)
+	return args.Error(0)
+}
+
+func newTestUploadPolicyService(t *testing.T, repo *MockUploadPolicyRepository) *UploadPolicyService {
+	defaults, err := filetype.ParsePolicy([]string{"video", "replay"})
+	require.NoError(t, err)
+	return NewUploadPolicyService(repo, defaults, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+}
+
+var mp4Header = []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2")
+
+// TestCheckFileType проверяет политику по умолчанию и собственную политику игры
+func TestCheckFileType(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		head    []byte
+		want    error
+	}{
+		{name: "default allows video", allowed: nil, head: mp4Header},
+		{name: "default rejects unknown", allowed: nil, head: []byte("plain text"), want: ErrFileTypeNotAllowed},
+		{name: "game allows unknown", allowed: []string{"unknown"}, head: []byte("plain text")},
+		{name: "game allows only sc2", allowed: []string{"application/x-sc2-replay"}, head: mp4Header, want: ErrFileTypeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockUploadPolicyRepository)
+			service := newTestUploadPolicyService(t, repo)
+
+			gameID, userID := uuid.New(), uuid.New()
+			repo.On("GetAllowedTypes", mock.Anything, gameID, userID).Return(tt.allowed, nil)
+
+			err := service.CheckFileType(context.Background(), gameID, userID, filetype.Detect(tt.head))
+
+			if tt.want == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetAllowedTypes_Invalid проверяет, что неизвестный формат не попадает в БД
+func TestSetAllowedTypes_Invalid(t *testing.T) {
+	repo := new(MockUploadPolicyRepository)
+	service := newTestUploadPolicyService(t, repo)
+
+	_, err := service.SetAllowedTypes(context.Background(), uuid.New(), uuid.New(), []string{"video", "text/plain"})
+	assert.ErrorIs(t, err, ErrInvalidUploadPolicy)
+
+	_, err = service.SetAllowedTypes(context.Background(), uuid.New(), uuid.New(), []string{})
+	assert.ErrorIs(t, err, ErrInvalidUploadPolicy, "пустая политика запретила бы любые загрузки")
+
+	repo.AssertNotCalled(t, "SetAllowedTypes")
+}
+
+// TestGetPolicy_Errors проверяет, что отсутствие игры отличается от сбоя БД
+func TestGetPolicy_Errors(t *testing.T) {
+	repo := new(MockUploadPolicyRepository)
+	service := newTestUploadPolicyService(t, repo)
+
+	missing, broken := uuid.New(), uuid.New()
+	repo.On("GetAllowedTypes", mock.Anything, missing, mock.Anything).Return(nil, fmt.Errorf("get allowed types: %w", repository.ErrNotFound))
+	repo.On("GetAllowedTypes", mock.Anything, broken, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	_, err := service.GetPolicy(context.Background(), missing, uuid.New())
+	assert.ErrorIs(t, err, ErrGameNotFound)
+
+	_, err = service.GetPolicy(context.Background(), broken, uuid.New())
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrGameNotFound)
+}
+
+// TestCreateReplay_DetectsContentType проверяет, что тип определяется по содержимому, а не по расширению
+func TestCreateReplay_DetectsContentType(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	policyRepo := new(MockUploadPolicyRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithTypePolicy(newTestUploadPolicyService(t, policyRepo)))
+
+	gameID, userID := uuid.New(), uuid.New()
+	policyRepo.On("GetAllowedTypes", mock.Anything, gameID, userID).Return(nil, nil)
+	mockStorage.On("Put", mock.Anything, stagingKey, mock.Anything, int64(len(mp4Header))).Return(nil)
+	mockReplayRepo.On("CreateWithBlob", mock.Anything, mock.AnythingOfType("*models.Replay"), mock.AnythingOfType("*models.Blob")).Return(true, nil)
+	mockStorage.On("Move", mock.Anything, stagingKey, mock.Anything).Return(nil)
+	// Заголовок без moov: параметры видео не извлекаются, но загрузка проходит
+	mockStorage.On("Open", mock.Anything, stagingKey).
+		Return(nopSeekCloser{bytes.NewReader(mp4Header)}, storage.ObjectInfo{Size: int64(len(mp4Header))}, nil)
+
+	file := newTestFileHeader(t, "match.rep", mp4Header)
+	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, "", "")
+
+	require.NoError(t, err)
+	assert.Equal(t, "video/mp4", replay.ContentType)
+	assert.Nil(t, replay.DurationMS)
+}
+
+// TestCreateReplay_FileTypeNotAllowed проверяет, что файл запрещенного формата не пишется в хранилище
+func TestCreateReplay_FileTypeNotAllowed(t *testing.T) {
+	mockReplayRepo := new(MockReplayRepository)
+	mockStorage := new(MockFileStorage)
+	policyRepo := new(MockUploadPolicyRepository)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewReplayService(mockReplayRepo, mockStorage, logger, WithTypePolicy(newTestUploadPolicyService(t, policyRepo)))
+
+	gameID, userID := uuid.New(), uuid.New()
+	policyRepo.On("GetAllowedTypes", mock.Anything, gameID, userID).Return(nil, nil)
+
+	file := newTestFileHeader(t, "fake.mp4", []byte("#!/bin/sh\necho hi\n"))
+	replay, err := service.CreateReplay(context.Background(), file, gameID, userID, "", "")
+
+	assert.Nil(t, replay)
+	assert.True(t, errors.Is(err, ErrFileTypeNotAllowed))
+	mockStorage.AssertNotCalled(t, "Put")
+	mockReplayRepo.AssertNotCalled(t, "CreateWithBlob")
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fckoffmw/replay-service/server/internal/models"
+	"github.com/fckoffmw/replay-service/server/internal/repository"
+	"github.com/fckoffmw/replay-service/server/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockUploadRepository - мок для UploadRepository
+type MockUploadRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockUploadRepository) GetByID(ctx context.Context, uploadID, gameID, userID uuid.UUID) (*models.UploadSession, error) {
+	args := m.Called(ctx, uploadID, gameID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UploadSession), args.Error(1)
+}
+
+func (m *MockUploadRepository) AppendChunk(ctx context.Context, uploadID, userID uuid.UUID, expectedOffset, written int64, chunkKey string, expiresAt time.Time) error {
+	args := m.Called(ctx, uploadID, userID, expectedOffset, written, chunkKey, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockUploadRepository) Claim(ctx context.Context, lease models.UploadLease, gameID, userID uuid.UUID, leaseTTL time.Duration, expiresAt time.Time) (*models.UploadSession, error) {
+	args := m.Called(ctx, lease, gameID, userID, leaseTTL, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UploadSession), args.Error(1)
+}
+
+func (m *MockUploadRepository) RenewLease(ctx context.Context, lease models.UploadLease, expiresAt time.Time) error {
+	args := m.Called(ctx, lease, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockUploadRepository) Release(ctx context.Context, lease models.UploadLease) error {
+	args := m.Called(ctx, lease)
+	return args.Error(0)
+}
+
+func (m *MockUploadRepository) Finish(ctx context.Context, uploadID uuid.UUID, expiresAt time.Time) ([]string, error) {
+	args := m.Called(ctx, uploadID, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockUploadRepository) Delete(ctx context.Context, uploadID, userID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, uploadID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockUploadRepository) DeleteExpired(ctx context.Context, lease time.Duration) ([]string, error) {
+	args := m.Called(ctx, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockReplayCreator - мок для ReplayService.CreateReplayFromStream
+type MockReplayCreator struct {
+	mock.Mock
+}
+
+func (m *MockReplayCreator) CreateReplayFromStream(ctx context.Context, src io.Reader, filename string, size int64, gameID, userID uuid.UUID, title, comment string, upload *models.UploadLease) (*models.Replay, error) {
+	args := m.Called(ctx, src, filename, size, gameID, userID, title, comment, upload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Replay), args.Error(1)
+}
+
+func (m *MockReplayCreator) GetReplay(ctx context.Context, replayID, userID uuid.UUID) (*models.Replay, error) {
+	args := m.Called(ctx, replayID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Replay), args.Error(1)
+}
+
+func newTestUploadService() (*UploadService, *MockUploadRepository, *MockReplayCreator, *MockFileStorage) {
+	mockUploadRepo := new(MockUploadRepository)
+	mockReplays := new(MockReplayCreator)
+	mockStorage := new(MockFileStorage)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	service := NewUploadService(mockUploadRepo, mockReplays, mockStorage, time.Hour, logger)
+	return service, mockUploadRepo, mockReplays, mockStorage
+}
+
+// leaseOf проверяет, что аренда взята на сессию uploadID под непустым токеном
+func leaseOf(uploadID uuid.UUID) interface{} {
+	return mock.MatchedBy(func(lease models.UploadLease) bool {
+		return lease.UploadID == uploadID && lease.Token != uuid.Nil
+	})
+}
+
+// uploadLeaseOf - то же для аренды, переданной в конвейер создания реплея
+func uploadLeaseOf(uploadID uuid.UUID) interface{} {
+	return mock.MatchedBy(func(lease *models.UploadLease) bool {
+		return lease != nil && lease.UploadID == uploadID && lease.Token != uuid.Nil
+	})
+}
+
+// chunkKeyFor проверяет, что кусок лежит под uploads/{upload_id}/
+func chunkKeyFor(uploadID uuid.UUID) interface{} {
+	return mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, storage.UploadsPrefix+"/"+uploadID.String()+"/")
+	})
+}
+
+// TestCreateUpload_GameNotFound проверяет, что чужая игра дает ErrGameNotFound
+func TestCreateUpload_GameNotFound(t *testing.T) {
+	service, mockUploadRepo, _, _ := newTestUploadService()
+
+	mockUploadRepo.On("Create", mock.Anything, mock.Anything).
+		Return(fmt.Errorf("create upload session: %w", repository.ErrNotFound))
+
+	_, err := service.CreateUpload(context.Background(), uuid.New(), uuid.New(), "match.rep", 10, "", "")
+
+	assert.ErrorIs(t, err, ErrGameNotFound)
+}
+
+// TestAppendChunk_Success проверяет запись куска и сдвиг смещения
+func TestAppendChunk_Success(t *testing.T) {
+	service, mockUploadRepo, _, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 10, Offset: 4, GameID: gameID, UserID: userID}
+
+	var stored []byte
+	mockUploadRepo.On("GetByID", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+	mockStorage.On("Put", mock.Anything, chunkKeyFor(uploadID), mock.Anything, int64(-1)).
+		Run(func(args mock.Arguments) {
+			stored, _ = io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+	mockUploadRepo.On("AppendChunk", mock.Anything, uploadID, userID, int64(4), int64(3), mock.Anything, mock.Anything).Return(nil)
+
+	offset, err := service.AppendChunk(context.Background(), uploadID, gameID, userID, 4, strings.NewReader("abc"), -1)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), offset)
+	assert.Equal(t, "abc", string(stored))
+	mockUploadRepo.AssertExpectations(t)
+}
+
+// TestAppendChunk_OffsetMismatch проверяет отказ, если клиент шлет кусок не с того смещения
+func TestAppendChunk_OffsetMismatch(t *testing.T) {
+	service, mockUploadRepo, _, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 10, Offset: 4}
+
+	mockUploadRepo.On("GetByID", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+
+	offset, err := service.AppendChunk(context.Background(), uploadID, gameID, userID, 0, strings.NewReader("abc"), 3)
+
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	assert.Equal(t, int64(4), offset, "возвращается текущее смещение сессии")
+	mockStorage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestAppendChunk_TooLarge проверяет, что кусок длиннее Upload-Length удаляется из хранилища
+func TestAppendChunk_TooLarge(t *testing.T) {
+	service, mockUploadRepo, _, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 5, Offset: 0}
+
+	mockUploadRepo.On("GetByID", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+	mockStorage.On("Put", mock.Anything, chunkKeyFor(uploadID), mock.Anything, int64(-1)).
+		Run(func(args mock.Arguments) {
+			io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+	mockStorage.On("Delete", mock.Anything, chunkKeyFor(uploadID)).Return(nil)
+
+	_, err := service.AppendChunk(context.Background(), uploadID, gameID, userID, 0, strings.NewReader("0123456789"), -1)
+
+	assert.ErrorIs(t, err, ErrUploadTooLarge)
+	mockStorage.AssertExpectations(t)
+	mockUploadRepo.AssertNotCalled(t, "AppendChunk", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestAppendChunk_ConcurrentWrite проверяет, что проигравший гонку кусок удаляется
+func TestAppendChunk_ConcurrentWrite(t *testing.T) {
+	service, mockUploadRepo, _, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 10, Offset: 0}
+
+	mockUploadRepo.On("GetByID", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+	mockStorage.On("Put", mock.Anything, chunkKeyFor(uploadID), mock.Anything, int64(3)).
+		Run(func(args mock.Arguments) {
+			io.ReadAll(args.Get(2).(io.Reader))
+		}).Return(nil)
+	mockUploadRepo.On("AppendChunk", mock.Anything, uploadID, userID, int64(0), int64(3), mock.Anything, mock.Anything).
+		Return(fmt.Errorf("upload session %w", repository.ErrNotFound))
+	mockStorage.On("Delete", mock.Anything, chunkKeyFor(uploadID)).Return(nil)
+
+	_, err := service.AppendChunk(context.Background(), uploadID, gameID, userID, 0, strings.NewReader("abc"), 3)
+
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestFinalizeUpload_Success проверяет сборку кусков в реплей и удаление кусков
+func TestFinalizeUpload_Success(t *testing.T) {
+	service, mockUploadRepo, mockReplays, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	title := "Final"
+	chunkKeys := []string{"uploads/a/0", "uploads/a/1"}
+	session := &models.UploadSession{
+		ID: uploadID, OriginalName: "match.rep", Title: &title,
+		SizeBytes: 6, Offset: 6, ChunkKeys: chunkKeys, GameID: gameID,
+	}
+	replay := &models.Replay{ID: uuid.New()}
+
+	var assembled []byte
+	mockUploadRepo.On("Claim", mock.Anything, leaseOf(uploadID), gameID, userID, uploadFinalizeLease, mock.Anything).Return(session, nil)
+	mockStorage.On("Open", mock.Anything, "uploads/a/0").Return(nopSeekCloser{bytes.NewReader([]byte("abc"))}, storage.ObjectInfo{}, nil)
+	mockStorage.On("Open", mock.Anything, "uploads/a/1").Return(nopSeekCloser{bytes.NewReader([]byte("def"))}, storage.ObjectInfo{}, nil)
+	mockReplays.On("CreateReplayFromStream", mock.Anything, mock.Anything, "match.rep", int64(6), gameID, userID, "Final", "", uploadLeaseOf(uploadID)).
+		Run(func(args mock.Arguments) {
+			assembled, _ = io.ReadAll(args.Get(1).(io.Reader))
+		}).Return(replay, nil)
+	mockUploadRepo.On("Finish", mock.Anything, uploadID, mock.Anything).Return(chunkKeys, nil)
+	mockStorage.On("Delete", mock.Anything, "uploads/a/0").Return(nil)
+	mockStorage.On("Delete", mock.Anything, "uploads/a/1").Return(nil)
+
+	result, err := service.FinalizeUpload(context.Background(), uploadID, gameID, userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, replay.ID, result.ID)
+	assert.Equal(t, "abcdef", string(assembled), "куски должны читаться по порядку")
+	mockUploadRepo.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestFinalizeUpload_Incomplete проверяет отказ финализировать недогруженную сессию
+func TestFinalizeUpload_Incomplete(t *testing.T) {
+	service, mockUploadRepo, mockReplays, _ := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 10, Offset: 4}
+
+	mockUploadRepo.On("Claim", mock.Anything, leaseOf(uploadID), gameID, userID, uploadFinalizeLease, mock.Anything).
+		Return(nil, fmt.Errorf("claim upload session: %w", repository.ErrNotFound))
+	mockUploadRepo.On("GetByID", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+
+	_, err := service.FinalizeUpload(context.Background(), uploadID, gameID, userID)
+
+	assert.ErrorIs(t, err, ErrUploadIncomplete)
+	mockReplays.AssertNotCalled(t, "CreateReplayFromStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestFinalizeUpload_ReleasesOnError проверяет, что при ошибке сессию можно финализировать повторно
+func TestFinalizeUpload_ReleasesOnError(t *testing.T) {
+	service, mockUploadRepo, mockReplays, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, OriginalName: "match.rep", SizeBytes: 0, ChunkKeys: nil, GameID: gameID}
+
+	mockUploadRepo.On("Claim", mock.Anything, leaseOf(uploadID), gameID, userID, uploadFinalizeLease, mock.Anything).Return(session, nil)
+	mockReplays.On("CreateReplayFromStream", mock.Anything, mock.Anything, "match.rep", int64(0), gameID, userID, "", "", uploadLeaseOf(uploadID)).
+		Return(nil, fmt.Errorf("storage unavailable"))
+	mockUploadRepo.On("Release", mock.Anything, leaseOf(uploadID)).Return(nil)
+
+	_, err := service.FinalizeUpload(context.Background(), uploadID, gameID, userID)
+
+	assert.Error(t, err)
+	mockUploadRepo.AssertExpectations(t)
+	mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+// TestFinalizeUpload_RenewsLease проверяет, что аренда продлевается, пока сборка файла идет дольше нее
+func TestFinalizeUpload_RenewsLease(t *testing.T) {
+	service, mockUploadRepo, mockReplays, _ := newTestUploadService()
+	service.lease = 30 * time.Millisecond
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, OriginalName: "match.rep", GameID: gameID}
+
+	mockUploadRepo.On("Claim", mock.Anything, leaseOf(uploadID), gameID, userID, service.lease, mock.Anything).Return(session, nil)
+	mockUploadRepo.On("RenewLease", mock.Anything, leaseOf(uploadID), mock.Anything).Return(nil)
+	mockReplays.On("CreateReplayFromStream", mock.Anything, mock.Anything, "match.rep", int64(0), gameID, userID, "", "", uploadLeaseOf(uploadID)).
+		Run(func(mock.Arguments) { time.Sleep(5 * service.lease) }).
+		Return(&models.Replay{ID: uuid.New()}, nil)
+	mockUploadRepo.On("Finish", mock.Anything, uploadID, mock.Anything).Return([]string(nil), nil)
+
+	_, err := service.FinalizeUpload(context.Background(), uploadID, gameID, userID)
+	require.NoError(t, err)
+
+	renewals := len(mockUploadRepo.Calls)
+	mockUploadRepo.AssertCalled(t, "RenewLease", mock.Anything, leaseOf(uploadID), mock.Anything)
+
+	// После финализации аренда больше не продлевается
+	time.Sleep(2 * service.lease)
+	assert.Len(t, mockUploadRepo.Calls, renewals)
+}
+
+// TestFinalizeUpload_LeaseLost проверяет, что перехват аренды отменяет сборку файла
+func TestFinalizeUpload_LeaseLost(t *testing.T) {
+	service, mockUploadRepo, mockReplays, mockStorage := newTestUploadService()
+	service.lease = 30 * time.Millisecond
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	session := &models.UploadSession{ID: uploadID, OriginalName: "match.rep", GameID: gameID}
+
+	var claimed models.UploadLease
+	mockUploadRepo.On("Claim", mock.Anything, leaseOf(uploadID), gameID, userID, service.lease, mock.Anything).
+		Run(func(args mock.Arguments) { claimed = args.Get(1).(models.UploadLease) }).
+		Return(session, nil)
+	mockUploadRepo.On("RenewLease", mock.Anything, leaseOf(uploadID), mock.Anything).
+		Return(fmt.Errorf("renew upload lease: %w", repository.ErrNotFound))
+	mockReplays.On("CreateReplayFromStream", mock.Anything, mock.Anything, "match.rep", int64(0), gameID, userID, "", "", uploadLeaseOf(uploadID)).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(nil, context.Canceled)
+	mockUploadRepo.On("Release", mock.Anything, leaseOf(uploadID)).Return(nil)
+
+	_, err := service.FinalizeUpload(context.Background(), uploadID, gameID, userID)
+
+	assert.ErrorIs(t, err, ErrUploadLeaseLost)
+	mockUploadRepo.AssertCalled(t, "Release", mock.Anything, claimed)
+	mockStorage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+// TestFinalizeUpload_AlreadyFinalized проверяет, что повторное завершение возвращает собранный реплей
+func TestFinalizeUpload_AlreadyFinalized(t *testing.T) {
+	service, mockUploadRepo, mockReplays, mockStorage := newTestUploadService()
+
+	uploadID, gameID, userID := uuid.New(), uuid.New(), uuid.New()
+	replay := &models.Replay{ID: uuid.New()}
+	// Куски остались: прошлое завершение создало реплей, но не успело их удалить
+	session := &models.UploadSession{ID: uploadID, SizeBytes: 3, Offset: 3, ChunkKeys: []string{"uploads/a/0"}, ReplayID: &replay.ID}
+
+	mockUploadRepo.On("Claim", mock.Anything, leaseOf(uploadID), gameID, userID, uploadFinalizeLease, mock.Anything).
+		Return(nil, fmt.Errorf("claim upload session: %w", repository.ErrNotFound))
+	mockUploadRepo.On("GetByID", mock.Anything, uploadID, gameID, userID).Return(session, nil)
+	mockReplays.On("GetReplay", mock.Anything, replay.ID, userID).Return(replay, nil)
+	mockUploadRepo.On("Finish", mock.Anything, uploadID, mock.Anything).Return([]string{"uploads/a/0"}, nil)
+	mockStorage.On("Delete", mock.Anything, "uploads/a/0").Return(nil)
+
+	result, err := service.FinalizeUpload(context.Background(), uploadID, gameID, userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, replay.ID, result.ID)
+	mockReplays.AssertNotCalled(t, "CreateReplayFromStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockStorage.AssertExpectations(t)
+}
+
+// TestCleanupExpired проверяет удаление кусков истекших сессий
+func TestCleanupExpired(t *testing.T) {
+	service, mockUploadRepo, _, mockStorage := newTestUploadService()
+
+	mockUploadRepo.On("DeleteExpired", mock.Anything, uploadFinalizeLease).Return([]string{"uploads/a/0", "uploads/b/0"}, nil)
+	mockStorage.On("Delete", mock.Anything, "uploads/a/0").Return(nil)
+	mockStorage.On("Delete", mock.Anything, "uploads/b/0").Return(nil)
+
+	err := service.CleanupExpired(context.Background())
+
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+}
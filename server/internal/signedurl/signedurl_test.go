@@ -0,0 +1,91 @@
+package signedurl
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSignVerify проверяет, что ссылка действует для своего реплея до истечения срока
+func TestSignVerify(t *testing.T) {
+	signer := NewSigner("secret", 5*time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+	signer.now = func() time.Time { return now }
+	replayID, userID := uuid.New(), uuid.New()
+
+	query, expires := signer.Sign(replayID, userID)
+	assert.Equal(t, now.Add(5*time.Minute), expires)
+	assert.True(t, Signed(query))
+
+	got, err := signer.Verify(replayID, query)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+
+	signer.now = func() time.Time { return expires }
+	_, err = signer.Verify(replayID, query)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+// TestVerify_Tampered проверяет отказ для чужого реплея, подмененных параметров и другого секрета
+func TestVerify_Tampered(t *testing.T) {
+	signer := NewSigner("secret", 5*time.Minute)
+	replayID, userID := uuid.New(), uuid.New()
+	query, _ := signer.Sign(replayID, userID)
+
+	_, err := signer.Verify(uuid.New(), query)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	extended := cloneQuery(query)
+	extended.Set(QueryExpires, "99999999999")
+	_, err = signer.Verify(replayID, extended)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	otherUser := cloneQuery(query)
+	otherUser.Set(QueryUser, uuid.New().String())
+	_, err = signer.Verify(replayID, otherUser)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	unsigned := cloneQuery(query)
+	unsigned.Del(QuerySignature)
+	assert.False(t, Signed(unsigned))
+	_, err = signer.Verify(replayID, unsigned)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = NewSigner("other", 5*time.Minute).Verify(replayID, query)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func cloneQuery(query url.Values) url.Values {
+	clone := make(url.Values, len(query))
+	for k, v := range query {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// TestShareView проверяет, что подпись просмотра привязана к ссылке и реплею и не подходит к скачиванию
+func TestShareView(t *testing.T) {
+	signer := NewSigner("secret", 5*time.Minute)
+	now := time.Unix(1_700_000_000, 0)
+	signer.now = func() time.Time { return now }
+	shareID, replayID := uuid.New(), uuid.New()
+
+	query, expires := signer.SignShareView(shareID, replayID)
+	assert.True(t, Signed(query))
+	require.NoError(t, signer.VerifyShareView(shareID, replayID, query))
+
+	assert.ErrorIs(t, signer.VerifyShareView(uuid.New(), replayID, query), ErrInvalid)
+	assert.ErrorIs(t, signer.VerifyShareView(shareID, uuid.New(), query), ErrInvalid)
+
+	asDownload := cloneQuery(query)
+	asDownload.Set(QueryUser, shareID.String())
+	_, err := signer.Verify(replayID, asDownload)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	signer.now = func() time.Time { return expires }
+	assert.ErrorIs(t, signer.VerifyShareView(shareID, replayID, query), ErrExpired)
+}
@@ -1,13 +1,14 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"os"
 	"path/filepath"
-
-	"github.com/google/uuid"
+	"strings"
+	"time"
 )
 
 type FileStorage struct {
@@ -20,51 +21,237 @@ func NewFileStorage(baseDir string) *FileStorage {
 	}
 }
 
-func (fs *FileStorage) SaveReplayFile(file *multipart.FileHeader, userID, gameID, replayID uuid.UUID) (string, error) {
-	src, err := file.Open()
+// tempPrefix начинает имена временных файлов, которые Put переименовывает в итоговые
+// Такие файлы - не объекты хранилища: Walk их пропускает, а после падения их удаляет RemoveTempFiles
+const tempPrefix = ".tmp-"
+
+// Put записывает файл атомарно: во временный файл в той же директории, fsync, затем rename
+// Читатели и сверка никогда не видят недописанный файл под ключом key, даже если процесс упал
+func (fs *FileStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	fullPath, err := fs.fullPath(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix+filepath.Base(fullPath)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if err := writeFile(tmp, r, size); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	return syncDir(dir)
+}
+
+// writeFile копирует r в dst, сбрасывает данные на диск и закрывает dst
+// Ошибка Close не теряется: на некоторых файловых системах о нехватке места сообщает именно она
+func writeFile(dst *os.File, r io.Reader, size int64) error {
+	written, err := io.Copy(dst, r)
+	if err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	if size >= 0 && written != size {
+		dst.Close()
+		return fmt.Errorf("failed to save file: wrote %d of %d bytes", written, size)
+	}
+
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	return nil
+}
+
+// syncDir сбрасывает на диск запись директории, чтобы rename пережил падение питания
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// RemoveTempFiles удаляет временные файлы, оставшиеся от записей, прерванных падением процесса
+// Удаляются только файлы, измененные раньше before (времени старта процесса): обход большого хранилища
+// идет в фоне, и файлы, которые этот процесс пишет сейчас, не трогаются. Возвращает число удаленных файлов
+func (fs *FileStorage) RemoveTempFiles(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(fs.baseDir, func(fullPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to remove temp files: %w", err)
+	}
+	return removed, nil
+}
+
+func (fs *FileStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error) {
+	fullPath, err := fs.fullPath(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+		return nil, ObjectInfo{}, err
 	}
-	defer src.Close()
 
-	relativePath := filepath.Join(userID.String(), gameID.String(), replayID.String()+filepath.Ext(file.Filename))
-	fullPath := filepath.Join(fs.baseDir, relativePath)
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, ObjectInfo{}, wrapOSError("open file", err)
+	}
 
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	dst, err := os.Create(fullPath)
+	return file, ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (fs *FileStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	fullPath, err := fs.fullPath(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return ObjectInfo{}, err
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return "", fmt.Errorf("failed to save file: %w", err)
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return ObjectInfo{}, wrapOSError("stat file", err)
 	}
 
-	return relativePath, nil
+	return ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
 }
 
-func (fs *FileStorage) DeleteFile(relativePath string) error {
-	fullPath := filepath.Join(fs.baseDir, relativePath)
+func (fs *FileStorage) Delete(ctx context.Context, key string) error {
+	fullPath, err := fs.fullPath(key)
+	if err != nil {
+		return err
+	}
+
 	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 	return nil
 }
 
-func (fs *FileStorage) DeleteFiles(relativePaths []string) []error {
-	var errors []error
-	for _, path := range relativePaths {
-		if err := fs.DeleteFile(path); err != nil {
-			errors = append(errors, err)
+func (fs *FileStorage) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := fs.fullPath(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := fs.fullPath(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return wrapOSError("move file", err)
+	}
+	return syncDir(filepath.Dir(dstPath))
+}
+
+func (fs *FileStorage) Walk(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	root, err := fs.fullPath(prefix)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(root, func(fullPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(fs.baseDir, fullPath)
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: filepath.ToSlash(rel), Size: stat.Size(), ModTime: stat.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk storage: %w", err)
 	}
-	return errors
+	return nil
 }
 
-func (fs *FileStorage) GetFilePath(relativePath string) string {
-	return filepath.Join(fs.baseDir, relativePath)
+func (fs *FileStorage) fullPath(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(fs.baseDir, clean), nil
+}
+
+func wrapOSError(operation string, err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to %s: %w", operation, ErrNotFound)
+	}
+	return fmt.Errorf("failed to %s: %w", operation, err)
 }
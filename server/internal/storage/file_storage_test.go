@@ -1,10 +1,14 @@
 package storage
 
 import (
-	"mime/multipart"
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -27,25 +31,6 @@ func cleanupTestStorage(t *testing.T, tmpDir string) {
 	require.NoError(t, err, "не удалось удалить временную директорию")
 }
 
-// createTestFile создает тестовый multipart.FileHeader
-func createTestFile(t *testing.T, filename string, content []byte) *multipart.FileHeader {
-	tmpFile := filepath.Join(os.TempDir(), filename)
-	err := os.WriteFile(tmpFile, content, 0644)
-	require.NoError(t, err)
-
-	file, err := os.Open(tmpFile)
-	require.NoError(t, err)
-	defer file.Close()
-
-	stat, err := file.Stat()
-	require.NoError(t, err)
-
-	return &multipart.FileHeader{
-		Filename: filename,
-		Size:     stat.Size(),
-	}
-}
-
 // TestNewFileStorage проверяет создание FileStorage
 func TestNewFileStorage(t *testing.T) {
 	baseDir := "/test/storage"
@@ -55,34 +40,89 @@ func TestNewFileStorage(t *testing.T) {
 	assert.Equal(t, baseDir, storage.baseDir)
 }
 
-// TestGetFilePath проверяет получение полного пути к файлу
-func TestGetFilePath(t *testing.T) {
+// TestFullPath проверяет получение полного пути к файлу
+func TestFullPath(t *testing.T) {
 	storage, tmpDir := setupTestStorage(t)
 	defer cleanupTestStorage(t, tmpDir)
 
 	relativePath := "user/game/replay.rep"
 	expectedPath := filepath.Join(tmpDir, relativePath)
 
-	fullPath := storage.GetFilePath(relativePath)
+	fullPath, err := storage.fullPath(relativePath)
 
+	assert.NoError(t, err)
 	assert.Equal(t, expectedPath, fullPath)
 }
 
-// TestSaveReplayFile_Success проверяет успешное сохранение файла
-// Примечание: полноценное тестирование SaveReplayFile требует реального multipart файла
-// Этот тест пропускается, так как создание настоящего multipart.FileHeader сложно в unit-тестах
-func TestSaveReplayFile_Success(t *testing.T) {
-	t.Skip("Тест требует реального HTTP multipart файла, тестируется через integration тесты")
+// TestFullPath_Traversal проверяет, что ключ не может выйти за пределы baseDir
+func TestFullPath_Traversal(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+
+	_, err := storage.fullPath("../outside.rep")
+
+	assert.Error(t, err)
 }
 
-// TestSaveReplayFile_CreatesDirectories проверяет создание директорий
-func TestSaveReplayFile_CreatesDirectories(t *testing.T) {
-	t.Skip("Тест требует реального HTTP multipart файла, тестируется через integration тесты")
+// TestPut_Success проверяет сохранение потока в файл
+func TestPut_Success(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+
+	key := "user/game/replay.rep"
+	content := []byte("replay content")
+
+	err := storage.Put(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+
+	saved, err := os.ReadFile(filepath.Join(tmpDir, key))
+	require.NoError(t, err)
+	assert.Equal(t, content, saved)
 }
 
-// TestSaveReplayFile_PreservesExtension проверяет сохранение расширения файла
-func TestSaveReplayFile_PreservesExtension(t *testing.T) {
-	t.Skip("Тест требует реального HTTP multipart файла, тестируется через integration тесты")
+// TestPut_CreatesDirectories проверяет создание директорий {user_id}/{game_id}
+func TestPut_CreatesDirectories(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+
+	key := ReplayKey(uuid.New(), uuid.New(), uuid.New(), "match.dem")
+
+	err := storage.Put(context.Background(), key, bytes.NewReader([]byte("x")), 1)
+	require.NoError(t, err)
+
+	stat, err := os.Stat(filepath.Dir(filepath.Join(tmpDir, key)))
+	require.NoError(t, err)
+	assert.True(t, stat.IsDir())
+}
+
+// TestOpen_Success проверяет чтение сохраненного файла и его метаданных
+func TestOpen_Success(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+
+	key := "user/game/replay.rep"
+	content := []byte("replay content")
+	require.NoError(t, storage.Put(context.Background(), key, bytes.NewReader(content), int64(len(content))))
+
+	reader, info, err := storage.Open(context.Background(), key)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, key, info.Key)
+}
+
+// TestOpen_NotFound проверяет, что отсутствующий файл дает ErrNotFound
+func TestOpen_NotFound(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+
+	_, _, err := storage.Open(context.Background(), "user/game/missing.rep")
+
+	assert.ErrorIs(t, err, ErrNotFound)
 }
 
 // TestDeleteFile_Success проверяет успешное удаление файла
@@ -92,7 +132,7 @@ func TestDeleteFile_Success(t *testing.T) {
 
 	// Создаем файл
 	relPath := "user/game/replay.rep"
-	fullPath := storage.GetFilePath(relPath)
+	fullPath := filepath.Join(tmpDir, relPath)
 	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
 	require.NoError(t, err)
 	err = os.WriteFile(fullPath, []byte("test"), 0644)
@@ -103,7 +143,7 @@ func TestDeleteFile_Success(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Удаляем файл
-	err = storage.DeleteFile(relPath)
+	err = storage.Delete(context.Background(), relPath)
 
 	assert.NoError(t, err)
 
@@ -120,87 +160,160 @@ func TestDeleteFile_NonExistent(t *testing.T) {
 	relPath := "user/game/nonexistent.rep"
 
 	// Удаление несуществующего файла не должно возвращать ошибку
-	err := storage.DeleteFile(relPath)
+	err := storage.Delete(context.Background(), relPath)
 
-	// В зависимости от реализации, может быть nil или ошибка
-	// Проверяем, что не паникует
-	_ = err
+	assert.NoError(t, err)
+}
+
+// TestReplayKey проверяет схему ключей {user_id}/{game_id}/{replay_id}.ext
+func TestReplayKey(t *testing.T) {
+	userID := uuid.New()
+	gameID := uuid.New()
+	replayID := uuid.New()
+
+	key := ReplayKey(userID, gameID, replayID, "Final Match.rep")
+
+	assert.Equal(t, userID.String()+"/"+gameID.String()+"/"+replayID.String()+".rep", key)
 }
 
-// TestDeleteFiles_Success проверяет удаление нескольких файлов
-func TestDeleteFiles_Success(t *testing.T) {
+// TestBlobKey проверяет схему ключей blobs/{sha256[:2]}/{sha256}[.compression]
+func TestBlobKey(t *testing.T) {
+	sum := "ab0123456789"
+
+	assert.Equal(t, "blobs/ab/ab0123456789", BlobKey(sum, "none"))
+	assert.Equal(t, "blobs/ab/ab0123456789.zstd", BlobKey(sum, "zstd"))
+}
+
+// TestMove проверяет перенос файла с созданием каталогов назначения
+func TestMove(t *testing.T) {
 	storage, tmpDir := setupTestStorage(t)
 	defer cleanupTestStorage(t, tmpDir)
+	ctx := context.Background()
 
-	// Создаем несколько файлов
-	filePaths := []string{
-		"user/game/replay1.rep",
-		"user/game/replay2.rep",
-		"user/game/replay3.rep",
-	}
+	require.NoError(t, storage.Put(ctx, "staging/id", bytes.NewReader([]byte("data")), 4))
 
-	for _, relPath := range filePaths {
-		fullPath := storage.GetFilePath(relPath)
-		err := os.MkdirAll(filepath.Dir(fullPath), 0755)
-		require.NoError(t, err)
-		err = os.WriteFile(fullPath, []byte("test"), 0644)
-		require.NoError(t, err)
-	}
+	require.NoError(t, storage.Move(ctx, "staging/id", "blobs/ab/abcd"))
+	data, err := os.ReadFile(filepath.Join(tmpDir, "blobs", "ab", "abcd"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), data)
+	assert.NoFileExists(t, filepath.Join(tmpDir, "staging", "id"))
 
-	// Удаляем все файлы
-	errs := storage.DeleteFiles(filePaths)
+	assert.ErrorIs(t, storage.Move(ctx, "staging/missing", "blobs/ab/abce"), ErrNotFound)
+}
+
+// TestWalk проверяет обход файлов с ключами через "/"
+func TestWalk(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+	ctx := context.Background()
+
+	require.NoError(t, storage.Put(ctx, "user/game/a.rep", bytes.NewReader([]byte("aa")), 2))
+	require.NoError(t, storage.Put(ctx, "uploads/id/0", bytes.NewReader([]byte("b")), 1))
+
+	sizes := make(map[string]int64)
+	err := storage.Walk(ctx, "", func(info ObjectInfo) error {
+		sizes[info.Key] = info.Size
+		return nil
+	})
 
-	assert.Empty(t, errs, "не должно быть ошибок при удалении")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"user/game/a.rep": 2, "uploads/id/0": 1}, sizes)
+}
+
+// TestWalk_MissingPrefix проверяет, что обход несуществующего префикса не ошибка
+func TestWalk_MissingPrefix(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
 
-	// Проверяем, что все файлы удалены
-	for _, relPath := range filePaths {
-		fullPath := storage.GetFilePath(relPath)
-		_, err := os.Stat(fullPath)
-		assert.True(t, os.IsNotExist(err), "файл %s должен быть удален", relPath)
+	err := storage.Walk(context.Background(), "missing", func(info ObjectInfo) error {
+		t.Fatalf("unexpected object %s", info.Key)
+		return nil
+	})
+
+	assert.NoError(t, err)
+}
+
+// failingReader отдает часть данных и обрывается ошибкой, как оборванная загрузка
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
 	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
 }
 
-// TestDeleteFiles_PartialFailure проверяет частичный сбой при удалении
-func TestDeleteFiles_PartialFailure(t *testing.T) {
+// TestPut_FailedWriteKeepsTarget проверяет, что оборванная запись не оставляет недописанный файл под ключом
+func TestPut_FailedWriteKeepsTarget(t *testing.T) {
 	storage, tmpDir := setupTestStorage(t)
 	defer cleanupTestStorage(t, tmpDir)
+	ctx := context.Background()
 
-	// Создаем один существующий файл
-	existingPath := "user/game/existing.rep"
-	fullPath := storage.GetFilePath(existingPath)
-	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+	require.NoError(t, storage.Put(ctx, "blobs/ab/abcd", bytes.NewReader([]byte("original")), 8))
+
+	err := storage.Put(ctx, "blobs/ab/abcd", &failingReader{data: []byte("trunc")}, 100)
+	assert.Error(t, err)
+
+	err = storage.Put(ctx, "blobs/ab/new", bytes.NewReader([]byte("short")), 100)
+	assert.Error(t, err, "файл короче заявленного размера не сохраняется")
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "blobs", "ab", "abcd"))
 	require.NoError(t, err)
-	err = os.WriteFile(fullPath, []byte("test"), 0644)
+	assert.Equal(t, []byte("original"), data)
+	assert.NoFileExists(t, filepath.Join(tmpDir, "blobs", "ab", "new"))
+
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "blobs", "ab"))
 	require.NoError(t, err)
+	assert.Len(t, entries, 1, "временные файлы удаляются")
+}
 
-	filePaths := []string{
-		existingPath,
-		"user/game/nonexistent1.rep",
-		"user/game/nonexistent2.rep",
-	}
+// TestRemoveTempFiles проверяет уборку временных файлов, оставшихся после падения
+func TestRemoveTempFiles(t *testing.T) {
+	storage, tmpDir := setupTestStorage(t)
+	defer cleanupTestStorage(t, tmpDir)
+	ctx := context.Background()
 
-	errs := storage.DeleteFiles(filePaths)
+	require.NoError(t, storage.Put(ctx, "blobs/ab/abcd", bytes.NewReader([]byte("data")), 4))
+	leftover := filepath.Join(tmpDir, "blobs", "ab", tempPrefix+"abce-123")
+	require.NoError(t, os.WriteFile(leftover, []byte("partial"), 0644))
 
-	// Могут быть ошибки для несуществующих файлов
-	// Проверяем, что существующий файл удален
-	_, err = os.Stat(fullPath)
-	assert.True(t, os.IsNotExist(err), "существующий файл должен быть удален")
+	var keys []string
+	require.NoError(t, storage.Walk(ctx, "", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	}))
+	assert.Equal(t, []string{"blobs/ab/abcd"}, keys, "Walk не показывает временные файлы")
+
+	removed, err := storage.RemoveTempFiles(ctx, time.Now().Add(time.Second))
 
-	// Количество ошибок зависит от реализации
-	_ = errs
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, leftover)
+	assert.FileExists(t, filepath.Join(tmpDir, "blobs", "ab", "abcd"))
 }
 
-// TestDeleteFiles_EmptyList проверяет удаление пустого списка
-func TestDeleteFiles_EmptyList(t *testing.T) {
+// TestRemoveTempFiles_InProgress проверяет, что файлы записей, начатых после старта, не удаляются
+func TestRemoveTempFiles_InProgress(t *testing.T) {
 	storage, tmpDir := setupTestStorage(t)
 	defer cleanupTestStorage(t, tmpDir)
 
-	errs := storage.DeleteFiles([]string{})
+	started := time.Now()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "staging"), 0755))
+	stale := filepath.Join(tmpDir, "staging", tempPrefix+"old")
+	writing := filepath.Join(tmpDir, "staging", tempPrefix+"new")
+	require.NoError(t, os.WriteFile(stale, []byte("partial"), 0644))
+	require.NoError(t, os.Chtimes(stale, started.Add(-time.Hour), started.Add(-time.Hour)))
+	require.NoError(t, os.WriteFile(writing, []byte("partial"), 0644))
+	require.NoError(t, os.Chtimes(writing, started.Add(time.Second), started.Add(time.Second)))
 
-	assert.Empty(t, errs, "не должно быть ошибок для пустого списка")
-}
+	removed, err := storage.RemoveTempFiles(context.Background(), started)
 
-// TestFileStorage_Integration проверяет полный цикл работы с файлами
-func TestFileStorage_Integration(t *testing.T) {
-	t.Skip("Тест требует реального HTTP multipart файла, тестируется через integration тесты")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, writing, "файл текущей записи остается")
 }
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBucket = "replays"
+
+// fakeS3 - минимальный in-process S3 сервер
+// Зачем: проверяем S3-драйвер без MinIO; поддерживает ровно те запросы, которые делает minio-go
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+	// sizes - размер, который HEAD сообщает вместо настоящего: так объект в несколько ГиБ
+	// изображается без выделения памяти
+	sizes map[string]int64
+	// copies, partCopies - число запросов CopyObject и UploadPartCopy
+	copies, partCopies int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+		sizes:   make(map[string]int64),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if key == "" {
+		if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+			f.listObjects(w, r.URL.Query().Get("prefix"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		uploadID := strconv.Itoa(f.nextID)
+		f.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>",
+			bucket, key, uploadID)
+
+	case r.Method == http.MethodPut && query.Has("uploadId") && r.Header.Get("X-Amz-Copy-Source") != "":
+		_, data, ok := f.copySource(w, r)
+		if !ok {
+			return
+		}
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &start, &end)
+		start, end = min(start, int64(len(data))), min(end+1, int64(len(data)))
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][partNumber] = data[start:end]
+		f.partCopies++
+		fmt.Fprintf(w, `<CopyPartResult><LastModified>2023-11-14T22:13:20.000Z</LastModified><ETag>"part-%d"</ETag></CopyPartResult>`, partNumber)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][partNumber] = readS3Body(r)
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, partNumber))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"complete"</ETag></CompleteMultipartUploadResult>`,
+			bucket, key)
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		sourceKey, data, ok := f.copySource(w, r)
+		if !ok {
+			return
+		}
+		if f.sizes[sourceKey] > 5<<30 {
+			// Как настоящий S3: одиночное копирование больше 5 ГиБ запрещено
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<Error><Code>InvalidRequest</Code></Error>")
+			return
+		}
+		f.objects[key] = data
+		f.copies++
+		fmt.Fprint(w, `<CopyObjectResult><LastModified>2023-11-14T22:13:20.000Z</LastModified><ETag>"object"</ETag></CopyObjectResult>`)
+
+	case r.Method == http.MethodPut:
+		f.objects[key] = readS3Body(r)
+		w.Header().Set("ETag", `"object"`)
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if size, ok := f.sizes[key]; ok && r.Method == http.MethodHead {
+			w.Header().Set("ETag", `"object"`)
+			w.Header().Set("Last-Modified", time.Unix(1700000000, 0).UTC().Format(http.TimeFormat))
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("ETag", `"object"`)
+		http.ServeContent(w, r, key, time.Unix(1700000000, 0), bytes.NewReader(data))
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// copySource возвращает ключ и объект из X-Amz-Copy-Source или отвечает NoSuchKey
+func (f *fakeS3) copySource(w http.ResponseWriter, r *http.Request) (string, []byte, bool) {
+	source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	data, ok := f.objects[sourceKey]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+	}
+	return sourceKey, data, ok
+}
+
+// listObjects отвечает на ListObjectsV2 одной страницей
+func (f *fakeS3) listObjects(w http.ResponseWriter, prefix string) {
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>",
+		testBucket, prefix, len(keys))
+	for _, key := range keys {
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>2023-11-14T22:13:20.000Z</LastModified><ETag>"object"</ETag><Size>%d</Size><StorageClass>STANDARD</StorageClass></Contents>`,
+			key, len(f.objects[key]))
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+// readS3Body читает тело запроса, снимая aws-chunked кодирование потоковой подписи
+func readS3Body(r *http.Request) []byte {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		data, _ := io.ReadAll(r.Body)
+		return data
+	}
+
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return data
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 {
+			return data
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return data
+		}
+		data = append(data, chunk...)
+		reader.ReadString('\n')
+	}
+}
+
+// setupTestS3Storage запускает fake S3 и подключает к нему драйвер
+func setupTestS3Storage(t *testing.T) (*S3Storage, *fakeS3) {
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s3, err := NewS3Storage(context.Background(), S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: "test",
+		SecretKey: "testtesttest",
+		Prefix:    "prod",
+	})
+	require.NoError(t, err)
+
+	return s3, fake
+}
+
+// TestNewS3Storage_MissingBucket проверяет, что драйвер не стартует без бакета
+func TestNewS3Storage_MissingBucket(t *testing.T) {
+	server := httptest.NewServer(newFakeS3())
+	defer server.Close()
+
+	_, err := NewS3Storage(context.Background(), S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "missing",
+		AccessKey: "test",
+		SecretKey: "testtesttest",
+	})
+
+	assert.Error(t, err)
+}
+
+// TestS3Storage_PutOpen проверяет запись объекта известного размера и чтение его обратно
+func TestS3Storage_PutOpen(t *testing.T) {
+	s3, fake := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	content := []byte("starcraft replay bytes")
+	require.NoError(t, s3.Put(ctx, "user/game/replay.rep", bytes.NewReader(content), int64(len(content))))
+
+	assert.Equal(t, content, fake.objects["prod/user/game/replay.rep"], "ключ должен учитывать префикс")
+
+	reader, info, err := s3.Open(ctx, "user/game/replay.rep")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "user/game/replay.rep", info.Key)
+}
+
+// TestS3Storage_PutUnknownSize проверяет multipart-загрузку потока неизвестной длины
+func TestS3Storage_PutUnknownSize(t *testing.T) {
+	s3, fake := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("demo"), 1024)
+	require.NoError(t, s3.Put(ctx, "user/game/replay.dem", bytes.NewReader(content), -1))
+
+	assert.Equal(t, content, fake.objects["prod/user/game/replay.dem"])
+	assert.Empty(t, fake.uploads, "multipart-загрузка должна быть завершена")
+}
+
+// TestS3Storage_Seek проверяет, что объект можно читать с произвольного смещения
+func TestS3Storage_Seek(t *testing.T) {
+	s3, _ := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	require.NoError(t, s3.Put(ctx, "key", strings.NewReader("0123456789"), 10))
+
+	reader, _, err := s3.Open(ctx, "key")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	_, err = reader.Seek(6, io.SeekStart)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "6789", string(data))
+}
+
+// TestS3Storage_NotFound проверяет, что отсутствующий объект дает ErrNotFound
+func TestS3Storage_NotFound(t *testing.T) {
+	s3, _ := setupTestS3Storage(t)
+
+	_, _, err := s3.Open(context.Background(), "user/game/missing.rep")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestS3Storage_Delete проверяет удаление, в том числе повторное
+func TestS3Storage_Delete(t *testing.T) {
+	s3, fake := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	require.NoError(t, s3.Put(ctx, "key", strings.NewReader("data"), 4))
+
+	assert.NoError(t, s3.Delete(ctx, "key"))
+	assert.NotContains(t, fake.objects, "prod/key")
+	assert.NoError(t, s3.Delete(ctx, "key"), "удаление отсутствующего объекта не ошибка")
+}
+
+// TestS3Storage_Walk проверяет обход объектов с учетом префикса драйвера
+func TestS3Storage_Walk(t *testing.T) {
+	s3, fake := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	require.NoError(t, s3.Put(ctx, "user/game/a.rep", strings.NewReader("aa"), 2))
+	require.NoError(t, s3.Put(ctx, "user/game/b.rep", strings.NewReader("bbb"), 3))
+	fake.objects["other/c.rep"] = []byte("c")
+
+	var keys []string
+	err := s3.Walk(ctx, "", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user/game/a.rep", "user/game/b.rep"}, keys, "объекты вне префикса драйвера не видны")
+}
+
+// TestS3Storage_Move проверяет копирование на стороне S3 с удалением исходного объекта
+func TestS3Storage_Move(t *testing.T) {
+	s3, fake := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	require.NoError(t, s3.Put(ctx, "staging/id", strings.NewReader("data"), 4))
+
+	require.NoError(t, s3.Move(ctx, "staging/id", "blobs/ab/abcd"))
+	assert.Equal(t, []byte("data"), fake.objects["prod/blobs/ab/abcd"])
+	assert.NotContains(t, fake.objects, "prod/staging/id")
+
+	assert.ErrorIs(t, s3.Move(ctx, "staging/missing", "blobs/ab/abce"), ErrNotFound)
+}
+
+// TestS3Storage_MoveLarge проверяет перенос объекта больше 5 ГиБ: одиночный CopyObject для него
+// запрещен, поэтому объект должен копироваться по частям
+func TestS3Storage_MoveLarge(t *testing.T) {
+	s3, fake := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("demo"), 1024)
+	require.NoError(t, s3.Put(ctx, "staging/id", bytes.NewReader(content), int64(len(content))))
+	fake.sizes["prod/staging/id"] = 6 << 30
+
+	require.NoError(t, s3.Move(ctx, "staging/id", "blobs/ab/abcd"))
+	assert.Equal(t, content, fake.objects["prod/blobs/ab/abcd"])
+	assert.NotContains(t, fake.objects, "prod/staging/id")
+	assert.Zero(t, fake.copies)
+	assert.Greater(t, fake.partCopies, 1)
+	assert.Empty(t, fake.uploads, "multipart-копирование должно быть завершено")
+}
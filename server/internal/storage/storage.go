@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DriverDisk = "disk"
+	DriverS3   = "s3"
+)
+
+const (
+	// UploadsPrefix - префикс ключей для кусков незавершенных resumable-загрузок
+	UploadsPrefix = "uploads"
+	// QuarantinePrefix - префикс, куда сверка с БД переносит файлы-сироты
+	QuarantinePrefix = "quarantine"
+	// BlobsPrefix - префикс content-addressed файлов, общих для реплеев с одинаковым содержимым
+	BlobsPrefix = "blobs"
+	// StagingPrefix - префикс, куда пишется новый файл, пока не известен его хеш
+	StagingPrefix = "staging"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Blob определяет хранилище файлов, не зависящее от бэкенда
+// Ключи - относительные пути через "/", например blobs/{sha256[:2]}/{sha256}
+type Blob interface {
+	// Put записывает содержимое r под ключом key; size = -1, если размер неизвестен
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete не возвращает ошибку, если ключа не существует
+	Delete(ctx context.Context, key string) error
+	// Move переносит объект src под ключ dst, перезаписывая dst
+	Move(ctx context.Context, src, dst string) error
+	// Walk вызывает fn для каждого объекта, ключ которого начинается с prefix ("" - все объекты)
+	// Ошибка fn прерывает обход и возвращается из Walk
+	Walk(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+func ReplayKey(userID, gameID, replayID uuid.UUID, originalName string) string {
+	return path.Join(userID.String(), gameID.String(), replayID.String()+filepath.Ext(originalName))
+}
+
+// UploadChunkKey возвращает уникальный ключ куска загрузки, начинающегося со смещения offset
+// Суффикс не дает двум параллельным запросам с одним смещением перезаписать друг друга
+func UploadChunkKey(uploadID uuid.UUID, offset int64) string {
+	return path.Join(UploadsPrefix, uploadID.String(), fmt.Sprintf("%020d-%s", offset, uuid.New().String()[:8]))
+}
+
+// QuarantineKey возвращает ключ, под которым хранится файл key после переноса в карантин
+func QuarantineKey(key string) string {
+	return path.Join(QuarantinePrefix, key)
+}
+
+// BlobKey возвращает ключ файла с содержимым sum (SHA-256 исходных байтов), сжатого алгоритмом compression
+// Первые два символа хеша - отдельный каталог, чтобы в одном каталоге не копились сотни тысяч файлов
+func BlobKey(sum, compression string) string {
+	name := sum
+	if compression != "" && compression != "none" {
+		name += "." + compression
+	}
+	return path.Join(BlobsPrefix, sum[:2], name)
+}
+
+// StagingKey возвращает временный ключ файла реплея replayID до переноса под BlobKey
+func StagingKey(replayID uuid.UUID) string {
+	return path.Join(StagingPrefix, replayID.String())
+}
@@ -0,0 +1,270 @@
+// Package tagexpr разбирает выражения над тегами для фильтрации списков: ranked AND (clutch OR ace) AND NOT smurf
+package tagexpr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxNameLength - предельная длина имени тега в символах
+	MaxNameLength = 50
+	// MaxTags - предел числа тегов в одном выражении, считая повторы: каждый тег - отдельный подзапрос
+	MaxTags = 20
+)
+
+var (
+	ErrSyntax      = errors.New("invalid tag expression")
+	ErrInvalidName = errors.New("invalid tag name")
+)
+
+type Op int
+
+const (
+	OpTag Op = iota
+	OpAnd
+	OpOr
+	OpNot
+)
+
+// Expr - узел выражения: OpTag - имя тега в Tag, OpNot - одно выражение в Args, OpAnd/OpOr - два и больше
+type Expr struct {
+	Op   Op
+	Tag  string
+	Args []*Expr
+}
+
+// Tag собирает лист выражения
+func Tag(name string) *Expr {
+	return &Expr{Op: OpTag, Tag: name}
+}
+
+// Tags возвращает имена тегов выражения без повторов
+func (e *Expr) Tags() []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(*Expr)
+	walk = func(e *Expr) {
+		if e.Op == OpTag {
+			if !seen[e.Tag] {
+				seen[e.Tag] = true
+				names = append(names, e.Tag)
+			}
+			return
+		}
+		for _, arg := range e.Args {
+			walk(arg)
+		}
+	}
+	walk(e)
+	return names
+}
+
+func (e *Expr) String() string {
+	switch e.Op {
+	case OpTag:
+		return e.Tag
+	case OpNot:
+		return "NOT " + e.Args[0].group()
+	}
+	sep := " AND "
+	if e.Op == OpOr {
+		sep = " OR "
+	}
+	parts := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		parts[i] = arg.group()
+	}
+	return strings.Join(parts, sep)
+}
+
+func (e *Expr) group() string {
+	if e.Op == OpAnd || e.Op == OpOr {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+// NormalizeName приводит имя тега к хранимому виду: нижний регистр, без пробелов по краям
+// Имя - буквы, цифры и _ . : -, начинается с буквы или цифры; and, or, not заняты операторами
+func NormalizeName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	for i, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			continue
+		}
+		if i > 0 && strings.ContainsRune("_.:-", r) {
+			continue
+		}
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	if keyword(name) != "" {
+		return "", fmt.Errorf("%w: %q is an operator", ErrInvalidName, name)
+	}
+	return name, nil
+}
+
+// Parse разбирает выражение; пустая строка - нет фильтра (nil)
+// Операторы AND, OR, NOT в любом регистре, скобки; теги через пробел без оператора - AND.
+// Приоритет: NOT, затем AND, затем OR
+func Parse(s string) (*Expr, error) {
+	tokens := tokenize(s)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(' || r == ')':
+			tokens = append(tokens, string(r))
+			i += size
+		default:
+			end := i
+			for end < len(s) {
+				r, size := utf8.DecodeRuneInString(s[end:])
+				if unicode.IsSpace(r) || r == '(' || r == ')' {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, s[i:end])
+			i = end
+		}
+	}
+	return tokens
+}
+
+func keyword(token string) string {
+	switch upper := strings.ToUpper(token); upper {
+	case "AND", "OR", "NOT":
+		return upper
+	}
+	return ""
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	depth  int
+	// leaves - сколько тегов уже разобрано; повторы одного имени считаются отдельно
+	leaves int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) or() (*Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	expr := &Expr{Op: OpOr, Args: []*Expr{left}}
+	for keyword(p.peek()) == "OR" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		expr.Args = append(expr.Args, right)
+	}
+	if len(expr.Args) == 1 {
+		return left, nil
+	}
+	return expr, nil
+}
+
+func (p *parser) and() (*Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	expr := &Expr{Op: OpAnd, Args: []*Expr{left}}
+	for {
+		next := p.peek()
+		if next == "" || next == ")" || keyword(next) == "OR" {
+			break
+		}
+		if keyword(next) == "AND" {
+			p.pos++
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		expr.Args = append(expr.Args, right)
+	}
+	if len(expr.Args) == 1 {
+		return left, nil
+	}
+	return expr, nil
+}
+
+func (p *parser) unary() (*Expr, error) {
+	// Глубина ограничена, чтобы цепочка скобок или NOT не раскрутила рекурсию и SQL
+	if p.depth++; p.depth > MaxTags {
+		return nil, fmt.Errorf("%w: too deeply nested", ErrSyntax)
+	}
+	defer func() { p.depth-- }()
+
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("%w: unexpected end", ErrSyntax)
+	case keyword(token) == "NOT":
+		p.pos++
+		arg, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Op: OpNot, Args: []*Expr{arg}}, nil
+	case token == "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing )", ErrSyntax)
+		}
+		p.pos++
+		return expr, nil
+	case token == ")" || keyword(token) != "":
+		return nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, token)
+	}
+
+	name, err := NormalizeName(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+	}
+	if p.leaves++; p.leaves > MaxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrSyntax, MaxTags)
+	}
+	p.pos++
+	return Tag(name), nil
+}
@@ -0,0 +1,85 @@
+package tagexpr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParse проверяет приоритет операторов, неявный AND и приведение имен к нижнему регистру
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "ranked", want: "ranked"},
+		{in: "Ranked clutch", want: "ranked AND clutch"},
+		{in: "ranked and clutch or ace", want: "(ranked AND clutch) OR ace"},
+		{in: "ranked AND (clutch OR ace) AND NOT smurf", want: "ranked AND (clutch OR ace) AND NOT smurf"},
+		{in: "not (a or b)", want: "NOT (a OR b)"},
+		{in: "NOT NOT de_dust2", want: "NOT NOT de_dust2"},
+		{in: "патч:1.2", want: "патч:1.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			expr, err := Parse(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.String())
+		})
+	}
+
+	expr, err := Parse("  ")
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+}
+
+// TestParse_Errors проверяет отказ на синтаксические ошибки и слишком сложные выражения
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{
+		"ranked AND",
+		"(ranked",
+		"ranked)",
+		"OR ace",
+		"NOT",
+		"-smurf",
+		"a,b",
+		strings.Repeat("(", 30) + "a" + strings.Repeat(")", 30),
+		strings.Repeat("NOT ", 30) + "a",
+		strings.TrimSpace(strings.Repeat("t OR ", 10)) + " " + strings.Repeat("x", MaxNameLength+1),
+	} {
+		_, err := Parse(in)
+		assert.ErrorIs(t, err, ErrSyntax, in)
+	}
+
+	var many []string
+	for i := 0; i <= MaxTags; i++ {
+		many = append(many, "t"+strings.Repeat("x", i))
+	}
+	_, err := Parse(strings.Join(many, " OR "))
+	assert.ErrorIs(t, err, ErrSyntax)
+
+	// Повторы одного тега тоже считаются: каждый лист - подзапрос
+	_, err = Parse(strings.Repeat("a OR ", MaxTags) + "a")
+	assert.ErrorIs(t, err, ErrSyntax)
+	_, err = Parse(strings.Repeat("a OR ", 40000) + "a")
+	assert.ErrorIs(t, err, ErrSyntax)
+
+	expr, err := Parse(strings.Repeat("a OR ", MaxTags-1) + "a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, expr.Tags())
+}
+
+// TestNormalizeName проверяет допустимые имена тегов
+func TestNormalizeName(t *testing.T) {
+	name, err := NormalizeName("  Ranked-S3 ")
+	require.NoError(t, err)
+	assert.Equal(t, "ranked-s3", name)
+
+	for _, bad := range []string{"", "two words", "_lead", "Or", "a(b)"} {
+		_, err := NormalizeName(bad)
+		assert.ErrorIs(t, err, ErrInvalidName, bad)
+	}
+}
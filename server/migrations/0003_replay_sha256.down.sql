@@ -0,0 +1 @@
+ALTER TABLE replays DROP COLUMN IF EXISTS sha256;
@@ -0,0 +1,2 @@
+-- SHA-256 исходного (несжатого) содержимого; пустая строка у реплеев, загруженных до появления колонки
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS sha256 TEXT NOT NULL DEFAULT '';
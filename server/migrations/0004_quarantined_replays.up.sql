@@ -0,0 +1,21 @@
+-- Записи реплеев, файл которых пропал из хранилища; переносятся сюда сверкой хранилища с БД
+-- Внешних ключей нет: игра или пользователь могут быть удалены раньше, чем запись разберут вручную
+CREATE TABLE IF NOT EXISTS quarantined_replays (
+    id UUID PRIMARY KEY,
+    title TEXT,
+    original_name TEXT NOT NULL,
+    file_path TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    uploaded_at TIMESTAMPTZ NOT NULL,
+    compression TEXT NOT NULL,
+    compressed BOOLEAN NOT NULL,
+    sha256 TEXT NOT NULL DEFAULT '',
+    comment TEXT,
+    game_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    reason TEXT NOT NULL,
+    quarantined_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_quarantined_replays_user_id ON quarantined_replays (user_id);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON quarantined_replays TO PUBLIC;
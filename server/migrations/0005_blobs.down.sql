@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS replays_blob_refcount ON replays;
+DROP FUNCTION IF EXISTS replays_blob_refcount();
+DROP TABLE IF EXISTS blobs;
@@ -0,0 +1,30 @@
+-- Content-addressed файлы: реплеи с одинаковым содержимым ссылаются на один файл blobs/{sha256[:2]}/{sha256}
+-- ref_count ведет триггер на replays, поэтому каскадные удаления игр и пользователей тоже отпускают ссылки
+CREATE TABLE IF NOT EXISTS blobs (
+    sha256 TEXT PRIMARY KEY,
+    file_path TEXT NOT NULL UNIQUE,
+    compression TEXT NOT NULL,
+    compressed BOOLEAN NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION replays_blob_refcount() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE blobs SET ref_count = ref_count - 1 WHERE file_path = OLD.file_path;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE blobs SET ref_count = ref_count + 1 WHERE file_path = NEW.file_path;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS replays_blob_refcount ON replays;
+CREATE TRIGGER replays_blob_refcount
+AFTER INSERT OR DELETE OR UPDATE OF file_path ON replays
+FOR EACH ROW EXECUTE FUNCTION replays_blob_refcount();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON blobs TO PUBLIC;
@@ -0,0 +1,9 @@
+-- Переопределения квот пользователей; NULL - значение по умолчанию из QUOTA_MAX_BYTES / QUOTA_MAX_REPLAYS, 0 - без ограничения
+CREATE TABLE IF NOT EXISTS user_quotas (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    max_bytes BIGINT CHECK (max_bytes >= 0),
+    max_replays BIGINT CHECK (max_replays >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_quotas TO PUBLIC;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_blobs_key_id;
+ALTER TABLE blobs DROP CONSTRAINT IF EXISTS blobs_key_check;
+ALTER TABLE blobs DROP COLUMN IF EXISTS wrapped_key;
+ALTER TABLE blobs DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,9 @@
+-- Ключ данных зашифрованного blob, обернутый мастер-ключом key_id; NULL - файл хранится открытым
+-- Ротация мастер-ключа переписывает только эти колонки, сами файлы не меняются
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS key_id TEXT;
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+
+ALTER TABLE blobs DROP CONSTRAINT IF EXISTS blobs_key_check;
+ALTER TABLE blobs ADD CONSTRAINT blobs_key_check CHECK ((key_id IS NULL) = (wrapped_key IS NULL));
+
+CREATE INDEX IF NOT EXISTS idx_blobs_key_id ON blobs(key_id) WHERE key_id IS NOT NULL;
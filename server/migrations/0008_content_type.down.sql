@@ -0,0 +1,3 @@
+ALTER TABLE games DROP COLUMN IF EXISTS allowed_types;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS content_type;
+ALTER TABLE replays DROP COLUMN IF EXISTS content_type;
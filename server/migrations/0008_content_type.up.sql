@@ -0,0 +1,19 @@
+-- MIME-тип, определенный по содержимому при загрузке; старым реплеям проставляется по расширению,
+-- как его раньше выбирал обработчик скачивания
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT 'application/octet-stream';
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT 'application/octet-stream';
+
+UPDATE replays SET content_type = CASE lower(substring(original_name FROM '\.[^.]*$'))
+    WHEN '.mp4' THEN 'video/mp4'
+    WHEN '.webm' THEN 'video/webm'
+    WHEN '.ogg' THEN 'video/ogg'
+    WHEN '.ogv' THEN 'video/ogg'
+    WHEN '.mov' THEN 'video/quicktime'
+    WHEN '.avi' THEN 'video/x-msvideo'
+    WHEN '.mkv' THEN 'video/x-matroska'
+    WHEN '.m4v' THEN 'video/x-m4v'
+    ELSE 'application/octet-stream'
+END;
+
+-- Разрешенные для загрузки форматы игры (виды video/replay/unknown или MIME-типы); NULL - UPLOAD_ALLOWED_TYPES
+ALTER TABLE games ADD COLUMN IF NOT EXISTS allowed_types TEXT[];
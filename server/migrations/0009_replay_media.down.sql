@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_replays_duration;
+
+ALTER TABLE replays DROP COLUMN IF EXISTS bitrate;
+ALTER TABLE replays DROP COLUMN IF EXISTS frame_rate;
+ALTER TABLE replays DROP COLUMN IF EXISTS video_codec;
+ALTER TABLE replays DROP COLUMN IF EXISTS height;
+ALTER TABLE replays DROP COLUMN IF EXISTS width;
+ALTER TABLE replays DROP COLUMN IF EXISTS duration_ms;
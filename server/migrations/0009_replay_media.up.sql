@@ -0,0 +1,10 @@
+-- Параметры видео из заголовков контейнера (MP4 moov, Matroska/WebM Info и Tracks)
+-- NULL - реплей не видео, параметр не удалось определить или файл еще не разобран (replay-service probe-media)
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS width INTEGER;
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS height INTEGER;
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS video_codec TEXT;
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS frame_rate DOUBLE PRECISION;
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS bitrate BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_replays_duration ON replays(game_id, duration_ms) WHERE duration_ms IS NOT NULL;
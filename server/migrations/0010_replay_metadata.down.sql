@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_replays_metadata;
+
+ALTER TABLE replays DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,6 @@
+-- Метаданные игрового реплея от парсера его формата (карта, игроки, длительность матча, версия, победитель)
+-- NULL - формат не поддерживается или файл не разобран
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- jsonb_path_ops: фильтры по метаданным - проверка вхождения (@>)
+CREATE INDEX IF NOT EXISTS idx_replays_metadata ON replays USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,2 @@
+-- TRUE - MP4 при загрузке переписан с moov в начале файла (faststart)
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS remuxed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_replays_source;
+
+ALTER TABLE replays DROP COLUMN IF EXISTS source_replay_id;
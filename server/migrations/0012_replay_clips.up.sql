@@ -0,0 +1,4 @@
+-- Фрагмент, вырезанный из другого реплея; при удалении исходного фрагмент остается самостоятельным реплеем
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS source_replay_id UUID REFERENCES replays(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_replays_source ON replays(source_replay_id) WHERE source_replay_id IS NOT NULL;
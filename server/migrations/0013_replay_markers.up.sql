@@ -0,0 +1,16 @@
+-- Отметки на временной шкале реплея: момент задается временем от начала или номером кадра (тика) игры
+CREATE TABLE IF NOT EXISTS replay_markers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    replay_id UUID NOT NULL REFERENCES replays(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    time_ms BIGINT CHECK (time_ms >= 0),
+    frame BIGINT CHECK (frame >= 0),
+    label TEXT NOT NULL,
+    color TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (time_ms IS NOT NULL OR frame IS NOT NULL)
+);
+CREATE INDEX IF NOT EXISTS idx_replay_markers_replay_id ON replay_markers (replay_id, time_ms, frame);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON replay_markers TO PUBLIC;
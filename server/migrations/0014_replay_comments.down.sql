@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS replay_comment_revisions;
+DROP TABLE IF EXISTS replay_comments;
@@ -0,0 +1,28 @@
+-- Обсуждение реплея: комментарии с ответами; replays.comment остается описанием от загрузившего
+CREATE TABLE IF NOT EXISTS replay_comments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    replay_id UUID NOT NULL REFERENCES replays(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES replay_comments(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    time_ms BIGINT CHECK (time_ms >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    edited_at TIMESTAMPTZ,
+    -- Удаленный комментарий остается в ветке, чтобы не терять ответы на него
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_replay_comments_replay ON replay_comments (replay_id, created_at, id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_replay_comments_parent ON replay_comments (parent_id, created_at, id) WHERE parent_id IS NOT NULL;
+
+-- Прежние версии текста: строка добавляется при каждой правке; created_at - когда версия была написана
+CREATE TABLE IF NOT EXISTS replay_comment_revisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    comment_id UUID NOT NULL REFERENCES replay_comments(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    replaced_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_replay_comment_revisions_comment ON replay_comment_revisions (comment_id, replaced_at);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON replay_comments TO PUBLIC;
+GRANT SELECT, INSERT, UPDATE, DELETE ON replay_comment_revisions TO PUBLIC;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS game_tags;
+DROP TABLE IF EXISTS replay_tags;
+DROP TABLE IF EXISTS tags;
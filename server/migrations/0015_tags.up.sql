@@ -0,0 +1,27 @@
+-- Теги пользователя; имена хранятся в нижнем регистре и уникальны в пределах пользователя
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    color TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS replay_tags (
+    replay_id UUID NOT NULL REFERENCES replays(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (replay_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_replay_tags_tag_id ON replay_tags (tag_id);
+
+CREATE TABLE IF NOT EXISTS game_tags (
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (game_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_game_tags_tag_id ON game_tags (tag_id);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON tags TO PUBLIC;
+GRANT SELECT, INSERT, UPDATE, DELETE ON replay_tags TO PUBLIC;
+GRANT SELECT, INSERT, UPDATE, DELETE ON game_tags TO PUBLIC;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_replay_comments_search;
+DROP INDEX IF EXISTS idx_games_search;
+DROP INDEX IF EXISTS idx_replays_search;
+
+ALTER TABLE replay_comments DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE games DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE replays DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,20 @@
+-- Полнотекстовый поиск по реплеям: словарь simple, т.к. названия и описания бывают на разных языках,
+-- а имена игроков и карт нельзя приводить к основе
+-- Вес: A - название, B - имя файла (разделители заменены пробелами), C - описание, D - строки метаданных
+ALTER TABLE replays ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple'::regconfig, coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple'::regconfig, regexp_replace(original_name, '[._-]+', ' ', 'g')), 'B') ||
+    setweight(to_tsvector('simple'::regconfig, coalesce(comment, '')), 'C') ||
+    setweight(jsonb_to_tsvector('simple'::regconfig, coalesce(metadata, '{}'::jsonb), '["string"]'), 'D')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_replays_search ON replays USING GIN (search_vector);
+
+ALTER TABLE games ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple'::regconfig, name)
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_games_search ON games USING GIN (search_vector);
+
+ALTER TABLE replay_comments ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple'::regconfig, body)
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_replay_comments_search ON replay_comments USING GIN (search_vector);
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_replays_user_id ON replays (user_id);
+
+DROP INDEX IF EXISTS idx_replays_user_uploaded;
@@ -0,0 +1,5 @@
+-- Список реплеев пользователя по всем играм: новые первыми, курсор продолжает после (uploaded_at, id)
+CREATE INDEX IF NOT EXISTS idx_replays_user_uploaded ON replays (user_id, uploaded_at DESC, id DESC);
+
+-- Покрывается новым индексом по префиксу user_id
+DROP INDEX IF EXISTS idx_replays_user_id;
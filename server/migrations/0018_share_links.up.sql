@@ -0,0 +1,19 @@
+-- Публичные ссылки на реплей или игру; в БД хранится только SHA-256 токена ссылки
+CREATE TABLE IF NOT EXISTS share_links (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    token_hash TEXT NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    replay_id UUID REFERENCES replays(id) ON DELETE CASCADE,
+    game_id UUID REFERENCES games(id) ON DELETE CASCADE,
+    -- bcrypt-хеш пароля; NULL - ссылка без пароля
+    password_hash TEXT,
+    expires_at TIMESTAMPTZ,
+    max_views INTEGER CHECK (max_views > 0),
+    view_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    CHECK ((replay_id IS NULL) <> (game_id IS NULL))
+);
+CREATE INDEX IF NOT EXISTS idx_share_links_user ON share_links (user_id, created_at DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON share_links TO PUBLIC;
@@ -0,0 +1,22 @@
+-- Доступ зарегистрированных пользователей к чужой игре или реплею
+-- Выдача начинается приглашением (pending) и действует после принятия (accepted)
+CREATE TABLE IF NOT EXISTS access_grants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    grantee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    replay_id UUID REFERENCES replays(id) ON DELETE CASCADE,
+    game_id UUID REFERENCES games(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'commenter', 'editor')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMPTZ,
+    CHECK ((replay_id IS NULL) <> (game_id IS NULL)),
+    CHECK (owner_id <> grantee_id),
+    UNIQUE NULLS NOT DISTINCT (grantee_id, game_id, replay_id)
+);
+CREATE INDEX IF NOT EXISTS idx_access_grants_owner ON access_grants (owner_id, created_at DESC);
+-- Проверка доступа: выдачи пользователя на игру или реплей
+CREATE INDEX IF NOT EXISTS idx_access_grants_game ON access_grants (game_id, grantee_id) WHERE status = 'accepted';
+CREATE INDEX IF NOT EXISTS idx_access_grants_replay ON access_grants (replay_id, grantee_id) WHERE status = 'accepted';
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON access_grants TO PUBLIC;
@@ -0,0 +1,9 @@
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS source_replay_id;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS remuxed;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS metadata;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS bitrate;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS frame_rate;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS video_codec;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS height;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS width;
+ALTER TABLE quarantined_replays DROP COLUMN IF EXISTS duration_ms;
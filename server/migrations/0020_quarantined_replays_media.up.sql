@@ -0,0 +1,11 @@
+-- Колонки, добавленные в replays после 0008: запись в карантине должна восстанавливаться без потерь
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS width INTEGER;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS height INTEGER;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS video_codec TEXT;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS frame_rate DOUBLE PRECISION;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS bitrate BIGINT;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS metadata JSONB;
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS remuxed BOOLEAN NOT NULL DEFAULT FALSE;
+-- Без внешнего ключа, как у game_id и user_id: исходный реплей может быть удален раньше
+ALTER TABLE quarantined_replays ADD COLUMN IF NOT EXISTS source_replay_id UUID;
@@ -0,0 +1,3 @@
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS finalizing BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE upload_sessions SET finalizing = TRUE WHERE finalizing_at IS NOT NULL;
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS finalizing_at;
@@ -0,0 +1,4 @@
+-- Финализация держит аренду: если процесс упал, по истечении аренды сессию можно финализировать заново
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS finalizing_at TIMESTAMPTZ;
+UPDATE upload_sessions SET finalizing_at = NOW() WHERE finalizing;
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS finalizing;
@@ -0,0 +1,2 @@
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS replay_id;
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS lease_token;
@@ -0,0 +1,4 @@
+-- Аренду финализации продлевает и снимает только взявший ее запрос;
+-- реплей, собранный из сессии, закрепляется за ней, чтобы повторное завершение вернуло его же
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS lease_token UUID;
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS replay_id UUID REFERENCES replays(id) ON DELETE SET NULL;